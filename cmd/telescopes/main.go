@@ -27,9 +27,18 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/banzaicloud/productinfo/pkg/productinfo-client/client"
 	"github.com/go-openapi/strfmt"
@@ -47,20 +56,36 @@ import (
 const (
 	// the list of flags supported by the application
 	// these constants can be used to retrieve the passed in values or defaults via viper
-	logLevelFlag         = "log-level"
-	listenAddressFlag    = "listen-address"
-	productInfoFlag      = "productinfo-address"
-	devModeFlag          = "dev-mode"
-	tokenSigningKeyFlag  = "token-signing-key"
-	tokenSigningKeyAlias = "tokensigningkey"
-	vaultAddrAlias       = "vault_addr"
-	vaultAddrFlag        = "vault-address"
-	helpFlag             = "help"
-	metricsEnabledFlag   = "metrics-enabled"
-	metricsAddressFlag   = "metrics-address"
+	logLevelFlag            = "log-level"
+	logFormatFlag           = "log-format"
+	listenAddressFlag       = "listen-address"
+	productInfoFlag         = "productinfo-address"
+	productInfoWorkersFlag  = "productinfo-workers"
+	productInfoAttemptsFlag = "productinfo-attempts"
+	devModeFlag             = "dev-mode"
+	tokenSigningKeyFlag     = "token-signing-key"
+	tokenSigningKeyAlias    = "tokensigningkey"
+	vaultAddrAlias          = "vault_addr"
+	vaultAddrFlag           = "vault-address"
+	helpFlag                = "help"
+	metricsEnabledFlag      = "metrics-enabled"
+	metricsAddressFlag      = "metrics-address"
+	shutdownTimeoutFlag     = "shutdown-timeout"
+	spotOnlyMinPoolsFlag    = "spot-only-min-pools"
+	spotMarginFlag          = "spot-margin"
+	newGenEpsilonFlag       = "new-gen-epsilon"
+	familyBonusFlag         = "family-bonus"
 
 	cfgAppRole     = "telescopes-app-role"
 	defaultAppRole = "telescopes"
+
+	// the percentage of regular nodes of the requests that don't set it, if it's not set in the environment
+	defaultOnDemandPct = 0
+
+	// the prefix of the product info snapshot file in the TELESCOPES_PRICE_SOURCE environment variable
+	filePriceSource = "file:"
+	// the prefix of the audit log file in the TELESCOPES_AUDIT_SINK environment variable
+	fileAuditSink = "file:"
 )
 
 var (
@@ -72,25 +97,47 @@ var (
 // defineFlags defines supported flags and makes them available for viper
 func defineFlags() {
 	flag.String(logLevelFlag, "info", "log level")
+	flag.String(logFormatFlag, "text", "log format, text or json")
 	flag.String(listenAddressFlag, ":9090", "the address where the server listens to HTTP requests.")
 	flag.String(productInfoFlag, "http://localhost:9090/api/v1", "the address of the Product Info service to retrieve attribute and pricing info [format=scheme://host:port/basepath]")
+	flag.Int(productInfoWorkersFlag, 10, "the maximum number of parallel calls to the Product Info service per recommendation")
+	flag.Int(productInfoAttemptsFlag, 3, "the maximum number of attempts of a Product Info call failing with a server error or timeout")
 	flag.Bool(devModeFlag, false, "development mode, if true token based authentication is disabled, false by default")
 	flag.String(tokenSigningKeyFlag, "", "The token signing key for the authentication process")
 	flag.String(vaultAddrFlag, "", "The vault address for authentication token management")
 	flag.Bool(helpFlag, false, "print usage")
 	flag.Bool(metricsEnabledFlag, false, "internal metrics are exposed if enabled")
 	flag.String(metricsAddressFlag, ":9900", "the address where internal metrics are exposed")
+	flag.Duration(shutdownTimeoutFlag, 30*time.Second, "the time the in-flight requests are given to finish on shutdown")
+	flag.Int(spotOnlyMinPoolsFlag, 0, "the minimum number of distinct instance types of spot-only recommendations, not enforced if 0")
+	flag.Float64(spotMarginFlag, 0, "the safety margin (percentage) added to the spot prices when they're compared to the on-demand prices")
+	flag.Float64(newGenEpsilonFlag, 1, "the price tolerance (percentage) of the layouts the newest instance generations are preferred among")
+	flag.Float64(familyBonusFlag, 10, "the price tolerance (percentage) of the layouts of the preferred instance families over the cheapest layout")
 }
 
 // bindFlags binds parsed flags into viper
 func bindFlags() {
 	flag.Parse()
 	viper.BindPFlags(flag.CommandLine)
+	bindLogEnv()
+}
+
+// bindLogEnv binds the logging environment variables into viper, the flags set on the command line take precedence
+func bindLogEnv() {
+	viper.BindEnv(logLevelFlag, "TELESCOPES_LOG_LEVEL")
+	viper.BindEnv(logFormatFlag, "TELESCOPES_LOG_FORMAT")
 }
 
-// setLogLevel sets the log level
-func setLogLevel() {
-	parsedLevel, err := log.ParseLevel(viper.GetString("log-level"))
+// configureLogger sets the log level and format of the logger shared by all the packages
+func configureLogger() {
+	formatter, err := logFormatter(viper.GetString(logFormatFlag))
+	if err != nil {
+		log.WithError(err).Warn("Couldn't parse log format, using text")
+	} else {
+		log.SetFormatter(formatter)
+	}
+
+	parsedLevel, err := log.ParseLevel(viper.GetString(logLevelFlag))
 	if err != nil {
 		log.WithError(err).Warnf("Couldn't parse log level, using default: %s", log.GetLevel())
 	} else {
@@ -98,6 +145,19 @@ func setLogLevel() {
 		log.Debugf("Set log level to %s", parsedLevel)
 	}
 }
+
+// logFormatter creates the formatter of the log format, the fields of the entries (eg: the request id) are
+// serialized as attributes of the json objects
+func logFormatter(format string) (log.Formatter, error) {
+	switch format {
+	case "json":
+		return &log.JSONFormatter{}, nil
+	case "text":
+		return &log.TextFormatter{}, nil
+	default:
+		return nil, fmt.Errorf("invalid log format: %s", format)
+	}
+}
 func init() {
 
 	// describe the flags for the application
@@ -107,8 +167,8 @@ func init() {
 	// flags are available through the entire application via viper
 	bindFlags()
 
-	// handle log level
-	setLogLevel()
+	// handle log level and format
+	configureLogger()
 
 	// set configuration defaults
 	viper.SetDefault(cfgAppRole, defaultAppRole)
@@ -159,29 +219,43 @@ func main() {
 
 	ensureCfg()
 
-	piUrl := parseProductInfoAddress()
-	transport := httptransport.New(piUrl.Host, piUrl.Path, []string{piUrl.Scheme})
-	pc := client.New(transport, strfmt.Default)
-
-	engine, err := recommender.NewEngine(recommender.NewProductInfoClient(pc))
+	pis := productInfoSource()
+
+	engine, err := recommender.NewEngine(pis,
+		recommender.WithWorkers(viper.GetInt(productInfoWorkersFlag)),
+		recommender.WithMaxAttempts(viper.GetInt(productInfoAttemptsFlag)),
+		recommender.WithRequestTimeout(parseRequestTimeout()),
+		recommender.WithMinSpotPools(viper.GetInt(spotOnlyMinPoolsFlag)),
+		recommender.WithSpotMargin(viper.GetFloat64(spotMarginFlag)),
+		recommender.WithDefaultOnDemandPct(parseDefaultOnDemandPct()),
+		recommender.WithNewGenEpsilon(viper.GetFloat64(newGenEpsilonFlag)),
+		recommender.WithFamilyBonus(viper.GetFloat64(familyBonusFlag)))
 	quitOnError("failed to start telescopes", err)
 
 	// configure the gin validator
-	err = api.ConfigureValidator(pc)
+	err = api.ConfigureValidator(pis)
 	quitOnError("failed to start telescopes", err)
 
 	routeHandler := api.NewRouteHandler(engine)
+	routeHandler.CheckDefaultRegions()
+	audit := auditSink()
+	routeHandler.SetAuditSink(audit)
 
 	// new default gin engine (recovery, logger middleware)
 	router := gin.Default()
 
-	// enable authentication if not dev-mode
+	// enable authentication if not dev-mode, api keys are used instead of tokens if they are configured
 	if !viper.GetBool(devModeFlag) {
-		log.Debug("enable authentication")
-		signingKey := viper.GetString(tokenSigningKeyAlias)
-		appRole := viper.GetString(cfgAppRole)
-
-		routeHandler.EnableAuth(router, appRole, signingKey)
+		if apiKeys := parseList(os.Getenv("TELESCOPES_API_KEYS")); len(apiKeys) > 0 {
+			log.Debug("enable api key authentication")
+			routeHandler.EnableAPIKeyAuth(os.Getenv("TELESCOPES_API_KEY_HEADER"), apiKeys)
+		} else {
+			log.Debug("enable authentication")
+			signingKey := viper.GetString(tokenSigningKeyAlias)
+			appRole := viper.GetString(cfgAppRole)
+
+			routeHandler.EnableAuth(appRole, signingKey, parseList(os.Getenv("TELESCOPES_REQUIRED_SCOPES"))...)
+		}
 	}
 
 	// add prometheus metric endpoint
@@ -195,7 +269,60 @@ func main() {
 	routeHandler.ConfigureRoutes(router)
 	log.Info("Configured routes")
 
-	router.Run(viper.GetString(listenAddressFlag))
+	listener, err := net.Listen("tcp", viper.GetString(listenAddressFlag))
+	quitOnError("failed to start telescopes", err)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	err = serve(&http.Server{Handler: router}, listener, parseTLS(), signals, viper.GetDuration(shutdownTimeoutFlag))
+	// the pending audit records are written even if the server stopped unexpectedly
+	if closeErr := audit.Close(); closeErr != nil {
+		log.WithError(closeErr).Error("could not close the audit log")
+	}
+	quitOnError("telescopes stopped unexpectedly", err)
+	log.Info("telescopes stopped")
+}
+
+// serverTLS is the certificate and the key the server terminates TLS with
+type serverTLS struct {
+	certFile string
+	keyFile  string
+	// HTTP/2 is negotiated with the clients supporting it if set, HTTP/1.1 is served otherwise
+	http2 bool
+}
+
+// serve serves the requests until a signal is received, then stops accepting new connections and waits for the
+// in-flight requests to finish; the connections of the requests still running after the timeout are closed, which
+// cancels their context. The requests are served over TLS if it's configured, over plain HTTP otherwise
+func serve(srv *http.Server, listener net.Listener, tlsCfg *serverTLS, signals <-chan os.Signal, timeout time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		if tlsCfg == nil {
+			errs <- srv.Serve(listener)
+			return
+		}
+		if !tlsCfg.http2 {
+			// a non-nil map turns off the automatic HTTP/2 support of the server
+			srv.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+		}
+		errs <- srv.ServeTLS(listener, tlsCfg.certFile, tlsCfg.keyFile)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-signals:
+		log.Infof("received signal [%s], shutting down", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.WithError(err).Warnf("the in-flight requests didn't finish in [%s], closing the connections", timeout)
+		return srv.Close()
+	}
+	return nil
 }
 
 func parseProductInfoAddress() *url.URL {
@@ -207,6 +334,119 @@ func parseProductInfoAddress() *url.URL {
 	return u
 }
 
+// productInfoSource creates the source of the product info selected by the TELESCOPES_PRICE_SOURCE environment variable,
+// file:/path loads a snapshot file, the product info service is used otherwise; the product info service is
+// supplemented with the snapshot file in the TELESCOPES_PRODUCT_SUPPLEMENT environment variable if it's set
+func productInfoSource() recommender.ProductInfoSource {
+	source := os.Getenv("TELESCOPES_PRICE_SOURCE")
+	supplement := os.Getenv("TELESCOPES_PRODUCT_SUPPLEMENT")
+	if source == "" {
+		piUrl := parseProductInfoAddress()
+		transport := httptransport.New(piUrl.Host, piUrl.Path, []string{piUrl.Scheme})
+		pic := recommender.NewProductInfoClient(client.New(transport, strfmt.Default))
+		if supplement == "" {
+			return pic
+		}
+		if !strings.HasPrefix(supplement, filePriceSource) {
+			log.Fatalf("TELESCOPES_PRODUCT_SUPPLEMENT is not a valid supplement: %s", supplement)
+		}
+		path := strings.TrimPrefix(supplement, filePriceSource)
+		fs, err := recommender.NewFileProductInfoSource(path)
+		quitOnError("failed to load the product info supplement", err)
+		log.Infof("supplementing the product info service with: %s", path)
+		return recommender.NewSupplementedProductInfoSource(pic, fs)
+	}
+	if supplement != "" {
+		log.Warn("TELESCOPES_PRODUCT_SUPPLEMENT is ignored, the product info snapshot holds the data of the instance types")
+	}
+	if !strings.HasPrefix(source, filePriceSource) {
+		log.Fatalf("TELESCOPES_PRICE_SOURCE is not a valid source: %s", source)
+	}
+	path := strings.TrimPrefix(source, filePriceSource)
+	fs, err := recommender.NewFileProductInfoSource(path)
+	quitOnError("failed to load the product info snapshot", err)
+	log.Infof("recommending from the product info snapshot: %s", path)
+	return fs
+}
+
+// auditSink creates the sink of the served recommendations selected by the TELESCOPES_AUDIT_SINK environment variable,
+// file:/path appends them to a JSON lines file, they are not recorded otherwise
+func auditSink() api.AuditSink {
+	sink := os.Getenv("TELESCOPES_AUDIT_SINK")
+	if sink == "" {
+		return api.NoopAuditSink{}
+	}
+	if !strings.HasPrefix(sink, fileAuditSink) {
+		log.Fatalf("TELESCOPES_AUDIT_SINK is not a valid sink: %s", sink)
+	}
+	path := strings.TrimPrefix(sink, fileAuditSink)
+	fs, err := api.NewFileAuditSink(path)
+	quitOnError("failed to open the audit log", err)
+	log.Infof("recording the recommendations to the audit log: %s", path)
+	return fs
+}
+
+// parseList parses a comma separated list of an environment variable, eg: the scopes the tokens must be granted
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseRequestTimeout reads the hard deadline of the recommendations from the TELESCOPES_REQUEST_TIMEOUT environment
+// variable, eg: 45s or 1m; the default of the engine is used if it's not set
+func parseRequestTimeout() time.Duration {
+	timeout := os.Getenv("TELESCOPES_REQUEST_TIMEOUT")
+	if timeout == "" {
+		return recommender.DefaultRequestTimeout
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		log.Fatalf("TELESCOPES_REQUEST_TIMEOUT is not a valid duration: %s", timeout)
+	}
+	return d
+}
+
+// parseDefaultOnDemandPct reads the percentage of regular nodes of the requests that don't set it from the
+// TELESCOPES_DEFAULT_ONDEMAND_PCT environment variable, eg: 100 for all on-demand clusters
+func parseDefaultOnDemandPct() int {
+	pct := os.Getenv("TELESCOPES_DEFAULT_ONDEMAND_PCT")
+	if pct == "" {
+		return defaultOnDemandPct
+	}
+	p, err := strconv.Atoi(pct)
+	if err != nil {
+		log.Fatalf("TELESCOPES_DEFAULT_ONDEMAND_PCT is not a valid percentage: %s", pct)
+	}
+	return p
+}
+
+// parseTLS reads the certificate and the key the server terminates TLS with from the TELESCOPES_TLS_CERT and
+// TELESCOPES_TLS_KEY environment variables (PEM files), nil if neither is set; HTTP/2 is served over TLS unless
+// TELESCOPES_HTTP2 is false
+func parseTLS() *serverTLS {
+	certFile, keyFile := os.Getenv("TELESCOPES_TLS_CERT"), os.Getenv("TELESCOPES_TLS_KEY")
+	if certFile == "" && keyFile == "" {
+		return nil
+	}
+	if certFile == "" || keyFile == "" {
+		log.Fatal("both TELESCOPES_TLS_CERT and TELESCOPES_TLS_KEY must be set to terminate TLS")
+	}
+	cfg := &serverTLS{certFile: certFile, keyFile: keyFile, http2: true}
+	if h2 := os.Getenv("TELESCOPES_HTTP2"); h2 != "" {
+		enabled, err := strconv.ParseBool(h2)
+		if err != nil {
+			log.Fatalf("TELESCOPES_HTTP2 is not a valid boolean: %s", h2)
+		}
+		cfg.http2 = enabled
+	}
+	return cfg
+}
+
 func quitOnError(msg string, err error) {
 	if err != nil {
 		log.Errorf("%s : %s", msg, err.Error())
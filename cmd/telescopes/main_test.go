@@ -15,10 +15,29 @@
 package main
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
 	"os"
+	"path/filepath"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/banzaicloud/telescopes/internal/app/telescopes/api"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
@@ -100,6 +119,14 @@ func Test_configurationStringDefaults(t *testing.T) {
 				assert.Equal(t, ":9090", val, fmt.Sprintf("invalid default for %s", listenAddressFlag))
 			},
 		},
+		{
+			name:     fmt.Sprintf("defaults for: %s", productInfoWorkersFlag),
+			viperKey: productInfoWorkersFlag,
+			args:     []string{}, // no flags provided
+			check: func(val interface{}) {
+				assert.Equal(t, 10, val, fmt.Sprintf("invalid default for %s", productInfoWorkersFlag))
+			},
+		},
 		{
 			name:     fmt.Sprintf("defaults for: %s", devModeFlag),
 			viperKey: devModeFlag,
@@ -146,3 +173,268 @@ func Test_configurationStringDefaults(t *testing.T) {
 		})
 	}
 }
+
+func Test_logEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		env   map[string]string
+		check func()
+	}{
+		{
+			name: "log level and format from the environment",
+			args: []string{},
+			env:  map[string]string{"TELESCOPES_LOG_LEVEL": "debug", "TELESCOPES_LOG_FORMAT": "json"},
+			check: func() {
+				assert.Equal(t, "debug", viper.GetString(logLevelFlag))
+				assert.Equal(t, "json", viper.GetString(logFormatFlag))
+			},
+		},
+		{
+			name: "flags take precedence over the environment",
+			args: []string{"--log-level", "warn", "--log-format", "text"},
+			env:  map[string]string{"TELESCOPES_LOG_LEVEL": "debug", "TELESCOPES_LOG_FORMAT": "json"},
+			check: func() {
+				assert.Equal(t, "warn", viper.GetString(logLevelFlag))
+				assert.Equal(t, "text", viper.GetString(logFormatFlag))
+			},
+		},
+		{
+			name: "defaults without flags and environment",
+			args: []string{},
+			check: func() {
+				assert.Equal(t, "info", viper.GetString(logLevelFlag))
+				assert.Equal(t, "text", viper.GetString(logFormatFlag))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for k, v := range test.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+			pflag.CommandLine = pflag.NewFlagSet(os.Args[0], pflag.ContinueOnError)
+			defineFlags()
+			setupInputs(test.args, nil)
+			bindLogEnv()
+
+			test.check()
+		})
+	}
+}
+
+func Test_logFormatter(t *testing.T) {
+	tests := []struct {
+		format string
+		check  func(formatter log.Formatter, err error)
+	}{
+		{
+			format: "json",
+			check: func(formatter log.Formatter, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				// the request scoped fields are serialized as attributes
+				b, err := formatter.Format(log.WithField("requestId", "id").WithError(errors.New("failed")))
+				assert.Nil(t, err, "the entry couldn't be formatted")
+				var entry map[string]interface{}
+				assert.Nil(t, json.Unmarshal(b, &entry), "the entry should be json")
+				assert.Equal(t, "id", entry["requestId"])
+				assert.Equal(t, "failed", entry["error"])
+			},
+		},
+		{
+			format: "text",
+			check: func(formatter log.Formatter, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.IsType(t, &log.TextFormatter{}, formatter)
+			},
+		},
+		{
+			format: "xml",
+			check: func(formatter log.Formatter, err error) {
+				assert.EqualError(t, err, "invalid log format: xml")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			test.check(logFormatter(test.format))
+		})
+	}
+}
+
+func Test_serve(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration time.Duration
+		timeout  time.Duration
+		check    func(resp *http.Response, err error, canceled bool)
+	}{
+		{
+			name:     "in-flight request drained",
+			duration: 100 * time.Millisecond,
+			timeout:  5 * time.Second,
+			check: func(resp *http.Response, err error, canceled bool) {
+				assert.Nil(t, err, "the request should finish")
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.False(t, canceled, "the request shouldn't be canceled")
+			},
+		},
+		{
+			name:     "request running after the timeout canceled",
+			duration: 5 * time.Second,
+			timeout:  100 * time.Millisecond,
+			check: func(resp *http.Response, err error, canceled bool) {
+				assert.NotNil(t, err, "the connection should be closed")
+				assert.True(t, canceled, "the context of the request should be canceled")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			started, canceled := make(chan bool), make(chan bool, 1)
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				started <- true
+				select {
+				case <-time.After(test.duration):
+					canceled <- false
+				case <-r.Context().Done():
+					canceled <- true
+				}
+			})
+
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.Nil(t, err, "the listener couldn't be created")
+			signals := make(chan os.Signal, 1)
+			served := make(chan error, 1)
+			go func() {
+				served <- serve(&http.Server{Handler: handler}, listener, nil, signals, test.timeout)
+			}()
+
+			type result struct {
+				resp *http.Response
+				err  error
+			}
+			results := make(chan result, 1)
+			go func() {
+				resp, err := http.Get("http://" + listener.Addr().String())
+				results <- result{resp, err}
+			}()
+
+			<-started
+			signals <- syscall.SIGTERM
+			assert.Nil(t, <-served, "the server should stop without error")
+			r := <-results
+			test.check(r.resp, r.err, <-canceled)
+		})
+	}
+}
+
+// writeSelfSignedCert writes a self-signed certificate of the loopback address and its key to the directory
+func writeSelfSignedCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err, "the key couldn't be generated")
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "telescopes"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	assert.Nil(t, err, "the certificate couldn't be created")
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err, "the key couldn't be marshalled")
+
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	assert.Nil(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
+
+func Test_serveTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telescopes-tls")
+	assert.Nil(t, err, "the directory couldn't be created")
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeSelfSignedCert(t, dir)
+
+	tests := []struct {
+		name     string
+		http2    bool
+		protocol string
+	}{
+		{name: "http/2 negotiated", http2: true, protocol: "h2"},
+		{name: "http/2 disabled", http2: false, protocol: "http/1.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gin.SetMode(gin.ReleaseMode)
+			router := gin.New()
+			api.NewRouteHandler(nil).ConfigureRoutes(router)
+
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.Nil(t, err, "the listener couldn't be created")
+			signals := make(chan os.Signal, 1)
+			served := make(chan error, 1)
+			go func() {
+				served <- serve(&http.Server{Handler: router}, listener, &serverTLS{certFile: certFile, keyFile: keyFile, http2: test.http2}, signals, time.Second)
+			}()
+
+			// the certificate is self-signed
+			tlsConfig := &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2", "http/1.1"}}
+			conn, err := tls.Dial("tcp", listener.Addr().String(), tlsConfig)
+			assert.Nil(t, err, "the tls handshake should succeed")
+			assert.Equal(t, test.protocol, conn.ConnectionState().NegotiatedProtocol)
+			conn.Close()
+
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+			resp, err := client.Get("https://" + listener.Addr().String() + "/status")
+			assert.Nil(t, err, "the status should be served over tls")
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			resp.Body.Close()
+
+			signals <- syscall.SIGTERM
+			assert.Nil(t, <-served, "the server should stop without error")
+		})
+	}
+}
+
+func Test_parseTLS(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		check func(cfg *serverTLS)
+	}{
+		{
+			name: "plain http by default",
+			check: func(cfg *serverTLS) {
+				assert.Nil(t, cfg)
+			},
+		},
+		{
+			name: "tls with http/2",
+			env:  map[string]string{"TELESCOPES_TLS_CERT": "tls.crt", "TELESCOPES_TLS_KEY": "tls.key"},
+			check: func(cfg *serverTLS) {
+				assert.Equal(t, &serverTLS{certFile: "tls.crt", keyFile: "tls.key", http2: true}, cfg)
+			},
+		},
+		{
+			name: "tls without http/2",
+			env:  map[string]string{"TELESCOPES_TLS_CERT": "tls.crt", "TELESCOPES_TLS_KEY": "tls.key", "TELESCOPES_HTTP2": "false"},
+			check: func(cfg *serverTLS) {
+				assert.False(t, cfg.http2)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for k, v := range test.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+			test.check(parseTLS())
+		})
+	}
+}
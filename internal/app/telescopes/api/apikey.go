@@ -0,0 +1,65 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultAPIKeyHeader is the header carrying the api key if no other is configured
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// EnableAPIKeyAuth enables api key authentication on the api routes as an alternative to the token based one,
+// the requests must carry one of the keys in the header; it must be called before the routes are configured
+func (r *RouteHandler) EnableAPIKeyAuth(headerName string, keys []string) {
+	if headerName == "" {
+		headerName = DefaultAPIKeyHeader
+	}
+	r.authHeaders = append(r.authHeaders, headerName)
+	r.auth = append(r.auth, APIKeyAuth(headerName, keys))
+}
+
+// APIKeyAuth is a gin middleware that rejects the requests with 401 unless the header holds one of the keys,
+// only the hashes of the keys are kept and they are all compared in constant time
+func APIKeyAuth(headerName string, keys []string) gin.HandlerFunc {
+	hashes := make([][sha256.Size]byte, 0, len(keys))
+	for _, key := range keys {
+		hashes = append(hashes, sha256.Sum256([]byte(key)))
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(headerName)
+		hash := sha256.Sum256([]byte(key))
+
+		// every key is compared so that the time taken doesn't tell which one matched
+		var match int
+		for i := range hashes {
+			match |= subtle.ConstantTimeCompare(hash[:], hashes[i][:])
+		}
+		if key == "" || match != 1 {
+			logger(c).Warn("request with a missing or invalid api key")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code":    "unauthorized",
+				"message": "missing or invalid api key",
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteHandler_EnableAPIKeyAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		check   func(w *httptest.ResponseRecorder)
+	}{
+		{
+			name:    "request with a valid key",
+			path:    "/api/v1/recommender/unknown",
+			headers: map[string]string{DefaultAPIKeyHeader: "key-2"},
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, w.Code, "the request should reach the api")
+			},
+		},
+		{
+			name:    "request with an invalid key",
+			path:    "/api/v1/recommender/unknown",
+			headers: map[string]string{DefaultAPIKeyHeader: "key-3"},
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, w.Code)
+				assert.JSONEq(t, `{"code":"unauthorized","message":"missing or invalid api key"}`, w.Body.String())
+			},
+		},
+		{
+			name:    "key in another header",
+			path:    "/api/v1/recommender/unknown",
+			headers: map[string]string{"Authorization": "key-1"},
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, w.Code)
+			},
+		},
+		{
+			name: "probes reachable without a key",
+			path: "/status",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+			},
+		},
+		{
+			name:    "key header allowed in cross-origin requests",
+			path:    "/api/v1/recommender/unknown",
+			headers: map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": http.MethodGet},
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), http.CanonicalHeaderKey(DefaultAPIKeyHeader))
+			},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	rh := NewRouteHandler(nil)
+	rh.SetRateLimiter(nil)
+	rh.EnableAPIKeyAuth("", []string{"key-1", "key-2"})
+	router := gin.New()
+	rh.ConfigureRoutes(router)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			method := http.MethodGet
+			if _, ok := test.headers["Access-Control-Request-Method"]; ok {
+				method = http.MethodOptions
+			}
+			req := httptest.NewRequest(method, test.path, nil)
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			test.check(w)
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/banzaicloud/bank-vaults/auth"
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// the number of audit records buffered by the file sink before new records are dropped
+const defaultAuditBuffer = 1024
+
+// AuditRecord describes a recommendation served by the api
+type AuditRecord struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"requestId"`
+	// the subject of the token the request was authenticated with, empty if the request wasn't authenticated with a token
+	Caller   string                                 `json:"caller,omitempty"`
+	Provider string                                 `json:"provider"`
+	Region   string                                 `json:"region"`
+	Request  recommender.ClusterRecommendationReq   `json:"request"`
+	Response *recommender.ClusterRecommendationResp `json:"response,omitempty"`
+	// the reason the recommendation couldn't be served
+	Error string `json:"error,omitempty"`
+}
+
+// AuditSink records the recommendations served by the api; recording must not block the request
+type AuditSink interface {
+	// Record records the recommendation
+	Record(rec AuditRecord)
+	// Close flushes the pending records and releases the sink
+	Close() error
+}
+
+// NoopAuditSink discards the records, it's the sink of the api unless one is configured
+type NoopAuditSink struct{}
+
+// Record discards the record
+func (NoopAuditSink) Record(AuditRecord) {}
+
+// Close does nothing
+func (NoopAuditSink) Close() error { return nil }
+
+// FileAuditSink appends the records to a file as JSON lines, the records are written in the background
+type FileAuditSink struct {
+	file    *os.File
+	records chan AuditRecord
+	done    chan struct{}
+	once    sync.Once
+	// guards the records channel against the records arriving after the sink is closed
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewFileAuditSink creates a sink appending the records to the file at the path, the file is created if it doesn't exist
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileAuditSink{
+		file:    file,
+		records: make(chan AuditRecord, defaultAuditBuffer),
+		done:    make(chan struct{}),
+	}
+	go s.write()
+	return s, nil
+}
+
+// Record queues the record to be written, the record is dropped if the buffer is full or the sink is closed
+func (s *FileAuditSink) Record(rec AuditRecord) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		log.WithField("requestId", rec.RequestID).Warn("the audit log is closed, the record is dropped")
+		return
+	}
+	select {
+	case s.records <- rec:
+	default:
+		log.WithField("requestId", rec.RequestID).Error("the audit log buffer is full, the record is dropped")
+	}
+}
+
+// Close writes the queued records and closes the file, no records can be recorded afterwards
+func (s *FileAuditSink) Close() error {
+	s.once.Do(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.closed = true
+		close(s.records)
+	})
+	<-s.done
+	return s.file.Close()
+}
+
+// write writes the queued records to the file until the sink is closed
+func (s *FileAuditSink) write() {
+	defer close(s.done)
+	enc := json.NewEncoder(s.file)
+	for rec := range s.records {
+		if err := enc.Encode(rec); err != nil {
+			log.WithField("requestId", rec.RequestID).WithError(err).Error("could not write the audit record")
+		}
+	}
+}
+
+// SetAuditSink sets the sink the served recommendations are recorded to
+func (r *RouteHandler) SetAuditSink(s AuditSink) {
+	r.audit = s
+}
+
+// recordAudit records the recommendation served for the request to the audit sink
+func (r *RouteHandler) recordAudit(c *gin.Context, provider string, region string, req recommender.ClusterRecommendationReq,
+	resp *recommender.ClusterRecommendationResp, err error) {
+	rec := AuditRecord{
+		Time:      time.Now().UTC(),
+		RequestID: c.GetString(requestIDKey),
+		Provider:  provider,
+		Region:    region,
+		Request:   req,
+		Response:  resp,
+	}
+	if claims, ok := auth.GetCurrentUser(c).(*auth.ScopedClaims); ok {
+		rec.Caller = claims.Subject
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	r.audit.Record(rec)
+}
@@ -0,0 +1,201 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/banzaicloud/bank-vaults/auth"
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// readAuditRecords reads the records of the audit log file
+func readAuditRecords(t *testing.T, path string) []AuditRecord {
+	f, err := os.Open(path)
+	assert.Nil(t, err, "the audit log couldn't be opened")
+	defer f.Close()
+
+	var records []AuditRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec AuditRecord
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &rec), "the audit record couldn't be parsed")
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestFileAuditSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.Nil(t, err, "the temp dir couldn't be created")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.jsonl")
+
+	// the records are appended to the existing ones
+	for _, id := range []string{"req-1", "req-2"} {
+		sink, err := NewFileAuditSink(path)
+		assert.Nil(t, err, "the sink couldn't be created")
+		sink.Record(AuditRecord{RequestID: id, Provider: "ec2", Region: "eu-west-1"})
+		assert.Nil(t, sink.Close(), "the sink couldn't be closed")
+	}
+
+	records := readAuditRecords(t, path)
+	assert.Equal(t, 2, len(records))
+	assert.Equal(t, "req-1", records[0].RequestID)
+	assert.Equal(t, "req-2", records[1].RequestID)
+	assert.Equal(t, "eu-west-1", records[1].Region)
+}
+
+func TestFileAuditSink_RecordAfterClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.Nil(t, err, "the temp dir couldn't be created")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.jsonl")
+
+	sink, err := NewFileAuditSink(path)
+	assert.Nil(t, err, "the sink couldn't be created")
+	sink.Record(AuditRecord{RequestID: "req-1"})
+	assert.Nil(t, sink.Close(), "the sink couldn't be closed")
+
+	// the late record is dropped instead of sent on the closed channel
+	assert.NotPanics(t, func() { sink.Record(AuditRecord{RequestID: "late"}) })
+
+	records := readAuditRecords(t, path)
+	assert.Equal(t, 1, len(records))
+	assert.Equal(t, "req-1", records[0].RequestID)
+}
+
+func TestRouteHandler_recordAudit(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		check func(rec AuditRecord)
+	}{
+		{
+			name: "recommendation recorded",
+			body: `{"sumCpu": 16, "sumMem": 64, "minNodes": 2, "maxNodes": 6, "onDemandPct": 50}`,
+			check: func(rec AuditRecord) {
+				assert.Equal(t, "audited-request", rec.RequestID)
+				assert.Equal(t, "1", rec.Caller, "the subject of the token should be recorded")
+				assert.Equal(t, "ec2", rec.Provider)
+				assert.Equal(t, "eu-west-1", rec.Region)
+				assert.Equal(t, float64(16), rec.Request.SumCpu)
+				assert.NotEmpty(t, rec.Response.NodePools, "the layout should be recorded")
+				assert.Empty(t, rec.Error)
+				assert.False(t, rec.Time.IsZero(), "the time should be recorded")
+			},
+		},
+		{
+			name: "failed recommendation recorded",
+			body: `{"sumCpu": 16, "sumMem": 64, "minNodes": 2, "maxNodes": 6, "onDemandPct": 50, "maxNodeCpu": 1}`,
+			check: func(rec AuditRecord) {
+				assert.Nil(t, rec.Response, "there should be no layout")
+				assert.Contains(t, rec.Error, "there are no instance types within the node size bounds")
+			},
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "audit")
+	assert.Nil(t, err, "the temp dir couldn't be created")
+	defer os.RemoveAll(dir)
+
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := recommender.NewEngine(fs)
+	assert.Nil(t, err, "the engine couldn't be created")
+	assert.Nil(t, ConfigureValidator(fs), "the validator couldn't be configured")
+
+	gin.SetMode(gin.TestMode)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, test.name+".jsonl")
+			sink, err := NewFileAuditSink(path)
+			assert.Nil(t, err, "the sink couldn't be created")
+
+			rh := NewRouteHandler(engine)
+			rh.SetRateLimiter(nil)
+			rh.SetAuditSink(sink)
+			rh.auth = []gin.HandlerFunc{auth.JWTAuth(whitelistTokenStore{}, testSigningKey, nil)}
+			router := gin.New()
+			rh.ConfigureRoutes(router)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/recommender/ec2/eu-west-1/cluster/", strings.NewReader(test.body))
+			req.Header.Set("Authorization", "Bearer "+signedToken(t, ""))
+			req.Header.Set(requestIDHeader, "audited-request")
+			router.ServeHTTP(httptest.NewRecorder(), req)
+			assert.Nil(t, sink.Close(), "the sink couldn't be closed")
+
+			records := readAuditRecords(t, path)
+			if assert.Equal(t, 1, len(records), "a record should be written") {
+				test.check(records[0])
+			}
+		})
+	}
+}
+
+func TestRouteHandler_recordAuditGroups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.Nil(t, err, "the temp dir couldn't be created")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.jsonl")
+
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := recommender.NewEngine(fs)
+	assert.Nil(t, err, "the engine couldn't be created")
+	assert.Nil(t, ConfigureValidator(fs), "the validator couldn't be configured")
+	sink, err := NewFileAuditSink(path)
+	assert.Nil(t, err, "the sink couldn't be created")
+
+	gin.SetMode(gin.TestMode)
+	rh := NewRouteHandler(engine)
+	rh.SetRateLimiter(nil)
+	rh.SetAuditSink(sink)
+	router := gin.New()
+	rh.ConfigureRoutes(router)
+
+	body := `{"groups": {"web": {"sumCpu": 8, "sumMem": 16, "minNodes": 1, "maxNodes": 4, "onDemandPct": 100},
+		"ml": {"sumCpu": 8, "sumMem": 16, "sumGpu": 1, "minNodes": 1, "maxNodes": 4},
+		"tiny": {"sumCpu": 8, "sumMem": 16, "minNodes": 1, "maxNodes": 4, "maxNodeCpu": 1}}}`
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/recommender/ec2/eu-west-1/cluster/groups", strings.NewReader(body)))
+	assert.Nil(t, sink.Close(), "the sink couldn't be closed")
+
+	records := readAuditRecords(t, path)
+	assert.Equal(t, 3, len(records), "a record should be written per group")
+	for _, rec := range records {
+		switch {
+		case rec.Request.SumGpu > 0:
+			assert.Nil(t, rec.Response)
+			assert.Contains(t, rec.Error, "gpu", "the group should be recorded with its own error")
+		case rec.Request.MaxNodeCpu > 0:
+			assert.Nil(t, rec.Response)
+			assert.Contains(t, rec.Error, "there are no instance types within the node size bounds", "the group should be recorded with its own error")
+		default:
+			assert.NotEmpty(t, rec.Response.NodePools, "the recommended group should be recorded with its layout")
+			assert.Empty(t, rec.Error)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/banzaicloud/bank-vaults/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScopes is a gin middleware that rejects the requests with 403 unless the token validated by the JWT
+// authentication is granted all the scopes, the scope claim of the token holds the space separated granted scopes
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var granted []string
+		if claims, ok := auth.GetCurrentUser(c).(*auth.ScopedClaims); ok {
+			granted = strings.Fields(claims.Scope)
+		}
+
+		var missing []string
+		for _, scope := range scopes {
+			if !contains(granted, scope) {
+				missing = append(missing, scope)
+			}
+		}
+		if len(missing) > 0 {
+			logger(c).Warnf("token without the required scopes: %v", missing)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"code":    "forbidden",
+				"message": fmt.Sprintf("the token is not granted the required scopes %v", missing),
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// contains reports whether the value is in the slice
+func contains(slice []string, value string) bool {
+	for _, s := range slice {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
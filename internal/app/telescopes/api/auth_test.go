@@ -0,0 +1,167 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/base32"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/banzaicloud/bank-vaults/auth"
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSigningKey = "test-signing-key"
+
+// whitelistTokenStore whitelists every token
+type whitelistTokenStore struct{}
+
+func (whitelistTokenStore) Store(userID string, token *auth.Token) error { return nil }
+func (whitelistTokenStore) Lookup(userID string, tokenID string) (*auth.Token, error) {
+	return auth.NewToken(tokenID, "test"), nil
+}
+func (whitelistTokenStore) Revoke(userID string, tokenID string) error { return nil }
+func (whitelistTokenStore) List(userID string) ([]*auth.Token, error)  { return nil, nil }
+
+// signedToken issues a token granted the scope, signed the way the JWT authentication expects
+func signedToken(t *testing.T, scope string) string {
+	claims := auth.ScopedClaims{
+		StandardClaims: jwt.StandardClaims{Subject: "1", Id: "token-1"},
+		Scope:          scope,
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).
+		SignedString([]byte(base32.StdEncoding.EncodeToString([]byte(testSigningKey))))
+	assert.Nil(t, err, "the token couldn't be signed")
+	return token
+}
+
+func TestRequireScopes(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		check func(w *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "token granted the required scope",
+			token: signedToken(t, "cluster:write recommender:read"),
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+			},
+		},
+		{
+			name:  "token without scopes",
+			token: signedToken(t, ""),
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, w.Code)
+				assert.JSONEq(t, `{"code":"forbidden","message":"the token is not granted the required scopes [recommender:read]"}`, w.Body.String())
+			},
+		},
+		{
+			name:  "token granted another scope",
+			token: signedToken(t, "recommender:write"),
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusForbidden, w.Code)
+			},
+		},
+		{
+			name: "missing token",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, w.Code)
+			},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(auth.JWTAuth(whitelistTokenStore{}, testSigningKey, nil), RequireScopes("recommender:read"))
+	router.GET("/", func(c *gin.Context) {
+		c.JSON(http.StatusOK, "ok")
+	})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			test.check(w)
+		})
+	}
+}
+
+func TestRouteHandler_auth(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		check func(w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "liveness probe not authenticated",
+			path: "/status",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+			},
+		},
+		{
+			name: "api authenticated",
+			path: "/api/v1/recommender/unknown",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, w.Code)
+			},
+		},
+		{
+			name: "providers authenticated",
+			path: "/api/v1/recommender/providers",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnauthorized, w.Code)
+			},
+		},
+		{
+			name: "capabilities not authenticated",
+			path: "/api/v1/recommender/capabilities",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+				var c recommender.Capabilities
+				assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &c), "the response couldn't be parsed")
+				assert.Equal(t, "ec2", c.Providers[0].ID)
+			},
+		},
+	}
+
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := recommender.NewEngine(fs)
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	gin.SetMode(gin.TestMode)
+	rh := NewRouteHandler(engine)
+	rh.SetRateLimiter(nil)
+	rh.auth = []gin.HandlerFunc{auth.JWTAuth(whitelistTokenStore{}, testSigningKey, nil)}
+	router := gin.New()
+	rh.ConfigureRoutes(router)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+			test.check(w)
+		})
+	}
+}
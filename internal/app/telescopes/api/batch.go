@@ -0,0 +1,165 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+const (
+	// the maximum number of requests in a batch
+	maxBatchSize = 20
+	// the maximum number of recommendations of a batch computed in parallel
+	maxBatchConcurrency = 4
+)
+
+// BatchRecommendationReq encapsulates a recommendation request of a batch, decorated with the provider and region
+type BatchRecommendationReq struct {
+	// Cloud provider of the recommended cluster
+	Provider string `json:"provider" binding:"required,provider"`
+	// Region of the recommended cluster
+	Region string `json:"region" binding:"required,region"`
+	recommender.ClusterRecommendationReq
+}
+
+// BatchRecommendationResult holds the recommendation for a request of a batch, or the error if it couldn't be served
+type BatchRecommendationResult struct {
+	Provider       string                                 `json:"provider"`
+	Region         string                                 `json:"region"`
+	Recommendation *recommender.ClusterRecommendationResp `json:"recommendation,omitempty"`
+	Error          *BatchError                            `json:"error,omitempty"`
+}
+
+// BatchError describes why a request of a batch couldn't be served
+type BatchError struct {
+	// the status code the request would have been responded with on its own
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// the failed field validations of the request
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// Error returns the message of the error
+func (e *BatchError) Error() string {
+	return e.Message
+}
+
+// swagger:route POST /recommender/cluster/batch recommend recommendClusterBatch
+//
+// Provides recommended sets of node pools for multiple providers, regions and requirements. The requests are served
+// independently, the result of each request holds either the recommendation or the reason it couldn't be served.
+//
+//     Consumes:
+//     - application/json
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Security:
+//
+//     Responses:
+//       200: BatchRecommendationResponse
+func (r *RouteHandler) recommendClusterBatch(c *gin.Context) {
+	logger(c).Info("recommend cluster setups in batch")
+
+	var reqs []BatchRecommendationReq
+	if err := c.BindJSON(&reqs); err != nil {
+		logger(c).Errorf("failed to bind request body: %s", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "bad_params",
+			"message": "validation failed",
+			"cause":   validationMessage(err),
+			"details": validationDetails(err),
+		})
+		return
+	}
+
+	if len(reqs) == 0 || len(reqs) > maxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "bad_params",
+			"message": fmt.Sprintf("the batch must contain 1 to %d requests", maxBatchSize),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, r.recommendBatchItems(c.Request.Context(), c, reqs))
+}
+
+// recommendBatchItems serves the requests of a batch in parallel until the context is done, the results are in the
+// order of the requests
+func (r *RouteHandler) recommendBatchItems(ctx context.Context, c *gin.Context, reqs []BatchRecommendationReq) []BatchRecommendationResult {
+	var (
+		entry   = logger(c)
+		v       = binding.Validator.Engine().(*validator.Validate)
+		results = make([]BatchRecommendationResult, len(reqs))
+		sem     = make(chan struct{}, maxBatchConcurrency)
+		wg      sync.WaitGroup
+	)
+
+	for i := range reqs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = r.recommendBatchItem(ctx, entry, v, reqs[i])
+			if results[i].Error != nil {
+				r.recordAudit(c, reqs[i].Provider, reqs[i].Region, reqs[i].ClusterRecommendationReq, nil, results[i].Error)
+			} else {
+				r.recordAudit(c, reqs[i].Provider, reqs[i].Region, reqs[i].ClusterRecommendationReq, results[i].Recommendation, nil)
+			}
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// recommendBatchItem validates and serves a request of a batch
+func (r *RouteHandler) recommendBatchItem(ctx context.Context, entry *log.Entry, v *validator.Validate, req BatchRecommendationReq) BatchRecommendationResult {
+	result := BatchRecommendationResult{Provider: req.Provider, Region: req.Region}
+
+	if err := v.Struct(req); err != nil {
+		entry.Errorf("validation failed. err: %s", err.Error())
+		result.Error = &BatchError{Status: http.StatusBadRequest, Code: "bad_params", Message: validationMessage(err),
+			Details: validationDetails(err)}
+		return result
+	}
+
+	resp, err := r.engine.RecommendClusterCtx(ctx, req.Provider, req.Region, req.ClusterRecommendationReq)
+	if err != nil {
+		entry.WithError(err).Errorf("could not recommend cluster for provider: %s, region: %s", req.Provider, req.Region)
+		status, code := errorStatus(err)
+		if code == "" {
+			code = "internal_error"
+		}
+		result.Error = &BatchError{Status: status, Code: code, Message: err.Error()}
+		return result
+	}
+
+	result.Recommendation = resp
+	return result
+}
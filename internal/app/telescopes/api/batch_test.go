@@ -0,0 +1,200 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/productinfo/pkg/productinfo-client/models"
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// concurrentProductInfoSource records the maximum number of product detail calls in flight at once; like the product
+// info service it doesn't version the prices, so every recommendation fetches the products
+type concurrentProductInfoSource struct {
+	recommender.ProductInfoSource
+	latency  time.Duration
+	inflight int32
+	max      int32
+}
+
+func (piCli *concurrentProductInfoSource) GetProductDetails(provider string, region string) ([]*models.ProductDetails, error) {
+	n := atomic.AddInt32(&piCli.inflight, 1)
+	defer atomic.AddInt32(&piCli.inflight, -1)
+	for {
+		max := atomic.LoadInt32(&piCli.max)
+		if n <= max || atomic.CompareAndSwapInt32(&piCli.max, max, n) {
+			break
+		}
+	}
+	time.Sleep(piCli.latency)
+	return piCli.ProductInfoSource.GetProductDetails(provider, region)
+}
+
+// batchRouter creates a router recommending from the source
+func batchRouter(t *testing.T, source recommender.ProductInfoSource) *gin.Engine {
+	engine, err := recommender.NewEngine(source, recommender.WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+	assert.Nil(t, ConfigureValidator(source), "the validator couldn't be configured")
+
+	gin.SetMode(gin.TestMode)
+	rh := NewRouteHandler(engine)
+	rh.SetRateLimiter(nil)
+	router := gin.New()
+	rh.ConfigureRoutes(router)
+	return router
+}
+
+func TestRouteHandler_recommendClusterBatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		check func(w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "a result per request, in the order of the requests",
+			body: `[{"provider": "ec2", "region": "eu-west-1", "sumCpu": 8, "sumMem": 16, "minNodes": 1, "maxNodes": 4, "onDemandPct": 100},
+				{"provider": "ec2", "region": "eu-west-1", "sumCpu": 16, "sumMem": 64, "minNodes": 2, "maxNodes": 8, "onDemandPct": 50}]`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+				var results []BatchRecommendationResult
+				assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &results), "the response couldn't be parsed")
+				if assert.Equal(t, 2, len(results)) {
+					assert.Equal(t, float64(8), results[0].Recommendation.Request.SumCpu)
+					assert.Equal(t, float64(16), results[1].Recommendation.Request.SumCpu)
+					for _, res := range results {
+						assert.Nil(t, res.Error)
+						assert.Equal(t, "ec2", res.Provider)
+						assert.Equal(t, "eu-west-1", res.Region)
+					}
+				}
+			},
+		},
+		{
+			name: "a failing request doesn't fail the batch",
+			body: `[{"provider": "ec2", "region": "eu-west-1", "sumCpu": 8, "sumMem": 16, "minNodes": 1, "maxNodes": 4, "maxNodeCpu": 1},
+				{"provider": "ec2", "region": "eu-west-1", "sumCpu": 8, "sumMem": 16, "minNodes": 1, "maxNodes": 4, "sumGpu": 1},
+				{"provider": "ec2", "region": "eu-west-1", "sumCpu": 8, "sumMem": 16, "minNodes": 1, "maxNodes": 4}]`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+				var results []BatchRecommendationResult
+				assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &results), "the response couldn't be parsed")
+				if assert.Equal(t, 3, len(results)) {
+					assert.Nil(t, results[0].Recommendation)
+					assert.Equal(t, http.StatusUnprocessableEntity, results[0].Error.Status)
+					assert.Equal(t, recommender.NoViableInstances, results[0].Error.Code)
+					assert.Contains(t, results[0].Error.Message, "there are no instance types within the node size bounds")
+					assert.Nil(t, results[1].Recommendation)
+					assert.Equal(t, http.StatusBadRequest, results[1].Error.Status)
+					assert.Equal(t, recommender.ResourceUnavailable, results[1].Error.Code)
+					assert.Nil(t, results[2].Error)
+					assert.NotEmpty(t, results[2].Recommendation.NodePools)
+				}
+			},
+		},
+		{
+			name: "requests validated one by one",
+			body: `[{"provider": "gce", "region": "eu-west-1", "sumCpu": 8, "sumMem": 16, "minNodes": 1, "maxNodes": 4},
+				{"provider": "ec2", "region": "eu-west-1", "sumCpu": 8, "sumMem": 16, "minNodes": 4, "maxNodes": 1},
+				{"provider": "ec2", "region": "eu-west-1", "sumCpu": 8, "sumMem": 16, "minNodes": 1, "maxNodes": 4, "zones": ["us-east-1a"]},
+				{"provider": "ec2", "region": "eu-west-1", "sumCpu": 8, "sumMem": 16, "minNodes": 1, "maxNodes": 4}]`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+				var results []BatchRecommendationResult
+				assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &results), "the response couldn't be parsed")
+				if assert.Equal(t, 4, len(results)) {
+					for i, field := range []string{"provider", "minNodes", "zones[0]"} {
+						if assert.NotNil(t, results[i].Error, "the request %d should be rejected", i) {
+							assert.Equal(t, http.StatusBadRequest, results[i].Error.Status)
+							assert.Equal(t, "bad_params", results[i].Error.Code)
+							assert.Equal(t, field, results[i].Error.Details[0].Field)
+						}
+						assert.Nil(t, results[i].Recommendation)
+					}
+					assert.Nil(t, results[3].Error, "the valid request should be served")
+				}
+			},
+		},
+		{
+			name: "error - empty batch",
+			body: `[]`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+				assert.Contains(t, w.Body.String(), fmt.Sprintf("the batch must contain 1 to %d requests", maxBatchSize))
+			},
+		},
+		{
+			name: "error - batch too large",
+			body: "[" + strings.TrimSuffix(strings.Repeat(`{"provider": "ec2", "region": "eu-west-1", "sumCpu": 8, "sumMem": 16, "minNodes": 1, "maxNodes": 4},`, maxBatchSize+1), ",") + "]",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+				assert.Contains(t, w.Body.String(), fmt.Sprintf("the batch must contain 1 to %d requests", maxBatchSize))
+			},
+		},
+		{
+			name: "error - not a list of requests",
+			body: `{"provider": "ec2"}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+			},
+		},
+	}
+
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	router := batchRouter(t, fs)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/recommender/cluster/batch", strings.NewReader(test.body)))
+			test.check(w)
+		})
+	}
+}
+
+func TestRouteHandler_recommendClusterBatchConcurrency(t *testing.T) {
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	source := &concurrentProductInfoSource{ProductInfoSource: fs, latency: 20 * time.Millisecond}
+	router := batchRouter(t, source)
+
+	// the requests differ so that they are not served by the same computation
+	var reqs []string
+	for i := 0; i < 3*maxBatchConcurrency; i++ {
+		reqs = append(reqs, fmt.Sprintf(`{"provider": "ec2", "region": "eu-west-1", "sumCpu": %d, "sumMem": 16, "minNodes": 1, "maxNodes": 8}`, 8+i))
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/recommender/cluster/batch", strings.NewReader("["+strings.Join(reqs, ",")+"]")))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var results []BatchRecommendationResult
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &results), "the response couldn't be parsed")
+	assert.Equal(t, len(reqs), len(results))
+	for _, res := range results {
+		assert.Nil(t, res.Error)
+	}
+	max := atomic.LoadInt32(&source.max)
+	assert.True(t, max <= maxBatchConcurrency, "at most %d requests should be served at once, %d were", maxBatchConcurrency, max)
+	assert.True(t, max > 1, "the requests should be served in parallel")
+}
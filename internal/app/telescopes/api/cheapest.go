@@ -0,0 +1,106 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+// regionlessReq is the cluster recommendation request of the endpoints recommending in several regions: zones can't be
+// requested as they belong to a single region, the fields shadow the ones of the embedded request
+type regionlessReq struct {
+	Zones        []string `json:"zones,omitempty" binding:"max=0"`
+	ExcludeZones []string `json:"excludeZones,omitempty" binding:"max=0"`
+	recommender.ClusterRecommendationReq
+}
+
+// CheapestRegionReq encapsulates the recommendation request with the candidate regions
+type CheapestRegionReq struct {
+	// Candidate regions of the recommended cluster
+	Regions []string `json:"regions" binding:"required,min=1,max=10"`
+	regionlessReq
+}
+
+// swagger:route POST /recommender/:provider/cheapest recommend recommendCheapestRegion
+//
+// Provides the recommended set of node pools in the cheapest of the candidate regions of the given provider,
+// together with the ranking of the candidate regions by the total price of the recommendation.
+//
+//     Consumes:
+//     - application/json
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Security:
+//
+//     Responses:
+//       200: CheapestRegionResponse
+func (r *RouteHandler) recommendCheapestRegion(c *gin.Context) {
+	provider := c.Param(providerParam)
+	logger(c).Infof("recommend cheapest region for provider: %s", provider)
+
+	var req CheapestRegionReq
+	if err := c.BindJSON(&req); err != nil {
+		logger(c).Errorf("failed to bind request body: %s", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "bad_params",
+			"message": "validation failed",
+			"cause":   validationMessage(err),
+			"details": validationDetails(err),
+		})
+		return
+	}
+
+	if !validRegions(c, provider, req.Regions) {
+		return
+	}
+
+	if response, err := r.engine.RecommendCheapestRegion(c.Request.Context(), provider, req.Regions, req.ClusterRecommendationReq); err != nil {
+		logger(c).WithError(err).Errorf("could not recommend cheapest region for provider: %s", provider)
+		r.recordAudit(c, provider, strings.Join(req.Regions, ","), req.ClusterRecommendationReq, nil, err)
+		errorResponse(c, err)
+	} else {
+		r.recordAudit(c, provider, response.Region, req.ClusterRecommendationReq, response.Recommendation, nil)
+		c.JSON(http.StatusOK, *response)
+	}
+}
+
+// validRegions validates the candidate regions of the provider, responding with bad request on the first invalid one
+func validRegions(c *gin.Context, provider string, regions []string) bool {
+	v := binding.Validator.Engine().(*validator.Validate)
+	for _, region := range regions {
+		regionData := newRegionData(provider, region)
+		if err := v.Struct(regionData); err != nil {
+			logger(c).Errorf("validation failed. err: %s", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    "bad_params",
+				"message": fmt.Sprintf("invalid region in request: %s", regionData.String()),
+				"params":  regionData,
+			})
+			return false
+		}
+	}
+	return true
+}
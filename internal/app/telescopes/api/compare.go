@@ -0,0 +1,126 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ComparisonReq encapsulates the recommendation request with the targets the recommendations are compared on
+type ComparisonReq struct {
+	// The providers and regions the recommendations are compared on
+	Targets []ComparisonTarget `json:"targets" binding:"required,min=1,max=10"`
+	// The url the comparison is posted to, the comparison is computed asynchronously if set
+	CallbackURL string `json:"callbackUrl,omitempty" binding:"omitempty,url"`
+	regionlessReq
+}
+
+// ComparisonTarget identifies a provider and region a cluster is recommended in,
+// invalid targets are reported in the results instead of failing the comparison
+type ComparisonTarget struct {
+	Provider string `json:"provider"`
+	Region   string `json:"region"`
+}
+
+// ComparisonResp holds the recommendation for each of the targets and the ranking of the targets
+type ComparisonResp struct {
+	// The recommendation or the error for each target, in the order of the targets
+	Results []BatchRecommendationResult `json:"results"`
+	// The targets with a recommendation, ranked by the total price of the recommendation
+	Ranking []TargetRank `json:"ranking"`
+}
+
+// TargetRank summarizes the recommendation for a target
+// swagger:model ComparisonRank
+type TargetRank struct {
+	Provider string `json:"provider"`
+	Region   string `json:"region"`
+	// Total hourly price of the recommended cluster
+	TotalPrice float64 `json:"totalPrice"`
+}
+
+// swagger:route POST /recommender/compare recommend recommendComparison
+//
+// Provides the recommended set of node pools for the same requirements on each of the given providers and regions,
+// together with the ranking of the targets by the total price of the recommendation.
+// If a callback url is given, a job is created and responded with right away, the comparison is posted to the callback
+// once it's computed and can be polled on the job status endpoint.
+//
+//     Consumes:
+//     - application/json
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Security:
+//
+//     Responses:
+//       200: ComparisonResponse
+//       202: JobResponse
+func (r *RouteHandler) recommendComparison(c *gin.Context) {
+	logger(c).Info("compare cluster recommendations")
+
+	var req ComparisonReq
+	if err := c.BindJSON(&req); err != nil {
+		logger(c).Errorf("failed to bind request body: %s", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "bad_params",
+			"message": "validation failed",
+			"cause":   validationMessage(err),
+			"details": validationDetails(err),
+		})
+		return
+	}
+
+	reqs := make([]BatchRecommendationReq, 0, len(req.Targets))
+	for _, target := range req.Targets {
+		reqs = append(reqs, BatchRecommendationReq{
+			Provider:                 target.Provider,
+			Region:                   target.Region,
+			ClusterRecommendationReq: req.ClusterRecommendationReq,
+		})
+	}
+	if req.CallbackURL != "" {
+		r.recommendComparisonAsync(c, reqs, req.CallbackURL)
+		return
+	}
+	results := r.recommendBatchItems(c.Request.Context(), c, reqs)
+
+	c.JSON(http.StatusOK, ComparisonResp{Results: results, Ranking: rankTargets(results)})
+}
+
+// rankTargets ranks the targets with a recommendation by the total price of the recommendation
+func rankTargets(results []BatchRecommendationResult) []TargetRank {
+	ranking := make([]TargetRank, 0, len(results))
+	for _, result := range results {
+		if result.Recommendation == nil {
+			continue
+		}
+		ranking = append(ranking, TargetRank{
+			Provider:   result.Provider,
+			Region:     result.Region,
+			TotalPrice: result.Recommendation.Accuracy.RecTotalPrice,
+		})
+	}
+	sort.SliceStable(ranking, func(i, j int) bool {
+		return ranking[i].TotalPrice < ranking[j].TotalPrice
+	})
+	return ranking
+}
@@ -0,0 +1,125 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteHandler_recommendComparison(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		check func(w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "invalid targets reported inline",
+			body: `{"targets": [{"provider": "gce", "region": "europe-west1"}, {"provider": "ec2", "region": "eu-west-1"}, {"provider": "ec2", "region": "us-east-1"}],
+				"sumCpu": 16, "sumMem": 64, "minNodes": 2, "maxNodes": 6, "onDemandPct": 50}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+				var resp ComparisonResp
+				assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp), "the response couldn't be parsed")
+
+				assert.Equal(t, 3, len(resp.Results))
+				assert.Equal(t, "gce", resp.Results[0].Provider)
+				assert.Equal(t, http.StatusBadRequest, resp.Results[0].Error.Status)
+				assert.NotNil(t, resp.Results[1].Recommendation, "the valid target should be recommended for")
+				assert.Equal(t, "eu-west-1", resp.Results[1].Recommendation.Region, "the recommendation should be self-describing")
+				assert.NotNil(t, resp.Results[1].Recommendation.Request, "the request should be echoed")
+				assert.Equal(t, "us-east-1", resp.Results[2].Region)
+				assert.Equal(t, http.StatusBadRequest, resp.Results[2].Error.Status)
+
+				assert.Equal(t, []TargetRank{{Provider: "ec2", Region: "eu-west-1", TotalPrice: resp.Results[1].Recommendation.Accuracy.RecTotalPrice}}, resp.Ranking)
+			},
+		},
+		{
+			name: "no targets",
+			body: `{"targets": [], "sumCpu": 16, "sumMem": 64, "minNodes": 2, "maxNodes": 6, "onDemandPct": 50}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+			},
+		},
+		{
+			name: "zones requested",
+			body: `{"targets": [{"provider": "ec2", "region": "eu-west-1"}], "zones": ["eu-west-1a"],
+				"sumCpu": 16, "sumMem": 64, "minNodes": 2, "maxNodes": 6, "onDemandPct": 50}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+			},
+		},
+		{
+			name: "excluded zones requested",
+			body: `{"targets": [{"provider": "ec2", "region": "eu-west-1"}], "excludeZones": ["eu-west-1a"],
+				"sumCpu": 16, "sumMem": 64, "minNodes": 2, "maxNodes": 6, "onDemandPct": 50}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+				assert.Contains(t, w.Body.String(), "excludeZones must be at most 0")
+			},
+		},
+		{
+			name: "invalid recommendation request",
+			body: `{"targets": [{"provider": "ec2", "region": "eu-west-1"}],
+				"sumCpu": 16, "sumMem": 64, "minNodes": 2, "maxNodes": 6, "onDemandPct": 150}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, w.Code, "the embedded request should be validated")
+				assert.Contains(t, w.Body.String(), "onDemandPct must be at most 100")
+			},
+		},
+	}
+
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := recommender.NewEngine(fs)
+	assert.Nil(t, err, "the engine couldn't be created")
+	assert.Nil(t, ConfigureValidator(fs), "the validator couldn't be configured")
+
+	gin.SetMode(gin.TestMode)
+	rh := NewRouteHandler(engine)
+	rh.SetRateLimiter(nil)
+	router := gin.New()
+	rh.ConfigureRoutes(router)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/recommender/compare", strings.NewReader(test.body)))
+			test.check(w)
+		})
+	}
+}
+
+func Test_rankTargets(t *testing.T) {
+	rec := func(price float64) *recommender.ClusterRecommendationResp {
+		return &recommender.ClusterRecommendationResp{Accuracy: recommender.ClusterRecommendationAccuracy{RecTotalPrice: price}}
+	}
+	results := []BatchRecommendationResult{
+		{Provider: "ec2", Region: "eu-west-1", Recommendation: rec(2)},
+		{Provider: "gce", Region: "europe-west1", Error: &BatchError{Status: http.StatusUnprocessableEntity}},
+		{Provider: "azure", Region: "westeurope", Recommendation: rec(1)},
+	}
+
+	assert.Equal(t, []TargetRank{
+		{Provider: "azure", Region: "westeurope", TotalPrice: 1},
+		{Provider: "ec2", Region: "eu-west-1", TotalPrice: 2},
+	}, rankTargets(results))
+}
@@ -0,0 +1,120 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultRegionsFromEnv parses the default regions of the providers from the comma separated provider=region pairs of
+// the environment, eg: ec2=eu-west-1,gce=europe-west1; the malformed pairs are skipped
+func defaultRegionsFromEnv() map[string]string {
+	regions := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("TELESCOPES_DEFAULT_REGIONS"), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" || strings.TrimSpace(kv[1]) == "" {
+			log.Warnf("TELESCOPES_DEFAULT_REGIONS holds an invalid provider=region pair: %s, it's skipped", pair)
+			continue
+		}
+		regions[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return regions
+}
+
+// SetDefaultRegions replaces the default regions of the providers configured by the environment, the cluster
+// recommendations without a region in the path are rejected for the providers without one
+func (r *RouteHandler) SetDefaultRegions(regions map[string]string) {
+	r.defaultRegions = regions
+}
+
+// CheckDefaultRegions logs a warning for the default regions not known on their provider, they're kept nevertheless
+// and the requests falling back to them are rejected like the ones for an unknown region in the path
+func (r *RouteHandler) CheckDefaultRegions() {
+	providers := make([]string, 0, len(r.defaultRegions))
+	for provider := range r.defaultRegions {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	for _, provider := range providers {
+		region := r.defaultRegions[provider]
+		known, err := r.regions.Regions(provider)
+		if err != nil {
+			log.WithError(err).Warnf("could not check the default region: %s, provider: %s", region, provider)
+			continue
+		}
+		if !contains(known, region) {
+			log.Warnf("the default region: %s is not known on provider: %s", region, provider)
+			continue
+		}
+		log.Infof("the default region of provider: %s is %s", provider, region)
+	}
+}
+
+// swagger:route POST /recommender/:provider/cluster recommend recommendClusterInDefaultRegion
+//
+// Provides a recommended set of node pools on a given provider in its default region, configured with the
+// TELESCOPES_DEFAULT_REGIONS environment variable.
+//
+//     Consumes:
+//     - application/json
+//
+//     Produces:
+//     - application/json
+//     - application/yaml
+//
+//     Schemes: http
+//
+//     Security:
+//
+//     Responses:
+//       200: RecommendationResponse
+func (r *RouteHandler) recommendClusterInDefaultRegion(c *gin.Context) {
+	provider := c.Param(providerParam)
+	region, ok := r.defaultRegions[provider]
+	if !ok {
+		logger(c).Errorf("no default region, provider: %s", provider)
+		respond(c, http.StatusNotFound, gin.H{
+			"code":    recommender.RegionNotFound,
+			"message": fmt.Sprintf("no default region is configured for provider [%s], the region must be set in the path", provider),
+		})
+		return
+	}
+	// the handlers of the regional route read the region from the path
+	for i := range c.Params {
+		if c.Params[i].Key == regionParam {
+			c.Params[i].Value = region
+		}
+	}
+
+	if ValidateRegionData(r.regions)(c); c.IsAborted() {
+		return
+	}
+	start := time.Now()
+	runHandlers(c, gin.HandlersChain{ValidateRecommendationReq(), r.recommendClusterSetup})
+	recordRecommendation(c, start)
+}
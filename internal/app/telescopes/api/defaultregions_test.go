@@ -0,0 +1,131 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_defaultRegionsFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		regions map[string]string
+	}{
+		{
+			name:    "not set",
+			regions: map[string]string{},
+		},
+		{
+			name:    "regions of the providers",
+			value:   "ec2=eu-west-1, gce = europe-west1",
+			regions: map[string]string{"ec2": "eu-west-1", "gce": "europe-west1"},
+		},
+		{
+			name:    "malformed pairs skipped",
+			value:   "ec2,gce=,=eu-west-1,azure=westeurope",
+			regions: map[string]string{"azure": "westeurope"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Setenv("TELESCOPES_DEFAULT_REGIONS", test.value)
+			defer os.Unsetenv("TELESCOPES_DEFAULT_REGIONS")
+
+			assert.Equal(t, test.regions, defaultRegionsFromEnv())
+		})
+	}
+}
+
+func TestRouteHandler_recommendClusterInDefaultRegion(t *testing.T) {
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := recommender.NewEngine(fs)
+	assert.Nil(t, err, "the engine couldn't be created")
+	assert.Nil(t, ConfigureValidator(fs), "the validator couldn't be configured")
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		defaults map[string]string
+		body     string
+		check    func(w *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "recommended for the default region",
+			defaults: map[string]string{"ec2": "eu-west-1"},
+			body:     `{"sumCpu": 8, "sumMem": 32, "minNodes": 1, "maxNodes": 4}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+				var resp recommender.ClusterRecommendationResp
+				assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				assert.Equal(t, "ec2", resp.Provider)
+				assert.Equal(t, "eu-west-1", resp.Region)
+				assert.NotEmpty(t, resp.NodePools)
+			},
+		},
+		{
+			name:     "request validated as on the regional route",
+			defaults: map[string]string{"ec2": "eu-west-1"},
+			body:     `{"sumCpu": -1, "sumMem": 32, "minNodes": 1, "maxNodes": 4}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+				assert.Contains(t, w.Body.String(), "bad_params")
+			},
+		},
+		{
+			name:     "unknown default region",
+			defaults: map[string]string{"ec2": "eu-north-9"},
+			body:     `{"sumCpu": 8, "sumMem": 32, "minNodes": 1, "maxNodes": 4}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, w.Code)
+				assert.Contains(t, w.Body.String(), recommender.RegionNotFound)
+				assert.Contains(t, w.Body.String(), "eu-west-1", "the known regions should be listed")
+			},
+		},
+		{
+			name:     "no default region",
+			defaults: map[string]string{"gce": "europe-west1"},
+			body:     `{"sumCpu": 8, "sumMem": 32, "minNodes": 1, "maxNodes": 4}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, w.Code)
+				assert.Contains(t, w.Body.String(), "no default region is configured for provider [ec2]")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rh := NewRouteHandler(engine)
+			rh.SetRateLimiter(nil)
+			rh.SetDefaultRegions(test.defaults)
+			rh.CheckDefaultRegions()
+			router := gin.New()
+			rh.ConfigureRoutes(router)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/recommender/ec2/cluster", strings.NewReader(test.body)))
+			test.check(w)
+		})
+	}
+}
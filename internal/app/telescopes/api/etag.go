@@ -0,0 +1,79 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/gin-gonic/gin"
+)
+
+// respondTagged responds with the body in the negotiated format like respond, tagged with an ETag computed over the
+// serialized body and the version of the prices; if the If-None-Match header of the request holds the tag, 304 is
+// responded without the body
+func respondTagged(c *gin.Context, body interface{}, version string) {
+	contentType := gin.MIMEJSON + "; charset=utf-8"
+	marshal := json.Marshal
+	if c.NegotiateFormat(gin.MIMEJSON, mimeYAML) == mimeYAML {
+		contentType = mimeYAML + "; charset=utf-8"
+		marshal = yaml.Marshal
+	}
+
+	b, err := marshal(body)
+	if err != nil {
+		logger(c).WithError(err).Error("could not marshal the response")
+		c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": err.Error()})
+		return
+	}
+
+	etag := entityTag(version, contentType, b)
+	c.Header("ETag", etag)
+	// the representation depends on the negotiated format
+	c.Writer.Header().Add("Vary", "Accept")
+	if noneMatch(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, contentType, b)
+}
+
+// entityTag computes the quoted entity tag of the serialized body; the version of the prices is part of it so that the
+// tags change with the prices even if the body doesn't
+func entityTag(version string, contentType string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(version))
+	h.Write([]byte{0})
+	h.Write([]byte(contentType))
+	h.Write([]byte{0})
+	h.Write(body)
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// noneMatch reports whether the If-None-Match header holds the entity tag, or * matching any tag;
+// the tags are compared weakly, as required for If-None-Match
+func noneMatch(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_noneMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		match  bool
+	}{
+		{name: "no header", header: "", match: false},
+		{name: "same tag", header: `"abc"`, match: true},
+		{name: "other tag", header: `"abd"`, match: false},
+		{name: "tag in a list", header: `"xyz", "abc"`, match: true},
+		{name: "weak tag", header: `W/"abc"`, match: true},
+		{name: "any tag", header: `*`, match: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.match, noneMatch(test.header, `"abc"`))
+		})
+	}
+}
+
+func Test_entityTag(t *testing.T) {
+	body := []byte(`{"provider":"ec2"}`)
+	tag := entityTag("v1", gin.MIMEJSON, body)
+
+	assert.Equal(t, tag, entityTag("v1", gin.MIMEJSON, body), "the tag should be stable")
+	assert.NotEqual(t, tag, entityTag("v2", gin.MIMEJSON, body), "the tag should change with the prices")
+	assert.NotEqual(t, tag, entityTag("v1", mimeYAML, body), "the tag should change with the format")
+	assert.NotEqual(t, tag, entityTag("v1", gin.MIMEJSON, []byte(`{"provider":"gce"}`)), "the tag should change with the body")
+}
+
+func TestRouteHandler_recommendClusterSetup_etag(t *testing.T) {
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := recommender.NewEngine(fs)
+	assert.Nil(t, err, "the engine couldn't be created")
+	assert.Nil(t, ConfigureValidator(fs), "the validator couldn't be configured")
+
+	gin.SetMode(gin.TestMode)
+	rh := NewRouteHandler(engine)
+	rh.SetRateLimiter(nil)
+	router := gin.New()
+	rh.ConfigureRoutes(router)
+
+	recommend := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/recommender/ec2/eu-west-1/cluster/",
+			strings.NewReader(`{"sumCpu": 16, "sumMem": 64, "minNodes": 2, "maxNodes": 6, "onDemandPct": 50}`))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := recommend(nil)
+	assert.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	assert.NotEmpty(t, etag, "the response should be tagged")
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		check   func(w *httptest.ResponseRecorder)
+	}{
+		{
+			name:    "unchanged recommendation",
+			headers: map[string]string{"If-None-Match": etag},
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotModified, w.Code)
+				assert.Empty(t, w.Body.String(), "the body should be omitted")
+				assert.Equal(t, etag, w.Header().Get("ETag"))
+			},
+		},
+		{
+			name:    "stale tag",
+			headers: map[string]string{"If-None-Match": `"stale"`},
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Equal(t, first.Body.String(), w.Body.String())
+			},
+		},
+		{
+			name:    "tag of another format",
+			headers: map[string]string{"If-None-Match": etag, "Accept": mimeYAML},
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.NotEqual(t, etag, w.Header().Get("ETag"))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(recommend(test.headers))
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+)
+
+// FailoverReq encapsulates the recommendation request with the candidate regions in their order of priority
+type FailoverReq struct {
+	// Candidate regions of the recommended cluster, the primary one first
+	Regions []string `json:"regions" binding:"required,min=1,max=10"`
+	regionlessReq
+}
+
+// swagger:route POST /recommender/:provider/failover recommend recommendFailover
+//
+// Provides the recommended set of node pools in each of the candidate regions of the given provider, in the order of
+// the regions, with the feasibility and the total price of the recommendation in each of them.
+//
+//     Consumes:
+//     - application/json
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Security:
+//
+//     Responses:
+//       200: FailoverResponse
+func (r *RouteHandler) recommendFailover(c *gin.Context) {
+	provider := c.Param(providerParam)
+	logger(c).Infof("recommend failover regions for provider: %s", provider)
+
+	var req FailoverReq
+	if err := c.BindJSON(&req); err != nil {
+		logger(c).Errorf("failed to bind request body: %s", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "bad_params",
+			"message": "validation failed",
+			"cause":   validationMessage(err),
+			"details": validationDetails(err),
+		})
+		return
+	}
+
+	if !validRegions(c, provider, req.Regions) {
+		return
+	}
+
+	if response, err := r.engine.RecommendFailover(c.Request.Context(), provider, req.Regions, req.ClusterRecommendationReq); err != nil {
+		logger(c).WithError(err).Errorf("could not recommend failover regions for provider: %s", provider)
+		r.recordAudit(c, provider, strings.Join(req.Regions, ","), req.ClusterRecommendationReq, nil, err)
+		errorResponse(c, err)
+	} else {
+		if response.Region == "" {
+			// the ranking is returned with the reasons, the request is audited as failed
+			err = recommender.NewError(recommender.NoViableInstances, "could not recommend cluster with the requested resources in any of the regions %v", req.Regions)
+			r.recordAudit(c, provider, strings.Join(req.Regions, ","), req.ClusterRecommendationReq, nil, err)
+			c.JSON(http.StatusOK, *response)
+			return
+		}
+		var primary *recommender.ClusterRecommendationResp
+		for _, region := range response.Regions {
+			if region.Region == response.Region {
+				primary = region.Recommendation
+			}
+		}
+		r.recordAudit(c, provider, response.Region, req.ClusterRecommendationReq, primary, nil)
+		c.JSON(http.StatusOK, *response)
+	}
+}
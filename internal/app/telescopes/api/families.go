@@ -0,0 +1,63 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import "github.com/banzaicloud/telescopes/pkg/recommender"
+
+// the query parameter selecting the view of the node pools grouped by instance family
+const groupByFamilyParam = "groupByFamily"
+
+// FamilyGroup is the node pools of an instance family with their subtotals
+type FamilyGroup struct {
+	// The recommended node pools of the instance types of the family
+	NodePools []recommender.NodePool `json:"nodePools"`
+	// Number of the nodes of the family
+	Nodes int `json:"nodes"`
+	// Number of the cpus of the family
+	Cpu float64 `json:"cpu"`
+	// The memory of the family (GB)
+	Mem float64 `json:"memory"`
+	// The hourly price of the nodes of the family
+	Price float64 `json:"price"`
+}
+
+// familyGroupedResp is the view of the recommendation with the node pools listed under their instance families
+type familyGroupedResp struct {
+	recommender.ClusterRecommendationResp
+	// the node pools are only listed under their families, the field shadows the one of the embedded recommendation
+	NodePools *struct{} `json:"nodePools,omitempty"`
+	// The recommended node pools by instance family
+	Families map[string]*FamilyGroup `json:"families"`
+}
+
+// groupByFamily lists the node pools of the recommendation under their instance families with the subtotals of the
+// families, the recommendation is not changed
+func groupByFamily(resp recommender.ClusterRecommendationResp) familyGroupedResp {
+	families := make(map[string]*FamilyGroup)
+	for _, np := range resp.NodePools {
+		family := recommender.InstanceFamily(resp.Provider, np.VmType.Type)
+		group, ok := families[family]
+		if !ok {
+			group = &FamilyGroup{NodePools: []recommender.NodePool{}}
+			families[family] = group
+		}
+		group.NodePools = append(group.NodePools, np)
+		group.Nodes += np.SumNodes
+		group.Cpu += float64(np.SumNodes) * np.VmType.Cpus
+		group.Mem += float64(np.SumNodes) * np.VmType.Mem
+		group.Price += np.PoolPrice
+	}
+	return familyGroupedResp{ClusterRecommendationResp: resp, Families: families}
+}
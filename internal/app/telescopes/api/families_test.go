@@ -0,0 +1,127 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_groupByFamily(t *testing.T) {
+	resp := recommender.ClusterRecommendationResp{
+		Provider: "ec2",
+		NodePools: []recommender.NodePool{
+			{VmType: recommender.VirtualMachine{Type: "m5.xlarge", Cpus: 4, Mem: 16}, SumNodes: 3, VmClass: "regular", PoolPrice: 0.6},
+			{VmType: recommender.VirtualMachine{Type: "m5.2xlarge", Cpus: 8, Mem: 32}, SumNodes: 2, VmClass: "spot", PoolPrice: 0.3},
+			{VmType: recommender.VirtualMachine{Type: "c5.xlarge", Cpus: 4, Mem: 8}, SumNodes: 1, VmClass: "spot", PoolPrice: 0.07},
+		},
+	}
+
+	grouped := groupByFamily(resp)
+	assert.Equal(t, 2, len(grouped.Families))
+	m5 := grouped.Families["m5"]
+	assert.Equal(t, 2, len(m5.NodePools))
+	assert.Equal(t, 5, m5.Nodes)
+	assert.Equal(t, 28.0, m5.Cpu)
+	assert.Equal(t, 112.0, m5.Mem)
+	assert.InDelta(t, 0.9, m5.Price, 1e-9)
+	c5 := grouped.Families["c5"]
+	assert.Equal(t, 1, c5.Nodes)
+	assert.Equal(t, "c5.xlarge", c5.NodePools[0].VmType.Type)
+	assert.Equal(t, 3, len(resp.NodePools), "the recommendation should not be changed")
+
+	b, err := json.Marshal(grouped)
+	assert.Nil(t, err, "the view couldn't be marshalled")
+	var view map[string]interface{}
+	assert.Nil(t, json.Unmarshal(b, &view))
+	assert.NotContains(t, view, "nodePools", "the node pools should only be listed under their families")
+	assert.Equal(t, "ec2", view["provider"])
+}
+
+func TestRouteHandler_recommendClusterSetup_groupByFamily(t *testing.T) {
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := recommender.NewEngine(fs)
+	assert.Nil(t, err, "the engine couldn't be created")
+	assert.Nil(t, ConfigureValidator(fs), "the validator couldn't be configured")
+
+	gin.SetMode(gin.TestMode)
+	rh := NewRouteHandler(engine)
+	rh.SetRateLimiter(nil)
+	router := gin.New()
+	rh.ConfigureRoutes(router)
+
+	tests := []struct {
+		name  string
+		query string
+		check func(w *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "node pools grouped by family",
+			query: "?groupByFamily=true",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+				var view struct {
+					NodePools []recommender.NodePool                    `json:"nodePools"`
+					Families  map[string]FamilyGroup                    `json:"families"`
+					Accuracy  recommender.ClusterRecommendationAccuracy `json:"accuracy"`
+				}
+				assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &view))
+				assert.Nil(t, view.NodePools)
+				var nodes int
+				var price float64
+				for family, group := range view.Families {
+					for _, np := range group.NodePools {
+						assert.True(t, strings.HasPrefix(np.VmType.Type, family+"."), "[%s] is not of the family [%s]", np.VmType.Type, family)
+					}
+					nodes += group.Nodes
+					price += group.Price
+				}
+				assert.Equal(t, view.Accuracy.RecNodes, nodes, "the subtotals should add up to the totals")
+				assert.InDelta(t, view.Accuracy.RecTotalPrice, price, 1e-9)
+			},
+		},
+		{
+			name: "node pools not grouped by default",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.NotContains(t, w.Body.String(), `"families"`)
+			},
+		},
+		{
+			name:  "invalid flag",
+			query: "?groupByFamily=maybe",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+				assert.Contains(t, w.Body.String(), "bad_params")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/recommender/ec2/eu-west-1/cluster/"+test.query,
+				strings.NewReader(`{"sumCpu": 16, "sumMem": 64, "minNodes": 2, "maxNodes": 6, "onDemandPct": 50}`)))
+			test.check(w)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+)
+
+// swagger:route POST /recommender/:provider/:region/validate recommend validateRecommendation
+//
+// Checks whether a recommendation request is valid and feasible on a given provider in a specific region, without
+// computing the recommendation. The failed field validations are reported as reasons like the infeasible requirements.
+//
+//     Consumes:
+//     - application/json
+//
+//     Produces:
+//     - application/json
+//     - application/yaml
+//
+//     Schemes: http
+//
+//     Security:
+//
+//     Responses:
+//       200: FeasibilityResponse
+func (r *RouteHandler) validateRecommendation(c *gin.Context) {
+	logger(c).Info("validate recommendation request")
+	provider := c.Param(providerParam)
+	region := c.Param(regionParam)
+
+	req, err := bindRecommendationReq(c)
+	if err != nil {
+		details := validationDetails(err)
+		if details == nil {
+			// the body couldn't be parsed, there are no fields to report on
+			logger(c).Errorf("failed to bind request body: %s", err.Error())
+			respond(c, http.StatusBadRequest, gin.H{"code": "bad_params", "message": "validation failed", "cause": validationMessage(err)})
+			return
+		}
+		f := recommender.Feasibility{}
+		for _, d := range details {
+			f.Reasons = append(f.Reasons, d.Message)
+		}
+		respond(c, http.StatusOK, f)
+		return
+	}
+
+	f, err := r.engine.CheckFeasibility(c.Request.Context(), provider, region, req.ClusterRecommendationReq)
+	if err != nil {
+		logger(c).WithError(err).Errorf("could not check feasibility for provider: %s, region: %s", provider, region)
+		errorResponse(c, err)
+		return
+	}
+	respond(c, http.StatusOK, *f)
+}
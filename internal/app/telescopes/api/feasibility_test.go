@@ -0,0 +1,107 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteHandler_validateRecommendation(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		body  string
+		check func(w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "feasible request",
+			path: "/api/v1/recommender/ec2/eu-west-1/validate",
+			body: `{"sumCpu": 16, "sumMem": 64, "minNodes": 1, "maxNodes": 8, "onDemandPct": 50}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+				var f recommender.Feasibility
+				assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &f), "the response couldn't be parsed")
+				assert.True(t, f.Valid, "the request should be valid")
+			},
+		},
+		{
+			name: "invalid fields reported as reasons",
+			path: "/api/v1/recommender/ec2/eu-west-1/validate",
+			body: `{"sumCpu": 16, "sumMem": 64, "minNodes": 8, "maxNodes": 1, "onDemandPct": 150}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+				var f recommender.Feasibility
+				assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &f), "the response couldn't be parsed")
+				assert.False(t, f.Valid, "the request shouldn't be valid")
+				assert.Equal(t, []string{"minNodes must be less than or equal to maxNodes", "onDemandPct must be at most 100"}, f.Reasons)
+			},
+		},
+		{
+			name: "infeasible request",
+			path: "/api/v1/recommender/ec2/eu-west-1/validate",
+			body: `{"sumCpu": 16, "sumMem": 64, "sumGpu": 1, "minNodes": 1, "maxNodes": 8, "onDemandPct": 50}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+				var f recommender.Feasibility
+				assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &f), "the response couldn't be parsed")
+				assert.False(t, f.Valid, "the request shouldn't be feasible")
+				assert.Equal(t, 1, len(f.Reasons))
+			},
+		},
+		{
+			name: "malformed body",
+			path: "/api/v1/recommender/ec2/eu-west-1/validate",
+			body: `{"sumCpu": "many"}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+			},
+		},
+		{
+			name: "unknown region",
+			path: "/api/v1/recommender/ec2/us-east-1/validate",
+			body: `{"sumCpu": 16, "sumMem": 64, "minNodes": 1, "maxNodes": 8, "onDemandPct": 50}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, w.Code)
+			},
+		},
+	}
+
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := recommender.NewEngine(fs)
+	assert.Nil(t, err, "the engine couldn't be created")
+	assert.Nil(t, ConfigureValidator(fs), "the validator couldn't be configured")
+
+	gin.SetMode(gin.TestMode)
+	rh := NewRouteHandler(engine)
+	rh.SetRateLimiter(nil)
+	router := gin.New()
+	rh.ConfigureRoutes(router)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(test.body)))
+			test.check(w)
+		})
+	}
+}
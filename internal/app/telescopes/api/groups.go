@@ -0,0 +1,123 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// swagger:route POST /recommender/:provider/:region/cluster/groups recommend recommendClusterGroups
+//
+// Provides the recommended set of node pools for each node group of a cluster on a given provider in a specific region,
+// together with the combined price of the groups.
+//
+//     Consumes:
+//     - application/json
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Security:
+//
+//     Responses:
+//       200: ClusterGroupsResponse
+func (r *RouteHandler) recommendClusterGroups(c *gin.Context) {
+	logger(c).Info("recommend cluster node groups")
+	provider := c.Param(providerParam)
+	region := c.Param(regionParam)
+
+	var req recommender.ClusterGroupsReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger(c).Errorf("failed to bind request body: %s", err.Error())
+		respond(c, http.StatusBadRequest, gin.H{
+			"code":    "bad_params",
+			"message": "validation failed",
+			"cause":   validationMessage(err),
+			"details": validationDetails(err),
+		})
+		return
+	}
+	if cause, details := validateGroups(provider, region, req); cause != "" {
+		logger(c).Errorf("validation failed. err: %s", cause)
+		respond(c, http.StatusBadRequest, gin.H{
+			"code":    "bad_params",
+			"message": "validation failed",
+			"cause":   cause,
+			"details": details,
+		})
+		return
+	}
+
+	response, err := r.engine.RecommendClusterGroups(c.Request.Context(), provider, region, req)
+	groupsErr, _ := err.(*recommender.ClusterGroupsError)
+	for name, group := range req.Groups {
+		switch {
+		case groupsErr != nil && groupsErr.Groups[name] != nil:
+			r.recordAudit(c, provider, region, group, nil, groupsErr.Groups[name])
+		case response != nil:
+			r.recordAudit(c, provider, region, group, response.Groups[name], nil)
+		default:
+			r.recordAudit(c, provider, region, group, nil, err)
+		}
+	}
+	if err != nil {
+		logger(c).WithError(err).Errorf("could not recommend cluster node groups for provider: %s, region: %s", provider, region)
+		errorResponse(c, err)
+		return
+	}
+	respond(c, http.StatusOK, *response)
+}
+
+// validateGroups validates each node group like the requests of the cluster recommendation endpoint, including the
+// zones of the region; the failed validations name the group, the cause is empty if the groups are valid
+func validateGroups(provider string, region string, req recommender.ClusterGroupsReq) (string, []FieldError) {
+	names := make([]string, 0, len(req.Groups))
+	for name := range req.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var (
+		causes  []string
+		details []FieldError
+	)
+	for _, name := range names {
+		err := binding.Validator.ValidateStruct(RequestWrapper{ClusterRecommendationReq: req.Groups[name], Provider: provider, Region: region})
+		if err == nil {
+			continue
+		}
+		prefix := fmt.Sprintf("groups[%s].", name)
+		groupDetails := validationDetails(err)
+		if groupDetails == nil {
+			causes = append(causes, prefix+err.Error())
+			continue
+		}
+		for _, d := range groupDetails {
+			d.Field, d.Message = prefix+d.Field, prefix+d.Message
+			causes = append(causes, d.Message)
+			details = append(details, d)
+		}
+	}
+	return strings.Join(causes, "; "), details
+}
@@ -0,0 +1,125 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteHandler_recommendClusterGroups(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		check func(w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "a recommendation per group",
+			body: `{"groups": {"web": {"sumCpu": 8, "sumMem": 16, "minNodes": 1, "maxNodes": 4, "onDemandPct": 100},
+				"batch": {"sumCpu": 16, "sumMem": 32, "minNodes": 1, "maxNodes": 8, "onDemandPct": 0, "zones": ["eu-west-1a"]}}}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+				var resp recommender.ClusterGroupsResp
+				assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp), "the response couldn't be parsed")
+				assert.Equal(t, 2, len(resp.Groups))
+				assert.InDelta(t, resp.Groups["web"].Accuracy.RecTotalPrice+resp.Groups["batch"].Accuracy.RecTotalPrice, resp.RecTotalPrice, 1e-9)
+			},
+		},
+		{
+			name: "no groups",
+			body: `{"groups": {}}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+			},
+		},
+		{
+			name: "invalid group",
+			body: `{"groups": {"web": {"sumCpu": 8, "sumMem": 16, "minNodes": 4, "maxNodes": 1}}}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+				assert.Equal(t, "groups[web].minNodes must be less than or equal to maxNodes", validationCause(t, w))
+			},
+		},
+		{
+			name: "zone of another region",
+			body: `{"groups": {"web": {"sumCpu": 8, "sumMem": 16, "minNodes": 1, "maxNodes": 4},
+				"batch": {"sumCpu": 8, "sumMem": 16, "minNodes": 1, "maxNodes": 4, "zones": ["us-east-1a"], "excludeZones": ["eu-west-1z"]}}}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+				assert.Equal(t, "groups[batch].excludeZones[0] is invalid: eu-west-1z; groups[batch].zones[0] is invalid: us-east-1a", validationCause(t, w))
+			},
+		},
+		{
+			name: "invalid groups",
+			body: `{"groups": {"web": {"sumCpu": 8, "sumMem": 16, "minNodes": 4, "maxNodes": 1},
+				"batch": {"sumCpu": -1, "sumMem": 16, "minNodes": 1, "maxNodes": 4}}}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+				var resp struct {
+					Details []FieldError `json:"details"`
+				}
+				assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp), "the response couldn't be parsed")
+				var fields []string
+				for _, d := range resp.Details {
+					fields = append(fields, d.Field)
+				}
+				assert.Equal(t, []string{"groups[batch].sumCpu", "groups[web].minNodes"}, fields)
+			},
+		},
+		{
+			name: "unavailable resources of a group",
+			body: `{"groups": {"ml": {"sumCpu": 8, "sumMem": 16, "sumGpu": 1, "minNodes": 1, "maxNodes": 4}}}`,
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+				assert.Contains(t, w.Body.String(), "node group [ml]")
+			},
+		},
+	}
+
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := recommender.NewEngine(fs)
+	assert.Nil(t, err, "the engine couldn't be created")
+	assert.Nil(t, ConfigureValidator(fs), "the validator couldn't be configured")
+
+	gin.SetMode(gin.TestMode)
+	rh := NewRouteHandler(engine)
+	rh.SetRateLimiter(nil)
+	router := gin.New()
+	rh.ConfigureRoutes(router)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/recommender/ec2/eu-west-1/cluster/groups", strings.NewReader(test.body)))
+			test.check(w)
+		})
+	}
+}
+
+// validationCause returns the cause of the rejected request
+func validationCause(t *testing.T, w *httptest.ResponseRecorder) string {
+	var resp struct {
+		Cause string `json:"cause"`
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp), "the response couldn't be parsed")
+	return resp.Cause
+}
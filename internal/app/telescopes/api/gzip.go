@@ -0,0 +1,111 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// the minimum size of the compressed responses, smaller ones are not worth the overhead
+const gzipMinSize = 1024
+
+// gzipWriter buffers the response body so that it can be decided whether to compress it once it's complete
+type gzipWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	return w.buf.Write(b)
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.buf.WriteString(s)
+}
+
+// flush writes the buffered body to the wrapped writer, compressed if it's large enough and not encoded yet
+func (w *gzipWriter) flush() {
+	h := w.ResponseWriter.Header()
+	h.Add("Vary", "Accept-Encoding")
+	if w.buf.Len() < gzipMinSize || h.Get("Content-Encoding") != "" {
+		if _, err := w.ResponseWriter.Write(w.buf.Bytes()); err != nil {
+			log.WithError(err).Warn("could not write the response")
+		}
+		return
+	}
+
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	gz := gzip.NewWriter(w.ResponseWriter)
+	if _, err := gz.Write(w.buf.Bytes()); err != nil {
+		log.WithError(err).Warn("could not write the compressed response")
+	}
+	if err := gz.Close(); err != nil {
+		log.WithError(err).Warn("could not write the compressed response")
+	}
+}
+
+// Gzip middleware compresses the responses of at least gzipMinSize bytes if the client accepts the gzip encoding,
+// responses already encoded by the handler (eg. the metrics) are left untouched
+func Gzip() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		w := &gzipWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		w.flush()
+	}
+}
+
+// acceptsGzip returns true if the Accept-Encoding header accepts the gzip encoding, explicitly or with the * wildcard,
+// with a non-zero quality value
+func acceptsGzip(header string) bool {
+	gzipQ, anyQ := -1.0, -1.0
+	for _, token := range strings.Split(header, ",") {
+		parts := strings.Split(token, ";")
+		q := 1.0
+		for _, param := range parts[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.ToLower(strings.TrimSpace(kv[0])) == "q" {
+				v, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+				if err != nil {
+					v = 0
+				}
+				q = v
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(parts[0])) {
+		case "gzip", "x-gzip":
+			gzipQ = q
+		case "*":
+			anyQ = q
+		}
+	}
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return anyQ > 0
+}
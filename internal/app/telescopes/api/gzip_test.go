@@ -0,0 +1,138 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header  string
+		accepts bool
+	}{
+		{header: "", accepts: false},
+		{header: "gzip", accepts: true},
+		{header: "deflate, gzip", accepts: true},
+		{header: "GZIP;q=0.5", accepts: true},
+		{header: "gzip;q=0", accepts: false},
+		{header: "gzip; q=0.0, deflate", accepts: false},
+		{header: "gzip;q=0.001", accepts: true},
+		{header: "*", accepts: true},
+		{header: "*;q=0", accepts: false},
+		{header: "gzip;q=0, *", accepts: false},
+		{header: "x-gzip", accepts: true},
+		{header: "identity, deflate", accepts: false},
+		{header: "gzip;q=invalid", accepts: false},
+	}
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			assert.Equal(t, test.accepts, acceptsGzip(test.header))
+		})
+	}
+}
+
+func TestGzip(t *testing.T) {
+	large := strings.Repeat("node pool ", 200)
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		check          func(w *httptest.ResponseRecorder)
+	}{
+		{
+			name:           "large response compressed",
+			path:           "/large",
+			acceptEncoding: "gzip, deflate",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+				assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+				assert.True(t, w.Body.Len() < len(large), "the response should be compressed")
+
+				gz, err := gzip.NewReader(w.Body)
+				assert.Nil(t, err, "the response should be gzipped")
+				body, err := ioutil.ReadAll(gz)
+				assert.Nil(t, err, "the response should be decompressed")
+				assert.Equal(t, `"`+large+`"`, string(body))
+			},
+		},
+		{
+			name:           "tiny response not compressed",
+			path:           "/status",
+			acceptEncoding: "gzip",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, "", w.Header().Get("Content-Encoding"))
+				assert.Equal(t, `"ok"`, w.Body.String())
+			},
+		},
+		{
+			name:           "response not compressed if gzip is refused",
+			path:           "/large",
+			acceptEncoding: "gzip;q=0, deflate",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, "", w.Header().Get("Content-Encoding"))
+				assert.Equal(t, `"`+large+`"`, w.Body.String())
+			},
+		},
+		{
+			name:           "response compressed if any encoding is accepted",
+			path:           "/large",
+			acceptEncoding: "deflate;q=0.5, *",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+			},
+		},
+		{
+			name: "response not compressed without accepting gzip",
+			path: "/large",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, "", w.Header().Get("Content-Encoding"))
+				assert.Equal(t, `"`+large+`"`, w.Body.String())
+			},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Gzip())
+	router.GET("/large", func(c *gin.Context) {
+		c.JSON(http.StatusOK, large)
+	})
+	router.GET("/status", func(c *gin.Context) {
+		c.JSON(http.StatusOK, "ok")
+	})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if test.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", test.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			test.check(w)
+		})
+	}
+}
@@ -0,0 +1,451 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// the default time the jobs are kept for after their last update
+	defaultJobTTL = time.Hour
+	// the default number of attempts of delivering the result of a job to the callback
+	defaultCallbackAttempts = 3
+	// the default delay before the second attempt of a delivery, doubled for every further attempt
+	defaultCallbackDelay = time.Second
+	// the time limit of a single delivery attempt
+	callbackTimeout = 10 * time.Second
+	// the default number of jobs running at the same time, the comparisons over the limit are rejected
+	defaultMaxJobs = 10
+	// the default time limit of computing the comparison of a job
+	defaultJobTimeout = 5 * time.Minute
+	// the seconds the clients are asked to wait before retrying a comparison rejected for too many running jobs
+	jobRetryAfter = 10
+)
+
+// the networks of the internal addresses besides the loopback and link-local ones, the results aren't delivered to
+// them unless the host of the callback is allowed
+var internalNetworks = parseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7")
+
+// the statuses of the jobs
+const (
+	JobRunning   = "running"
+	JobCompleted = "completed"
+)
+
+// the statuses of the delivery of the job results
+const (
+	CallbackPending   = "pending"
+	CallbackDelivered = "delivered"
+	CallbackFailed    = "failed"
+)
+
+// Job describes an asynchronous comparison, it's both the response of the job status endpoint and the body posted to the callback
+type Job struct {
+	ID      string    `json:"id"`
+	Status  string    `json:"status"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+	// The comparison, set once the job is completed
+	Result *ComparisonResp `json:"result,omitempty"`
+	// The url the result is posted to
+	CallbackURL string `json:"callbackUrl"`
+	// The status of the delivery of the result to the callback
+	CallbackStatus string `json:"callbackStatus"`
+	// The number of delivery attempts so far
+	CallbackAttempts int `json:"callbackAttempts,omitempty"`
+	// The reason the last delivery attempt failed
+	CallbackError string `json:"callbackError,omitempty"`
+}
+
+// jobEntry holds a job with its expiration time
+type jobEntry struct {
+	job     Job
+	expires time.Time
+}
+
+// JobStore keeps the asynchronous jobs in memory, the jobs expire after not being updated for the given time
+type JobStore struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	jobs map[string]jobEntry
+	now  func() time.Time
+}
+
+// NewJobStore creates an in-memory store keeping the jobs for the given time after their last update
+func NewJobStore(ttl time.Duration) *JobStore {
+	return &JobStore{
+		ttl:  ttl,
+		jobs: make(map[string]jobEntry),
+		now:  time.Now,
+	}
+}
+
+// create stores a new running job delivering its result to the callback
+func (s *JobStore) create(callbackURL string) (Job, error) {
+	id := newRequestID()
+	if id == "" {
+		return Job{}, errors.New("could not generate job id")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	// the expired jobs are purged instead of running a janitor
+	for id, entry := range s.jobs {
+		if !now.Before(entry.expires) {
+			delete(s.jobs, id)
+		}
+	}
+	job := Job{
+		ID:             id,
+		Status:         JobRunning,
+		Created:        now.UTC(),
+		Updated:        now.UTC(),
+		CallbackURL:    callbackURL,
+		CallbackStatus: CallbackPending,
+	}
+	s.jobs[id] = jobEntry{job: job, expires: now.Add(s.ttl)}
+	return job, nil
+}
+
+// Get returns the job with the id, false if it's not known or already expired
+func (s *JobStore) Get(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.jobs[id]
+	if !ok || !s.now().Before(entry.expires) {
+		return Job{}, false
+	}
+	return entry.job, true
+}
+
+// update applies the change to the job and returns the updated job, the expiration of the job is extended
+func (s *JobStore) update(id string, change func(job *Job)) Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	entry := s.jobs[id]
+	change(&entry.job)
+	entry.job.Updated = now.UTC()
+	entry.expires = now.Add(s.ttl)
+	s.jobs[id] = entry
+	return entry.job
+}
+
+// CallbackClient posts the results of the jobs to their callbacks, retrying the failed deliveries with exponential backoff;
+// the results are only delivered to public addresses unless the host of the callback is allowed
+type CallbackClient struct {
+	client   *http.Client
+	attempts int
+	delay    time.Duration
+	// the hosts the results are delivered to even if they resolve to internal addresses
+	allowedHosts map[string]bool
+}
+
+// NewCallbackClient creates a client making at most the given number of delivery attempts, the delay before the
+// second attempt is doubled for every further attempt; the results can be delivered to the allowed hosts even if they
+// resolve to loopback, link-local or private addresses
+func NewCallbackClient(attempts int, delay time.Duration, allowedHosts ...string) *CallbackClient {
+	cc := &CallbackClient{
+		attempts:     attempts,
+		delay:        delay,
+		allowedHosts: make(map[string]bool),
+	}
+	for _, host := range allowedHosts {
+		cc.allowedHosts[strings.ToLower(host)] = true
+	}
+	// the addresses are checked when the connection is made, so that neither a changed dns record nor a redirect
+	// leads to an internal address; no proxy is used for the same reason
+	cc.client = &http.Client{
+		Timeout:   callbackTimeout,
+		Transport: &http.Transport{DialContext: cc.dial, TLSHandshakeTimeout: callbackTimeout},
+	}
+	return cc
+}
+
+// Check returns an error if the results can't be delivered to the url: it's not an http(s) url or its host resolves
+// to an internal address and it's not allowed
+func (cc *CallbackClient) Check(ctx context.Context, callbackURL string) error {
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("the callback url scheme [%s] is not supported", u.Scheme)
+	}
+	if cc.allowedHosts[strings.ToLower(u.Hostname())] {
+		return nil
+	}
+	_, err = cc.resolve(ctx, u.Hostname())
+	return err
+}
+
+// dial connects to the public addresses of the host, or to the host itself if it's allowed
+func (cc *CallbackClient) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if cc.allowedHosts[strings.ToLower(host)] {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	ips, err := cc.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		var conn net.Conn
+		if conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port)); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// resolve returns the addresses of the host, an error if any of them is internal
+func (cc *CallbackClient) resolve(ctx context.Context, host string) ([]net.IP, error) {
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		ips = ips[:0]
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+	for _, ip := range ips {
+		if internalIP(ip) {
+			return nil, fmt.Errorf("the callback host [%s] resolves to the internal address [%s]", host, ip)
+		}
+	}
+	return ips, nil
+}
+
+// internalIP reports whether the address is a loopback, link-local, unspecified or private one
+func internalIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, network := range internalNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCIDRs parses the networks in CIDR notation, it panics if any of them is malformed
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// callbackHostsFromEnv parses the comma separated hosts of the TELESCOPES_CALLBACK_ALLOWED_HOSTS environment variable,
+// the results are delivered to them even if they resolve to internal addresses
+func callbackHostsFromEnv() []string {
+	var hosts []string
+	for _, host := range strings.Split(os.Getenv("TELESCOPES_CALLBACK_ALLOWED_HOSTS"), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// maxJobsFromEnv reads the number of jobs running at the same time from the TELESCOPES_MAX_JOBS environment variable
+func maxJobsFromEnv() int {
+	if value := os.Getenv("TELESCOPES_MAX_JOBS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err == nil && n > 0 {
+			return n
+		}
+		log.Warnf("TELESCOPES_MAX_JOBS is not a valid number of jobs: %s, using the default: %d", value, defaultMaxJobs)
+	}
+	return defaultMaxJobs
+}
+
+// jobTimeoutFromEnv reads the time limit of computing the comparison of a job from the TELESCOPES_JOB_TIMEOUT
+// environment variable
+func jobTimeoutFromEnv() time.Duration {
+	if value := os.Getenv("TELESCOPES_JOB_TIMEOUT"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err == nil && d > 0 {
+			return d
+		}
+		log.Warnf("TELESCOPES_JOB_TIMEOUT is not a valid duration: %s, using the default: %s", value, defaultJobTimeout)
+	}
+	return defaultJobTimeout
+}
+
+// Deliver posts the body to the url as JSON until it's accepted or the attempts are used up, responses other than
+// 429 and server errors are not retried; it returns the number of attempts made
+func (cc *CallbackClient) Deliver(ctx context.Context, url string, body interface{}) (int, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+
+	delay := cc.delay
+	for attempt := 1; ; attempt++ {
+		retry, err := cc.post(ctx, url, b)
+		if err == nil || !retry || attempt >= cc.attempts {
+			return attempt, err
+		}
+		log.WithError(err).Debugf("callback delivery attempt [%d] failed, retrying in %s", attempt, delay)
+		select {
+		case <-ctx.Done():
+			return attempt, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// post makes a single delivery attempt, reporting whether a failed attempt may be retried
+func (cc *CallbackClient) post(ctx context.Context, url string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", gin.MIMEJSON)
+	resp, err := cc.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500,
+		fmt.Errorf("the callback responded with status [%d]", resp.StatusCode)
+}
+
+// recommendComparisonAsync starts computing the comparison in the background and responds with the job right away,
+// the result is posted to the callback once the job is completed; the comparison is rejected if the callback is not
+// allowed or too many jobs are running
+func (r *RouteHandler) recommendComparisonAsync(c *gin.Context, reqs []BatchRecommendationReq, callbackURL string) {
+	if err := r.callbacks.Check(c.Request.Context(), callbackURL); err != nil {
+		logger(c).Errorf("the callback is not allowed: %s", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"code": "bad_params", "message": "validation failed", "cause": err.Error()})
+		return
+	}
+	select {
+	case r.jobSlots <- struct{}{}:
+	default:
+		logger(c).Warnf("too many running jobs [%d], the comparison is rejected", cap(r.jobSlots))
+		c.Header("Retry-After", strconv.Itoa(jobRetryAfter))
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"code":    "too_many_jobs",
+			"message": fmt.Sprintf("too many running jobs, retry in [%ds]", jobRetryAfter),
+		})
+		return
+	}
+
+	job, err := r.jobs.create(callbackURL)
+	if err != nil {
+		<-r.jobSlots
+		logger(c).WithError(err).Error("could not create job")
+		c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": err.Error()})
+		return
+	}
+	logger(c).Infof("comparison job created: %s", job.ID)
+
+	// the gin context is recycled once the request is served, the job works on a copy;
+	// the job outlives the request, it's not canceled with it
+	go r.runComparison(context.Background(), c.Copy(), job.ID, reqs, callbackURL)
+
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "compare")+"jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// runComparison computes the comparison of the job within the time limit of the jobs and delivers the result to the
+// callback, the slot of the job is released once the delivery is done
+func (r *RouteHandler) runComparison(ctx context.Context, c *gin.Context, id string, reqs []BatchRecommendationReq, callbackURL string) {
+	defer func() { <-r.jobSlots }()
+
+	// the targets not recommended in time are delivered with the timeout error,
+	// the delivery itself is bounded by the attempts of the client
+	computeCtx, cancel := context.WithTimeout(ctx, r.jobTimeout)
+	results := r.recommendBatchItems(computeCtx, c, reqs)
+	cancel()
+	job := r.jobs.update(id, func(job *Job) {
+		job.Status = JobCompleted
+		job.Result = &ComparisonResp{Results: results, Ranking: rankTargets(results)}
+	})
+
+	attempts, err := r.callbacks.Deliver(ctx, callbackURL, job)
+	if err != nil {
+		logger(c).WithError(err).Errorf("could not deliver the result of job %s after %d attempts", id, attempts)
+	}
+	r.jobs.update(id, func(job *Job) {
+		job.CallbackAttempts = attempts
+		if err != nil {
+			job.CallbackStatus = CallbackFailed
+			job.CallbackError = err.Error()
+			return
+		}
+		job.CallbackStatus = CallbackDelivered
+	})
+}
+
+// swagger:route GET /recommender/jobs/:id recommend getJob
+//
+// Provides the status of an asynchronous comparison, and the result once it's completed.
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http
+//
+//	Security:
+//
+//	Responses:
+//	  200: JobResponse
+func (r *RouteHandler) getJob(c *gin.Context) {
+	// the job id is at the position of the region in the path
+	id := c.Param(regionParam)
+	job, ok := r.jobs.Get(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"code": "not_found", "message": fmt.Sprintf("job [%s] is not known", id)})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,325 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobStore(t *testing.T) {
+	now := time.Now()
+	s := NewJobStore(time.Minute)
+	s.now = func() time.Time { return now }
+
+	job, err := s.create("http://callback.example.com")
+	assert.Nil(t, err, "the job couldn't be created")
+	assert.Equal(t, JobRunning, job.Status)
+
+	now = now.Add(50 * time.Second)
+	s.update(job.ID, func(job *Job) { job.Status = JobCompleted })
+
+	// the expiration is extended by the update
+	now = now.Add(50 * time.Second)
+	stored, ok := s.Get(job.ID)
+	assert.True(t, ok, "the job should be kept")
+	assert.Equal(t, JobCompleted, stored.Status)
+
+	now = now.Add(time.Minute)
+	_, ok = s.Get(job.ID)
+	assert.False(t, ok, "the job should be expired")
+}
+
+func TestCallbackClient_Deliver(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		check    func(attempts int, calls int, err error)
+	}{
+		{
+			name:     "delivered after a server error",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusOK},
+			check: func(attempts int, calls int, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, 2, attempts)
+				assert.Equal(t, 2, calls)
+			},
+		},
+		{
+			name:     "client errors not retried",
+			statuses: []int{http.StatusBadRequest, http.StatusOK},
+			check: func(attempts int, calls int, err error) {
+				assert.EqualError(t, err, "the callback responded with status [400]")
+				assert.Equal(t, 1, calls)
+			},
+		},
+		{
+			name:     "attempts used up",
+			statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusTooManyRequests, http.StatusOK},
+			check: func(attempts int, calls int, err error) {
+				assert.EqualError(t, err, "the callback responded with status [429]")
+				assert.Equal(t, 3, attempts)
+				assert.Equal(t, 3, calls)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.statuses[calls])
+				calls++
+			}))
+			defer srv.Close()
+
+			// the test server listens on the loopback address
+			attempts, err := NewCallbackClient(3, time.Millisecond, "127.0.0.1").Deliver(context.Background(), srv.URL, gin.H{"id": "job"})
+			test.check(attempts, calls, err)
+		})
+	}
+}
+
+func TestCallbackClient_Check(t *testing.T) {
+	tests := []struct {
+		name  string
+		url   string
+		check func(err error)
+	}{
+		{
+			name: "public address allowed",
+			url:  "https://93.184.216.34/hooks/telescopes",
+			check: func(err error) {
+				assert.Nil(t, err, "the error should be nil")
+			},
+		},
+		{
+			name: "allowed host",
+			url:  "http://127.0.0.1:8080/hooks/telescopes",
+			check: func(err error) {
+				assert.Nil(t, err, "the error should be nil")
+			},
+		},
+		{
+			name: "loopback address rejected",
+			url:  "http://[::1]:8080/hooks/telescopes",
+			check: func(err error) {
+				assert.EqualError(t, err, "the callback host [::1] resolves to the internal address [::1]")
+			},
+		},
+		{
+			name: "link-local address rejected",
+			url:  "http://169.254.169.254/latest/meta-data/",
+			check: func(err error) {
+				assert.EqualError(t, err, "the callback host [169.254.169.254] resolves to the internal address [169.254.169.254]")
+			},
+		},
+		{
+			name: "private address rejected",
+			url:  "http://10.0.12.7/hooks/telescopes",
+			check: func(err error) {
+				assert.EqualError(t, err, "the callback host [10.0.12.7] resolves to the internal address [10.0.12.7]")
+			},
+		},
+		{
+			name: "unsupported scheme rejected",
+			url:  "ftp://93.184.216.34/hooks/telescopes",
+			check: func(err error) {
+				assert.EqualError(t, err, "the callback url scheme [ftp] is not supported")
+			},
+		},
+	}
+	cc := NewCallbackClient(3, time.Millisecond, "127.0.0.1")
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(cc.Check(context.Background(), test.url))
+		})
+	}
+}
+
+func TestCallbackClient_DeliverInternal(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer srv.Close()
+
+	// the loopback address of the test server is not allowed
+	_, err := NewCallbackClient(1, time.Millisecond).Deliver(context.Background(), srv.URL, gin.H{"id": "job"})
+	if assert.NotNil(t, err, "the delivery should fail") {
+		assert.Contains(t, err.Error(), "resolves to the internal address [127.0.0.1]")
+	}
+	assert.Equal(t, 0, calls, "the callback shouldn't be called")
+}
+
+func TestRouteHandler_recommendComparisonAsyncRejected(t *testing.T) {
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := recommender.NewEngine(fs)
+	assert.Nil(t, err, "the engine couldn't be created")
+	assert.Nil(t, ConfigureValidator(fs), "the validator couldn't be configured")
+
+	gin.SetMode(gin.TestMode)
+	rh := NewRouteHandler(engine)
+	rh.SetRateLimiter(nil)
+	rh.jobSlots = make(chan struct{}, 1)
+	router := gin.New()
+	rh.ConfigureRoutes(router)
+
+	compare := func(callbackURL string) *httptest.ResponseRecorder {
+		body := `{"targets": [{"provider": "ec2", "region": "eu-west-1"}], "callbackUrl": "` + callbackURL + `",
+			"sumCpu": 16, "sumMem": 64, "minNodes": 2, "maxNodes": 6, "onDemandPct": 50}`
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/recommender/compare", strings.NewReader(body)))
+		return w
+	}
+
+	w := compare("http://127.0.0.1:8080/hooks/telescopes")
+	assert.Equal(t, http.StatusBadRequest, w.Code, "internal callbacks should be rejected")
+	assert.Contains(t, w.Body.String(), "resolves to the internal address [127.0.0.1]")
+
+	// the only slot is taken by a running job
+	rh.jobSlots <- struct{}{}
+	w = compare("https://93.184.216.34/hooks/telescopes")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "too_many_jobs")
+	assert.Equal(t, "10", w.Header().Get("Retry-After"))
+}
+
+func TestRouteHandler_runComparisonTimeout(t *testing.T) {
+	delivered := make(chan Job, 1)
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var job Job
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&job), "the callback body couldn't be parsed")
+		delivered <- job
+	}))
+	defer callback.Close()
+
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := recommender.NewEngine(fs)
+	assert.Nil(t, err, "the engine couldn't be created")
+	assert.Nil(t, ConfigureValidator(fs), "the validator couldn't be configured")
+
+	gin.SetMode(gin.TestMode)
+	rh := NewRouteHandler(engine)
+	rh.callbacks = NewCallbackClient(1, time.Millisecond, "127.0.0.1")
+	// the deadline of the job is exceeded before the comparison is computed
+	rh.jobTimeout = time.Nanosecond
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/recommender/compare", nil)
+	job, err := rh.jobs.create(callback.URL)
+	assert.Nil(t, err, "the job couldn't be created")
+
+	rh.jobSlots <- struct{}{}
+	rh.runComparison(context.Background(), c, job.ID, []BatchRecommendationReq{{
+		Provider:                 "ec2",
+		Region:                   "eu-west-1",
+		ClusterRecommendationReq: recommender.ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 6},
+	}}, callback.URL)
+
+	select {
+	case result := <-delivered:
+		if assert.NotNil(t, result.Result, "the comparison should be delivered") {
+			assert.Nil(t, result.Result.Results[0].Recommendation, "there should be no recommendation")
+			assert.NotNil(t, result.Result.Results[0].Error, "the timeout should be delivered")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the result wasn't delivered to the callback")
+	}
+	assert.Equal(t, 0, len(rh.jobSlots), "the slot of the job should be released")
+}
+
+func TestRouteHandler_recommendComparisonAsync(t *testing.T) {
+	// the callback fails the first delivery
+	delivered := make(chan Job, 1)
+	var calls int
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls++; calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var job Job
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&job), "the callback body couldn't be parsed")
+		delivered <- job
+	}))
+	defer callback.Close()
+
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := recommender.NewEngine(fs)
+	assert.Nil(t, err, "the engine couldn't be created")
+	assert.Nil(t, ConfigureValidator(fs), "the validator couldn't be configured")
+
+	gin.SetMode(gin.TestMode)
+	rh := NewRouteHandler(engine)
+	rh.SetRateLimiter(nil)
+	rh.callbacks = NewCallbackClient(3, time.Millisecond, "127.0.0.1")
+	router := gin.New()
+	rh.ConfigureRoutes(router)
+
+	body := `{"targets": [{"provider": "ec2", "region": "eu-west-1"}], "callbackUrl": "` + callback.URL + `",
+		"sumCpu": 16, "sumMem": 64, "minNodes": 2, "maxNodes": 6, "onDemandPct": 50}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/recommender/compare", strings.NewReader(body)))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var job Job
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &job), "the response couldn't be parsed")
+	assert.Equal(t, JobRunning, job.Status)
+	assert.Equal(t, "/api/v1/recommender/jobs/"+job.ID, w.Header().Get("Location"))
+
+	select {
+	case result := <-delivered:
+		assert.Equal(t, job.ID, result.ID)
+		assert.Equal(t, JobCompleted, result.Status)
+		if assert.NotNil(t, result.Result, "the comparison should be delivered") {
+			assert.NotNil(t, result.Result.Results[0].Recommendation)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the result wasn't delivered to the callback")
+	}
+
+	// the delivery is recorded right after the callback returns
+	var polled Job
+	for i := 0; i < 100 && polled.CallbackStatus != CallbackDelivered; i++ {
+		time.Sleep(10 * time.Millisecond)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/recommender/jobs/"+job.ID, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &polled), "the response couldn't be parsed")
+	}
+	assert.Equal(t, CallbackDelivered, polled.CallbackStatus)
+	assert.Equal(t, 2, polled.CallbackAttempts)
+	assert.Equal(t, JobCompleted, polled.Status)
+	assert.NotNil(t, polled.Result, "the comparison should be polled")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/recommender/jobs/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	body = `{"targets": [{"provider": "ec2", "region": "eu-west-1"}], "callbackUrl": "not a url",
+		"sumCpu": 16, "sumMem": 64, "minNodes": 2, "maxNodes": 6, "onDemandPct": 50}`
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/recommender/compare", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "invalid callback urls should be rejected")
+}
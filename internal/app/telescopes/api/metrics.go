@@ -0,0 +1,78 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	metricLabels = []string{providerParam, regionParam}
+
+	recommendationRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "telescopes",
+		Name:      "recommendation_requests_total",
+		Help:      "Total number of cluster recommendation requests",
+	}, metricLabels)
+
+	recommendationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "telescopes",
+		Name:      "recommendation_failures_total",
+		Help:      "Total number of failed cluster recommendation requests",
+	}, metricLabels)
+
+	recommendationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "telescopes",
+		Name:      "recommendation_duration_seconds",
+		Help:      "Latency of cluster recommendation requests",
+		Buckets:   prometheus.DefBuckets,
+	}, metricLabels)
+)
+
+func init() {
+	prometheus.MustRegister(recommendationRequests, recommendationFailures, recommendationDuration)
+}
+
+// RecommendationMetrics middleware records the count, the failures and the latency of recommendation requests,
+// it's meant to be added after the path parameters are validated to keep the cardinality of the labels low
+func RecommendationMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		recordRecommendation(c, start)
+	}
+}
+
+// recordRecommendation records the recommendation request served since start
+func recordRecommendation(c *gin.Context, start time.Time) {
+	labels := prometheus.Labels{providerParam: c.Param(providerParam), regionParam: c.Param(regionParam)}
+	recommendationRequests.With(labels).Inc()
+	if c.Writer.Status() >= http.StatusBadRequest {
+		recommendationFailures.With(labels).Inc()
+	}
+	recommendationDuration.With(labels).Observe(time.Since(start).Seconds())
+}
+
+// metricsHandler exposes the metrics of the application in the prometheus format
+func metricsHandler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
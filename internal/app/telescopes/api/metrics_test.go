@@ -0,0 +1,90 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorded reads the number of requests, failures and latency observations recorded with the labels
+func recorded(t *testing.T, labels prometheus.Labels) (float64, float64, uint64) {
+	var requests, failures, latency dto.Metric
+	assert.Nil(t, recommendationRequests.With(labels).Write(&requests))
+	assert.Nil(t, recommendationFailures.With(labels).Write(&failures))
+	assert.Nil(t, recommendationDuration.With(labels).(prometheus.Metric).Write(&latency))
+	return requests.GetCounter().GetValue(), failures.GetCounter().GetValue(), latency.GetHistogram().GetSampleCount()
+}
+
+func TestRecommendationMetrics(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		failures float64
+	}{
+		{
+			name: "recommendation recorded",
+			body: `{"sumCpu": 8, "sumMem": 16, "minNodes": 1, "maxNodes": 4}`,
+		},
+		{
+			name:     "failed recommendation recorded",
+			body:     `{"sumCpu": 8, "sumMem": 16, "minNodes": 1, "maxNodes": 4, "maxNodeCpu": 1}`,
+			failures: 1,
+		},
+	}
+
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := recommender.NewEngine(fs, recommender.WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+	assert.Nil(t, ConfigureValidator(fs), "the validator couldn't be configured")
+
+	gin.SetMode(gin.TestMode)
+	rh := NewRouteHandler(engine)
+	rh.SetRateLimiter(nil)
+	router := gin.New()
+	rh.ConfigureRoutes(router)
+
+	labels := prometheus.Labels{providerParam: "ec2", regionParam: "eu-west-1"}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests, failures, latency := recorded(t, labels)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/recommender/ec2/eu-west-1/cluster/", strings.NewReader(test.body)))
+
+			afterRequests, afterFailures, afterLatency := recorded(t, labels)
+			assert.Equal(t, requests+1, afterRequests, "the request should be counted")
+			assert.Equal(t, failures+test.failures, afterFailures)
+			assert.Equal(t, latency+1, afterLatency, "the latency should be observed")
+		})
+	}
+
+	t.Run("metrics exposed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `telescopes_recommendation_requests_total{provider="ec2",region="eu-west-1"}`)
+		assert.Contains(t, w.Body.String(), `telescopes_recommendation_duration_seconds_count{provider="ec2",region="eu-west-1"}`)
+	})
+}
@@ -0,0 +1,42 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/ghodss/yaml"
+	"github.com/gin-gonic/gin"
+)
+
+// the content type of the yaml responses
+const mimeYAML = "application/yaml"
+
+// respond serializes the body as yaml if the client accepts it and doesn't prefer json, as json otherwise;
+// the yaml keys are the json field names
+func respond(c *gin.Context, status int, body interface{}) {
+	if c.NegotiateFormat(gin.MIMEJSON, mimeYAML) != mimeYAML {
+		c.JSON(status, body)
+		return
+	}
+
+	b, err := yaml.Marshal(body)
+	if err != nil {
+		logger(c).WithError(err).Error("could not marshal the response to yaml")
+		c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": err.Error()})
+		return
+	}
+	c.Data(status, mimeYAML+"; charset=utf-8", b)
+}
@@ -0,0 +1,165 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_respond(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		check  func(contentType string, body string)
+	}{
+		{
+			name: "json by default",
+			check: func(contentType string, body string) {
+				assert.Contains(t, contentType, gin.MIMEJSON)
+				assert.Contains(t, body, `"provider":"ec2"`)
+			},
+		},
+		{
+			name:   "yaml on request",
+			accept: "application/yaml",
+			check: func(contentType string, body string) {
+				assert.Contains(t, contentType, mimeYAML)
+				assert.Contains(t, body, "provider: ec2\n")
+				assert.Contains(t, body, "totalPrice: 1.5\n", "the yaml keys should be the json field names")
+			},
+		},
+		{
+			name:   "json preferred over yaml",
+			accept: "application/json, application/yaml;q=0.5",
+			check: func(contentType string, body string) {
+				assert.Contains(t, contentType, gin.MIMEJSON)
+			},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) {
+		respond(c, http.StatusOK, recommender.ClusterRecommendationResp{
+			Provider: "ec2",
+			Accuracy: recommender.ClusterRecommendationAccuracy{RecTotalPrice: 1.5},
+		})
+	})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.accept != "" {
+				req.Header.Set("Accept", test.accept)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			test.check(w.Header().Get("Content-Type"), w.Body.String())
+		})
+	}
+}
+
+func TestValidateRecommendationReq_yaml(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/dummy/dummyRegion/cluster", strings.NewReader(`{"sumCpu": 0, "sumMem": 10}`))
+	req.Header.Set("Accept", "application/yaml")
+	w := httptest.NewRecorder()
+	validationRouter().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), mimeYAML)
+	assert.Contains(t, w.Body.String(), "code: bad_params\n")
+}
+
+func TestRouteHandler_recommendClusterSetup_yaml(t *testing.T) {
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	router := batchRouter(t, fs)
+
+	for _, path := range []string{"/api/v1/recommender/ec2/eu-west-1/cluster/", "/api/v1/recommender/ec2/eu-west-1/cluster/?format=terraform"} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"sumCpu": 8, "sumMem": 16, "minNodes": 1, "maxNodes": 4}`))
+			req.Header.Set("Accept", "application/yaml")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Header().Get("Content-Type"), mimeYAML)
+		})
+	}
+}
+
+func TestRouteHandler_listInstances_yaml(t *testing.T) {
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	router := batchRouter(t, fs)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		body   string
+	}{
+		{name: "instance types", query: "minCpu=4", status: http.StatusOK, body: "instanceTypes:\n"},
+		{name: "error", query: "sort=unknown", status: http.StatusBadRequest, body: "code: bad_params\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/recommender/ec2/eu-west-1/instances?"+test.query, nil)
+			req.Header.Set("Accept", "application/yaml")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, test.status, w.Code)
+			assert.Contains(t, w.Header().Get("Content-Type"), mimeYAML)
+			assert.Contains(t, w.Body.String(), test.body)
+		})
+	}
+}
+
+func TestRouteHandler_compareInstanceTypes_yaml(t *testing.T) {
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	router := batchRouter(t, fs)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		body   string
+	}{
+		{name: "comparison", query: "a=m5.xlarge&b=c5.xlarge", status: http.StatusOK, body: "cheaperPerCpu: "},
+		{name: "error", query: "a=m5.xlarge", status: http.StatusBadRequest, body: "code: bad_params\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/recommender/ec2/eu-west-1/compare?"+test.query, nil)
+			req.Header.Set("Accept", "application/yaml")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, test.status, w.Code)
+			assert.Contains(t, w.Header().Get("Content-Type"), mimeYAML)
+			assert.Contains(t, w.Body.String(), test.body)
+		})
+	}
+}
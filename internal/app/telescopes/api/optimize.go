@@ -0,0 +1,73 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OptimumReq encapsulates the recommendation request with the scope of the search for the cheapest provider region
+type OptimumReq struct {
+	// The providers searched, all of them if not set
+	Providers []string `json:"providers,omitempty" binding:"max=10"`
+	// Maximum number of provider regions searched, 20 if not set
+	MaxTargets int `json:"maxTargets,omitempty" binding:"min=0,max=100"`
+	regionlessReq
+}
+
+// swagger:route POST /recommender/optimize recommend recommendOptimum
+//
+// Provides the recommended set of node pools in the cheapest of the regions of the given providers, all the providers
+// if none are given, together with the ranking of the cheapest provider regions.
+//
+//     Consumes:
+//     - application/json
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Security:
+//
+//     Responses:
+//       200: OptimumResponse
+func (r *RouteHandler) recommendOptimum(c *gin.Context) {
+	logger(c).Info("recommend optimum")
+
+	var req OptimumReq
+	if err := c.BindJSON(&req); err != nil {
+		logger(c).Errorf("failed to bind request body: %s", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "bad_params",
+			"message": "validation failed",
+			"cause":   validationMessage(err),
+			"details": validationDetails(err),
+		})
+		return
+	}
+
+	if response, err := r.engine.RecommendOptimum(c.Request.Context(), req.Providers, req.MaxTargets, req.ClusterRecommendationReq); err != nil {
+		logger(c).WithError(err).Error("could not recommend optimum")
+		r.recordAudit(c, strings.Join(req.Providers, ","), "", req.ClusterRecommendationReq, nil, err)
+		errorResponse(c, err)
+	} else {
+		r.recordAudit(c, response.Provider, response.Region, req.ClusterRecommendationReq, response.Recommendation, nil)
+		c.JSON(http.StatusOK, *response)
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// the default number of requests per second allowed for a client
+	defaultRateLimit = 10
+	// the default number of requests a client can make at once
+	defaultRateBurst = 20
+
+	// the limiters of the clients that haven't made a request for this long are evicted
+	rateLimiterIdleTTL = 10 * time.Minute
+	// the default number of clients limited at once, the least recently seen client is evicted for a new one
+	defaultRateLimitClients = 10000
+)
+
+// RateLimiter decides whether the requests of the clients are allowed
+type RateLimiter interface {
+	// Allow reports whether a request of the client is allowed now, and if it isn't, how long the client should wait
+	Allow(client string) (bool, time.Duration)
+}
+
+// clientLimiter is the token bucket of a client
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// IPRateLimiter limits the requests of every client with a token bucket of its own
+type IPRateLimiter struct {
+	mu        sync.Mutex
+	limit     rate.Limit
+	burst     int
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+	now       func() time.Time
+	// the maximum number of clients whose limiters are kept
+	maxClients int
+}
+
+// NewIPRateLimiter creates a limiter allowing limit requests per second to every client, with bursts of at most burst requests
+func NewIPRateLimiter(limit float64, burst int) *IPRateLimiter {
+	return &IPRateLimiter{
+		limit:      rate.Limit(limit),
+		burst:      burst,
+		clients:    make(map[string]*clientLimiter),
+		lastSweep:  time.Now(),
+		now:        time.Now,
+		maxClients: defaultRateLimitClients,
+	}
+}
+
+// Allow takes a token from the bucket of the client, the limiters of the idle clients are evicted on the way, and the one
+// of the least recently seen client if there are too many clients
+func (l *IPRateLimiter) Allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) > rateLimiterIdleTTL {
+		for c, cl := range l.clients {
+			if now.Sub(cl.lastSeen) > rateLimiterIdleTTL {
+				delete(l.clients, c)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	cl, ok := l.clients[client]
+	if !ok {
+		if len(l.clients) >= l.maxClients {
+			l.evictOldest()
+		}
+		cl = &clientLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[client] = cl
+	}
+	cl.lastSeen = now
+
+	r := cl.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		return false, time.Second
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		// the token is given back, the request is rejected instead of being delayed
+		r.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// evictOldest evicts the limiter of the least recently seen client
+func (l *IPRateLimiter) evictOldest() {
+	var (
+		oldest   string
+		lastSeen time.Time
+	)
+	for c, cl := range l.clients {
+		if oldest == "" || cl.lastSeen.Before(lastSeen) {
+			oldest, lastSeen = c, cl.lastSeen
+		}
+	}
+	delete(l.clients, oldest)
+}
+
+// RateLimit rejects the requests of the clients exceeding the limit with 429 and a Retry-After header,
+// clients are identified by their ip address: the address of the connection, or the forwarded one if the connection
+// comes from one of the trusted proxies
+func RateLimit(l RateLimiter, trustedProxies []*net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client := rateLimitClient(c.Request, trustedProxies)
+		allowed, wait := l.Allow(client)
+		if allowed {
+			c.Next()
+			return
+		}
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		logger(c).Warnf("rate limit exceeded by client: %s", client)
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"code":    "rate_limited",
+			"message": fmt.Sprintf("too many requests, retry in [%ds]", retryAfter),
+		})
+	}
+}
+
+// rateLimitClient returns the ip address of the client of the request; the X-Forwarded-For and X-Real-Ip headers can
+// be set by anyone, they're only taken into account if the connection comes from a trusted proxy, and the last
+// forwarded address not belonging to a trusted proxy is the client
+func rateLimitClient(req *http.Request, trustedProxies []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	if !trustedIP(host, trustedProxies) {
+		return host
+	}
+	forwarded := strings.Split(req.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(addr) == nil {
+			continue
+		}
+		if !trustedIP(addr, trustedProxies) {
+			return addr
+		}
+	}
+	if addr := strings.TrimSpace(req.Header.Get("X-Real-Ip")); net.ParseIP(addr) != nil {
+		return addr
+	}
+	return host
+}
+
+// trustedIP reports whether the address belongs to one of the trusted networks
+func trustedIP(addr string, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// trustedProxiesFromEnv parses the comma separated addresses and CIDR networks of the TELESCOPES_TRUSTED_PROXIES
+// environment variable, the malformed entries are skipped
+func trustedProxiesFromEnv() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(os.Getenv("TELESCOPES_TRUSTED_PROXIES"), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Warnf("TELESCOPES_TRUSTED_PROXIES holds an invalid address: %s, it's skipped", entry)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// rateLimiterFromEnv creates the limiter configured by the TELESCOPES_RATE_LIMIT (requests per second) and
+// TELESCOPES_RATE_BURST environment variables, rate limiting is disabled if the limit is 0
+func rateLimiterFromEnv() RateLimiter {
+	limit := float64(defaultRateLimit)
+	if value := os.Getenv("TELESCOPES_RATE_LIMIT"); value != "" {
+		l, err := strconv.ParseFloat(value, 64)
+		if err != nil || l < 0 {
+			log.Warnf("TELESCOPES_RATE_LIMIT is not a valid rate: %s, using the default: %d", value, defaultRateLimit)
+		} else {
+			limit = l
+		}
+	}
+	if limit == 0 {
+		log.Info("rate limiting is disabled")
+		return nil
+	}
+
+	burst := defaultRateBurst
+	if value := os.Getenv("TELESCOPES_RATE_BURST"); value != "" {
+		b, err := strconv.Atoi(value)
+		if err != nil || b < 1 {
+			log.Warnf("TELESCOPES_RATE_BURST is not a valid burst: %s, using the default: %d", value, defaultRateBurst)
+		} else {
+			burst = b
+		}
+	}
+	return NewIPRateLimiter(limit, burst)
+}
@@ -0,0 +1,216 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPRateLimiter_Allow(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		check func(l *IPRateLimiter)
+	}{
+		{
+			name: "requests allowed up to the burst",
+			check: func(l *IPRateLimiter) {
+				for i := 0; i < 2; i++ {
+					allowed, _ := l.Allow("10.0.0.1")
+					assert.True(t, allowed, "the request should be allowed")
+				}
+				allowed, wait := l.Allow("10.0.0.1")
+				assert.False(t, allowed, "the request should be rejected")
+				assert.Equal(t, time.Second, wait)
+			},
+		},
+		{
+			name: "clients limited separately",
+			check: func(l *IPRateLimiter) {
+				l.Allow("10.0.0.1")
+				l.Allow("10.0.0.1")
+				allowed, _ := l.Allow("10.0.0.2")
+				assert.True(t, allowed, "the request of the other client should be allowed")
+			},
+		},
+		{
+			name: "bucket refilled over time",
+			check: func(l *IPRateLimiter) {
+				l.Allow("10.0.0.1")
+				l.Allow("10.0.0.1")
+				l.now = func() time.Time { return now.Add(time.Second) }
+				allowed, _ := l.Allow("10.0.0.1")
+				assert.True(t, allowed, "the request should be allowed after the refill")
+			},
+		},
+		{
+			name: "idle clients evicted",
+			check: func(l *IPRateLimiter) {
+				l.Allow("10.0.0.1")
+				l.now = func() time.Time { return now.Add(2 * rateLimiterIdleTTL) }
+				l.Allow("10.0.0.2")
+				assert.Equal(t, 1, len(l.clients))
+			},
+		},
+		{
+			name: "least recently seen client evicted for a new one",
+			check: func(l *IPRateLimiter) {
+				l.maxClients = 2
+				l.Allow("10.0.0.1")
+				l.now = func() time.Time { return now.Add(time.Millisecond) }
+				l.Allow("10.0.0.2")
+				l.Allow("10.0.0.3")
+				assert.Equal(t, 2, len(l.clients))
+				_, ok := l.clients["10.0.0.1"]
+				assert.False(t, ok, "the least recently seen client should be evicted")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := NewIPRateLimiter(1, 2)
+			l.now = func() time.Time { return now }
+			l.lastSweep = now
+			test.check(l)
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		limiter RateLimiter
+		check   func(w *httptest.ResponseRecorder)
+	}{
+		{
+			name:    "api requests over the limit rejected",
+			path:    "/api/v1/recommender/unknown",
+			limiter: NewIPRateLimiter(0.5, 1),
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusTooManyRequests, w.Code)
+				assert.Equal(t, "2", w.Header().Get("Retry-After"))
+				assert.JSONEq(t, `{"code":"rate_limited","message":"too many requests, retry in [2s]"}`, w.Body.String())
+			},
+		},
+		{
+			name:    "probes not rate limited",
+			path:    "/status",
+			limiter: NewIPRateLimiter(0.5, 1),
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+			},
+		},
+		{
+			name: "rate limiting disabled",
+			path: "/api/v1/recommender/unknown",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, w.Code)
+			},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rh := NewRouteHandler(nil)
+			rh.SetRateLimiter(test.limiter)
+			router := gin.New()
+			rh.ConfigureRoutes(router)
+
+			var w *httptest.ResponseRecorder
+			for i := 0; i < 2; i++ {
+				w = httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+			}
+			test.check(w)
+		})
+	}
+}
+
+func TestRateLimit_forwardedAddresses(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+
+	tests := []struct {
+		name    string
+		trusted []*net.IPNet
+		headers []map[string]string
+		check   func(w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "rotated forwarded addresses of an untrusted connection limited together",
+			headers: []map[string]string{
+				{"X-Forwarded-For": "203.0.113.1"},
+				{"X-Forwarded-For": "203.0.113.2", "X-Real-Ip": "203.0.113.3"},
+			},
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusTooManyRequests, w.Code)
+			},
+		},
+		{
+			name:    "forwarded addresses of a trusted proxy limited separately",
+			trusted: []*net.IPNet{proxies},
+			headers: []map[string]string{
+				{"X-Forwarded-For": "203.0.113.1"},
+				{"X-Forwarded-For": "203.0.113.2"},
+			},
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, w.Code)
+			},
+		},
+		{
+			name:    "spoofed address prepended to the one of the trusted proxy ignored",
+			trusted: []*net.IPNet{proxies},
+			headers: []map[string]string{
+				{"X-Forwarded-For": "198.51.100.1, 203.0.113.1"},
+				{"X-Forwarded-For": "198.51.100.2, 203.0.113.1, 10.0.0.2"},
+			},
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusTooManyRequests, w.Code)
+			},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rh := NewRouteHandler(nil)
+			rh.SetRateLimiter(NewIPRateLimiter(0.5, 1))
+			rh.trustedProxies = test.trusted
+			router := gin.New()
+			rh.ConfigureRoutes(router)
+
+			var w *httptest.ResponseRecorder
+			for _, headers := range test.headers {
+				w = httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodGet, "/api/v1/recommender/unknown", nil)
+				req.RemoteAddr = "10.0.0.1:41234"
+				for k, v := range headers {
+					req.Header.Set(k, v)
+				}
+				router.ServeHTTP(w, req)
+			}
+			test.check(w)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+)
+
+// the default time the regions of a provider are cached for
+const defaultRegionCacheTTL = 10 * time.Minute
+
+// regionsEntry holds the cached regions of a provider with their expiration time
+type regionsEntry struct {
+	regions []string
+	expires time.Time
+}
+
+// RegionCache caches the regions of the providers, so that the region of every request can be checked cheaply
+type RegionCache struct {
+	mu      sync.Mutex
+	list    func(provider string) ([]string, error)
+	ttl     time.Duration
+	entries map[string]regionsEntry
+	now     func() time.Time
+}
+
+// NewRegionCache creates a cache of the regions listed by the function, keeping them for the given time;
+// failed listings are not cached
+func NewRegionCache(list func(provider string) ([]string, error), ttl time.Duration) *RegionCache {
+	return &RegionCache{
+		list:    list,
+		ttl:     ttl,
+		entries: make(map[string]regionsEntry),
+		now:     time.Now,
+	}
+}
+
+// engineRegions lists the identifiers of the regions of the provider known by the engine
+func engineRegions(e *recommender.Engine) func(provider string) ([]string, error) {
+	return func(provider string) ([]string, error) {
+		regions, err := e.GetRegions(provider)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, 0, len(regions))
+		for _, r := range regions {
+			ids = append(ids, r.ID)
+		}
+		return ids, nil
+	}
+}
+
+// Regions returns the sorted identifiers of the regions of the provider
+func (rc *RegionCache) Regions(provider string) ([]string, error) {
+	rc.mu.Lock()
+	entry, ok := rc.entries[provider]
+	rc.mu.Unlock()
+	if ok && rc.now().Before(entry.expires) {
+		return entry.regions, nil
+	}
+
+	regions, err := rc.list(provider)
+	if err != nil {
+		return nil, err
+	}
+	sorted := append([]string(nil), regions...)
+	sort.Strings(sorted)
+
+	rc.mu.Lock()
+	rc.entries[provider] = regionsEntry{regions: sorted, expires: rc.now().Add(rc.ttl)}
+	rc.mu.Unlock()
+	return sorted, nil
+}
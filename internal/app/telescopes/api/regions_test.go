@@ -0,0 +1,107 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegionCache_Regions(t *testing.T) {
+	var calls int
+	failing := false
+	rc := NewRegionCache(func(provider string) ([]string, error) {
+		calls++
+		if failing {
+			return nil, errors.New("product info unreachable")
+		}
+		return []string{"us-east-1", "eu-west-1"}, nil
+	}, time.Minute)
+	now := time.Now()
+	rc.now = func() time.Time { return now }
+
+	regions, err := rc.Regions("ec2")
+	assert.Nil(t, err, "the error should be nil")
+	assert.Equal(t, []string{"eu-west-1", "us-east-1"}, regions, "the regions should be sorted")
+
+	rc.Regions("ec2")
+	assert.Equal(t, 1, calls, "the regions should be served from the cache")
+
+	now = now.Add(2 * time.Minute)
+	failing = true
+	_, err = rc.Regions("ec2")
+	assert.EqualError(t, err, "product info unreachable")
+	_, err = rc.Regions("ec2")
+	assert.NotNil(t, err, "the failed listing shouldn't be cached")
+	assert.Equal(t, 3, calls)
+}
+
+func TestValidateRegionData(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		check func(w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "known region",
+			path: "/ec2/eu-west-1",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+			},
+		},
+		{
+			name: "unknown region",
+			path: "/ec2/eu-west-9",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, w.Code)
+				assert.JSONEq(t, `{"code": "region_not_found", "message": "region [eu-west-9] is not known on provider [ec2]",
+					"regions": ["eu-west-1", "us-east-1"]}`, w.Body.String())
+			},
+		},
+		{
+			name: "regions can't be listed",
+			path: "/gce/europe-west1",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, w.Code)
+			},
+		},
+	}
+
+	rc := NewRegionCache(func(provider string) ([]string, error) {
+		if provider != "ec2" {
+			return nil, errors.New("product info unreachable")
+		}
+		return []string{"eu-west-1", "us-east-1"}, nil
+	}, time.Minute)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/:provider/:region", ValidateRegionData(rc), func(c *gin.Context) {
+		c.JSON(http.StatusOK, "ok")
+	})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+			test.check(w)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// the header carrying the correlation id of the request
+	requestIDHeader = "X-Request-ID"
+	// the key of the correlation id in the gin context
+	requestIDKey = "requestID"
+)
+
+// incoming correlation ids are only accepted if they are safe to be logged and echoed back
+var requestIDRegexp = regexp.MustCompile(`^[a-zA-Z0-9\-_.:]{1,128}$`)
+
+// RequestID middleware reads the correlation id of the request from the X-Request-ID header, or generates a new one
+// if absent, stores it in the context (and in the request context for the engine) and echoes it back in the response header
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !requestIDRegexp.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Request = c.Request.WithContext(recommender.WithCorrelationID(c.Request.Context(), id))
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// logger returns a log entry decorated with the correlation id of the request
+func logger(c *gin.Context) *log.Entry {
+	return log.WithField("requestId", c.GetString(requestIDKey))
+}
+
+// newRequestID generates a random (version 4) UUID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.WithError(err).Warn("could not generate request id")
+		return ""
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
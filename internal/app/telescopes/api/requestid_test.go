@@ -0,0 +1,102 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name  string
+		id    string
+		check func(id string)
+	}{
+		{
+			name: "incoming id echoed",
+			id:   "req-1.a:b_c",
+			check: func(id string) {
+				assert.Equal(t, "req-1.a:b_c", id)
+			},
+		},
+		{
+			name: "id generated if absent",
+			check: func(id string) {
+				assert.Regexp(t, uuidRegexp, id)
+			},
+		},
+		{
+			name: "unsafe incoming id replaced",
+			id:   "req 1<script>",
+			check: func(id string) {
+				assert.Regexp(t, uuidRegexp, id)
+			},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var ctxID, reqCtxID string
+			var logID interface{}
+			router := gin.New()
+			router.Use(RequestID())
+			router.GET("/", func(c *gin.Context) {
+				ctxID = c.GetString(requestIDKey)
+				reqCtxID = recommender.CorrelationID(c.Request.Context())
+				logID = logger(c).Data["requestId"]
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.id != "" {
+				req.Header.Set(requestIDHeader, test.id)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			id := w.Header().Get(requestIDHeader)
+			test.check(id)
+			assert.Equal(t, id, ctxID, "the id should be stored in the context")
+			assert.Equal(t, id, reqCtxID, "the id should be passed to the engine in the request context")
+			assert.Equal(t, id, logID, "the id should be logged")
+		})
+	}
+
+	t.Run("ids generated per request", func(t *testing.T) {
+		router := gin.New()
+		router.Use(RequestID())
+		router.GET("/", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		ids := make(map[string]bool)
+		for i := 0; i < 3; i++ {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			ids[w.Header().Get(requestIDHeader)] = true
+		}
+		assert.Equal(t, 3, len(ids), "the generated ids should be unique")
+	})
+}
@@ -15,9 +15,14 @@
 package api
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/banzaicloud/bank-vaults/auth"
 	"github.com/banzaicloud/telescopes/pkg/recommender"
@@ -29,36 +34,92 @@ import (
 )
 
 const (
-	providerParam = "provider"
-	regionParam   = "region"
+	providerParam     = "provider"
+	regionParam       = "region"
+	instanceTypeParam = "type"
+
+	// non-standard status code for requests abandoned by the client
+	statusClientClosedRequest = 499
+
+	// the time limit of the readiness check, so that it never blocks the probe
+	readinessTimeout = 2 * time.Second
 )
 
 // RouteHandler struct that wraps the recommender engine
 type RouteHandler struct {
-	engine *recommender.Engine
+	engine  *recommender.Engine
+	limiter RateLimiter
+	// the proxies whose forwarded client addresses the requests are rate limited by
+	trustedProxies []*net.IPNet
+	// the authentication middleware of the api, the probes are not authenticated
+	auth []gin.HandlerFunc
+	// the headers carrying the credentials besides the Authorization header, allowed in cross-origin requests
+	authHeaders []string
+	// the sink the served recommendations are recorded to
+	audit AuditSink
+	// the known regions of the providers the region in the path is checked against
+	regions *RegionCache
+	// the asynchronous jobs and the client delivering their results
+	jobs      *JobStore
+	callbacks *CallbackClient
+	// the slots of the jobs running at the same time, and the time limit of their comparisons
+	jobSlots   chan struct{}
+	jobTimeout time.Duration
+	// the swagger spec served with the swagger ui, the swagger ui is disabled if empty
+	swaggerSpec string
+	// the regions of the providers the cluster recommendations without a region in the path are made for
+	defaultRegions map[string]string
 }
 
 // NewRouteHandler creates a new RouteHandler and returns a reference to it
 func NewRouteHandler(e *recommender.Engine) *RouteHandler {
 	return &RouteHandler{
-		engine: e,
+		engine:         e,
+		limiter:        rateLimiterFromEnv(),
+		trustedProxies: trustedProxiesFromEnv(),
+		audit:          NoopAuditSink{},
+		regions:        NewRegionCache(engineRegions(e), defaultRegionCacheTTL),
+		jobs:           NewJobStore(defaultJobTTL),
+		callbacks:      NewCallbackClient(defaultCallbackAttempts, defaultCallbackDelay, callbackHostsFromEnv()...),
+		jobSlots:       make(chan struct{}, maxJobsFromEnv()),
+		jobTimeout:     jobTimeoutFromEnv(),
+		swaggerSpec:    swaggerSpecFromEnv(),
+		defaultRegions: defaultRegionsFromEnv(),
 	}
 }
 
-func getCorsConfig() cors.Config {
+// SetRateLimiter replaces the rate limiter of the api configured by the environment, nil disables rate limiting
+func (r *RouteHandler) SetRateLimiter(l RateLimiter) {
+	r.limiter = l
+}
+
+func getCorsConfig(authHeaders ...string) cors.Config {
 	config := cors.DefaultConfig()
+	// all origins are allowed unless the allowed origins are configured
 	config.AllowAllOrigins = true
-	if !config.AllowAllOrigins {
-		config.AllowOrigins = []string{"http://", "https://"}
+	if origins := parseCorsOrigins(os.Getenv("TELESCOPES_CORS_ORIGINS")); len(origins) > 0 {
+		config.AllowAllOrigins = false
+		config.AllowOrigins = origins
 	}
 	config.AllowMethods = []string{http.MethodPut, http.MethodDelete, http.MethodGet, http.MethodPost, http.MethodOptions}
-	config.AllowHeaders = []string{"Origin", "Authorization", "Content-Type"}
-	config.ExposeHeaders = []string{"Content-Length"}
+	config.AllowHeaders = append([]string{"Origin", "Authorization", "Content-Type", requestIDHeader}, authHeaders...)
+	config.ExposeHeaders = []string{"Content-Length", "ETag", requestIDHeader}
 	config.AllowCredentials = true
 	config.MaxAge = 12
 	return config
 }
 
+// parseCorsOrigins parses the comma separated list of allowed origins
+func parseCorsOrigins(value string) []string {
+	var origins []string
+	for _, origin := range strings.Split(value, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
 // ConfigureRoutes configures the gin engine, defines the rest API for this application
 func (r *RouteHandler) ConfigureRoutes(router *gin.Engine) {
 	log.Info("configuring routes")
@@ -70,40 +131,344 @@ func (r *RouteHandler) ConfigureRoutes(router *gin.Engine) {
 		basePath = basePathFromEnv
 	}
 
-	router.Use(cors.New(getCorsConfig()))
+	router.Use(RequestID())
+	router.Use(cors.New(getCorsConfig(r.authHeaders...)))
+	// registered after cors, the compressed responses carry the cors headers as well
+	router.Use(Gzip())
 
 	base := router.Group(basePath)
 	{
 		base.GET("/status", r.signalStatus)
+		base.GET("/readiness", r.signalReadiness)
+		base.GET("/health/details", r.signalHealthDetails)
+		base.GET("/metrics", metricsHandler())
+	}
+	if r.swaggerSpec != "" {
+		// the api docs are neither rate limited nor authenticated either
+		base.GET("/swagger/*any", r.serveSwagger)
+	}
+
+	validateProvider := ValidatePathParam(providerParam, v, "provider")
+
+	// the probes and the metrics of the base group are neither rate limited nor authenticated
+	var protected gin.HandlersChain
+	if r.limiter != nil {
+		protected = append(protected, RateLimit(r.limiter, r.trustedProxies))
 	}
+	protected = append(protected, r.auth...)
+
+	// the capabilities are public like the probes, but share the path segment with the protected routes
+	base.GET("/api/v1/recommender/:provider", dispatch(providerParam, paramRoutes{
+		"capabilities": {r.getCapabilities},
+		anyParam: append(append(gin.HandlersChain{}, protected...), dispatch(providerParam, paramRoutes{
+			"providers": {r.getProviders},
+		})),
+	}))
 
 	v1 := base.Group("/api/v1")
-	v1.Use(ValidatePathParam(providerParam, v, "provider"))
-	v1.Use(ValidateRegionData(v))
+	v1.Use(protected...)
 	recGroup := v1.Group("/recommender")
 	{
-		recGroup.POST("/:provider/:region/cluster/", r.recommendClusterSetup)
+		// static and wildcard path segments at the same position are dispatched on the path parameter
+		recGroup.POST("/:provider", dispatch(providerParam, paramRoutes{
+			"compare":  {r.recommendComparison},
+			"optimize": {r.recommendOptimum},
+		}))
+		recGroup.GET("/:provider/:region", dispatch(providerParam, paramRoutes{
+			"jobs": {r.getJob},
+			anyParam: {dispatch(regionParam, paramRoutes{
+				"regions": {validateProvider, r.getRegions},
+			})},
+		}))
+		recGroup.POST("/:provider/:region", dispatch(regionParam, paramRoutes{
+			"batch": {dispatch(providerParam, paramRoutes{
+				"cluster": {r.recommendClusterBatch},
+			})},
+			"cheapest": {validateProvider, r.recommendCheapestRegion},
+			"cluster":  {validateProvider, r.recommendClusterInDefaultRegion},
+			"failover": {validateProvider, r.recommendFailover},
+		}))
+	}
+
+	providerGroup := recGroup.Group("/:provider")
+	providerGroup.Use(validateProvider)
+
+	regionGroup := providerGroup.Group("/:region")
+	regionGroup.Use(ValidateRegionData(r.regions))
+	{
+		regionGroup.GET("/zones", r.getZones)
+		regionGroup.GET("/instances", r.listInstances)
+		regionGroup.GET("/instances/:type", r.getInstanceType)
+		regionGroup.GET("/compare", r.compareInstanceTypes)
+		// metrics are recorded on the route, leaving the cors and auth middleware of the router untouched
+		regionGroup.POST("/cluster/", RecommendationMetrics(), ValidateRecommendationReq(), r.recommendClusterSetup)
+		regionGroup.POST("/cluster/groups", RecommendationMetrics(), r.recommendClusterGroups)
+		regionGroup.POST("/validate", r.validateRecommendation)
 	}
 }
 
-// EnableAuth enables authentication middleware
-func (r *RouteHandler) EnableAuth(router *gin.Engine, role string, sgnKey string) {
-	router.Use(auth.JWTAuth(auth.NewVaultTokenStore(role), sgnKey, nil))
+// EnableAuth enables authentication middleware on the api routes, the tokens must be granted all the scopes if there are any;
+// it must be called before the routes are configured
+func (r *RouteHandler) EnableAuth(role string, sgnKey string, scopes ...string) {
+	r.auth = append(r.auth, auth.JWTAuth(auth.NewVaultTokenStore(role), sgnKey, nil))
+	if len(scopes) > 0 {
+		r.auth = append(r.auth, RequireScopes(scopes...))
+	}
 }
 
+// signalStatus is the liveness check, it only signals that the process is up
 func (r *RouteHandler) signalStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, "ok")
 }
 
+// signalReadiness signals whether the application can serve recommendations, that is the product info is reachable
+func (r *RouteHandler) signalReadiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	if err := r.engine.Ping(ctx); err != nil {
+		logger(c).WithError(err).Warn("product info is unreachable")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": http.StatusServiceUnavailable, "message": fmt.Sprintf("%s", err)})
+		return
+	}
+	c.JSON(http.StatusOK, "ok")
+}
+
+// signalHealthDetails signals the readiness like signalReadiness, describing the latency of the product info source and
+// the age of the product info in the body for monitoring
+func (r *RouteHandler) signalHealthDetails(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	health := r.engine.Health(ctx)
+	if !health.Reachable {
+		logger(c).Warnf("product info is unreachable: %s", health.Error)
+		c.JSON(http.StatusServiceUnavailable, health)
+		return
+	}
+	c.JSON(http.StatusOK, health)
+}
+
+// swagger:route GET /recommender/capabilities capabilities getCapabilities
+//
+// Describes the features this deployment supports: the providers, the optional pricing features of the product info
+// source and the configuration of the engine. The endpoint is not authenticated.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Responses:
+//       200: CapabilitiesResponse
+func (r *RouteHandler) getCapabilities(c *gin.Context) {
+	logger(c).Info("get capabilities")
+	if capabilities, err := r.engine.GetCapabilities(); err != nil {
+		errorResponse(c, err)
+	} else {
+		c.JSON(http.StatusOK, capabilities)
+	}
+}
+
+// swagger:route GET /recommender/providers providers getProviders
+//
+// Provides the list of cloud providers the recommender can serve recommendations for.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Security:
+//
+//     Responses:
+//       200: ProvidersResponse
+func (r *RouteHandler) getProviders(c *gin.Context) {
+	logger(c).Info("get providers")
+	if providers, err := r.engine.GetProviders(); err != nil {
+		errorResponse(c, err)
+	} else {
+		c.JSON(http.StatusOK, providers)
+	}
+}
+
+// swagger:route GET /recommender/:provider/regions regions getRegions
+//
+// Provides the list of regions of the given provider the recommender can serve recommendations for.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Security:
+//
+//     Responses:
+//       200: RegionsResponse
+func (r *RouteHandler) getRegions(c *gin.Context) {
+	provider := c.Param(providerParam)
+	logger(c).Infof("get regions for provider: %s", provider)
+	if regions, err := r.engine.GetRegions(provider); err != nil {
+		errorResponse(c, err)
+	} else {
+		c.JSON(http.StatusOK, regions)
+	}
+}
+
+// swagger:route GET /recommender/:provider/:region/zones zones getZones
+//
+// Provides the list of availability zones in the given region of the provider.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Security:
+//
+//     Responses:
+//       200: ZonesResponse
+func (r *RouteHandler) getZones(c *gin.Context) {
+	provider := c.Param(providerParam)
+	region := c.Param(regionParam)
+	logger(c).Infof("get zones for provider: %s, region: %s", provider, region)
+	if zones, err := r.engine.GetZones(provider, region); err != nil {
+		errorResponse(c, err)
+	} else {
+		c.JSON(http.StatusOK, zones)
+	}
+}
+
+// swagger:route GET /recommender/:provider/:region/instances instances listInstances
+//
+// Provides a page of the instance types in the given region of the provider matching the filters in the query, sorted
+// by on-demand price unless another order is requested.
+//
+//     Produces:
+//     - application/json
+//     - application/yaml
+//
+//     Schemes: http
+//
+//     Security:
+//
+//     Responses:
+//       200: InstanceTypesResponse
+func (r *RouteHandler) listInstances(c *gin.Context) {
+	provider := c.Param(providerParam)
+	region := c.Param(regionParam)
+
+	var (
+		filters recommender.InstanceFilters
+		page    recommender.Page
+	)
+	err := c.ShouldBindQuery(&filters)
+	if err == nil {
+		err = c.ShouldBindQuery(&page)
+	}
+	if err != nil {
+		logger(c).Errorf("validation failed. err: %s", err.Error())
+		respond(c, http.StatusBadRequest, gin.H{
+			"code":    "bad_params",
+			"message": "validation failed",
+			"cause":   validationMessage(err),
+			"details": validationDetails(err),
+		})
+		return
+	}
+
+	logger(c).Infof("list instance types for provider: %s, region: %s, filters: %#v, page: %#v", provider, region, filters, page)
+	if instanceTypes, err := r.engine.ListInstances(provider, region, filters, page); err != nil {
+		errorResponse(c, err)
+	} else {
+		respond(c, http.StatusOK, instanceTypes)
+	}
+}
+
+// swagger:route GET /recommender/:provider/:region/instances/:type instances getInstanceType
+//
+// Provides the product info of an instance type in the given region of the provider, as used by the recommendations.
+//
+//     Produces:
+//     - application/json
+//
+//     Schemes: http
+//
+//     Security:
+//
+//     Responses:
+//       200: InstanceTypeResponse
+func (r *RouteHandler) getInstanceType(c *gin.Context) {
+	provider := c.Param(providerParam)
+	region := c.Param(regionParam)
+	vmType := c.Param(instanceTypeParam)
+	logger(c).Infof("get instance type: %s, provider: %s, region: %s", vmType, provider, region)
+	if instanceType, err := r.engine.GetInstanceType(provider, region, vmType); err != nil {
+		errorResponse(c, err)
+	} else {
+		c.JSON(http.StatusOK, instanceType)
+	}
+}
+
+// InstanceTypePair selects the instance types compared side-by-side
+type InstanceTypePair struct {
+	// The first instance type
+	A string `form:"a" json:"a" binding:"required"`
+	// The second instance type
+	B string `form:"b" json:"b" binding:"required"`
+}
+
+// swagger:route GET /recommender/:provider/:region/compare instances compareInstanceTypes
+//
+// Provides the product info of two instance types in the given region of the provider side-by-side, with their
+// on-demand and spot prices per cpu.
+//
+//     Produces:
+//     - application/json
+//     - application/yaml
+//
+//     Schemes: http
+//
+//     Security:
+//
+//     Responses:
+//       200: InstanceTypeComparisonResponse
+func (r *RouteHandler) compareInstanceTypes(c *gin.Context) {
+	provider := c.Param(providerParam)
+	region := c.Param(regionParam)
+
+	var pair InstanceTypePair
+	if err := c.ShouldBindQuery(&pair); err != nil {
+		logger(c).Errorf("validation failed. err: %s", err.Error())
+		respond(c, http.StatusBadRequest, gin.H{
+			"code":    "bad_params",
+			"message": "validation failed",
+			"cause":   validationMessage(err),
+			"details": validationDetails(err),
+		})
+		return
+	}
+
+	logger(c).Infof("compare instance types: %s and %s, provider: %s, region: %s", pair.A, pair.B, provider, region)
+	if comparison, err := r.engine.CompareInstanceTypes(provider, region, pair.A, pair.B); err != nil {
+		errorResponse(c, err)
+	} else {
+		respond(c, http.StatusOK, comparison)
+	}
+}
+
 // swagger:route POST /recommender/:provider/:region/cluster recommend recommendClusterSetup
 //
 // Provides a recommended set of node pools on a given provider in a specific region.
+// The node pools are serialized into a Terraform JSON configuration fragment if the terraform format is requested.
+// The node pools are listed under their instance families with subtotals if groupByFamily is set.
 //
 //     Consumes:
 //     - application/json
 //
 //     Produces:
 //     - application/json
+//     - application/yaml
 //
 //     Schemes: http
 //
@@ -112,28 +477,86 @@ func (r *RouteHandler) signalStatus(c *gin.Context) {
 //     Responses:
 //       200: RecommendationResponse
 func (r *RouteHandler) recommendClusterSetup(c *gin.Context) {
-	log.Info("recommend cluster setup")
+	logger(c).Info("recommend cluster setup")
 	provider := c.Param(providerParam)
 	region := c.Param(regionParam)
 
-	// request decorated with provider and region
-	req := RequestWrapper{Provider: provider, Region: region}
+	// the request is validated by the ValidateRecommendationReq middleware
+	req := c.MustGet(recommendationReqKey).(RequestWrapper)
 
-	if err := c.BindJSON(&req); err != nil {
-		log.Errorf("failed to bind request body: %s", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    "bad_params",
-			"message": "validation failed",
-			"cause":   err.Error(),
-		})
+	format := c.DefaultQuery(formatParam, jsonFormat)
+	if format != jsonFormat && format != terraformFormat {
+		respond(c, http.StatusBadRequest, gin.H{"code": "bad_params", "message": fmt.Sprintf("unsupported format: [%s]", format)})
+		return
+	}
+	if _, ok := terraformResourceTypes[provider]; format == terraformFormat && !ok {
+		respond(c, http.StatusBadRequest, gin.H{"code": "bad_params", "message": fmt.Sprintf("the terraform format is not supported on provider [%s]", provider)})
+		return
+	}
+	byFamily, err := strconv.ParseBool(c.DefaultQuery(groupByFamilyParam, "false"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, gin.H{"code": "bad_params", "message": fmt.Sprintf("invalid %s: [%s]", groupByFamilyParam, c.Query(groupByFamilyParam))})
 		return
 	}
 
-	if response, err := r.engine.RecommendCluster(provider, region, req.ClusterRecommendationReq); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "message": fmt.Sprintf("%s", err)})
-	} else {
-		c.JSON(http.StatusOK, *response)
+	response, err := r.engine.RecommendClusterCtx(c.Request.Context(), provider, region, req.ClusterRecommendationReq)
+	r.recordAudit(c, provider, region, req.ClusterRecommendationReq, response, err)
+	if err != nil {
+		logger(c).WithError(err).Errorf("could not recommend cluster for provider: %s, region: %s", provider, region)
+		errorResponse(c, err)
+		return
 	}
+
+	if format == terraformFormat {
+		config, err := terraformNodePools(provider, region, response)
+		if err != nil {
+			errorResponse(c, err)
+			return
+		}
+		respond(c, http.StatusOK, config)
+		return
+	}
+	if byFamily {
+		respondTagged(c, groupByFamily(*response), r.engine.PriceVersion(provider, region))
+		return
+	}
+	respondTagged(c, *response, r.engine.PriceVersion(provider, region))
+}
+
+// errorResponse responds with the status code corresponding to the error returned by the engine, in the negotiated format
+func errorResponse(c *gin.Context, err error) {
+	status, code := errorStatus(err)
+	if code == "" {
+		respond(c, status, gin.H{"status": status, "message": fmt.Sprintf("%s", err)})
+		return
+	}
+	respond(c, status, gin.H{"code": code, "message": err.Error()})
+}
+
+// errorStatus returns the status code and the error code corresponding to the error returned by the engine,
+// the error code is empty for unexpected errors
+func errorStatus(err error) (int, string) {
+	switch err {
+	case context.Canceled:
+		// the client is gone, the response is only recorded in the logs
+		return statusClientClosedRequest, "request_canceled"
+	case context.DeadlineExceeded:
+		return http.StatusServiceUnavailable, "request_timeout"
+	}
+
+	switch code := recommender.ErrorCode(err); code {
+	case recommender.ResourceUnavailable, recommender.ProviderUnsupported:
+		return http.StatusBadRequest, code
+	case recommender.NoViableInstances, recommender.OverBudget:
+		return http.StatusUnprocessableEntity, code
+	case recommender.ProductInfoUnavailable:
+		return http.StatusServiceUnavailable, code
+	case recommender.UnknownInstanceType, recommender.RegionNotFound:
+		return http.StatusNotFound, code
+	case recommender.RecommendationTimeout:
+		return http.StatusGatewayTimeout, code
+	}
+	return http.StatusInternalServerError, ""
 }
 
 // RequestWrapper internal struct for passing provider/zone info to the validator
@@ -142,3 +565,39 @@ type RequestWrapper struct {
 	Provider string
 	Region   string
 }
+
+// paramRoutes maps path parameter values to the handlers serving them, the handlers of anyParam serve the values
+// not mapped otherwise
+type paramRoutes map[string]gin.HandlersChain
+
+// the key of the handlers serving any value of a path parameter
+const anyParam = "*"
+
+// dispatch returns a handler that serves a wildcard route with the handlers registered for the actual value of the
+// named path parameter; unknown values are rejected with 404
+// This is needed as the router doesn't support static and wildcard path segments at the same position
+func dispatch(name string, routes paramRoutes) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		handlers, ok := routes[c.Param(name)]
+		if !ok {
+			handlers, ok = routes[anyParam]
+		}
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    "not_found",
+				"message": fmt.Sprintf("no route for path: %s", c.Request.URL.Path),
+			})
+			return
+		}
+		runHandlers(c, handlers)
+	}
+}
+
+// runHandlers runs the handlers in order until one of them aborts the request
+func runHandlers(c *gin.Context, handlers gin.HandlersChain) {
+	for _, handler := range handlers {
+		if handler(c); c.IsAborted() {
+			return
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/banzaicloud/productinfo/pkg/productinfo-client/models"
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_getCorsConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins string
+		origin  string
+		check   func(header string)
+	}{
+		{
+			name:   "all origins allowed when not configured",
+			origin: "http://example.com",
+			check: func(header string) {
+				assert.Equal(t, "*", header, "all origins should be allowed")
+			},
+		},
+		{
+			name:    "configured origin allowed",
+			origins: "http://example.com, https://banzaicloud.com",
+			origin:  "https://banzaicloud.com",
+			check: func(header string) {
+				assert.Equal(t, "https://banzaicloud.com", header, "the origin should be allowed")
+			},
+		},
+		{
+			name:    "origin not configured is disallowed",
+			origins: "http://example.com,https://banzaicloud.com",
+			origin:  "http://evil.com",
+			check: func(header string) {
+				assert.Equal(t, "", header, "the origin should not be allowed")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Setenv("TELESCOPES_CORS_ORIGINS", test.origins)
+			defer os.Unsetenv("TELESCOPES_CORS_ORIGINS")
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(cors.New(getCorsConfig()))
+			router.GET("/status", func(c *gin.Context) {
+				c.JSON(http.StatusOK, "ok")
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/status", nil)
+			req.Header.Set("Origin", test.origin)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			test.check(w.Header().Get("Access-Control-Allow-Origin"))
+		})
+	}
+}
+
+func Test_errorStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{name: "unsupported provider", err: recommender.NewError(recommender.ProviderUnsupported, "unsupported"), status: http.StatusBadRequest, code: "provider_unsupported"},
+		{name: "unavailable resource", err: recommender.NewError(recommender.ResourceUnavailable, "unavailable"), status: http.StatusBadRequest, code: "resource_unavailable"},
+		{name: "unknown region", err: recommender.NewError(recommender.RegionNotFound, "not found"), status: http.StatusNotFound, code: "region_not_found"},
+		{name: "unknown instance type", err: recommender.NewError(recommender.UnknownInstanceType, "not found"), status: http.StatusNotFound, code: "unknown_instance_type"},
+		{name: "no viable instances", err: recommender.NewError(recommender.NoViableInstances, "none"), status: http.StatusUnprocessableEntity, code: "no_viable_instances"},
+		{name: "over budget", err: recommender.NewError(recommender.OverBudget, "too expensive"), status: http.StatusUnprocessableEntity, code: "over_budget"},
+		{name: "product info unavailable", err: recommender.NewError(recommender.ProductInfoUnavailable, "failed"), status: http.StatusServiceUnavailable, code: "product_info_unavailable"},
+		{name: "recommendation timeout", err: recommender.NewError(recommender.RecommendationTimeout, "timeout"), status: http.StatusGatewayTimeout, code: "recommendation_timeout"},
+		{name: "request deadline exceeded", err: context.DeadlineExceeded, status: http.StatusServiceUnavailable, code: "request_timeout"},
+		{name: "request canceled", err: context.Canceled, status: statusClientClosedRequest, code: "request_canceled"},
+		{name: "unexpected error", err: errors.New("unexpected"), status: http.StatusInternalServerError, code: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, code := errorStatus(test.err)
+			assert.Equal(t, test.status, status)
+			assert.Equal(t, test.code, code)
+		})
+	}
+}
+
+// failingProductInfoSource fails every call with the same error
+type failingProductInfoSource struct {
+	err error
+}
+
+func (s failingProductInfoSource) GetProviders() ([]string, error) {
+	return nil, s.err
+}
+
+func (s failingProductInfoSource) GetAttributeValues(provider string, region string, attr string) ([]float64, error) {
+	return nil, s.err
+}
+
+func (s failingProductInfoSource) GetRegions(provider string) ([]*models.RegionResp, error) {
+	return nil, s.err
+}
+
+func (s failingProductInfoSource) GetRegion(provider string, region string) ([]string, error) {
+	return nil, s.err
+}
+
+func (s failingProductInfoSource) GetProductDetails(provider string, region string) ([]*models.ProductDetails, error) {
+	return nil, s.err
+}
+
+func TestRouteHandler_catalogErrorStatus(t *testing.T) {
+	unreachable := errors.New("dial tcp: connection refused")
+	unsupported := recommender.NewError(recommender.ProviderUnsupported, "provider [aws] is not supported")
+	notFound := recommender.NewError(recommender.RegionNotFound, "region [mars-1] is not known on provider [ec2]")
+
+	tests := []struct {
+		name    string
+		err     error
+		handler func(rh *RouteHandler) gin.HandlerFunc
+		status  int
+		code    string
+	}{
+		{name: "providers - upstream failure", err: unreachable, handler: func(rh *RouteHandler) gin.HandlerFunc { return rh.getProviders },
+			status: http.StatusServiceUnavailable, code: recommender.ProductInfoUnavailable},
+		{name: "regions - upstream failure", err: unreachable, handler: func(rh *RouteHandler) gin.HandlerFunc { return rh.getRegions },
+			status: http.StatusServiceUnavailable, code: recommender.ProductInfoUnavailable},
+		{name: "regions - unsupported provider", err: unsupported, handler: func(rh *RouteHandler) gin.HandlerFunc { return rh.getRegions },
+			status: http.StatusBadRequest, code: recommender.ProviderUnsupported},
+		{name: "zones - upstream failure", err: unreachable, handler: func(rh *RouteHandler) gin.HandlerFunc { return rh.getZones },
+			status: http.StatusServiceUnavailable, code: recommender.ProductInfoUnavailable},
+		{name: "zones - unknown region", err: notFound, handler: func(rh *RouteHandler) gin.HandlerFunc { return rh.getZones },
+			status: http.StatusNotFound, code: recommender.RegionNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := recommender.NewEngine(failingProductInfoSource{err: test.err})
+			assert.Nil(t, err, "the engine couldn't be created")
+			rh := NewRouteHandler(engine)
+
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			c.Params = gin.Params{{Key: providerParam, Value: "ec2"}, {Key: regionParam, Value: "mars-1"}}
+			test.handler(rh)(c)
+
+			assert.Equal(t, test.status, w.Code)
+			var body map[string]interface{}
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, test.code, body["code"])
+		})
+	}
+}
+
+func TestRouteHandler_signalHealthDetails(t *testing.T) {
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := recommender.NewEngine(fs)
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	gin.SetMode(gin.TestMode)
+	rh := NewRouteHandler(engine)
+	router := gin.New()
+	rh.ConfigureRoutes(router)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/details", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var health recommender.Health
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &health), "the response couldn't be parsed")
+	assert.True(t, health.Reachable, "the product info should be reachable")
+	assert.True(t, health.SnapshotAgeSeconds > 0, "the snapshot age should be reported")
+}
+
+func TestRouteHandler_compareInstanceTypes(t *testing.T) {
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := recommender.NewEngine(fs)
+	assert.Nil(t, err, "the engine couldn't be created")
+	assert.Nil(t, ConfigureValidator(fs), "the validator couldn't be configured")
+
+	gin.SetMode(gin.TestMode)
+	rh := NewRouteHandler(engine)
+	rh.SetRateLimiter(nil)
+	router := gin.New()
+	rh.ConfigureRoutes(router)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		code   string
+	}{
+		{name: "instance types compared", query: "?a=m5.xlarge&b=c5.xlarge", status: http.StatusOK},
+		{name: "instance type not available", query: "?a=m5.xlarge&b=x1.32xlarge", status: http.StatusNotFound, code: "unknown_instance_type"},
+		{name: "instance type missing", query: "?a=m5.xlarge", status: http.StatusBadRequest, code: "bad_params"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/recommender/ec2/eu-west-1/compare"+test.query, nil))
+			assert.Equal(t, test.status, w.Code)
+			if test.code != "" {
+				assert.Contains(t, w.Body.String(), test.code)
+				return
+			}
+			var cmp recommender.InstanceTypeComparison
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &cmp), "the response couldn't be parsed")
+			assert.Equal(t, "m5.xlarge", cmp.A.Type)
+			assert.Equal(t, "c5.xlarge", cmp.B.Type)
+			assert.Equal(t, "c5.xlarge", cmp.CheaperPerCpu)
+		})
+	}
+}
+
+func TestRouteHandler_recommendOptimum(t *testing.T) {
+	fs, err := recommender.NewFileProductInfoSource("../../../../pkg/recommender/testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := recommender.NewEngine(fs)
+	assert.Nil(t, err, "the engine couldn't be created")
+	assert.Nil(t, ConfigureValidator(fs), "the validator couldn't be configured")
+
+	gin.SetMode(gin.TestMode)
+	rh := NewRouteHandler(engine)
+	rh.SetRateLimiter(nil)
+	router := gin.New()
+	rh.ConfigureRoutes(router)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "cheapest provider region recommended", body: `{"sumCpu": 16, "sumMem": 64, "minNodes": 2, "maxNodes": 6}`, status: http.StatusOK},
+		{name: "unsupported provider", body: `{"providers": ["unknown"], "sumCpu": 16, "sumMem": 64, "minNodes": 2, "maxNodes": 6}`,
+			status: http.StatusBadRequest, code: "provider_unsupported"},
+		{name: "too many targets", body: `{"maxTargets": 1000, "sumCpu": 16, "sumMem": 64, "minNodes": 2, "maxNodes": 6}`,
+			status: http.StatusBadRequest, code: "bad_params"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/recommender/optimize", strings.NewReader(test.body)))
+			assert.Equal(t, test.status, w.Code)
+			if test.code != "" {
+				assert.Contains(t, w.Body.String(), test.code)
+				return
+			}
+			var resp recommender.OptimumResp
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp), "the response couldn't be parsed")
+			assert.Equal(t, recommender.Target{Provider: "ec2", Region: "eu-west-1"}, resp.Target)
+			assert.Equal(t, 1, resp.Searched)
+			assert.NotNil(t, resp.Recommendation)
+		})
+	}
+}
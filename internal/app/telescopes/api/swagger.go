@@ -0,0 +1,72 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// the generated swagger spec, relative to the working directory
+const defaultSwaggerSpec = "api/openapi-spec/recommender.json"
+
+// swaggerSpecFromEnv returns the swagger spec file set in the TELESCOPES_SWAGGER_SPEC environment variable, or an empty
+// string if the swagger ui is disabled with TELESCOPES_SWAGGER_UI=false
+func swaggerSpecFromEnv() string {
+	if value := os.Getenv("TELESCOPES_SWAGGER_UI"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Warnf("TELESCOPES_SWAGGER_UI is not a valid boolean: %s, the swagger ui is enabled", value)
+		} else if !enabled {
+			log.Info("the swagger ui is disabled")
+			return ""
+		}
+	}
+	if spec := os.Getenv("TELESCOPES_SWAGGER_SPEC"); spec != "" {
+		return spec
+	}
+	return defaultSwaggerSpec
+}
+
+// SetSwaggerSpec replaces the swagger spec file served with the swagger ui configured by the environment, an empty
+// file disables the swagger ui; it must be called before the routes are configured
+func (r *RouteHandler) SetSwaggerSpec(file string) {
+	r.swaggerSpec = file
+}
+
+// serveSwagger serves the swagger ui page with its assets and the swagger spec it displays
+func (r *RouteHandler) serveSwagger(c *gin.Context) {
+	switch c.Param("any") {
+	case "/", "/index.html":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+	case "/swagger-ui.css":
+		c.Data(http.StatusOK, "text/css; charset=utf-8", []byte(swaggerUIStyle))
+	case "/swagger-ui.js":
+		c.Data(http.StatusOK, "application/javascript; charset=utf-8", []byte(swaggerUIScript))
+	case "/swagger.json":
+		if _, err := os.Stat(r.swaggerSpec); err != nil {
+			logger(c).WithError(err).Errorf("could not read the swagger spec: %s", r.swaggerSpec)
+			c.JSON(http.StatusNotFound, gin.H{"code": "not_found", "message": "the swagger spec is not available"})
+			return
+		}
+		c.File(r.swaggerSpec)
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"code": "not_found", "message": "page not found"})
+	}
+}
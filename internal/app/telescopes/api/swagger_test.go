@@ -0,0 +1,116 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteHandler_serveSwagger(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  string
+		path  string
+		check func(w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "swagger ui served",
+			spec: "../../../../api/openapi-spec/recommender.json",
+			path: "/swagger/",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+				assert.Contains(t, w.Body.String(), `url: "swagger.json"`)
+				assert.NotContains(t, w.Body.String(), "https://", "the assets should be served by the application")
+			},
+		},
+		{
+			name: "swagger ui style served",
+			spec: "../../../../api/openapi-spec/recommender.json",
+			path: "/swagger/swagger-ui.css",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Contains(t, w.Header().Get("Content-Type"), "text/css")
+			},
+		},
+		{
+			name: "swagger ui script served",
+			spec: "../../../../api/openapi-spec/recommender.json",
+			path: "/swagger/swagger-ui.js",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Contains(t, w.Header().Get("Content-Type"), "application/javascript")
+				assert.Contains(t, w.Body.String(), "window.SwaggerUI")
+			},
+		},
+		{
+			name: "swagger spec served",
+			spec: "../../../../api/openapi-spec/recommender.json",
+			path: "/swagger/swagger.json",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+				var spec struct {
+					Swagger string                     `json:"swagger"`
+					Paths   map[string]json.RawMessage `json:"paths"`
+				}
+				assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &spec), "the spec couldn't be parsed")
+				assert.Equal(t, "2.0", spec.Swagger)
+				for _, path := range []string{"/recommender/:provider/:region/cluster", "/recommender/:provider/:region/instances", "/recommender/cluster/batch", "/recommender/compare"} {
+					assert.Contains(t, spec.Paths, path, "the spec should document the route")
+				}
+			},
+		},
+		{
+			name: "missing swagger spec",
+			spec: "missing.json",
+			path: "/swagger/swagger.json",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, w.Code)
+			},
+		},
+		{
+			name: "swagger ui disabled",
+			path: "/swagger/",
+			check: func(w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, w.Code)
+			},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rh := NewRouteHandler(nil)
+			// a single request is allowed on the api, the docs are neither rate limited nor authenticated
+			rh.SetRateLimiter(NewIPRateLimiter(1, 1))
+			rh.EnableAPIKeyAuth("", []string{"key-1"})
+			rh.SetSwaggerSpec(test.spec)
+			router := gin.New()
+			rh.ConfigureRoutes(router)
+
+			for i := 0; i < 3; i++ {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+				test.check(w)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+// the assets of the swagger ui, served by the application so that the docs don't depend on a CDN being reachable
+const (
+	// the swagger ui page, the assets and the spec are loaded from next to the page
+	swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Telescopes API</title>
+  <link rel="stylesheet" href="swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="swagger-ui.js"></script>
+  <script>
+    window.onload = function() {
+      SwaggerUI({url: "swagger.json", dom_id: "#swagger-ui"});
+    };
+  </script>
+</body>
+</html>
+`
+
+	// the style of the swagger ui
+	swaggerUIStyle = `body { margin: 0; font-family: sans-serif; color: #3b4151; background: #fafafa; }
+#swagger-ui { max-width: 1200px; margin: 0 auto; padding: 20px; }
+h1 { font-size: 32px; margin: 0 0 8px; }
+h1 small { font-size: 14px; color: #89bf04; margin-left: 8px; }
+.info p { white-space: pre-wrap; }
+.tag { font-size: 22px; margin: 32px 0 8px; padding-bottom: 8px; border-bottom: 1px solid #d8dde7; }
+.op { margin: 0 0 12px; border: 1px solid; border-radius: 4px; }
+.op.get { border-color: #61affe; background: rgba(97, 175, 254, .1); }
+.op.post { border-color: #49cc90; background: rgba(73, 204, 144, .1); }
+.op-summary { display: flex; align-items: center; padding: 6px; cursor: pointer; }
+.method { min-width: 70px; padding: 6px 0; border-radius: 3px; color: #fff; font-weight: bold; text-align: center; }
+.get .method { background: #61affe; }
+.post .method { background: #49cc90; }
+.path { margin: 0 10px; font-family: monospace; font-size: 16px; font-weight: bold; }
+.op-body { display: none; padding: 12px 20px; background: #fff; }
+.op.open .op-body { display: block; }
+.op-body h4 { margin: 16px 0 6px; }
+.op-body p { white-space: pre-wrap; }
+table { width: 100%; border-collapse: collapse; }
+th, td { padding: 6px; text-align: left; vertical-align: top; border-bottom: 1px solid #eee; }
+.required { color: #f93e3e; font-size: 12px; }
+pre { margin: 0; padding: 10px; overflow: auto; background: #41444e; color: #fff; border-radius: 4px; font-size: 13px; }
+.error { color: #f93e3e; }
+`
+
+	// the swagger ui script, it renders the operations of the spec with their parameters and the models of their
+	// requests and responses
+	swaggerUIScript = `(function() {
+  "use strict";
+
+  function el(tag, className, text) {
+    var e = document.createElement(tag);
+    if (className) {
+      e.className = className;
+    }
+    if (text !== undefined) {
+      e.appendChild(document.createTextNode(text));
+    }
+    return e;
+  }
+
+  // resolve returns the schema a reference points to within the spec
+  function resolve(spec, schema) {
+    while (schema && schema.$ref) {
+      var path = schema.$ref.replace(/^#\//, "").split("/");
+      schema = spec;
+      for (var i = 0; i < path.length; i++) {
+        schema = schema[path[i]];
+      }
+    }
+    return schema || {};
+  }
+
+  // example returns a sample value of the schema, the references already visited are not expanded again
+  function example(spec, schema, seen) {
+    if (schema && schema.$ref) {
+      if (seen[schema.$ref]) {
+        return {};
+      }
+      seen = Object.create(seen);
+      seen[schema.$ref] = true;
+    }
+    schema = resolve(spec, schema);
+    switch (schema.type) {
+    case "array":
+      return [example(spec, schema.items, seen)];
+    case "string":
+      return schema.format === "date-time" ? "2018-01-01T00:00:00Z" : "string";
+    case "integer":
+      return 0;
+    case "number":
+      return 0.0;
+    case "boolean":
+      return false;
+    }
+    var obj = {};
+    if (schema.properties) {
+      Object.keys(schema.properties).forEach(function(name) {
+        obj[name] = example(spec, schema.properties[name], seen);
+      });
+    } else if (schema.additionalProperties) {
+      obj.key = example(spec, schema.additionalProperties, seen);
+    }
+    return obj;
+  }
+
+  function model(spec, schema) {
+    return el("pre", "", JSON.stringify(example(spec, schema, {}), null, 2));
+  }
+
+  function parameters(spec, params) {
+    var table = el("table");
+    var head = el("tr");
+    ["Name", "In", "Type", "Description"].forEach(function(h) {
+      head.appendChild(el("th", "", h));
+    });
+    table.appendChild(head);
+    params.forEach(function(p) {
+      var row = el("tr");
+      var name = el("td", "", p.name);
+      if (p.required) {
+        name.appendChild(el("div", "required", "required"));
+      }
+      row.appendChild(name);
+      row.appendChild(el("td", "", p["in"]));
+      var type = el("td");
+      if (p.schema) {
+        type.appendChild(model(spec, p.schema));
+      } else {
+        type.appendChild(document.createTextNode(p.type + (p.items ? " of " + p.items.type : "")));
+      }
+      row.appendChild(type);
+      row.appendChild(el("td", "", p.description || ""));
+      table.appendChild(row);
+    });
+    return table;
+  }
+
+  function operation(spec, path, method, op) {
+    var div = el("div", "op " + method);
+    var summary = el("div", "op-summary");
+    summary.appendChild(el("span", "method", method.toUpperCase()));
+    summary.appendChild(el("span", "path", path));
+    summary.appendChild(el("span", "", op.summary || ""));
+    summary.onclick = function() {
+      div.classList.toggle("open");
+    };
+    div.appendChild(summary);
+
+    var body = el("div", "op-body");
+    if (op.description) {
+      body.appendChild(el("p", "", op.description));
+    }
+    if (op.parameters && op.parameters.length) {
+      body.appendChild(el("h4", "", "Parameters"));
+      body.appendChild(parameters(spec, op.parameters));
+    }
+    body.appendChild(el("h4", "", "Responses"));
+    Object.keys(op.responses || {}).forEach(function(code) {
+      var resp = resolve(spec, op.responses[code]);
+      body.appendChild(el("p", "", code + " " + (resp.description || "")));
+      if (resp.schema) {
+        body.appendChild(model(spec, resp.schema));
+      }
+    });
+    div.appendChild(body);
+    return div;
+  }
+
+  function render(spec, root) {
+    var info = el("div", "info");
+    var title = el("h1", "", spec.info.title);
+    title.appendChild(el("small", "", spec.info.version));
+    info.appendChild(title);
+    info.appendChild(el("p", "", spec.info.description || ""));
+    info.appendChild(el("p", "", "Base path: " + spec.basePath));
+    root.appendChild(info);
+
+    // the operations are listed under their first tag
+    var tags = {};
+    Object.keys(spec.paths).sort().forEach(function(path) {
+      Object.keys(spec.paths[path]).forEach(function(method) {
+        var op = spec.paths[path][method];
+        var tag = (op.tags && op.tags[0]) || "default";
+        (tags[tag] = tags[tag] || []).push(operation(spec, path, method, op));
+      });
+    });
+    Object.keys(tags).sort().forEach(function(tag) {
+      root.appendChild(el("div", "tag", tag));
+      tags[tag].forEach(function(op) {
+        root.appendChild(op);
+      });
+    });
+  }
+
+  window.SwaggerUI = function(options) {
+    var root = document.querySelector(options.dom_id);
+    var xhr = new XMLHttpRequest();
+    xhr.open("GET", options.url);
+    xhr.onload = function() {
+      if (xhr.status !== 200) {
+        root.appendChild(el("p", "error", "the swagger spec could not be loaded: " + xhr.status));
+        return;
+      }
+      render(JSON.parse(xhr.responseText), root);
+    };
+    xhr.onerror = function() {
+      root.appendChild(el("p", "error", "the swagger spec could not be loaded"));
+    };
+    xhr.send();
+  };
+})();
+`
+)
@@ -0,0 +1,208 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+)
+
+const (
+	// the query parameter selecting the format of the recommendation
+	formatParam = "format"
+	// the recommendation is serialized as a Terraform JSON configuration fragment
+	terraformFormat = "terraform"
+	// the default format, the recommendation is serialized as is
+	jsonFormat = "json"
+)
+
+// characters not allowed in the names of Terraform resources
+var terraformNameRegexp = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// terraformResourceTypes maps the providers to the Terraform resource types of their node pools
+var terraformResourceTypes = map[string]string{
+	"ec2":     "aws_eks_node_group",
+	"gce":     "google_container_node_pool",
+	"azure":   "azurerm_kubernetes_cluster_node_pool",
+	"oracle":  "oci_containerengine_node_pool",
+	"alibaba": "alicloud_cs_kubernetes_node_pool",
+}
+
+// terraformConfig is a Terraform JSON configuration fragment holding resources by type and name
+type terraformConfig struct {
+	Resource map[string]map[string]interface{} `json:"resource"`
+}
+
+// awsNodeGroup is the aws_eks_node_group resource of a node pool
+type awsNodeGroup struct {
+	ClusterName   string           `json:"cluster_name"`
+	NodeGroupName string           `json:"node_group_name"`
+	NodeRoleArn   string           `json:"node_role_arn"`
+	SubnetIds     string           `json:"subnet_ids"`
+	InstanceTypes []string         `json:"instance_types"`
+	CapacityType  string           `json:"capacity_type"`
+	ScalingConfig awsScalingConfig `json:"scaling_config"`
+}
+
+// awsScalingConfig is the scaling configuration of an aws_eks_node_group
+type awsScalingConfig struct {
+	DesiredSize int `json:"desired_size"`
+	MinSize     int `json:"min_size"`
+	MaxSize     int `json:"max_size"`
+}
+
+// googleNodePool is the google_container_node_pool resource of a node pool
+type googleNodePool struct {
+	Name          string           `json:"name"`
+	Cluster       string           `json:"cluster"`
+	Location      string           `json:"location"`
+	NodeLocations []string         `json:"node_locations,omitempty"`
+	NodeCount     int              `json:"node_count"`
+	NodeConfig    googleNodeConfig `json:"node_config"`
+}
+
+// googleNodeConfig is the node configuration of a google_container_node_pool
+type googleNodeConfig struct {
+	MachineType string `json:"machine_type"`
+	Spot        bool   `json:"spot"`
+}
+
+// azureNodePool is the azurerm_kubernetes_cluster_node_pool resource of a node pool
+type azureNodePool struct {
+	Name                string `json:"name"`
+	KubernetesClusterId string `json:"kubernetes_cluster_id"`
+	VmSize              string `json:"vm_size"`
+	NodeCount           int    `json:"node_count"`
+	Priority            string `json:"priority"`
+}
+
+// oracleNodePool is the oci_containerengine_node_pool resource of a node pool
+type oracleNodePool struct {
+	Name              string            `json:"name"`
+	ClusterId         string            `json:"cluster_id"`
+	CompartmentId     string            `json:"compartment_id"`
+	KubernetesVersion string            `json:"kubernetes_version"`
+	NodeShape         string            `json:"node_shape"`
+	NodeConfigDetails oracleNodeDetails `json:"node_config_details"`
+}
+
+// oracleNodeDetails is the node configuration of an oci_containerengine_node_pool
+type oracleNodeDetails struct {
+	Size int `json:"size"`
+}
+
+// alibabaNodePool is the alicloud_cs_kubernetes_node_pool resource of a node pool
+type alibabaNodePool struct {
+	Name          string   `json:"name"`
+	ClusterId     string   `json:"cluster_id"`
+	VswitchIds    string   `json:"vswitch_ids"`
+	InstanceTypes []string `json:"instance_types"`
+	DesiredSize   int      `json:"desired_size"`
+	SpotStrategy  string   `json:"spot_strategy"`
+}
+
+// terraformNodePools serializes the recommended node pools into a Terraform JSON configuration fragment of the node
+// pool resources of the provider; the cluster the node pools belong to is referenced through Terraform variables
+func terraformNodePools(provider string, region string, resp *recommender.ClusterRecommendationResp) (*terraformConfig, error) {
+	resourceType, ok := terraformResourceTypes[provider]
+	if !ok {
+		return nil, fmt.Errorf("the terraform format is not supported on provider [%s]", provider)
+	}
+
+	resources := make(map[string]interface{})
+
+	for _, np := range resp.NodePools {
+		if np.SumNodes == 0 {
+			continue
+		}
+		name := terraformName(np)
+		spot := np.VmClass == "spot"
+
+		switch provider {
+		case "ec2":
+			capacityType := "ON_DEMAND"
+			if spot {
+				capacityType = "SPOT"
+			}
+			resources[name] = awsNodeGroup{
+				ClusterName:   "${var.cluster_name}",
+				NodeGroupName: name,
+				NodeRoleArn:   "${var.node_role_arn}",
+				SubnetIds:     "${var.subnet_ids}",
+				InstanceTypes: []string{np.VmType.Type},
+				CapacityType:  capacityType,
+				ScalingConfig: awsScalingConfig{DesiredSize: np.SumNodes, MinSize: np.SumNodes, MaxSize: np.SumNodes},
+			}
+		case "gce":
+			resources[name] = googleNodePool{
+				Name:          name,
+				Cluster:       "${var.cluster_name}",
+				Location:      region,
+				NodeLocations: resp.Zones,
+				NodeCount:     np.SumNodes,
+				NodeConfig:    googleNodeConfig{MachineType: np.VmType.Type, Spot: spot},
+			}
+		case "azure":
+			priority := "Regular"
+			if spot {
+				priority = "Spot"
+			}
+			resources[name] = azureNodePool{
+				// the names of the azure node pools are limited to 12 lowercase alphanumeric characters
+				Name:                fmt.Sprintf("pool%d", len(resources)),
+				KubernetesClusterId: "${var.cluster_id}",
+				VmSize:              np.VmType.Type,
+				NodeCount:           np.SumNodes,
+				Priority:            priority,
+			}
+		case "oracle":
+			resources[name] = oracleNodePool{
+				Name:              name,
+				ClusterId:         "${var.cluster_id}",
+				CompartmentId:     "${var.compartment_id}",
+				KubernetesVersion: "${var.kubernetes_version}",
+				NodeShape:         np.VmType.Type,
+				NodeConfigDetails: oracleNodeDetails{Size: np.SumNodes},
+			}
+		case "alibaba":
+			spotStrategy := "NoSpot"
+			if spot {
+				spotStrategy = "SpotAsPriceGo"
+			}
+			resources[name] = alibabaNodePool{
+				Name:          name,
+				ClusterId:     "${var.cluster_id}",
+				VswitchIds:    "${var.vswitch_ids}",
+				InstanceTypes: []string{np.VmType.Type},
+				DesiredSize:   np.SumNodes,
+				SpotStrategy:  spotStrategy,
+			}
+		}
+	}
+
+	config := &terraformConfig{Resource: map[string]map[string]interface{}{}}
+	if len(resources) > 0 {
+		config.Resource[resourceType] = resources
+	}
+	return config, nil
+}
+
+// terraformName derives a valid Terraform resource name from the instance type and class of the node pool
+func terraformName(np recommender.NodePool) string {
+	return terraformNameRegexp.ReplaceAllString(strings.ToLower(np.VmType.Type), "_") + "_" + np.VmClass
+}
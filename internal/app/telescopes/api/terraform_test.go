@@ -0,0 +1,101 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_terraformNodePools(t *testing.T) {
+	resp := &recommender.ClusterRecommendationResp{
+		Zones: []string{"europe-west1-b"},
+		NodePools: []recommender.NodePool{
+			{VmType: recommender.VirtualMachine{Type: "m5.xlarge"}, SumNodes: 3, VmClass: "regular"},
+			{VmType: recommender.VirtualMachine{Type: "m5.2xlarge"}, SumNodes: 2, VmClass: "spot"},
+			{VmType: recommender.VirtualMachine{Type: "c5.xlarge"}, SumNodes: 0, VmClass: "spot"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		provider string
+		check    func(config string, err error)
+	}{
+		{
+			name:     "eks node groups on ec2",
+			provider: "ec2",
+			check: func(config string, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.JSONEq(t, `{"resource": {"aws_eks_node_group": {
+					"m5_xlarge_regular": {
+						"cluster_name": "${var.cluster_name}", "node_group_name": "m5_xlarge_regular",
+						"node_role_arn": "${var.node_role_arn}", "subnet_ids": "${var.subnet_ids}",
+						"instance_types": ["m5.xlarge"], "capacity_type": "ON_DEMAND",
+						"scaling_config": {"desired_size": 3, "min_size": 3, "max_size": 3}
+					},
+					"m5_2xlarge_spot": {
+						"cluster_name": "${var.cluster_name}", "node_group_name": "m5_2xlarge_spot",
+						"node_role_arn": "${var.node_role_arn}", "subnet_ids": "${var.subnet_ids}",
+						"instance_types": ["m5.2xlarge"], "capacity_type": "SPOT",
+						"scaling_config": {"desired_size": 2, "min_size": 2, "max_size": 2}
+					}
+				}}}`, config)
+			},
+		},
+		{
+			name:     "gke node pools on gce",
+			provider: "gce",
+			check: func(config string, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Contains(t, config, `"google_container_node_pool"`)
+				assert.Contains(t, config, `"m5_2xlarge_spot":{"name":"m5_2xlarge_spot","cluster":"${var.cluster_name}","location":"europe-west1","node_locations":["europe-west1-b"],"node_count":2,"node_config":{"machine_type":"m5.2xlarge","spot":true}}`)
+			},
+		},
+		{
+			name:     "aks node pools on azure",
+			provider: "azure",
+			check: func(config string, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Contains(t, config, `"azurerm_kubernetes_cluster_node_pool"`)
+				assert.Contains(t, config, `"priority":"Spot"`)
+				assert.Contains(t, config, `"priority":"Regular"`)
+				assert.NotContains(t, config, "c5.xlarge", "empty node pools should be skipped")
+			},
+		},
+		{
+			name:     "error - provider not supported",
+			provider: "dummy",
+			check: func(config string, err error) {
+				assert.EqualError(t, err, "the terraform format is not supported on provider [dummy]")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := terraformNodePools(test.provider, "europe-west1", resp)
+			if err != nil {
+				test.check("", err)
+				return
+			}
+			b, err := json.Marshal(config)
+			assert.Nil(t, err)
+			test.check(string(b), nil)
+		})
+	}
+}
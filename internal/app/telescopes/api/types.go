@@ -14,11 +14,267 @@
 
 package api
 
+import "github.com/banzaicloud/telescopes/pkg/recommender"
+
 // GetRecommendationParams is a placeholder for the recommendation route's path parameters
-// swagger:parameters recommendClusterSetup
+// swagger:parameters recommendClusterSetup recommendClusterGroups validateRecommendation getZones
 type GetRecommendationParams struct {
 	// in:path
 	Provider string `json:"provider"`
 	// in:path
 	Region string `json:"region"`
 }
+
+// RecommendationFormatParams holds the format of the recommendation
+// swagger:parameters recommendClusterSetup
+type RecommendationFormatParams struct {
+	// the format of the recommendation: json (default) or terraform, a Terraform JSON configuration fragment of the node pools
+	// in:query
+	Format string `json:"format"`
+	// list the node pools of the json recommendation under their instance families with the subtotals of the families
+	// in:query
+	GroupByFamily bool `json:"groupByFamily"`
+}
+
+// RecommendationParams holds the recommendation request
+// swagger:parameters recommendClusterSetup recommendClusterInDefaultRegion
+type RecommendationParams struct {
+	// in:body
+	Body recommender.ClusterRecommendationReq
+}
+
+// ProvidersResponse holds the list of the supported cloud providers
+// swagger:response ProvidersResponse
+type ProvidersResponse struct {
+	// in:body
+	Body []recommender.Provider
+}
+
+// GetRegionsParams is a placeholder for the regions route's path parameters
+// swagger:parameters getRegions
+type GetRegionsParams struct {
+	// in:path
+	Provider string `json:"provider"`
+}
+
+// RegionsResponse holds the list of the regions of a provider
+// swagger:response RegionsResponse
+type RegionsResponse struct {
+	// in:body
+	Body []recommender.Region
+}
+
+// ZonesResponse holds the list of the availability zones in a region
+// swagger:response ZonesResponse
+type ZonesResponse struct {
+	// in:body
+	Body []string
+}
+
+// GetInstanceTypesParams is a placeholder for the instance types route's path parameters
+// swagger:parameters listInstances
+type GetInstanceTypesParams struct {
+	// in:path
+	Provider string `json:"provider"`
+	// in:path
+	Region string `json:"region"`
+}
+
+// InstanceFiltersParams holds the filters of the listed instance types
+// swagger:parameters listInstances
+type InstanceFiltersParams struct {
+	// in:query
+	recommender.InstanceFilters
+}
+
+// PageParams holds the page of the listed instance types
+// swagger:parameters listInstances
+type PageParams struct {
+	// in:query
+	recommender.Page
+}
+
+// InstanceTypesResponse holds a page of the instance types matching the filters
+// swagger:response InstanceTypesResponse
+type InstanceTypesResponse struct {
+	// in:body
+	Body recommender.InstanceTypePage
+}
+
+// GetInstanceTypeParams is a placeholder for the instance type route's path parameters
+// swagger:parameters getInstanceType
+type GetInstanceTypeParams struct {
+	// in:path
+	Provider string `json:"provider"`
+	// in:path
+	Region string `json:"region"`
+	// in:path
+	Type string `json:"type"`
+}
+
+// CompareInstanceTypesParams is a placeholder for the instance type comparison route's path parameters
+// swagger:parameters compareInstanceTypes
+type CompareInstanceTypesParams struct {
+	// in:path
+	Provider string `json:"provider"`
+	// in:path
+	Region string `json:"region"`
+	// in:query
+	A string `json:"a"`
+	// in:query
+	B string `json:"b"`
+}
+
+// InstanceTypeComparisonResponse holds the product info of two instance types side-by-side
+// swagger:response InstanceTypeComparisonResponse
+type InstanceTypeComparisonResponse struct {
+	// in:body
+	Body recommender.InstanceTypeComparison
+}
+
+// InstanceTypeResponse holds the product info of an instance type
+// swagger:response InstanceTypeResponse
+type InstanceTypeResponse struct {
+	// in:body
+	Body recommender.InstanceType
+}
+
+// BatchRecommendationParams holds the requests of a batch recommendation
+// swagger:parameters recommendClusterBatch
+type BatchRecommendationParams struct {
+	// in:body
+	Body []BatchRecommendationReq
+}
+
+// BatchRecommendationResponse holds the results of a batch recommendation, in the order of the requests
+// swagger:response BatchRecommendationResponse
+type BatchRecommendationResponse struct {
+	// in:body
+	Body []BatchRecommendationResult
+}
+
+// GetCheapestRegionParams is a placeholder for the cheapest region route's path parameters
+// swagger:parameters recommendCheapestRegion
+type GetCheapestRegionParams struct {
+	// in:path
+	Provider string `json:"provider"`
+}
+
+// CheapestRegionParams holds the recommendation request with the candidate regions
+// swagger:parameters recommendCheapestRegion
+type CheapestRegionParams struct {
+	// in:body
+	Body CheapestRegionReq
+}
+
+// CheapestRegionResponse holds the recommendation in the cheapest region and the ranking of the candidate regions
+// swagger:response CheapestRegionResponse
+type CheapestRegionResponse struct {
+	// in:body
+	Body recommender.CheapestRegionResp
+}
+
+// GetDefaultRegionParams is a placeholder for the default region recommendation route's path parameters
+// swagger:parameters recommendClusterInDefaultRegion
+type GetDefaultRegionParams struct {
+	// in:path
+	Provider string `json:"provider"`
+}
+
+// GetFailoverParams is a placeholder for the failover route's path parameters
+// swagger:parameters recommendFailover
+type GetFailoverParams struct {
+	// in:path
+	Provider string `json:"provider"`
+}
+
+// FailoverParams holds the recommendation request with the candidate regions in their order of priority
+// swagger:parameters recommendFailover
+type FailoverParams struct {
+	// in:body
+	Body FailoverReq
+}
+
+// FailoverResponse holds the recommendations in the candidate regions in their order of priority
+// swagger:response FailoverResponse
+type FailoverResponse struct {
+	// in:body
+	Body recommender.FailoverResp
+}
+
+// OptimumParams holds the recommendation request with the scope of the search
+// swagger:parameters recommendOptimum
+type OptimumParams struct {
+	// in:body
+	Body OptimumReq
+}
+
+// OptimumResponse holds the recommendation in the cheapest provider region and the ranking of the cheapest ones
+// swagger:response OptimumResponse
+type OptimumResponse struct {
+	// in:body
+	Body recommender.OptimumResp
+}
+
+// ComparisonParams holds the recommendation request with the targets
+// swagger:parameters recommendComparison
+type ComparisonParams struct {
+	// in:body
+	Body ComparisonReq
+}
+
+// ComparisonResponse holds the recommendations for the targets and their ranking
+// swagger:response ComparisonResponse
+type ComparisonResponse struct {
+	// in:body
+	Body ComparisonResp
+}
+
+// GetJobParams is a placeholder for the job status route's path parameters
+// swagger:parameters getJob
+type GetJobParams struct {
+	// in:path
+	ID string `json:"id"`
+}
+
+// JobResponse holds the status of an asynchronous comparison
+// swagger:response JobResponse
+type JobResponse struct {
+	// in:body
+	Body Job
+}
+
+// CapabilitiesResponse holds the features the deployment supports
+// swagger:response CapabilitiesResponse
+type CapabilitiesResponse struct {
+	// in:body
+	Body recommender.Capabilities
+}
+
+// ClusterGroupsParams holds the requirements of the node groups of the cluster
+// swagger:parameters recommendClusterGroups
+type ClusterGroupsParams struct {
+	// in:body
+	Body recommender.ClusterGroupsReq
+}
+
+// ClusterGroupsResponse holds the recommendation for each node group of the cluster
+// swagger:response ClusterGroupsResponse
+type ClusterGroupsResponse struct {
+	// in:body
+	Body recommender.ClusterGroupsResp
+}
+
+// FeasibilityParams holds the recommendation request to validate
+// swagger:parameters validateRecommendation
+type FeasibilityParams struct {
+	// in:body
+	Body recommender.ClusterRecommendationReq
+}
+
+// FeasibilityResponse holds whether the recommendation request is valid and feasible, with the reasons if it isn't
+// swagger:response FeasibilityResponse
+type FeasibilityResponse struct {
+	// in:body
+	Body recommender.Feasibility
+}
@@ -18,10 +18,11 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+	"sort"
+	"strings"
+	"unicode"
 
-	"github.com/banzaicloud/productinfo/pkg/productinfo-client/client"
-	"github.com/banzaicloud/productinfo/pkg/productinfo-client/client/providers"
-	"github.com/banzaicloud/productinfo/pkg/productinfo-client/client/regions"
+	"github.com/banzaicloud/telescopes/pkg/recommender"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/sirupsen/logrus"
@@ -35,13 +36,19 @@ const (
 	ntwExtra  = "extra"
 )
 
+// the key of the validated recommendation request in the gin context
+const recommendationReqKey = "recommendationReq"
+
+var architectures = []string{recommender.Amd64, recommender.Arm64}
+
 // ConfigureValidator configures the Gin validator with custom validator functions
-func ConfigureValidator(pc *client.Productinfo) error {
+func ConfigureValidator(pc recommender.ProductInfoSource) error {
 	v := binding.Validator.Engine().(*validator.Validate)
 	v.RegisterValidation("provider", providerValidator(pc))
 	v.RegisterValidation("region", regionValidator(pc))
 	v.RegisterValidation("zone", zoneValidator(pc))
 	v.RegisterValidation("network", networkPerfValidator())
+	v.RegisterValidation("architecture", architectureValidator())
 	return nil
 }
 
@@ -52,9 +59,9 @@ func ValidatePathParam(name string, validate *validator.Validate, tags ...string
 		for _, tag := range tags {
 			err := validate.Field(p, tag)
 			if err != nil {
-				logrus.Errorf("validation failed. err: %s", err.Error())
+				logger(c).Errorf("validation failed. err: %s", err.Error())
 				c.Abort()
-				c.JSON(http.StatusBadRequest, gin.H{
+				respond(c, http.StatusBadRequest, gin.H{
 					"code":    "bad_params",
 					"message": fmt.Sprintf("invalid %s parameter", name),
 					"params":  map[string]string{name: p},
@@ -65,38 +72,146 @@ func ValidatePathParam(name string, validate *validator.Validate, tags ...string
 	}
 }
 
-// ValidateRegionData middleware function to validate region information in the request path
-func ValidateRegionData(validate *validator.Validate) gin.HandlerFunc {
-	const (
-		providerParam = "provider"
-		regionParam   = "region"
-	)
+// ValidateRegionData middleware function to validate region information in the request path, regions unknown on the
+// provider are rejected with 404 listing the known ones
+func ValidateRegionData(regions *RegionCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provider, region := c.Param(providerParam), c.Param(regionParam)
+		logger(c).Debugf("region data being validated: %s", newRegionData(provider, region))
+
+		known, err := regions.Regions(provider)
+		if err != nil {
+			logger(c).WithError(err).Errorf("could not get regions for provider: %s", provider)
+			c.Abort()
+			errorResponse(c, err)
+			return
+		}
+		if !contains(known, region) {
+			logger(c).Errorf("unknown region: %s, provider: %s", region, provider)
+			c.Abort()
+			respond(c, http.StatusNotFound, gin.H{
+				"code":    recommender.RegionNotFound,
+				"message": fmt.Sprintf("region [%s] is not known on provider [%s]", region, provider),
+				"regions": known,
+			})
+			return
+		}
+	}
+}
+
+// ValidateRecommendationReq middleware function to validate the recommendation request in the body, the validated
+// request is stored in the context for the handlers
+func ValidateRecommendationReq() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		regionData := newRegionData(c.Param(providerParam), c.Param(regionParam))
-		logrus.Debugf("region data being validated: %s", regionData)
-		err := validate.Struct(regionData)
+		req, err := bindRecommendationReq(c)
 		if err != nil {
-			logrus.Errorf("validation failed. err: %s", err.Error())
+			logger(c).Errorf("validation failed. err: %s", err.Error())
 			c.Abort()
-			c.JSON(http.StatusBadRequest, gin.H{
+			respond(c, http.StatusBadRequest, gin.H{
 				"code":    "bad_params",
-				"message": fmt.Sprintf("invalid region in path: %s", regionData),
-				"params":  regionData,
+				"message": "validation failed",
+				"cause":   validationMessage(err),
+				"details": validationDetails(err),
 			})
 			return
 		}
+		c.Set(recommendationReqKey, req)
+	}
+}
+
+// bindRecommendationReq binds and validates the recommendation request in the body, decorated with the provider and
+// region of the path
+func bindRecommendationReq(c *gin.Context) (RequestWrapper, error) {
+	req := RequestWrapper{Provider: c.Param(providerParam), Region: c.Param(regionParam)}
+	err := c.ShouldBindJSON(&req)
+	return req, err
+}
+
+// FieldError describes the failed validation of a field of the request
+type FieldError struct {
+	// the name of the field in the json body
+	Field string `json:"field"`
+	// the validation tag that failed
+	Tag     string `json:"tag"`
+	Message string `json:"message"`
+}
+
+// validationDetails returns the failed field validations if the error is a validation error, nil otherwise
+func validationDetails(err error) []FieldError {
+	errs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return nil
+	}
+
+	details := make([]FieldError, 0, len(errs))
+	for _, fe := range errs {
+		details = append(details, FieldError{
+			Field:   jsonName(fe.Field),
+			Tag:     fe.Tag,
+			Message: fmt.Sprintf("%s %s", jsonName(fe.Field), fieldErrorMessage(fe)),
+		})
 	}
+	// the validation errors are not ordered
+	sort.Slice(details, func(i, j int) bool {
+		return details[i].Message < details[j].Message
+	})
+	return details
 }
 
-func providerValidator(pc *client.Productinfo) validator.Func {
+// validationMessage returns a message naming the invalid fields of the request if the error is a validation error,
+// the message of the error otherwise
+func validationMessage(err error) string {
+	details := validationDetails(err)
+	if details == nil {
+		return err.Error()
+	}
+
+	msgs := make([]string, len(details))
+	for i, d := range details {
+		msgs[i] = d.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// fieldErrorMessage describes the failed validation of a field
+func fieldErrorMessage(fe *validator.FieldError) string {
+	switch fe.Tag {
+	case "required":
+		return "is required"
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fe.Param)
+	case "min":
+		return fmt.Sprintf("must be at least %s", fe.Param)
+	case "max":
+		return fmt.Sprintf("must be at most %s", fe.Param)
+	case "ltefield":
+		return fmt.Sprintf("must be less than or equal to %s", jsonName(fe.Param))
+	case "gtefield":
+		return fmt.Sprintf("must be greater than or equal to %s", jsonName(fe.Param))
+	default:
+		return fmt.Sprintf("is invalid: %v", fe.Value)
+	}
+}
+
+// jsonName returns the name of the request field in the json body
+func jsonName(field string) string {
+	if field == "" {
+		return field
+	}
+	r := []rune(field)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+func providerValidator(pc recommender.ProductInfoSource) validator.Func {
 	return func(v *validator.Validate, topStruct reflect.Value, currentStruct reflect.Value, field reflect.Value, fieldtype reflect.Type, fieldKind reflect.Kind, param string) bool {
-		cProviders, err := pc.Providers.GetProviders(providers.NewGetProvidersParams())
+		cProviders, err := pc.GetProviders()
 		if err != nil {
 			logrus.WithError(err).Errorf("failed to get providers")
 			return false
 		}
-		for _, p := range cProviders.Payload.Providers {
-			if p.Provider == field.String() {
+		for _, p := range cProviders {
+			if p == field.String() {
 				return true
 			}
 		}
@@ -123,20 +238,20 @@ func newRegionData(provider string, region string) regionData {
 }
 
 // validationFn validation logic for the region data to be registered with the validator
-func regionValidator(pc *client.Productinfo) validator.Func {
+func regionValidator(pc recommender.ProductInfoSource) validator.Func {
 
 	return func(v *validator.Validate, topStruct reflect.Value, currentStruct reflect.Value, field reflect.Value, fieldtype reflect.Type, fieldKind reflect.Kind, param string) bool {
 		currentProvider := currentStruct.FieldByName("Provider").String()
 		currentRegion := currentStruct.FieldByName("Region").String()
 
-		response, err := pc.Regions.GetRegions(regions.NewGetRegionsParams().WithProvider(currentProvider))
+		response, err := pc.GetRegions(currentProvider)
 		if err != nil {
 			logrus.WithError(err).Errorf("could not get regions for provider: %s", currentProvider)
 			return false
 		}
 
-		logrus.Debugf("current region: %s, regions: %#v", currentRegion, response.Payload)
-		for _, r := range response.Payload {
+		logrus.Debugf("current region: %s, regions: %#v", currentRegion, response)
+		for _, r := range response {
 			if r.ID == currentRegion {
 				return true
 			}
@@ -146,14 +261,18 @@ func regionValidator(pc *client.Productinfo) validator.Func {
 }
 
 // zoneValidator validates the zone in the recommendation request.
-func zoneValidator(pc *client.Productinfo) validator.Func {
+func zoneValidator(pc recommender.ProductInfoSource) validator.Func {
 	return func(v *validator.Validate, topStruct reflect.Value, currentStruct reflect.Value, field reflect.Value,
 		fieldtype reflect.Type, fieldKind reflect.Kind, param string) bool {
 
 		provider := reflect.Indirect(topStruct).FieldByName("Provider").String()
 		region := reflect.Indirect(topStruct).FieldByName("Region").String()
-		response, _ := pc.Regions.GetRegion(regions.NewGetRegionParams().WithProvider(provider).WithRegion(region))
-		for _, zone := range response.Payload.Zones {
+		zones, err := pc.GetRegion(provider, region)
+		if err != nil {
+			logrus.WithError(err).Errorf("could not describe region: %s, provider: %s", region, provider)
+			return false
+		}
+		for _, zone := range zones {
 			if zone == field.String() {
 				return true
 			}
@@ -175,3 +294,17 @@ func networkPerfValidator() validator.Func {
 		return false
 	}
 }
+
+// architectureValidator validates the cpu architecture in the recommendation request.
+func architectureValidator() validator.Func {
+	return func(v *validator.Validate, topStruct reflect.Value, currentStruct reflect.Value, field reflect.Value,
+		fieldtype reflect.Type, fieldKind reflect.Kind, param string) bool {
+
+		for _, a := range architectures {
+			if field.String() == a {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/banzaicloud/telescopes/pkg/recommender"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+// acceptAll is a validation function accepting every value, replacing the ones depending on the product info
+func acceptAll(v *validator.Validate, topStruct reflect.Value, currentStruct reflect.Value, field reflect.Value,
+	fieldtype reflect.Type, fieldKind reflect.Kind, param string) bool {
+	return true
+}
+
+// validationRouter returns a router serving the validated recommendation request
+func validationRouter() *gin.Engine {
+	v := binding.Validator.Engine().(*validator.Validate)
+	v.RegisterValidation("zone", acceptAll)
+	v.RegisterValidation("network", networkPerfValidator())
+	v.RegisterValidation("architecture", architectureValidator())
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/:provider/:region/cluster", ValidateRecommendationReq(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, c.MustGet(recommendationReqKey))
+	})
+	return router
+}
+
+func TestValidateRecommendationReq(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		check func(code int, cause string)
+	}{
+		{
+			name: "valid request",
+			body: `{"sumCpu": 0.5, "sumMem": 0.5, "minNodes": 1, "maxNodes": 1, "onDemandPct": 100}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusOK, code)
+			},
+		},
+		{
+			name: "onDemandPct at the lower boundary",
+			body: `{"sumCpu": 10, "sumMem": 10, "minNodes": 1, "maxNodes": 1, "onDemandPct": 0}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusOK, code)
+			},
+		},
+		{
+			name: "error - zero sumCpu",
+			body: `{"sumCpu": 0, "sumMem": 10, "minNodes": 1, "maxNodes": 1}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusBadRequest, code)
+				assert.Equal(t, "sumCpu must be greater than 0", cause)
+			},
+		},
+		{
+			name: "error - negative sumCpu",
+			body: `{"sumCpu": -1, "sumMem": 10, "minNodes": 1, "maxNodes": 1}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusBadRequest, code)
+				assert.Equal(t, "sumCpu must be greater than 0", cause)
+			},
+		},
+		{
+			name: "error - zero sumMem",
+			body: `{"sumCpu": 10, "sumMem": 0, "minNodes": 1, "maxNodes": 1}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusBadRequest, code)
+				assert.Equal(t, "sumMem must be greater than 0", cause)
+			},
+		},
+		{
+			name: "error - negative sumMem",
+			body: `{"sumCpu": 10, "sumMem": -0.5, "minNodes": 1, "maxNodes": 1}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusBadRequest, code)
+				assert.Equal(t, "sumMem must be greater than 0", cause)
+			},
+		},
+		{
+			name: "minNodes equal to maxNodes",
+			body: `{"sumCpu": 10, "sumMem": 10, "minNodes": 3, "maxNodes": 3}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusOK, code)
+			},
+		},
+		{
+			name: "error - minNodes greater than maxNodes",
+			body: `{"sumCpu": 10, "sumMem": 10, "minNodes": 4, "maxNodes": 3}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusBadRequest, code)
+				assert.Equal(t, "minNodes must be less than or equal to maxNodes", cause)
+			},
+		},
+		{
+			name: "error - maxNodePools less than minNodePools",
+			body: `{"sumCpu": 10, "sumMem": 10, "minNodes": 1, "maxNodes": 1, "minNodePools": 3, "maxNodePools": 2}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusBadRequest, code)
+				assert.Equal(t, "maxNodePools must be greater than or equal to minNodePools", cause)
+			},
+		},
+		{
+			name: "unlimited maxNodePools with minNodePools",
+			body: `{"sumCpu": 10, "sumMem": 10, "minNodes": 1, "maxNodes": 1, "minNodePools": 3}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusOK, code)
+			},
+		},
+		{
+			name: "nodeCount within minNodes and maxNodes",
+			body: `{"sumCpu": 10, "sumMem": 10, "minNodes": 1, "maxNodes": 4, "nodeCount": 4}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusOK, code)
+			},
+		},
+		{
+			name: "error - nodeCount greater than maxNodes",
+			body: `{"sumCpu": 10, "sumMem": 10, "minNodes": 1, "maxNodes": 3, "nodeCount": 4}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusBadRequest, code)
+				assert.Equal(t, "nodeCount must be less than or equal to maxNodes", cause)
+			},
+		},
+		{
+			name: "error - nodeCount less than minNodes",
+			body: `{"sumCpu": 10, "sumMem": 10, "minNodes": 2, "maxNodes": 3, "nodeCount": 1}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusBadRequest, code)
+				assert.Equal(t, "nodeCount must be greater than or equal to minNodes", cause)
+			},
+		},
+		{
+			name: "error - maxNodeCpu less than minNodeCpu",
+			body: `{"sumCpu": 10, "sumMem": 10, "minNodes": 1, "maxNodes": 1, "minNodeCpu": 8, "maxNodeCpu": 4}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusBadRequest, code)
+				assert.Equal(t, "maxNodeCpu must be greater than or equal to minNodeCpu", cause)
+			},
+		},
+		{
+			name: "error - maxNodeMem less than minNodeMem",
+			body: `{"sumCpu": 10, "sumMem": 10, "minNodes": 1, "maxNodes": 1, "minNodeMem": 32, "maxNodeMem": 16}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusBadRequest, code)
+				assert.Equal(t, "maxNodeMem must be greater than or equal to minNodeMem", cause)
+			},
+		},
+		{
+			name: "minNodeCpu without a cap",
+			body: `{"sumCpu": 10, "sumMem": 10, "minNodes": 1, "maxNodes": 1, "minNodeCpu": 8}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusOK, code)
+			},
+		},
+		{
+			name: "error - negative onDemandPct",
+			body: `{"sumCpu": 10, "sumMem": 10, "minNodes": 1, "maxNodes": 1, "onDemandPct": -1}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusBadRequest, code)
+				assert.Equal(t, "onDemandPct must be at least 0", cause)
+			},
+		},
+		{
+			name: "error - onDemandPct over 100",
+			body: `{"sumCpu": 10, "sumMem": 10, "minNodes": 1, "maxNodes": 1, "onDemandPct": 101}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusBadRequest, code)
+				assert.Equal(t, "onDemandPct must be at most 100", cause)
+			},
+		},
+		{
+			name: "error - multiple invalid fields reported",
+			body: `{"sumCpu": 0, "sumMem": 0, "minNodes": 1, "maxNodes": 1}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusBadRequest, code)
+				assert.Equal(t, "sumCpu must be greater than 0; sumMem must be greater than 0", cause)
+			},
+		},
+		{
+			name: "error - malformed body",
+			body: `{"sumCpu": "many"}`,
+			check: func(code int, cause string) {
+				assert.Equal(t, http.StatusBadRequest, code)
+				assert.Contains(t, cause, "cannot unmarshal string")
+			},
+		},
+	}
+	router := validationRouter()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/dummy/dummyRegion/cluster", strings.NewReader(test.body)))
+
+			var resp struct {
+				Cause string `json:"cause"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			test.check(w.Code, resp.Cause)
+		})
+	}
+}
+
+func TestValidateRecommendationReq_details(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		check func(details []FieldError)
+	}{
+		{
+			name: "a detail per invalid field",
+			body: `{"sumCpu": 0, "sumMem": 10, "minNodes": 4, "maxNodes": 3}`,
+			check: func(details []FieldError) {
+				assert.Equal(t, []FieldError{
+					{Field: "minNodes", Tag: "ltefield", Message: "minNodes must be less than or equal to maxNodes"},
+					{Field: "sumCpu", Tag: "gt", Message: "sumCpu must be greater than 0"},
+				}, details)
+			},
+		},
+		{
+			name: "no details for a malformed body",
+			body: `{"sumCpu": "many"}`,
+			check: func(details []FieldError) {
+				assert.Nil(t, details)
+			},
+		},
+	}
+	router := validationRouter()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/dummy/dummyRegion/cluster", strings.NewReader(test.body)))
+
+			var resp struct {
+				Code    string       `json:"code"`
+				Message string       `json:"message"`
+				Details []FieldError `json:"details"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, "bad_params", resp.Code)
+			assert.Equal(t, "validation failed", resp.Message)
+			test.check(resp.Details)
+		})
+	}
+}
+
+func TestValidateRecommendationReq_onDemandPct(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		check func(pct *int)
+	}{
+		{
+			name: "an explicit 0 bound",
+			body: `{"sumCpu": 10, "sumMem": 10, "minNodes": 1, "maxNodes": 1, "onDemandPct": 0}`,
+			check: func(pct *int) {
+				assert.NotNil(t, pct, "the percentage should be set")
+				assert.Equal(t, 0, *pct)
+			},
+		},
+		{
+			name: "an omitted percentage left unset",
+			body: `{"sumCpu": 10, "sumMem": 10, "minNodes": 1, "maxNodes": 1}`,
+			check: func(pct *int) {
+				assert.Nil(t, pct, "the percentage should be left to the engine default")
+			},
+		},
+	}
+	router := validationRouter()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/dummy/dummyRegion/cluster", strings.NewReader(test.body)))
+
+			var req recommender.ClusterRecommendationReq
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &req), "the request couldn't be parsed")
+			test.check(req.OnDemandPct)
+		})
+	}
+}
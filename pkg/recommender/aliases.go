@@ -0,0 +1,91 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// the well-known names of the instance types per provider, keyed by the lowercase alias
+	instanceTypeAliases = map[string]map[string]string{
+		"azure": {
+			// the classic names of the basic A series sizes
+			"extrasmall": "Standard_A0",
+			"small":      "Standard_A1",
+			"medium":     "Standard_A2",
+			"large":      "Standard_A3",
+			"extralarge": "Standard_A4",
+		},
+		"gce": {
+			"micro":    "f1-micro",
+			"f1.micro": "f1-micro",
+			"small":    "g1-small",
+			"g1.small": "g1-small",
+		},
+	}
+	// the shorthands of the ec2 instance sizes, eg: m5.xl for m5.xlarge
+	ec2SizeAliases = map[string]string{
+		"sm":  "small",
+		"med": "medium",
+		"lg":  "large",
+		"xl":  "xlarge",
+	}
+	// the shorthand of the multiple extra large ec2 instance sizes, eg: m5.2xl for m5.2xlarge
+	ec2MultiXlRegexp = regexp.MustCompile(`^(\d+)xl$`)
+	// azure instance types named without the tier, eg: D2s_v3 for Standard_D2s_v3
+	azureUntieredRegexp = regexp.MustCompile(`^[A-Z]+\d+[a-z\-]*(_v\d+)?$`)
+)
+
+// canonicalInstanceType resolves the alias of an instance type to its name on the provider, the name is returned as is
+// if it's not a known alias, so that it's matched exactly
+func canonicalInstanceType(provider string, vmType string) string {
+	if canonical, ok := instanceTypeAliases[provider][strings.ToLower(vmType)]; ok {
+		return canonical
+	}
+	switch provider {
+	case "ec2":
+		// ec2 instance types are lowercase, sizes may be abbreviated
+		vmType = strings.ToLower(vmType)
+		if parts := strings.SplitN(vmType, ".", 2); len(parts) == 2 {
+			if size, ok := ec2SizeAliases[parts[1]]; ok {
+				return parts[0] + "." + size
+			}
+			if m := ec2MultiXlRegexp.FindStringSubmatch(parts[1]); m != nil {
+				return parts[0] + "." + m[1] + "xlarge"
+			}
+		}
+	case "gce":
+		return strings.ToLower(vmType)
+	case "azure":
+		if azureUntieredRegexp.MatchString(vmType) {
+			return "Standard_" + vmType
+		}
+	}
+	return vmType
+}
+
+// canonicalInstanceTypes resolves the aliases of the instance types to their names on the provider
+func canonicalInstanceTypes(provider string, vmTypes []string) []string {
+	if len(vmTypes) == 0 {
+		return vmTypes
+	}
+	canonical := make([]string, len(vmTypes))
+	for i, vmType := range vmTypes {
+		canonical[i] = canonicalInstanceType(provider, vmType)
+	}
+	return canonical
+}
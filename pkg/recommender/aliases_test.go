@@ -0,0 +1,90 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_canonicalInstanceType(t *testing.T) {
+	tests := []struct {
+		name      string
+		provider  string
+		vmType    string
+		canonical string
+	}{
+		{name: "ec2 size shorthand", provider: "ec2", vmType: "m5.xl", canonical: "m5.xlarge"},
+		{name: "ec2 multiple extra large shorthand", provider: "ec2", vmType: "m5.2xl", canonical: "m5.2xlarge"},
+		{name: "ec2 uppercase", provider: "ec2", vmType: "M5.Large", canonical: "m5.large"},
+		{name: "ec2 canonical name", provider: "ec2", vmType: "c5.xlarge", canonical: "c5.xlarge"},
+		{name: "gce shared-core alias", provider: "gce", vmType: "micro", canonical: "f1-micro"},
+		{name: "azure classic size name", provider: "azure", vmType: "ExtraSmall", canonical: "Standard_A0"},
+		{name: "azure name without the tier", provider: "azure", vmType: "D2s_v3", canonical: "Standard_D2s_v3"},
+		{name: "azure canonical name", provider: "azure", vmType: "Basic_A1", canonical: "Basic_A1"},
+		{name: "unknown alias matched exactly", provider: "dummy", vmType: "Type-10", canonical: "Type-10"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.canonical, canonicalInstanceType(test.provider, test.vmType))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterAliases(t *testing.T) {
+	engine, err := NewEngine(mustFileSource(t), WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	tests := []struct {
+		name  string
+		check func(*testing.T)
+	}{
+		{
+			name: "included instance types resolved",
+			check: func(t *testing.T) {
+				resp, err := engine.RecommendCluster("ec2", "eu-west-1", ClusterRecommendationReq{SumCpu: 8, SumMem: 32, MinNodes: 1, MaxNodes: 4,
+					OnDemandPct: onDemand(100), Includes: []string{"M5.XL"}})
+				assert.Nil(t, err, "the error should be nil")
+				for _, np := range resp.NodePools {
+					assert.Equal(t, "m5.xlarge", np.VmType.Type)
+				}
+				assert.Equal(t, []string{"m5.xlarge"}, resp.Request.Includes, "the canonical names should be echoed")
+			},
+		},
+		{
+			name: "excluded instance types resolved",
+			check: func(t *testing.T) {
+				resp, err := engine.RecommendCluster("ec2", "eu-west-1", ClusterRecommendationReq{SumCpu: 8, SumMem: 32, MinNodes: 1, MaxNodes: 4,
+					OnDemandPct: onDemand(100), Excludes: []string{"m5.xl", "m5.2xl", "m5.lg"}})
+				assert.Nil(t, err, "the error should be nil")
+				for _, np := range resp.NodePools {
+					assert.NotContains(t, []string{"m5.xlarge", "m5.2xlarge", "m5.large"}, np.VmType.Type)
+				}
+			},
+		},
+		{
+			name: "instance type looked up by alias",
+			check: func(t *testing.T) {
+				it, err := engine.GetInstanceType("ec2", "eu-west-1", "m5.2xl")
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "m5.2xlarge", it.Type)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, test.check)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// the maximum number of alternative layouts of a recommendation, every one of them is laid out from scratch
+const maxAlternatives = 5
+
+// alternativesCount caps the number of alternatives requested
+func alternativesCount(n int) int {
+	if n > maxAlternatives {
+		return maxAlternatives
+	}
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// alternatives lays out at most n alternatives of the primary recommendation from the same product info, every alternative
+// excludes the dominant instance type of the layouts before it so that all of them are distinct; they are ranked by total price
+func (e *Engine) alternatives(ctx context.Context, provider string, region string, clusterReq ClusterRecommendationReq,
+	req ClusterRecommendationReq, attributes []string, pi *productInfo, primary *ClusterRecommendationResp, n int) []ClusterRecommendationResp {
+	altReq := req
+	// the rejected candidates are only explained for the primary recommendation
+	altReq.Explain = false
+	altReq.Excludes = append([]string{}, req.Excludes...)
+
+	var alts []ClusterRecommendationResp
+	prev := primary
+	for len(alts) < n {
+		dominant := dominantType(prev.NodePools)
+		if dominant == "" {
+			break
+		}
+		altReq.Excludes = append(altReq.Excludes, dominant)
+
+		alt, err := e.layoutCluster(ctx, provider, region, clusterReq, altReq, attributes, pi)
+		if err != nil {
+			log.WithError(err).Debugf("no more alternatives, excluded instance types: %v", altReq.Excludes)
+			break
+		}
+		alts = append(alts, *alt)
+		prev = alt
+	}
+
+	sort.SliceStable(alts, func(i, j int) bool {
+		return alts[i].Accuracy.RecTotalPrice < alts[j].Accuracy.RecTotalPrice
+	})
+	return alts
+}
+
+// dominantType returns the instance type of the node pool with the most cpus, empty if there are no nodes at all
+func dominantType(nodePools []NodePool) string {
+	var (
+		dominant string
+		maxCpus  float64
+	)
+	for _, np := range nodePools {
+		if cpus := np.getSum(Cpu); np.SumNodes > 0 && cpus > maxCpus {
+			dominant, maxCpus = np.VmType.Type, cpus
+		}
+	}
+	return dominant
+}
@@ -0,0 +1,118 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_dominantType(t *testing.T) {
+	tests := []struct {
+		name      string
+		nodePools []NodePool
+		check     func(vmType string)
+	}{
+		{
+			name: "node pool with the most cpus",
+			nodePools: []NodePool{
+				{SumNodes: 3, VmType: VirtualMachine{Type: "m5.large", Cpus: 2}},
+				{SumNodes: 2, VmType: VirtualMachine{Type: "m5.2xlarge", Cpus: 8}},
+				{SumNodes: 0, VmType: VirtualMachine{Type: "m5.4xlarge", Cpus: 16}},
+			},
+			check: func(vmType string) {
+				assert.Equal(t, "m5.2xlarge", vmType)
+			},
+		},
+		{
+			name:      "no nodes",
+			nodePools: []NodePool{{SumNodes: 0, VmType: VirtualMachine{Type: "m5.large", Cpus: 2}}},
+			check: func(vmType string) {
+				assert.Equal(t, "", vmType)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(dominantType(test.nodePools))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterAlternatives(t *testing.T) {
+	fs, err := NewFileProductInfoSource("testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := NewEngine(fs)
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	req := ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 6, OnDemandPct: onDemand(50)}
+
+	tests := []struct {
+		name         string
+		alternatives int
+		check        func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name: "alternatives not requested",
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Nil(t, resp.Alternatives)
+			},
+		},
+		{
+			name:         "distinct alternatives ranked by price",
+			alternatives: 2,
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, 2, len(resp.Alternatives))
+
+				// the primary recommendation is the cheapest one
+				assert.Equal(t, "m5.xlarge", resp.NodePools[0].VmType.Type)
+				price := resp.Accuracy.RecTotalPrice
+				for _, alt := range resp.Alternatives {
+					assert.True(t, alt.Accuracy.RecTotalPrice >= price, "the alternatives should be ranked by price")
+					price = alt.Accuracy.RecTotalPrice
+					assert.Equal(t, float64(16), alt.Accuracy.RecCpu)
+					assert.True(t, alt.Accuracy.RecMem >= 64, "the alternatives should cover the requested memory")
+					assert.NotEqual(t, resp.NodePools, alt.NodePools, "the alternatives should differ from the primary")
+				}
+				assert.Equal(t, "m5.2xlarge", resp.Alternatives[0].NodePools[0].VmType.Type)
+				assert.Equal(t, "r5.xlarge", resp.Alternatives[1].NodePools[0].VmType.Type)
+			},
+		},
+		{
+			name:         "alternatives bounded by the viable layouts",
+			alternatives: 10,
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, 2, len(resp.Alternatives))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := req
+			r.Alternatives = test.alternatives
+			test.check(engine.RecommendCluster("ec2", "eu-west-1", r))
+		})
+	}
+}
+
+func Test_alternativesCount(t *testing.T) {
+	assert.Equal(t, 0, alternativesCount(-1))
+	assert.Equal(t, 3, alternativesCount(3))
+	assert.Equal(t, maxAlternatives, alternativesCount(100))
+}
@@ -0,0 +1,113 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// the default time recommendations are cached for
+const defaultCacheTTL = 5 * time.Minute
+
+// cacheEntry is a cached recommendation with the time it was cached and its expiration time
+type cacheEntry struct {
+	resp    *ClusterRecommendationResp
+	created time.Time
+	expires time.Time
+}
+
+// recommendationCache caches recommendations by the fingerprint of the request
+type recommendationCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+	now     func() time.Time
+}
+
+// newRecommendationCache creates a cache that keeps recommendations for the given time
+func newRecommendationCache(ttl time.Duration) *recommendationCache {
+	return &recommendationCache{
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry),
+		now:     time.Now,
+	}
+}
+
+// get returns the cached recommendation for the key if it's not expired yet
+func (c *recommendationCache) get(key string) (*ClusterRecommendationResp, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.resp, true
+}
+
+// set caches the recommendation for the key, expired entries are evicted on the way
+func (c *recommendationCache) set(key string, resp *ClusterRecommendationResp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for k, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cacheEntry{resp: resp, created: now, expires: now.Add(c.ttl)}
+}
+
+// oldest returns the time the oldest recommendation still valid was cached, false if there's none
+func (c *recommendationCache) oldest() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var oldest time.Time
+	now := c.now()
+	for _, entry := range c.entries {
+		if now.Before(entry.expires) && (oldest.IsZero() || entry.created.Before(oldest)) {
+			oldest = entry.created
+		}
+	}
+	return oldest, !oldest.IsZero()
+}
+
+// fingerprint identifies the recommendation request for the provider in the region
+func fingerprint(provider string, region string, req ClusterRecommendationReq) (string, error) {
+	// the flag doesn't change the recommendation
+	req.NoCache = false
+
+	b, err := json.Marshal(struct {
+		Provider string                   `json:"provider"`
+		Region   string                   `json:"region"`
+		Req      ClusterRecommendationReq `json:"req"`
+	}{provider, region, req})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,210 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/productinfo/pkg/productinfo-client/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingProductInfoSource counts the product details calls made to the product info source
+type countingProductInfoSource struct {
+	dummyProductInfoSource
+	calls int32
+}
+
+func (piCli *countingProductInfoSource) GetProductDetails(provider string, region string) ([]*models.ProductDetails, error) {
+	atomic.AddInt32(&piCli.calls, 1)
+	return piCli.dummyProductInfoSource.GetProductDetails(provider, region)
+}
+
+func TestRecommendationCache(t *testing.T) {
+	now := time.Now()
+	resp := &ClusterRecommendationResp{Provider: "dummy"}
+
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		check   func(resp *ClusterRecommendationResp, ok bool)
+	}{
+		{
+			name:    "cached recommendation returned before the ttl",
+			elapsed: time.Minute,
+			check: func(cached *ClusterRecommendationResp, ok bool) {
+				assert.True(t, ok, "the recommendation should be cached")
+				assert.Equal(t, resp, cached)
+			},
+		},
+		{
+			name:    "cached recommendation expired after the ttl",
+			elapsed: 5 * time.Minute,
+			check: func(cached *ClusterRecommendationResp, ok bool) {
+				assert.False(t, ok, "the recommendation should be expired")
+				assert.Nil(t, cached)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache := newRecommendationCache(5 * time.Minute)
+			cache.now = func() time.Time { return now }
+			cache.set("key", resp)
+
+			cache.now = func() time.Time { return now.Add(test.elapsed) }
+			test.check(cache.get("key"))
+		})
+	}
+}
+
+func Test_fingerprint(t *testing.T) {
+	req := ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10}
+	key, err := fingerprint("dummy", "dummyRegion1", req)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name     string
+		provider string
+		region   string
+		req      func() ClusterRecommendationReq
+		check    func(k string)
+	}{
+		{
+			name:     "same request, same fingerprint",
+			provider: "dummy",
+			region:   "dummyRegion1",
+			req:      func() ClusterRecommendationReq { return req },
+			check: func(k string) {
+				assert.Equal(t, key, k)
+			},
+		},
+		{
+			name:     "the cache flag doesn't change the fingerprint",
+			provider: "dummy",
+			region:   "dummyRegion1",
+			req: func() ClusterRecommendationReq {
+				r := req
+				r.NoCache = true
+				return r
+			},
+			check: func(k string) {
+				assert.Equal(t, key, k)
+			},
+		},
+		{
+			name:     "different region, different fingerprint",
+			provider: "dummy",
+			region:   "dummyRegion2",
+			req:      func() ClusterRecommendationReq { return req },
+			check: func(k string) {
+				assert.NotEqual(t, key, k)
+			},
+		},
+		{
+			name:     "different request, different fingerprint",
+			provider: "dummy",
+			region:   "dummyRegion1",
+			req: func() ClusterRecommendationReq {
+				r := req
+				r.SumCpu = 101
+				return r
+			},
+			check: func(k string) {
+				assert.NotEqual(t, key, k)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			k, err := fingerprint(test.provider, test.region, test.req())
+			assert.Nil(t, err)
+			test.check(k)
+		})
+	}
+}
+
+func TestEngine_RecommendClusterCache(t *testing.T) {
+	req := ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(50)}
+
+	tests := []struct {
+		name    string
+		opts    []EngineOption
+		noCache bool
+		check   func(calls int32)
+	}{
+		{
+			name: "repeated request served from the cache",
+			check: func(calls int32) {
+				assert.Equal(t, int32(1), calls, "the product info should be fetched once")
+			},
+		},
+		{
+			name:    "cache bypassed by the request",
+			noCache: true,
+			check: func(calls int32) {
+				assert.Equal(t, int32(2), calls, "the product info should be fetched for every request")
+			},
+		},
+		{
+			name: "caching disabled",
+			opts: []EngineOption{WithCacheTTL(0)},
+			check: func(calls int32) {
+				assert.Equal(t, int32(2), calls, "the product info should be fetched for every request")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pi := &countingProductInfoSource{}
+			engine, err := NewEngine(pi, test.opts...)
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			r := req
+			r.NoCache = test.noCache
+			for i := 0; i < 2; i++ {
+				_, err := engine.RecommendCluster("dummy", "dummyRegion1", r)
+				assert.Nil(t, err)
+			}
+			test.check(atomic.LoadInt32(&pi.calls))
+		})
+	}
+}
+
+func TestRecommendationCache_oldest(t *testing.T) {
+	now := time.Now()
+	c := newRecommendationCache(5 * time.Minute)
+	c.now = func() time.Time { return now }
+
+	_, ok := c.oldest()
+	assert.False(t, ok, "there should be no cached recommendation")
+
+	c.set("first", &ClusterRecommendationResp{})
+	created := now
+	now = now.Add(4 * time.Minute)
+	c.set("second", &ClusterRecommendationResp{})
+
+	oldest, ok := c.oldest()
+	assert.True(t, ok, "there should be cached recommendations")
+	assert.Equal(t, created, oldest)
+
+	// the expired recommendations are not taken into account
+	now = now.Add(2 * time.Minute)
+	oldest, ok = c.oldest()
+	assert.True(t, ok, "there should be a cached recommendation")
+	assert.Equal(t, created.Add(4*time.Minute), oldest)
+}
@@ -0,0 +1,105 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+// Capabilities describes the features of the engine and its product info source, so that clients don't have to
+// guess what the deployment supports
+type Capabilities struct {
+	// The providers the engine can recommend clusters on
+	Providers []ProviderCapabilities `json:"providers"`
+	// Gpus can be requested, on the providers reporting the gpus of the instance types
+	Gpu bool `json:"gpu"`
+	// Local storage can be requested, the source reports the local storage of the instance types
+	LocalStorage bool `json:"localStorage"`
+	// Pods can be requested, the source reports the pod limits of the instance types
+	Pods bool `json:"pods"`
+	// The storage type can be requested, the source reports the storage classes of the instance types
+	StorageTypes bool `json:"storageTypes"`
+	// Custom machine types can be requested, the source reports the prices of the custom machine types
+	CustomMachineTypes bool `json:"customMachineTypes"`
+	// Stable spot pools can be requested, the source reports the variance of the spot prices
+	StableSpot bool `json:"stableSpot"`
+	// The maximum spot interruption rate can be requested, the source reports the interruption rates of the spot instances
+	SpotInterruptions bool `json:"spotInterruptions"`
+	// Reserved pricing can be requested, the source reports the reserved prices of the instance types
+	ReservedPricing bool `json:"reservedPricing"`
+	// The recommendations are tagged with the version of the prices, the tags change with the prices
+	PriceVersions bool `json:"priceVersions"`
+	// The time recommendations are cached for, 0s if caching is disabled
+	CacheTTL string `json:"cacheTtl"`
+	// The hard deadline of a recommendation
+	RequestTimeout string `json:"requestTimeout"`
+	// The minimum number of distinct instance types of spot-only layouts, 0 if not enforced
+	MinSpotPools int `json:"minSpotPools"`
+	// The default safety margin (percentage) added to the spot prices when they're compared to the on-demand prices
+	SpotMargin float64 `json:"spotMargin"`
+	// The percentage of regular (on-demand) nodes of the requests that don't set it
+	DefaultOnDemandPct int `json:"defaultOnDemandPct"`
+	// The price tolerance (percentage) of the layouts the newest instance generations are preferred among
+	NewGenEpsilon float64 `json:"newGenEpsilon"`
+}
+
+// ProviderCapabilities describes the features available on a provider
+type ProviderCapabilities struct {
+	Provider
+	// Spot/preemptible node pools are recommended, the percentage of on-demand nodes is ignored otherwise
+	Spot bool `json:"spot"`
+	// The minimum generation of the instance types can be requested
+	Generation bool `json:"generation"`
+}
+
+// GetCapabilities describes the features of the engine from its configuration and the abilities of the product info source
+func (e *Engine) GetCapabilities() (*Capabilities, error) {
+	providers, err := e.GetProviders()
+	if err != nil {
+		return nil, err
+	}
+
+	_, storage := e.piSource.(LocalStorageSource)
+	_, pods := e.piSource.(PodLimitSource)
+	_, storageTypes := e.piSource.(StorageTypeSource)
+	_, custom := e.piSource.(CustomMachinePriceSource)
+	_, variance := e.piSource.(SpotPriceVarianceSource)
+	_, interruptions := e.piSource.(SpotInterruptionSource)
+	_, reserved := e.piSource.(ReservedPriceSource)
+	_, versions := e.piSource.(PriceVersionSource)
+	c := &Capabilities{
+		Providers:          make([]ProviderCapabilities, 0, len(providers)),
+		Gpu:                true,
+		LocalStorage:       storage,
+		Pods:               pods,
+		StorageTypes:       storageTypes,
+		CustomMachineTypes: custom,
+		StableSpot:         variance,
+		SpotInterruptions:  interruptions,
+		ReservedPricing:    reserved,
+		PriceVersions:      versions,
+		CacheTTL:           e.cacheTTL.String(),
+		RequestTimeout:     e.reqTimeout.String(),
+		MinSpotPools:       e.minSpotPools,
+		SpotMargin:         e.spotMargin,
+		DefaultOnDemandPct: e.onDemandPct,
+		NewGenEpsilon:      e.newGenEps,
+	}
+	for _, p := range providers {
+		c.Providers = append(c.Providers, ProviderCapabilities{
+			Provider: p,
+			// the on-demand percentage is forced to 100 on oracle
+			Spot:       p.ID != "oracle",
+			Generation: generationReported(p.ID),
+		})
+	}
+	return c, nil
+}
@@ -0,0 +1,85 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_GetCapabilities(t *testing.T) {
+	tests := []struct {
+		name   string
+		source ProductInfoSource
+		opts   []EngineOption
+		check  func(c *Capabilities, err error)
+	}{
+		{
+			name:   "snapshot source",
+			source: mustFileSource(t),
+			opts:   []EngineOption{WithCacheTTL(0), WithMinSpotPools(2)},
+			check: func(c *Capabilities, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []ProviderCapabilities{{Provider: Provider{ID: "ec2", Name: "Amazon Web Services"}, Spot: true, Generation: true}}, c.Providers)
+				assert.True(t, c.Gpu)
+				assert.True(t, c.PriceVersions, "the snapshot versions its prices")
+				assert.True(t, c.ReservedPricing, "the snapshot may hold the reserved prices")
+				assert.True(t, c.SpotInterruptions, "the snapshot may hold the spot interruption rates")
+				assert.True(t, c.LocalStorage, "the snapshot may hold the local storage")
+				assert.True(t, c.StorageTypes, "the snapshot may hold the storage types")
+				assert.True(t, c.Pods, "the snapshot may hold the pod limits")
+				assert.True(t, c.CustomMachineTypes, "the snapshot may hold the custom machine prices")
+				assert.True(t, c.StableSpot, "the snapshot may hold the spot price variances")
+				assert.Equal(t, "0s", c.CacheTTL)
+				assert.Equal(t, DefaultRequestTimeout.String(), c.RequestTimeout)
+				assert.Equal(t, 2, c.MinSpotPools)
+			},
+		},
+		{
+			name:   "source reporting the optional prices",
+			source: &reservedProductInfoSource{},
+			opts:   []EngineOption{WithCacheTTL(time.Minute)},
+			check: func(c *Capabilities, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.True(t, c.ReservedPricing)
+				assert.Equal(t, "1m0s", c.CacheTTL)
+			},
+		},
+		{
+			name:   "providers can't be listed",
+			source: &dummyProductInfoSource{TcId: ProvidersError},
+			check: func(c *Capabilities, err error) {
+				assert.Nil(t, c)
+				assert.EqualError(t, err, ProvidersError)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.source, test.opts...)
+			assert.Nil(t, err, "the engine couldn't be created")
+			test.check(engine.GetCapabilities())
+		})
+	}
+}
+
+// mustFileSource loads the snapshot of the test data
+func mustFileSource(t *testing.T) *FileProductInfoSource {
+	fs, err := NewFileProductInfoSource("testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	return fs
+}
@@ -0,0 +1,105 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"sync"
+	"time"
+
+	"github.com/banzaicloud/productinfo/pkg/productinfo-client/models"
+)
+
+// catalog is the product info of a region the recommendations share regardless of the requested resources: the zones,
+// the instance types with their prices and the attribute values
+type catalog struct {
+	version    string
+	zones      []string
+	products   []*models.ProductDetails
+	attrValues map[string][]float64
+	// the time the products were fetched
+	fetched time.Time
+	// the time the catalog was cached
+	cached time.Time
+}
+
+// catalogCache caches the catalogs of the regions by the version of their prices, a catalog is dropped as soon as the
+// product info source reports a new version of the prices in the region; the catalogs of the sources not versioning
+// the prices are dropped after the ttl
+type catalogCache struct {
+	mu       sync.Mutex
+	catalogs map[string]*catalog
+	ttl      time.Duration
+}
+
+// newCatalogCache creates an empty catalog cache, the unversioned catalogs are cached for the ttl
+func newCatalogCache(ttl time.Duration) *catalogCache {
+	return &catalogCache{catalogs: make(map[string]*catalog), ttl: ttl}
+}
+
+// enabled returns true if the catalogs of the version are cached
+func (c *catalogCache) enabled(version string) bool {
+	return version != "" || c.ttl > 0
+}
+
+// expired returns true if the cached catalog is stale: it's cached at another version, or it's unversioned and was
+// cached more than the ttl ago
+func (c *catalogCache) expired(cached *catalog, version string) bool {
+	return cached.version != version || version == "" && time.Since(cached.cached) >= c.ttl
+}
+
+// get returns the catalog of the region cached at the version, an empty catalog if it's not cached, the prices
+// changed since or it expired; the items of the catalog must not be modified
+func (c *catalogCache) get(provider string, region string, version string) catalog {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := provider + "/" + region
+	cached, ok := c.catalogs[key]
+	if !ok {
+		return catalog{}
+	}
+	if c.expired(cached, version) {
+		delete(c.catalogs, key)
+		return catalog{}
+	}
+	attrValues := make(map[string][]float64, len(cached.attrValues))
+	for attr, values := range cached.attrValues {
+		attrValues[attr] = values
+	}
+	return catalog{version: version, zones: cached.zones, products: cached.products, attrValues: attrValues, fetched: cached.fetched}
+}
+
+// update caches the items of the catalog fetched at the version, the catalog cached at another version or expired is
+// replaced
+func (c *catalogCache) update(provider string, region string, fetched catalog) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := provider + "/" + region
+	cached, ok := c.catalogs[key]
+	if !ok || c.expired(cached, fetched.version) {
+		cached = &catalog{version: fetched.version, attrValues: make(map[string][]float64), cached: time.Now()}
+		c.catalogs[key] = cached
+	}
+	if fetched.zones != nil {
+		cached.zones = fetched.zones
+	}
+	if fetched.products != nil {
+		cached.products, cached.fetched = fetched.products, fetched.fetched
+	}
+	for attr, values := range fetched.attrValues {
+		cached.attrValues[attr] = values
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/productinfo/pkg/productinfo-client/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// versionedProductInfoSource versions the prices of the dummy source and counts the calls of the catalog made to it
+type versionedProductInfoSource struct {
+	slowProductInfoSource
+	version atomic.Value
+	calls   int32
+}
+
+func newVersionedProductInfoSource(version string, latency time.Duration) *versionedProductInfoSource {
+	vs := &versionedProductInfoSource{slowProductInfoSource: slowProductInfoSource{latency: latency}}
+	vs.version.Store(version)
+	return vs
+}
+
+func (piCli *versionedProductInfoSource) PriceVersion(provider string, region string) string {
+	return piCli.version.Load().(string)
+}
+
+func (piCli *versionedProductInfoSource) GetAttributeValues(provider string, region string, attr string) ([]float64, error) {
+	atomic.AddInt32(&piCli.calls, 1)
+	return piCli.slowProductInfoSource.GetAttributeValues(provider, region, attr)
+}
+
+func (piCli *versionedProductInfoSource) GetRegion(provider string, region string) ([]string, error) {
+	atomic.AddInt32(&piCli.calls, 1)
+	return piCli.slowProductInfoSource.GetRegion(provider, region)
+}
+
+func (piCli *versionedProductInfoSource) GetProductDetails(provider string, region string) ([]*models.ProductDetails, error) {
+	atomic.AddInt32(&piCli.calls, 1)
+	return piCli.slowProductInfoSource.GetProductDetails(provider, region)
+}
+
+func TestEngine_fetchProductInfoCatalog(t *testing.T) {
+	// the requests differ only in the requested resources
+	reqs := []ClusterRecommendationReq{
+		{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(50)},
+		{SumCpu: 60, SumMem: 120, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(50)},
+		{SumCpu: 80, SumMem: 90, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(50)},
+	}
+
+	tests := []struct {
+		name    string
+		version string
+		bump    bool
+		ttl     time.Duration
+		check   func(calls int32, pis []*productInfo)
+	}{
+		{
+			name:    "the catalog fetched once while the prices are unchanged",
+			version: "v1",
+			check: func(calls int32, pis []*productInfo) {
+				// the cpu and memory values, the zones and the products
+				assert.Equal(t, int32(4), calls, "the catalog should be fetched once")
+				for _, pi := range pis {
+					assert.Equal(t, pis[0].products, pi.products)
+					assert.Equal(t, []string{"dummyZone1", "dummyZone2", "dummyZone3"}, pi.zones)
+					assert.Equal(t, []float64{15, 16, 17}, pi.attrValues[Cpu])
+					assert.Equal(t, pis[0].fetched, pi.fetched, "the prices are as fresh as the cached catalog")
+				}
+			},
+		},
+		{
+			name:    "the catalog fetched again when the prices change",
+			version: "v1",
+			bump:    true,
+			check: func(calls int32, pis []*productInfo) {
+				assert.Equal(t, int32(4*len(reqs)), calls, "the catalog should be fetched for every new version")
+			},
+		},
+		{
+			name: "the unversioned catalog not cached without a cache ttl",
+			check: func(calls int32, pis []*productInfo) {
+				assert.Equal(t, int32(4*len(reqs)), calls, "the catalog should be fetched for every request")
+			},
+		},
+		{
+			name: "the unversioned catalog cached for the cache ttl",
+			ttl:  time.Minute,
+			check: func(calls int32, pis []*productInfo) {
+				assert.Equal(t, int32(4), calls, "the catalog should be fetched once")
+				for _, pi := range pis {
+					assert.Equal(t, pis[0].products, pi.products)
+					assert.Equal(t, pis[0].fetched, pi.fetched, "the prices are as fresh as the cached catalog")
+				}
+			},
+		},
+		{
+			name: "the unversioned catalog fetched again when it expires",
+			ttl:  time.Nanosecond,
+			check: func(calls int32, pis []*productInfo) {
+				assert.Equal(t, int32(4*len(reqs)), calls, "the catalog should be fetched for every request")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pi := newVersionedProductInfoSource(test.version, 0)
+			engine, err := NewEngine(pi, WithCacheTTL(test.ttl))
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			var pis []*productInfo
+			for i, req := range reqs {
+				if test.bump {
+					pi.version.Store(test.version + string(rune('a'+i)))
+				}
+				fetched, err := engine.fetchProductInfo(context.Background(), "dummy", "dummyRegion1", []string{Cpu, Memory}, req)
+				assert.Nil(t, err, "the error should be nil")
+				pis = append(pis, fetched)
+			}
+			test.check(atomic.LoadInt32(&pi.calls), pis)
+		})
+	}
+}
+
+func BenchmarkEngine_RecommendClusterCatalog(b *testing.B) {
+	reqs := []ClusterRecommendationReq{
+		{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(50)},
+		{SumCpu: 60, SumMem: 120, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(50)},
+		{SumCpu: 80, SumMem: 90, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(50)},
+	}
+
+	benchmarks := []struct {
+		name    string
+		version string
+	}{
+		{name: "unversioned"},
+		{name: "catalog cache", version: "v1"},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			pi := newVersionedProductInfoSource(bm.version, time.Millisecond)
+			// the recommendations themselves are not cached, only the catalog
+			engine, err := NewEngine(pi, WithCacheTTL(0))
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := engine.RecommendCluster("dummy", "dummyRegion1", reqs[i%len(reqs)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			b.Logf("%d product info calls for %d recommendations", atomic.LoadInt32(&pi.calls), b.N)
+		})
+	}
+}
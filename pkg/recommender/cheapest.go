@@ -0,0 +1,132 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// the maximum number of regions recommended for in parallel
+const maxParallelRegions = 4
+
+// CheapestRegionResp holds the recommendation in the cheapest region, and the ranking of all the candidate regions
+// swagger:model CheapestRegionResponse
+type CheapestRegionResp struct {
+	// The cheapest region
+	Region string `json:"region"`
+	// The recommendation in the cheapest region
+	Recommendation *ClusterRecommendationResp `json:"recommendation"`
+	// The candidate regions ranked by the total price of the recommendation, infeasible regions at the end
+	Ranking []RegionRank `json:"ranking"`
+}
+
+// RegionRank summarizes the recommendation in a candidate region
+type RegionRank struct {
+	// The candidate region
+	Region string `json:"region"`
+	// Whether a cluster can be recommended in the region
+	Feasible bool `json:"feasible"`
+	// Total hourly price of the recommended cluster in the region
+	TotalPrice float64 `json:"totalPrice,omitempty"`
+	// The reason why no cluster can be recommended in the region
+	Reason string `json:"reason,omitempty"`
+}
+
+// RecommendCheapestRegion recommends a cluster in each of the candidate regions and returns the cheapest one
+func (e *Engine) RecommendCheapestRegion(ctx context.Context, provider string, regions []string, req ClusterRecommendationReq) (*CheapestRegionResp, error) {
+	log.Infof("recommending cheapest region. Provider: [%s], regions: %v", provider, regions)
+
+	regions = dedupe(regions)
+	ranking, recs := e.recommendRegions(ctx, provider, regions, req)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// the ranking is sorted in place, the recommendations are looked up by region
+	byRegion := make(map[string]*ClusterRecommendationResp, len(regions))
+	for i, rec := range recs {
+		byRegion[regions[i]] = rec
+	}
+
+	sort.SliceStable(ranking, func(i, j int) bool {
+		if ranking[i].Feasible != ranking[j].Feasible {
+			return ranking[i].Feasible
+		}
+		if ranking[i].TotalPrice != ranking[j].TotalPrice {
+			return ranking[i].TotalPrice < ranking[j].TotalPrice
+		}
+		return ranking[i].Region < ranking[j].Region
+	})
+
+	if len(ranking) == 0 || !ranking[0].Feasible {
+		return nil, NewError(NoViableInstances, "could not recommend cluster with the requested resources in any of the regions %v", regions)
+	}
+
+	return &CheapestRegionResp{
+		Region:         ranking[0].Region,
+		Recommendation: byRegion[ranking[0].Region],
+		Ranking:        ranking,
+	}, nil
+}
+
+// recommendRegions recommends a cluster in each of the regions in parallel, returning the summaries and the
+// recommendations in the order of the regions; the recommendations of the infeasible regions are nil
+func (e *Engine) recommendRegions(ctx context.Context, provider string, regions []string, req ClusterRecommendationReq) ([]RegionRank, []*ClusterRecommendationResp) {
+	var (
+		ranking = make([]RegionRank, len(regions))
+		recs    = make([]*ClusterRecommendationResp, len(regions))
+		sem     = make(chan struct{}, maxParallelRegions)
+		wg      sync.WaitGroup
+	)
+
+	for i := range regions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			ranking[i].Region = regions[i]
+			rec, err := e.RecommendClusterCtx(ctx, provider, regions[i], req)
+			if err != nil {
+				log.WithError(err).Debugf("no cluster recommended in region: %s", regions[i])
+				ranking[i].Reason = err.Error()
+				return
+			}
+			recs[i] = rec
+			ranking[i].Feasible = true
+			ranking[i].TotalPrice = rec.Accuracy.RecTotalPrice
+		}(i)
+	}
+	wg.Wait()
+	return ranking, recs
+}
+
+// dedupe returns the distinct values of the slice, in the order of their first occurrence
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var distinct []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			distinct = append(distinct, v)
+		}
+	}
+	return distinct
+}
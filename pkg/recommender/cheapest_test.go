@@ -0,0 +1,100 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"testing"
+
+	"github.com/banzaicloud/productinfo/pkg/productinfo-client/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// regionalProductInfoSource prices the products of the dummy source per region
+type regionalProductInfoSource struct {
+	dummyProductInfoSource
+	// price multiplier per region, regions without a multiplier have no products
+	multipliers map[string]float64
+}
+
+func (piCli *regionalProductInfoSource) GetProductDetails(provider string, region string) ([]*models.ProductDetails, error) {
+	m, ok := piCli.multipliers[region]
+	if !ok {
+		return nil, nil
+	}
+	products, err := piCli.dummyProductInfoSource.GetProductDetails(provider, region)
+	if err != nil {
+		return nil, err
+	}
+	var priced []*models.ProductDetails
+	for _, p := range products {
+		pp := *p
+		pp.OnDemandPrice = p.OnDemandPrice * m
+		pp.SpotPrice = nil
+		for _, sp := range p.SpotPrice {
+			pp.SpotPrice = append(pp.SpotPrice, &models.ZonePrice{Zone: sp.Zone, Price: sp.Price * m})
+		}
+		priced = append(priced, &pp)
+	}
+	return priced, nil
+}
+
+func TestEngine_RecommendCheapestRegion(t *testing.T) {
+	pi := &regionalProductInfoSource{multipliers: map[string]float64{"dummyRegion1": 2, "dummyRegion2": 1}}
+	req := ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(50)}
+
+	tests := []struct {
+		name    string
+		regions []string
+		check   func(resp *CheapestRegionResp, err error)
+	}{
+		{
+			name:    "cheapest region recommended, regions ranked by total price",
+			regions: []string{"dummyRegion1", "dummyRegion2"},
+			check: func(resp *CheapestRegionResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "dummyRegion2", resp.Region)
+				assert.Equal(t, resp.Recommendation.Accuracy.RecTotalPrice, resp.Ranking[0].TotalPrice)
+				assert.Equal(t, []string{"dummyRegion2", "dummyRegion1"}, []string{resp.Ranking[0].Region, resp.Ranking[1].Region})
+				assert.True(t, resp.Ranking[0].TotalPrice < resp.Ranking[1].TotalPrice, "the ranking should be ordered by price")
+			},
+		},
+		{
+			name:    "infeasible regions ranked last",
+			regions: []string{"emptyRegion", "dummyRegion1", "dummyRegion1"},
+			check: func(resp *CheapestRegionResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "dummyRegion1", resp.Region)
+				assert.Equal(t, 2, len(resp.Ranking), "duplicate regions should be ranked once")
+				assert.Equal(t, RegionRank{Region: "emptyRegion", Reason: "could not recommend cluster with the requested resources"}, resp.Ranking[1])
+			},
+		},
+		{
+			name:    "error - no feasible region",
+			regions: []string{"emptyRegion"},
+			check: func(resp *CheapestRegionResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.Equal(t, NoViableInstances, ErrorCode(err))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(pi)
+			assert.Nil(t, err, "the engine couldn't be created")
+			test.check(engine.RecommendCheapestRegion(context.Background(), "dummy", test.regions, req))
+		})
+	}
+}
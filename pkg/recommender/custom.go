@@ -0,0 +1,155 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// the provider custom machine types are available on
+	customTypesProvider = "gce"
+	// the maximum number of vCPUs of a custom machine type, the count must be 1 or even
+	maxCustomCpus = 96
+	// the memory of a custom machine type per vCPU must be between these (GB)
+	minCustomMemPerCpu = 0.9
+	maxCustomMemPerCpu = 6.5
+	// the memory of a custom machine type is a multiple of 256 MB
+	customMemStep = 0.25
+)
+
+// CustomMachinePrices holds the hourly prices of the resources of the custom machine types
+type CustomMachinePrices struct {
+	// The on-demand price of a vCPU
+	CpuPrice float64 `json:"cpuPrice"`
+	// The on-demand price of a GB of memory
+	MemPrice float64 `json:"memPrice"`
+	// The preemptible price of a vCPU, custom machine types are recommended on-demand only if not set
+	SpotCpuPrice float64 `json:"spotCpuPrice,omitempty"`
+	// The preemptible price of a GB of memory
+	SpotMemPrice float64 `json:"spotMemPrice,omitempty"`
+}
+
+// customEligible returns true if a custom machine type can serve the request, custom machine types are only sized for
+// cpus and memory, the requests with further constraints are served with the predefined instance types
+func customEligible(req ClusterRecommendationReq) bool {
+	return !needsCandidateCheck(req) && req.CommitmentPct == 0 && req.MinNodePools <= 1
+}
+
+// customShape returns the vCPUs and memory of the smallest custom machine type providing the requested resources on the
+// given number of nodes, false if no custom machine type is large enough
+func customShape(sumCpu float64, sumMem float64, nodes int) (float64, float64, bool) {
+	cpus := evenCpus(math.Ceil(sumCpu / float64(nodes)))
+	mem := math.Max(roundUp(sumMem/float64(nodes), customMemStep), roundUp(minCustomMemPerCpu*cpus, customMemStep))
+	if mem > maxCustomMemPerCpu*cpus {
+		// more vCPUs are needed for the memory
+		cpus = evenCpus(math.Ceil(mem / maxCustomMemPerCpu))
+	}
+	return cpus, mem, cpus <= maxCustomCpus
+}
+
+// evenCpus rounds the vCPU count up to a valid custom machine type vCPU count
+func evenCpus(cpus float64) float64 {
+	if cpus <= 1 {
+		return 1
+	}
+	return 2 * math.Ceil(cpus/2)
+}
+
+// roundUp rounds the value up to a multiple of the step
+func roundUp(value float64, step float64) float64 {
+	return step * math.Ceil(value/step)
+}
+
+// customMachine creates the custom machine type with the given resources, priced with the custom machine prices
+func customMachine(cpus float64, mem float64, prices *CustomMachinePrices) VirtualMachine {
+	return VirtualMachine{
+		// the name of the custom machine types on gce
+		Type:          fmt.Sprintf("custom-%d-%d", int(cpus), int(mem*1024)),
+		OnDemandPrice: cpus*prices.CpuPrice + mem*prices.MemPrice,
+		AvgPrice:      cpus*prices.SpotCpuPrice + mem*prices.SpotMemPrice,
+		Cpus:          cpus,
+		Mem:           mem,
+		CurrentGen:    true,
+		Architecture:  Amd64,
+		Custom:        true,
+	}
+}
+
+// customNodePools recommends the node pools of the cheapest custom machine type layout for the request, trying every
+// number of nodes allowed by the request; nil if no custom machine type can serve the request
+func (e *Engine) customNodePools(region string, prices *CustomMachinePrices, req ClusterRecommendationReq) []NodePool {
+	if prices == nil || !customEligible(req) {
+		return nil
+	}
+	if req.onDemandPct() < 100 && prices.SpotCpuPrice == 0 {
+		log.Debug("preemptible custom machine types are not priced, only predefined instance types are recommended")
+		return nil
+	}
+
+	var (
+		cheapest  []NodePool
+		bestPrice float64
+	)
+	// fewer nodes than the largest custom machine types can serve the request with are not tried
+	minNodes := math.Max(math.Ceil(req.SumCpu/maxCustomCpus), math.Ceil(req.SumMem/(maxCustomMemPerCpu*maxCustomCpus)))
+	for nodes := int(math.Max(math.Max(float64(req.MinNodes), minNodes), 1)); nodes <= req.MaxNodes; nodes++ {
+		cpus, mem, ok := customShape(req.SumCpu, req.SumMem, nodes)
+		if !ok {
+			continue
+		}
+		// beyond the smallest custom machine type more nodes only cost more
+		minShape := cpus == 1 && mem == roundUp(minCustomMemPerCpu, customMemStep)
+		nodeReq := req
+		nodeReq.NodeCount = nodes
+		vms := []VirtualMachine{customMachine(cpus, mem, prices)}
+		// the custom machine types compete with the predefined ones at the adjusted prices
+		e.adjustPrices(customTypesProvider, region, vms)
+		nps, err := e.fixedCountNodePools(vms, nodeReq)
+		// the fewest nodes win on equal prices
+		if price := poolSetPrice(nps); err == nil && (cheapest == nil || price < bestPrice) {
+			cheapest, bestPrice = nps, price
+		}
+		if minShape {
+			break
+		}
+	}
+	return cheapest
+}
+
+// poolSetPrice returns the hourly price of the node pools
+func poolSetPrice(nps []NodePool) float64 {
+	var sum float64
+	for _, np := range nps {
+		sum += np.poolPrice()
+	}
+	return sum
+}
+
+// layoutName returns the instance types of the node pools with nodes in the order of the pools, the node pool sets of
+// the same price are ranked by it
+func layoutName(nps []NodePool) string {
+	var types []string
+	for _, np := range nps {
+		if np.SumNodes > 0 {
+			types = append(types, np.VmType.Type)
+		}
+	}
+	return strings.Join(types, ",")
+}
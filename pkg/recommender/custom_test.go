@@ -0,0 +1,171 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_customShape(t *testing.T) {
+	tests := []struct {
+		name   string
+		sumCpu float64
+		sumMem float64
+		nodes  int
+		check  func(cpus float64, mem float64, ok bool)
+	}{
+		{
+			name:   "odd vcpu counts rounded up to even",
+			sumCpu: 6, sumMem: 8, nodes: 2,
+			check: func(cpus float64, mem float64, ok bool) {
+				assert.True(t, ok)
+				assert.Equal(t, 4.0, cpus)
+				assert.Equal(t, 4.0, mem)
+			},
+		},
+		{
+			name:   "memory raised to the minimum per vcpu, rounded to 256 MB",
+			sumCpu: 8, sumMem: 2, nodes: 1,
+			check: func(cpus float64, mem float64, ok bool) {
+				assert.True(t, ok)
+				assert.Equal(t, 8.0, cpus)
+				assert.Equal(t, 7.25, mem)
+			},
+		},
+		{
+			name:   "vcpus raised for the memory",
+			sumCpu: 2, sumMem: 60, nodes: 2,
+			check: func(cpus float64, mem float64, ok bool) {
+				assert.True(t, ok)
+				assert.Equal(t, 6.0, cpus)
+				assert.Equal(t, 30.0, mem)
+			},
+		},
+		{
+			name:   "too many vcpus on a node",
+			sumCpu: 200, sumMem: 200, nodes: 2,
+			check: func(cpus float64, mem float64, ok bool) {
+				assert.False(t, ok, "custom machine types have at most 96 vcpus")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(customShape(test.sumCpu, test.sumMem, test.nodes))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterCustomTypes(t *testing.T) {
+	fs, err := NewFileProductInfoSource("testdata/custom.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := NewEngine(fs, WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	tests := []struct {
+		name    string
+		region  string
+		request ClusterRecommendationReq
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:    "custom machine type cheaper than the predefined ones",
+			region:  "europe-west1",
+			request: ClusterRecommendationReq{SumCpu: 6, SumMem: 8, MinNodes: 1, MaxNodes: 3, OnDemandPct: onDemand(100), CustomTypes: true},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, 1, len(resp.NodePools))
+				vm := resp.NodePools[0].VmType
+				assert.True(t, vm.Custom, "a custom machine type should be recommended")
+				assert.Equal(t, "custom-6-8192", vm.Type)
+				assert.Equal(t, 6.0, vm.Cpus)
+				assert.Equal(t, 8.0, vm.Mem)
+				assert.Equal(t, 1, resp.NodePools[0].SumNodes)
+			},
+		},
+		{
+			name:    "custom machine types disabled",
+			region:  "europe-west1",
+			request: ClusterRecommendationReq{SumCpu: 6, SumMem: 8, MinNodes: 1, MaxNodes: 3, OnDemandPct: onDemand(100)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				for _, np := range resp.NodePools {
+					assert.False(t, np.VmType.Custom, "only predefined instance types should be recommended")
+				}
+			},
+		},
+		{
+			name:    "predefined instance types cheaper than the custom ones",
+			region:  "europe-west1",
+			request: ClusterRecommendationReq{SumCpu: 8, SumMem: 30, MinNodes: 1, MaxNodes: 4, OnDemandPct: onDemand(100), CustomTypes: true},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				for _, np := range resp.NodePools {
+					assert.False(t, np.VmType.Custom, "the predefined instance types should be recommended")
+				}
+			},
+		},
+		{
+			name:    "preemptible custom machine types",
+			region:  "europe-west1",
+			request: ClusterRecommendationReq{SumCpu: 6, SumMem: 8, MinNodes: 1, MaxNodes: 3, OnDemandPct: onDemand(0), CustomTypes: true},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.True(t, resp.SpotOnly, "the layout should be spot-only")
+				assert.Equal(t, 1, len(resp.NodePools))
+				assert.Equal(t, spot, resp.NodePools[0].VmClass)
+				assert.True(t, resp.NodePools[0].VmType.Custom, "a custom machine type should be recommended")
+			},
+		},
+		{
+			name:    "custom machine types not priced in the region",
+			region:  "europe-west4",
+			request: ClusterRecommendationReq{SumCpu: 6, SumMem: 8, MinNodes: 1, MaxNodes: 4, OnDemandPct: onDemand(100), CustomTypes: true},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "n1-standard-2", resp.NodePools[0].VmType.Type)
+				assert.Equal(t, []string{"custom machine types are not available on provider [gce] in region [europe-west4], only predefined instance types are recommended"},
+					resp.Warnings)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(engine.RecommendCluster("gce", test.region, test.request))
+		})
+	}
+}
+
+func TestEngine_customNodePoolsManyNodes(t *testing.T) {
+	engine, err := NewEngine(&dummyProductInfoSource{})
+	assert.Nil(t, err, "the engine couldn't be created")
+	prices := &CustomMachinePrices{CpuPrice: 0.03, MemPrice: 0.004}
+	req := ClusterRecommendationReq{SumCpu: 6, SumMem: 8, MinNodes: 1, MaxNodes: 2000000000, OnDemandPct: onDemand(100), CustomTypes: true}
+
+	done := make(chan []NodePool)
+	go func() {
+		done <- engine.customNodePools("europe-west1", prices, req)
+	}()
+	select {
+	case nps := <-done:
+		assert.Equal(t, 1, len(nps))
+		assert.True(t, nps[0].VmType.Custom, "a custom machine type should be recommended")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the node counts beyond the smallest custom machine type should not be tried")
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// inflightCall is a recommendation being computed, the waiters get its result once done is closed; the computation
+// is cancelled once none of its callers waits for it any more
+type inflightCall struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+	resp    *ClusterRecommendationResp
+	err     error
+}
+
+// inflightGroup deduplicates the concurrent computations of the same recommendation by the fingerprint of the request
+type inflightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*inflightCall
+}
+
+// newInflightGroup creates an empty group of recommendations in flight
+func newInflightGroup() *inflightGroup {
+	return &inflightGroup{calls: make(map[string]*inflightCall)}
+}
+
+// do returns the result of fn for the key, the callers arriving while it's computed share the result of the first one
+// instead of calling fn again; fn runs detached from the context of any single caller (carrying the values of the
+// first context), so that a caller giving up doesn't fail the others, but it's cancelled when the context of the last
+// waiting caller is done. Each caller returns the error of its own context if it's done before the result is ready
+func (g *inflightGroup) do(ctx context.Context, key string,
+	fn func(ctx context.Context) (*ClusterRecommendationResp, error)) (*ClusterRecommendationResp, error) {
+	g.mu.Lock()
+	call, ok := g.calls[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(detachedContext{ctx})
+		call = &inflightCall{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = call
+		go func() {
+			defer cancel()
+			call.resp, call.err = fn(callCtx)
+
+			g.mu.Lock()
+			g.forget(key, call)
+			g.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.resp, call.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		if call.waiters--; call.waiters == 0 {
+			// nobody waits for the result, the outstanding product info calls are abandoned
+			call.cancel()
+			g.forget(key, call)
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// forget removes the call from the calls in flight unless it's been replaced already, the lock must be held
+func (g *inflightGroup) forget(key string, call *inflightCall) {
+	if g.calls[key] == call {
+		delete(g.calls, key)
+	}
+}
+
+// waiting returns the number of callers waiting for the computation in flight for the key
+func (g *inflightGroup) waiting(key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if call, ok := g.calls[key]; ok {
+		return call.waiters
+	}
+	return 0
+}
+
+// detachedContext carries the values of the wrapped context without its deadline and cancellation
+type detachedContext struct {
+	context.Context
+}
+
+// Deadline returns no deadline
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// Done returns a nil channel, the context is never cancelled
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+// Err returns nil, the context is never cancelled
+func (detachedContext) Err() error {
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/productinfo/pkg/productinfo-client/models"
+	"github.com/go-openapi/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingProductInfoSource counts the product details calls and holds them until released
+type blockingProductInfoSource struct {
+	dummyProductInfoSource
+	calls   int32
+	release chan struct{}
+}
+
+func (piCli *blockingProductInfoSource) GetProductDetails(provider string, region string) ([]*models.ProductDetails, error) {
+	atomic.AddInt32(&piCli.calls, 1)
+	<-piCli.release
+	return piCli.dummyProductInfoSource.GetProductDetails(provider, region)
+}
+
+func TestEngine_RecommendClusterDeduplicated(t *testing.T) {
+	const requests = 10
+	pi := &blockingProductInfoSource{release: make(chan struct{})}
+	// the cache is disabled, the requests are only served once by the deduplication
+	engine, err := NewEngine(pi, WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+	req := ClusterRecommendationReq{SumCpu: 64, SumMem: 64, MinNodes: 1, MaxNodes: 10}
+	key, err := fingerprint("dummy", "dummyRegion1", engine.withDefaults("dummy", req))
+	assert.Nil(t, err, "the request couldn't be fingerprinted")
+
+	var wg sync.WaitGroup
+	resps := make([]*ClusterRecommendationResp, requests)
+	errs := make([]error, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resps[i], errs[i] = engine.RecommendCluster("dummy", "dummyRegion1", req)
+		}(i)
+	}
+	for deadline := time.Now().Add(5 * time.Second); engine.inflight.waiting(key) < requests && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	close(pi.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&pi.calls), "the product details should be fetched once")
+	for i := 0; i < requests; i++ {
+		assert.Nil(t, errs[i], "the error should be nil")
+		assert.True(t, resps[0] == resps[i], "the requests should share the same recommendation")
+	}
+
+	_, err = engine.RecommendCluster("dummy", "dummyRegion1", req)
+	assert.Nil(t, err, "the error should be nil")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&pi.calls), "the finished computation should not be shared")
+}
+
+func Test_inflightGroup_callerGivesUp(t *testing.T) {
+	g := newInflightGroup()
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (*ClusterRecommendationResp, error) {
+		<-release
+		return &ClusterRecommendationResp{Provider: "dummy"}, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	gaveUp, shared := make(chan error), make(chan error)
+	go func() {
+		_, err := g.do(ctx, "key", fn)
+		gaveUp <- err
+	}()
+	go func() {
+		_, err := g.do(context.Background(), "key", fn)
+		shared <- err
+	}()
+	for g.waiting("key") < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-gaveUp)
+	close(release)
+	assert.Nil(t, <-shared, "the shared computation should not be cancelled with a caller giving up")
+}
+
+func TestEngine_RecommendClusterDeduplicatedCancelled(t *testing.T) {
+	// the product details keep failing, they're retried until the computation is cancelled
+	pi := &flakyProductInfoSource{failures: 1000, err: runtime.NewAPIError("unavailable", nil, http.StatusServiceUnavailable)}
+	engine, err := NewEngine(pi, WithCacheTTL(0), WithRetryPolicy(RetryPolicy{MaxAttempts: 1000, BaseDelay: 10 * time.Millisecond}))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = engine.RecommendClusterCtx(ctx, "dummy", "dummyRegion1", ClusterRecommendationReq{SumCpu: 64, SumMem: 64, MinNodes: 1, MaxNodes: 10})
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	calls := atomic.LoadInt32(&pi.calls)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, calls, atomic.LoadInt32(&pi.calls), "the product info calls should stop with the only caller")
+}
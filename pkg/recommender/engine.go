@@ -15,10 +15,12 @@
 package recommender
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
 	"sort"
+	"time"
 
 	"github.com/banzaicloud/productinfo/pkg/productinfo-client/models"
 	log "github.com/sirupsen/logrus"
@@ -32,8 +34,27 @@ const (
 	Memory = "memory"
 	// Cpu represents the cpu attribute for the recommender
 	Cpu = "cpu"
+	// the number of hours monthly costs are projected with
+	hoursPerMonth = 730
+	// Gpu represents the gpu attribute for the recommender
+	Gpu = "gpu"
+	// Storage represents the local storage attribute for the recommender
+	Storage = "storage"
+	// Pods represents the pod capacity attribute for the recommender
+	Pods = "pods"
+	// the default minimum number of distinct instance types of spot-only layouts
+	defaultMinSpotPools = 0
+	// the default safety margin on top of the spot prices (percentage)
+	defaultSpotMargin = 0
+	// the default percentage of regular nodes of the requests that don't set it
+	defaultOnDemandPct = 0
+	// the price penalty of the instance types per unit of the log distance of their memory per cpu from the preferred ratio
+	memRatioWeight = 0.5
 )
 
+// DefaultRequestTimeout is the hard deadline of a recommendation if the engine is not created with WithRequestTimeout
+const DefaultRequestTimeout = 30 * time.Second
+
 // ClusterRecommender defines operations for cluster recommendations
 type ClusterRecommender interface {
 	// RecommendAttrValues recommends attributes based on the input
@@ -47,49 +68,301 @@ type ClusterRecommender interface {
 
 	// RecommendCluster recommends a cluster layout on the given cloud provider, region and wanted resources
 	RecommendCluster(provider string, region string, req ClusterRecommendationReq) (*ClusterRecommendationResp, error)
+
+	// RecommendClusterCtx recommends a cluster layout, giving up when the context is done
+	RecommendClusterCtx(ctx context.Context, provider string, region string, req ClusterRecommendationReq) (*ClusterRecommendationResp, error)
 }
 
 // Engine represents the recommendation engine, it operates on a map of provider -> VmRegistry
 type Engine struct {
-	piSource ProductInfoSource
+	piSource     ProductInfoSource
+	workers      int
+	fetchTimeout time.Duration
+	reqTimeout   time.Duration
+	cacheTTL     time.Duration
+	cache        *recommendationCache
+	inflight     *inflightGroup
+	retry        RetryPolicy
+	minSpotPools int
+	spotMargin   float64
+	onDemandPct  int
+	newGenEps    float64
+	familyBonus  float64
+	adjuster     PriceAdjuster
+	catalog      *catalogCache
+}
+
+// EngineOption configures an optional parameter of the engine
+type EngineOption func(e *Engine)
+
+// WithWorkers sets the maximum number of parallel product info calls of a recommendation
+func WithWorkers(workers int) EngineOption {
+	return func(e *Engine) {
+		e.workers = workers
+	}
+}
+
+// WithFetchTimeout sets the time limit for fetching the product info of a recommendation
+func WithFetchTimeout(timeout time.Duration) EngineOption {
+	return func(e *Engine) {
+		e.fetchTimeout = timeout
+	}
+}
+
+// WithRequestTimeout sets the hard deadline of computing a recommendation
+func WithRequestTimeout(timeout time.Duration) EngineOption {
+	return func(e *Engine) {
+		e.reqTimeout = timeout
+	}
+}
+
+// WithCacheTTL sets the time recommendations, and the catalogs of the sources not versioning the prices, are cached for,
+// caching is disabled if zero
+func WithCacheTTL(ttl time.Duration) EngineOption {
+	return func(e *Engine) {
+		e.cacheTTL = ttl
+	}
+}
+
+// WithMinSpotPools sets the minimum number of distinct instance types of spot-only layouts, picking the spot pools
+// from several types reduces the chance of them being interrupted at once; not enforced if zero
+func WithMinSpotPools(pools int) EngineOption {
+	return func(e *Engine) {
+		e.minSpotPools = pools
+	}
+}
+
+// WithSpotMargin sets the default safety margin (percentage) added to the spot prices when they're compared to the
+// on-demand prices, instance types whose buffered spot price exceeds the on-demand price are recommended on-demand only
+func WithSpotMargin(pct float64) EngineOption {
+	return func(e *Engine) {
+		e.spotMargin = pct
+	}
+}
+
+// WithDefaultOnDemandPct sets the percentage of regular (on-demand) nodes of the requests that don't set it, an
+// explicit percentage in the request, including 0, is always kept
+func WithDefaultOnDemandPct(pct int) EngineOption {
+	return func(e *Engine) {
+		e.onDemandPct = pct
+	}
+}
+
+// WithNewGenEpsilon sets the price tolerance (percentage) of the layouts the newest instance generations are preferred
+// among if the request prefers them
+func WithNewGenEpsilon(pct float64) EngineOption {
+	return func(e *Engine) {
+		e.newGenEps = pct
+	}
+}
+
+// WithFamilyBonus sets the price tolerance (percentage) of the layouts of the preferred instance families over the
+// cheapest layout if the request prefers families
+func WithFamilyBonus(pct float64) EngineOption {
+	return func(e *Engine) {
+		e.familyBonus = pct
+	}
 }
 
-// NewEngine creates a new Engine instance
-func NewEngine(pis ProductInfoSource) (*Engine, error) {
-	return &Engine{
-		piSource: pis,
-	}, nil
+// WithPriceAdjuster sets the price adjuster transforming the list prices of the instance types before they're scored
+func WithPriceAdjuster(adjuster PriceAdjuster) EngineOption {
+	return func(e *Engine) {
+		e.adjuster = adjuster
+	}
+}
+
+// NewEngine creates a new Engine instance that recommends from the product info of the source, any implementation of
+// ProductInfoSource can be used, eg: a mock in tests or a file backed source where the product info service is unreachable
+func NewEngine(pis ProductInfoSource, opts ...EngineOption) (*Engine, error) {
+	e := &Engine{
+		piSource:     pis,
+		workers:      defaultWorkers,
+		fetchTimeout: defaultFetchTimeout,
+		reqTimeout:   DefaultRequestTimeout,
+		cacheTTL:     defaultCacheTTL,
+		retry:        defaultRetryPolicy,
+		minSpotPools: defaultMinSpotPools,
+		spotMargin:   defaultSpotMargin,
+		onDemandPct:  defaultOnDemandPct,
+		newGenEps:    defaultNewGenEpsilon,
+		familyBonus:  defaultFamilyBonus,
+		adjuster:     listPrices{},
+		inflight:     newInflightGroup(),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	if e.workers < 1 {
+		return nil, fmt.Errorf("invalid number of workers: %d", e.workers)
+	}
+	if e.fetchTimeout <= 0 {
+		return nil, fmt.Errorf("invalid fetch timeout: %s", e.fetchTimeout)
+	}
+	if e.reqTimeout <= 0 {
+		return nil, fmt.Errorf("invalid request timeout: %s", e.reqTimeout)
+	}
+	if e.cacheTTL < 0 {
+		return nil, fmt.Errorf("invalid cache ttl: %s", e.cacheTTL)
+	}
+	if e.adjuster == nil {
+		return nil, fmt.Errorf("invalid price adjuster: %v", e.adjuster)
+	}
+	if e.retry.MaxAttempts < 1 {
+		return nil, fmt.Errorf("invalid number of attempts: %d", e.retry.MaxAttempts)
+	}
+	if e.retry.BaseDelay < 0 {
+		return nil, fmt.Errorf("invalid retry delay: %s", e.retry.BaseDelay)
+	}
+	if e.retry.Jitter < 0 || e.retry.Jitter > 1 {
+		return nil, fmt.Errorf("invalid retry jitter: %v", e.retry.Jitter)
+	}
+	if e.minSpotPools < 0 {
+		return nil, fmt.Errorf("invalid minimum number of spot pools: %d", e.minSpotPools)
+	}
+	if e.spotMargin < 0 {
+		return nil, fmt.Errorf("invalid spot margin: %v", e.spotMargin)
+	}
+	if e.onDemandPct < 0 || e.onDemandPct > 100 {
+		return nil, fmt.Errorf("invalid default on-demand percentage: %d", e.onDemandPct)
+	}
+	if e.newGenEps < 0 {
+		return nil, fmt.Errorf("invalid new generation epsilon: %v", e.newGenEps)
+	}
+	if e.familyBonus < 0 {
+		return nil, fmt.Errorf("invalid family bonus: %v", e.familyBonus)
+	}
+	if e.cacheTTL > 0 {
+		e.cache = newRecommendationCache(e.cacheTTL)
+	}
+	e.catalog = newCatalogCache(e.cacheTTL)
+	return e, nil
 }
 
 // ClusterRecommendationReq encapsulates the recommendation input data
-// swagger:parameters recommendClusterSetup
+// swagger:model ClusterRecommendationReq
 type ClusterRecommendationReq struct {
 	// Total number of CPUs requested for the cluster
-	SumCpu float64 `json:"sumCpu" binding:"min=1"`
+	SumCpu float64 `json:"sumCpu" binding:"gt=0"`
 	// Total memory requested for the cluster (GB)
-	SumMem float64 `json:"sumMem" binding:"min=1"`
+	SumMem float64 `json:"sumMem" binding:"gt=0"`
 	// Minimum number of nodes in the recommended cluster
 	MinNodes int `json:"minNodes,omitempty" binding:"min=1,ltefield=MaxNodes"`
 	// Maximum number of nodes in the recommended cluster
 	MaxNodes int `json:"maxNodes,omitempty"`
-	// If true, recommended instance types will have a similar size
+	// NodeCount the exact number of nodes of the recommended cluster, only the instance type is chosen if set
+	NodeCount int `json:"nodeCount,omitempty" binding:"omitempty,gtefield=MinNodes,ltefield=MaxNodes"`
+	// MinNodeCpu the minimum number of cpus of a node, avoiding the scheduling overhead of tiny nodes; no limit if not set
+	MinNodeCpu float64 `json:"minNodeCpu,omitempty" binding:"min=0"`
+	// MaxNodeCpu the maximum number of cpus of a node, bounding the impact of a node failure; no limit if not set
+	MaxNodeCpu float64 `json:"maxNodeCpu,omitempty" binding:"omitempty,gtefield=MinNodeCpu"`
+	// MinNodeMem the minimum memory of a node (GB), no limit if not set
+	MinNodeMem float64 `json:"minNodeMem,omitempty" binding:"min=0"`
+	// MaxNodeMem the maximum memory of a node (GB), no limit if not set
+	MaxNodeMem float64 `json:"maxNodeMem,omitempty" binding:"omitempty,gtefield=MinNodeMem"`
+	// If true, all the node pools in the recommended cluster will have the same instance type
 	SameSize bool `json:"sameSize,omitempty"`
-	// Percentage of regular (on-demand) nodes in the recommended cluster
-	OnDemandPct int `json:"onDemandPct,omitempty" binding:"min=0,max=100"`
+	// Minimum number of node pools of distinct instance types in the recommended cluster
+	MinNodePools int `json:"minNodePools,omitempty" binding:"min=0"`
+	// Maximum number of node pools of distinct instance types in the recommended cluster, unlimited if not set
+	MaxNodePools int `json:"maxNodePools,omitempty" binding:"omitempty,gtefield=MinNodePools"`
+	// Percentage of the requested cpus and memory the cluster may fall short of in exchange for a cheaper layout,
+	// the realized coverage is reported in the accuracy
+	Tolerance float64 `json:"tolerance,omitempty" binding:"min=0,lt=100"`
+	// Percentage of memory recommended on top of the requested memory as headroom, the cpus are recommended as requested
+	MemHeadroomPct float64 `json:"memHeadroomPct,omitempty" binding:"min=0"`
+	// Percentage of regular (on-demand) nodes in the recommended cluster, the default of the engine if not set
+	OnDemandPct *int `json:"onDemandPct,omitempty" binding:"omitempty,min=0,max=100"`
+	// Percentage of the regular nodes priced at the reserved (committed use) rate, if the reserved prices are reported on the provider
+	CommitmentPct int `json:"commitmentPct,omitempty" binding:"min=0,max=100"`
+	// The commitment term of the reserved prices, 1yr-no-upfront if not set
+	CommitmentTerm string `json:"commitmentTerm,omitempty" binding:"omitempty,eq=1yr-no-upfront|eq=1yr-all-upfront|eq=3yr-no-upfront|eq=3yr-all-upfront"`
+	// Expected monthly runtime of the nodes in hours, the whole month (730 hours) if not set; the monthly price is
+	// projected for the runtime and the regular nodes are priced with the sustained use discounts of the runtime on gce
+	MonthlyHours float64 `json:"monthlyHours,omitempty" binding:"omitempty,gt=0,lte=730"`
+	// Compare the node pools at the prices with the sustained use discounts on gce, only the reported prices are discounted otherwise
+	OptimizeSustainedUse bool `json:"optimizeSustainedUse,omitempty"`
 	// Availability zones that the cluster should expand to
 	Zones []string `json:"zones,omitempty" binding:"dive,zone"`
+	// Availability zones the cluster shouldn't expand to, removed from the requested zones or the zones of the region
+	ExcludeZones []string `json:"excludeZones,omitempty" binding:"dive,zone"`
 	// Total number of GPUs requested for the cluster
-	SumGpu int `json:"sumGpu,omitempty"`
+	SumGpu float64 `json:"sumGpu,omitempty" binding:"min=0"`
+	// Total local storage requested for the cluster (GB)
+	SumStorage float64 `json:"sumStorage,omitempty" binding:"min=0"`
+	// Total number of pods the cluster must be able to host, given the pod limits of the instance types
+	SumPods int `json:"sumPods,omitempty" binding:"min=0"`
+	// StorageType the storage class the instance types must support, eg: nvme or ebs-optimized; any if not set
+	StorageType string `json:"storageType,omitempty"`
 	// Are burst instances allowed in recommendation
 	AllowBurst *bool `json:"allowBurst,omitempty"`
-	// NetworkPerf specifies the network performance category
+	// NoBurstable excludes the burstable (cpu credit based) instance families on every provider, eg: t3 on ec2 or the
+	// shared-core e2 types on gce
+	NoBurstable bool `json:"noBurstable,omitempty"`
+	// CustomTypes proposes a custom machine type sized for the requested resources if it's cheaper than the predefined
+	// instance types, on the providers supporting custom machine types (gce)
+	CustomTypes bool `json:"customTypes,omitempty"`
+	// NetworkPerf specifies the minimum network performance category
 	NetworkPerf *string `json:"networkPerf" binding:"omitempty,network"`
 	// Excludes is a blacklist - a slice with vm types to be excluded from the recommendation
 	Excludes []string `json:"excludes,omitempty"`
 	// Includes is a whitelist - a slice with vm types to be contained in the recommendation
 	Includes []string `json:"includes,omitempty"`
+	// Instance families preferred in the recommendation as long as they don't cost more than the preference bonus of
+	// the engine, the other families are recommended otherwise; a family matches its generations too, eg: m for m5
+	PreferredFamilies []string `json:"preferredFamilies,omitempty"`
 	// AllowOlderGen allow older generations of virtual machines (applies for EC2 only)
 	AllowOlderGen *bool `json:"allowOlderGen,omitempty"`
+	// MinGen the minimum generation of the recommended instance types (applies for EC2 only), any if not set
+	MinGen int `json:"minGen,omitempty" binding:"min=0"`
+	// PreferNewGen prefers the layout of the newest instance generations among the ones priced within the new
+	// generation epsilon of the engine of the cheapest one (applies for EC2 only), the cheapest layout if not set
+	PreferNewGen bool `json:"preferNewGen,omitempty"`
+	// Objective the candidates are ranked by: cost, performance (the vcpus weighted by the relative performance of their
+	// instance generation, applies for EC2 only) or the blend of both weighted by the cost weight, cost if not set
+	Objective string `json:"objective,omitempty" binding:"omitempty,eq=cost|eq=performance|eq=blend"`
+	// CostWeight the weight of the cost in the blend objective between 0 and 1, implies the blend objective if no
+	// objective is set, 0.5 if not set
+	CostWeight *float64 `json:"costWeight,omitempty" binding:"omitempty,min=0,max=1"`
+	// MaxPrice the maximum hourly price of the whole cluster (USD), no limit if not set
+	MaxPrice float64 `json:"maxPrice,omitempty" binding:"min=0"`
+	// MemRatio the preferred memory (GB) per cpu of the recommended instance types, no preference if not set
+	MemRatio float64 `json:"memRatio,omitempty" binding:"min=0"`
+	// Architecture the cpu architecture of the recommended instance types (amd64 or arm64), any if not set
+	Architecture string `json:"architecture,omitempty" binding:"omitempty,architecture"`
+	// Existing node pools of the cluster, new node pools are recommended for the requested resources exceeding their capacity
+	Existing []NodePool `json:"existing,omitempty"`
+	// Reserved the number of the reserved instances held per instance type, they're recommended at no cost before any
+	// new on-demand or spot nodes
+	Reserved map[string]int `json:"reserved,omitempty" binding:"omitempty,dive,min=0"`
+	// NamePrefix the prefix of the names of the recommended node pools (<prefix>-<type>-<class>), the node pools are not
+	// named if not set
+	NamePrefix string `json:"namePrefix,omitempty" binding:"omitempty,max=40"`
+	// Maximum interruption frequency (percentage of the spot instances interrupted a month) of the spot instance types,
+	// if the source reports the interruption rates; the instance types without a reported rate are not filtered
+	MaxInterruptionRate float64 `json:"maxInterruptionRate,omitempty" binding:"min=0,max=100"`
+	// StableSpot if true spot instance types with lower recent price variance are preferred over slightly cheaper ones
+	StableSpot bool `json:"stableSpot,omitempty"`
+	// SpotMargin the safety margin (percentage) added to the spot prices when they're compared to the on-demand prices,
+	// the default of the engine if not set
+	SpotMargin *float64 `json:"spotMargin,omitempty" binding:"omitempty,min=0"`
+	// MaxSpotPrice the maximum hourly price of a spot node (USD), the instance types whose current spot price exceeds it
+	// are not recommended as spot instances, no limit if not set
+	MaxSpotPrice float64 `json:"maxSpotPrice,omitempty" binding:"min=0"`
+	// WithSummary if true the response contains a human readable summary of the recommendation
+	WithSummary bool `json:"withSummary,omitempty"`
+	// Weighted if true the response contains the recommended node pools as a weighted target capacity specification too,
+	// the weights of the instance types are computed from their relative cpus
+	Weighted bool `json:"weighted,omitempty"`
+	// Explain if true the response lists the candidate instance types dropped from the recommendation and the reasons
+	Explain bool `json:"explain,omitempty"`
+	// Alternatives the number of alternative layouts to be recommended besides the cheapest one, at most 5
+	Alternatives int `json:"alternatives,omitempty" binding:"min=0,max=5"`
+	// NoCache if true the recommendation is computed from fresh product info instead of being served from the cache
+	NoCache bool `json:"noCache,omitempty"`
+
+	// the node pools of the held reservations consumed by the request, laid out by the engine
+	held []NodePool
 }
 
 // ClusterRecommendationResp encapsulates recommendation result data
@@ -97,12 +370,45 @@ type ClusterRecommendationReq struct {
 type ClusterRecommendationResp struct {
 	// The cloud provider
 	Provider string `json:"provider"`
+	// The region of the provider
+	Region string `json:"region,omitempty"`
+	// The request the recommendation is made for with the defaults of the engine applied, set on the recommendation
+	// but not on its alternatives
+	Request *ClusterRecommendationReq `json:"request,omitempty"`
 	// Availability zones in the recommendation - a multi-zone recommendation means that all node pools should expand to all zones
 	Zones []string `json:"zones,omitempty"`
-	// Recommended node pools
+	// Recommended node pools, to be added to the existing ones if any
 	NodePools []NodePool `json:"nodePools"`
+	// Existing node pools of the cluster, as in the request
+	ExistingNodePools []NodePool `json:"existingNodePools,omitempty"`
+	// Node pools of the held reserved instances, at no cost; set if reservations are held
+	ReservedNodePools []NodePool `json:"reservedNodePools,omitempty"`
+	// Number of the held reserved instances consumed per instance type, set if reservations are held
+	ReservedUsed map[string]int `json:"reservedUsed,omitempty"`
 	// Accuracy of the recommendation
 	Accuracy ClusterRecommendationAccuracy `json:"accuracy"`
+	// The cost and performance of the recommended node pools, set if an objective is requested
+	Objective *ObjectiveScore `json:"objective,omitempty"`
+	// Warnings about the parts of the request that couldn't be taken into account
+	Warnings []string `json:"warnings,omitempty"`
+	// The warnings with the codes identifying their kind, in the order of the messages
+	WarningDetails []Warning `json:"warningDetails,omitempty"`
+	// Human readable summary of the recommendation, set if requested
+	Summary string `json:"summary,omitempty"`
+	// Candidate instance types dropped from the recommendation with the reasons, set if an explanation is requested
+	Rejected []RejectedCandidate `json:"rejected,omitempty"`
+	// The recommended node pools as a spot fleet style weighted target capacity, set if requested
+	WeightedCapacity *WeightedCapacity `json:"weightedCapacity,omitempty"`
+	// Alternative layouts ranked by their total price, set if alternatives are requested
+	Alternatives []ClusterRecommendationResp `json:"alternatives,omitempty"`
+	// True if the recommended node pools are all spot/preemptible; the pools are only diversified over several
+	// instance types if the engine is configured with a minimum number of spot pools (WithMinSpotPools)
+	SpotOnly bool `json:"spotOnly,omitempty"`
+	// The time the prices of the recommendation are as of: the time the snapshot was taken if the source serves a
+	// snapshot, the time the engine fetched the prices otherwise
+	PricingAsOf time.Time `json:"pricingAsOf"`
+	// The version of the prices of the recommendation, set if the source reports it
+	PriceVersion string `json:"priceVersion,omitempty"`
 }
 
 // NodePool represents a set of instances with a specific vm type
@@ -113,6 +419,18 @@ type NodePool struct {
 	SumNodes int `json:"sumNodes"`
 	// Specifies if the recommended node pool consists of regular or spot/preemptible instance types
 	VmClass string `json:"vmClass"`
+	// Number of the regular nodes priced at the reserved rate, set if a commitment is requested
+	ReservedNodes int `json:"reservedNodes,omitempty"`
+	// Hourly price of the node pool (spot pools are priced with the current spot price)
+	PoolPrice float64 `json:"poolPrice"`
+	// Stability of the spot price of the instance type between 0 and 1 (the higher the stabler), set if stable spot pools are requested
+	StabilityScore float64 `json:"stabilityScore,omitempty"`
+	// The maximum hourly price to bid for a node of the spot pool, set on the spot pools if a maximum spot price is requested
+	SpotBid float64 `json:"spotBid,omitempty"`
+	// Name of the node pool, set on the recommended node pools if a name prefix is requested
+	Name string `json:"name,omitempty"`
+	// The nodes of the node pool spread evenly across the availability zones, set if multiple zones are requested
+	ZoneNodes []ZoneNodes `json:"zoneNodes,omitempty"`
 }
 
 // ClusterRecommendationAccuracy encapsulates recommendation accuracy
@@ -121,6 +439,12 @@ type ClusterRecommendationAccuracy struct {
 	RecMem float64 `json:"memory"`
 	// Number of recommended cpus
 	RecCpu float64 `json:"cpu"`
+	// Number of recommended gpus
+	RecGpu float64 `json:"gpu"`
+	// The summarised amount of local storage in the recommended cluster (GB)
+	RecStorage float64 `json:"storage,omitempty"`
+	// The number of pods the recommended cluster can host, set if pods are requested
+	RecPods int `json:"pods,omitempty"`
 	// Number of recommended nodes
 	RecNodes int `json:"nodes"`
 	// Availability zones in the recommendation
@@ -129,12 +453,39 @@ type ClusterRecommendationAccuracy struct {
 	RecRegularPrice float64 `json:"regularPrice"`
 	// Number of regular instance type in the recommended cluster
 	RecRegularNodes int `json:"regularNodes"`
+	// Number of the regular nodes priced at the reserved rate
+	RecReservedNodes int `json:"reservedNodes,omitempty"`
+	// Realized percentage of regular nodes in the recommended cluster
+	RecOnDemandPct float64 `json:"onDemandPct"`
 	// Amount of spot instance type prices in the recommended cluster
 	RecSpotPrice float64 `json:"spotPrice"`
 	// Number of spot instance type in the recommended cluster
 	RecSpotNodes int `json:"spotNodes"`
+	// Percentage of the requested cpus covered by the cluster (at most 100)
+	RecCpuCoverage float64 `json:"cpuCoverage"`
+	// Percentage of the requested memory covered by the cluster (at most 100)
+	RecMemCoverage float64 `json:"memCoverage"`
+	// CPUs recommended on top of the mixed, unlimited recommendation for the same request (negative if less),
+	// set for same size recommendations and recommendations limited to a number of node pools
+	RecCpuOverProvisioned float64 `json:"cpuOverProvisioned,omitempty"`
+	// Memory recommended on top of the mixed, unlimited recommendation for the same request (negative if less),
+	// set for same size recommendations and recommendations limited to a number of node pools
+	RecMemOverProvisioned float64 `json:"memOverProvisioned,omitempty"`
+	// Memory recommended on top of the requested memory as the requested headroom, set if a headroom is requested
+	RecMemHeadroom float64 `json:"memHeadroom,omitempty"`
+	// Memory recommended on top of the requested memory and headroom due to the sizes of the nodes, set if a headroom
+	// is requested
+	RecMemExcess float64 `json:"memExcess,omitempty"`
+	// Hourly price on top of the mixed, unlimited recommendation for the same request (negative if less),
+	// set for same size recommendations and recommendations limited to a number of node pools
+	RecPriceOverhead float64 `json:"priceOverhead,omitempty"`
 	// Total price in the recommended cluster
 	RecTotalPrice float64 `json:"totalPrice"`
+	// Projected monthly price of the recommended cluster
+	RecMonthlyPrice float64 `json:"monthlyPrice"`
+	// Projected monthly price of an all on-demand layout of the new node pools on top of the recommended ones (negative
+	// if less), set if spot nodes are requested
+	RecMonthlySavings float64 `json:"estimatedMonthlySavings,omitempty"`
 }
 
 // VirtualMachine describes an instance type
@@ -145,12 +496,22 @@ type VirtualMachine struct {
 	AvgPrice float64 `json:"avgPrice"`
 	// Regular price of the instance type
 	OnDemandPrice float64 `json:"onDemandPrice"`
+	// Reserved (committed use) price of the instance type, set if a commitment is requested and the price is reported
+	ReservedPrice float64 `json:"reservedPrice,omitempty"`
+	// Average hourly regular price of the instance type with the sustained use discount of the monthly runtime, set on gce
+	SustainedUsePrice float64 `json:"sustainedUsePrice,omitempty"`
 	// Number of CPUs in the instance type
 	Cpus float64 `json:"cpusPerVm"`
 	// Available memory in the instance type (GB)
 	Mem float64 `json:"memPerVm"`
 	// Number of GPUs in the instance type
 	Gpus float64 `json:"gpusPerVm"`
+	// Local storage in the instance type (GB), not set if it's not reported
+	Storage float64 `json:"storagePerVm,omitempty"`
+	// Maximum number of pods on a node of the instance type, not set if it's not reported
+	MaxPods int `json:"maxPodsPerVm,omitempty"`
+	// The storage classes supported by the instance type, not set if they're not reported
+	StorageTypes []string `json:"storageTypes,omitempty"`
 	// Burst signals a burst type instance
 	Burst bool `json:"burst"`
 	// NetworkPerf holds the network performance
@@ -159,6 +520,21 @@ type VirtualMachine struct {
 	NetworkPerfCat string `json:"networkPerfCategory"`
 	// CurrentGen the vm is of current generation
 	CurrentGen bool `json:"currentGen"`
+	// Architecture the cpu architecture of the vm
+	Architecture string `json:"architecture"`
+	// Generation the generation of the vm, not set if it's not known
+	Generation int `json:"generation,omitempty"`
+	// Custom signals a custom machine type sized for the request instead of a predefined instance type
+	Custom bool `json:"custom,omitempty"`
+	// InterruptionTier the interruption frequency tier of the spot instances, set if the interruption rates are fetched
+	InterruptionTier string `json:"interruptionTier,omitempty"`
+	// the stability score of the spot price, set if the spot price variance is fetched
+	stability float64
+	// the upper bound of the interruption frequency tier of the spot instances, set if the interruption rates are fetched
+	interruptionRate float64
+	// the list prices of the vm, set if the prices are adjusted by the price adjuster of the engine
+	listOnDemandPrice float64
+	listSpotPrice     float64
 }
 
 func (v *VirtualMachine) getAttrValue(attr string) float64 {
@@ -167,11 +543,28 @@ func (v *VirtualMachine) getAttrValue(attr string) float64 {
 		return v.Cpus
 	case Memory:
 		return v.Mem
+	case Gpu:
+		return v.Gpus
+	case Storage:
+		return v.Storage
+	case Pods:
+		return float64(v.MaxPods)
 	default:
 		return 0
 	}
 }
 
+// typeLess ranks the instance types of the same price: the smaller one first, the one of the lower name on equal sizes
+func typeLess(vm1 VirtualMachine, vm2 VirtualMachine) bool {
+	if vm1.Cpus != vm2.Cpus {
+		return vm1.Cpus < vm2.Cpus
+	}
+	if vm1.Mem != vm2.Mem {
+		return vm1.Mem < vm2.Mem
+	}
+	return vm1.Type < vm2.Type
+}
+
 // ByAvgPricePerCpu type for custom sorting of a slice of vms
 type ByAvgPricePerCpu []VirtualMachine
 
@@ -180,6 +573,9 @@ func (a ByAvgPricePerCpu) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
 func (a ByAvgPricePerCpu) Less(i, j int) bool {
 	pricePerCpu1 := a[i].AvgPrice / a[i].Cpus
 	pricePerCpu2 := a[j].AvgPrice / a[j].Cpus
+	if pricePerCpu1 == pricePerCpu2 {
+		return typeLess(a[i], a[j])
+	}
 	return pricePerCpu1 < pricePerCpu2
 }
 
@@ -191,30 +587,255 @@ func (a ByAvgPricePerMemory) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
 func (a ByAvgPricePerMemory) Less(i, j int) bool {
 	pricePerMem1 := a[i].AvgPrice / a[i].Mem
 	pricePerMem2 := a[j].AvgPrice / a[j].Mem
+	if pricePerMem1 == pricePerMem2 {
+		return typeLess(a[i], a[j])
+	}
 	return pricePerMem1 < pricePerMem2
 }
 
 // RecommendCluster performs recommendation based on the provided arguments
 func (e *Engine) RecommendCluster(provider string, region string, req ClusterRecommendationReq) (*ClusterRecommendationResp, error) {
+	return e.RecommendClusterCtx(context.Background(), provider, region, req)
+}
+
+// RecommendClusterCtx performs recommendation based on the provided arguments, the outstanding product info calls are
+// abandoned and the error of the context is returned if the context is done before the recommendation is ready; a
+// RecommendationTimeout error is returned if the request timeout of the engine is exceeded. The source calls take no
+// context, the abandoned ones are not aborted but left to finish in the background, and their results are dropped
+func (e *Engine) RecommendClusterCtx(ctx context.Context, provider string, region string, req ClusterRecommendationReq) (*ClusterRecommendationResp, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, e.reqTimeout)
+	defer cancel()
+
+	req = e.withDefaults(provider, req)
+	resp, err := e.cachedRecommendation(reqCtx, provider, region, req)
+	if err == context.DeadlineExceeded && reqCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		log.WithField("requestId", CorrelationID(ctx)).Errorf("recommendation not ready in %s, provider: %s, region: %s",
+			e.reqTimeout, provider, region)
+		return nil, NewError(RecommendationTimeout, "the recommendation couldn't be computed in [%s], the request is aborted", e.reqTimeout)
+	}
+	return resp, err
+}
 
+// withDefaults sets the defaults of the engine on the fields the request doesn't set, and resolves the aliases of the
+// included, excluded and reserved instance types
+func (e *Engine) withDefaults(provider string, req ClusterRecommendationReq) ClusterRecommendationReq {
+	if req.OnDemandPct == nil {
+		pct := e.onDemandPct
+		req.OnDemandPct = &pct
+	}
+	if req.SpotMargin == nil {
+		margin := e.spotMargin
+		req.SpotMargin = &margin
+	}
+	req.Includes = canonicalInstanceTypes(provider, req.Includes)
+	req.Excludes = canonicalInstanceTypes(provider, req.Excludes)
+	if len(req.Reserved) > 0 {
+		reserved := make(map[string]int, len(req.Reserved))
+		for vmType, count := range req.Reserved {
+			reserved[canonicalInstanceType(provider, vmType)] += count
+		}
+		req.Reserved = reserved
+	}
+	return req
+}
+
+// onDemandPct returns the percentage of regular nodes of the request, 0 if not set
+func (req *ClusterRecommendationReq) onDemandPct() int {
+	if req.OnDemandPct == nil {
+		return 0
+	}
+	return *req.OnDemandPct
+}
+
+// cachedRecommendation serves the recommendation from the cache if possible, computes and caches it otherwise; the
+// concurrent identical requests share the same computation, the requests with the no cache flag compute their own
+func (e *Engine) cachedRecommendation(ctx context.Context, provider string, region string, req ClusterRecommendationReq) (*ClusterRecommendationResp, error) {
 	log.Infof("recommending cluster configuration. Provider: [%s], region: [%s], recommendation request: [%#v]",
 		provider, region, req)
 
-	attributes := []string{Cpu, Memory}
+	if req.NoCache {
+		return e.recommendCluster(ctx, provider, region, req)
+	}
+
+	key, err := fingerprint(provider, region, req)
+	if err != nil {
+		log.WithError(err).Warn("could not fingerprint the request, skipping the cache")
+		return e.recommendCluster(ctx, provider, region, req)
+	}
+	if e.cache != nil {
+		if resp, ok := e.cache.get(key); ok {
+			log.Debugf("serving cached recommendation for request: [%s]", key)
+			return resp, nil
+		}
+	}
+
+	return e.inflight.do(ctx, key, func(ctx context.Context) (*ClusterRecommendationResp, error) {
+		resp, err := e.recommendCluster(ctx, provider, region, req)
+		if err != nil {
+			return nil, err
+		}
+		if e.cache != nil {
+			e.cache.set(key, resp)
+		}
+		return resp, nil
+	})
+}
+
+// recommendCluster computes the recommendation from the product info
+func (e *Engine) recommendCluster(ctx context.Context, provider string, region string, req ClusterRecommendationReq) (*ClusterRecommendationResp, error) {
+	// new node pools are only recommended for the resources not covered by the existing node pools
+	clusterReq := req
+	req, attributes := clusterReq.remaining()
+	if len(attributes) == 0 {
+		log.Info("the existing node pools satisfy the requested resources")
+		resp, err := clusterReq.clusterResp(provider, region, []NodePool{})
+		if err != nil {
+			return nil, err
+		}
+		// the existing node pools are priced as in the request
+		e.stampPricing(provider, region, time.Now().UTC(), resp)
+		resp.Request = &clusterReq
+		return resp, nil
+	}
+
+	if req.MinGen > 0 && !generationReported(provider) {
+		return nil, NewError(ResourceUnavailable, "the generation of the instance types is not known on provider [%s], the minimum generation can't be applied", provider)
+	}
+
+	pi, err := e.fetchProductInfo(ctx, provider, region, attributes, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.ExcludeZones) > 0 {
+		if pi.zones, err = excludeZones(region, pi, req.ExcludeZones); err != nil {
+			return nil, err
+		}
+		req.Zones, clusterReq.Zones = pi.zones, pi.zones
+	}
+
+	var heldWarnings []Warning
+	if len(req.Reserved) > 0 {
+		// new node pools are only recommended for the resources not covered by the held reservations either
+		if pi.productsErr != nil {
+			return nil, pi.productsErr
+		}
+		clusterReq.held, heldWarnings = clusterReq.heldReservations(provider, region, pi)
+		if req, attributes = clusterReq.remaining(); len(attributes) == 0 {
+			log.Info("the held reservations satisfy the requested resources")
+			resp, err := clusterReq.clusterResp(provider, region, []NodePool{})
+			if err != nil {
+				return nil, err
+			}
+			resp.setWarnings(heldWarnings)
+			e.stampPricing(provider, region, pi.fetched, resp)
+			resp.Request = &clusterReq
+			return resp, nil
+		}
+	}
+
+	resp, err := e.layoutCluster(ctx, provider, region, clusterReq, req, attributes, pi)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.PreferredFamilies) > 0 {
+		resp = e.preferFamilies(ctx, provider, region, clusterReq, req, attributes, pi, resp)
+	}
+	resp.setWarnings(append(heldWarnings, resp.WarningDetails...))
+	if n := alternativesCount(clusterReq.Alternatives); n > 0 {
+		resp.Alternatives = e.alternatives(ctx, provider, region, clusterReq, req, attributes, pi, resp, n)
+	}
+	e.stampPricing(provider, region, pi.fetched, resp)
+	for i := range resp.Alternatives {
+		e.stampPricing(provider, region, pi.fetched, &resp.Alternatives[i])
+	}
+	resp.Request = &clusterReq
+	return resp, nil
+}
+
+// stampPricing records the freshness of the prices in the response, the snapshot time of the source is preferred to
+// the time the prices were fetched
+func (e *Engine) stampPricing(provider string, region string, fetched time.Time, resp *ClusterRecommendationResp) {
+	resp.PricingAsOf = fetched
+	if ss, ok := e.piSource.(SnapshotSource); ok {
+		resp.PricingAsOf = ss.SnapshotTime().UTC()
+	}
+	resp.PriceVersion = e.PriceVersion(provider, region)
+}
+
+// layoutCluster lays out the node pools of the recommendation from the product info, req is the request for the
+// resources not covered by the existing node pools of the cluster request
+func (e *Engine) layoutCluster(ctx context.Context, provider string, region string, clusterReq ClusterRecommendationReq,
+	req ClusterRecommendationReq, attributes []string, pi *productInfo) (*ClusterRecommendationResp, error) {
+	warnings, err := req.ignoreUnreported(provider, region, pi)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.NodeCount > 0 {
+		// the attribute values are selected for the fixed number of nodes
+		req.MinNodes, req.MaxNodes = req.NodeCount, req.NodeCount
+	}
+
+	// spot-only layouts are spread over several instance types unless a single type or pool is requested
+	spotOnly := req.onDemandPct() == 0 && provider != "oracle"
+	if spotOnly && !req.SameSize && req.NodeCount == 0 && req.MinNodePools < e.minSpotPools {
+		req.MinNodePools = e.minSpotPools
+		if req.MaxNodePools > 0 && req.MaxNodePools < req.MinNodePools {
+			req.MinNodePools = req.MaxNodePools
+		}
+	}
+
+	if needsCandidateCheck(req) {
+		if pi.productsErr != nil {
+			log.Errorf("couldn't get product details. region: %s, provider: %s", region, provider)
+			return nil, pi.productsErr
+		}
+		if err := checkCandidates(provider, region, pi, req); err != nil {
+			return nil, err
+		}
+	}
+
 	nodePools := make(map[string][]NodePool, 2)
+	// the reason the node pools of an attribute couldn't be laid out as requested
+	var layoutErr error
+	// the mixed, unlimited recommendations the same size or limited recommendation is compared to
+	mixedNodePools := make(map[string][]NodePool, 2)
+	// the all on-demand recommendations the savings of the spot nodes are estimated against
+	onDemandNodePools := make(map[string][]NodePool, 2)
+	// the candidates dropped from the recommendation, collected if an explanation is requested
+	var rejected []RejectedCandidate
+	// whether the maximum spot price dropped any of the spot candidates
+	var spotCapped bool
 
 	for _, attr := range attributes {
+		// laying out the node pools doesn't outlive the request either
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 
-		values, err := e.RecommendAttrValues(provider, region, attr, req)
+		if pi.attrValuesErr[attr] != nil {
+			if ErrorCode(pi.attrValuesErr[attr]) != "" {
+				return nil, pi.attrValuesErr[attr]
+			}
+			return nil, fmt.Errorf("could not get values for attr: [%s], cause: [%s]", attr, pi.attrValuesErr[attr].Error())
+		}
+		values, err := selectAttrValues(pi.attrValues[attr], attr, req)
 		if err != nil {
 			return nil, fmt.Errorf("could not get values for attr: [%s], cause: [%s]", attr, err.Error())
 		}
 		log.Debugf("recommended values for [%s]: count:[%d] , values: [%#v./te]", attr, len(values), values)
 
 		vmFilters, _ := e.filtersForAttr(attr, provider)
+		if req.Explain {
+			explained, _ := e.explainedFilters(attr, provider)
+			rejected = append(rejected, e.rejectedCandidates(provider, pi, attr, values, explained, req)...)
+		}
 
-		filteredVms, err := e.RecommendVms(provider, region, attr, values, vmFilters, req)
+		filteredVms, err := e.recommendVms(provider, region, pi, attr, values, vmFilters, req)
 		if err != nil {
+			if ErrorCode(err) != "" {
+				return nil, err
+			}
 			return nil, fmt.Errorf("could not get virtual machines for attr: [%s], cause: [%s]", attr, err.Error())
 		}
 		if len(filteredVms) == 0 {
@@ -223,55 +844,508 @@ func (e *Engine) RecommendCluster(provider string, region string, req ClusterRec
 			continue
 		}
 		log.Debugf("recommended vms for [%s]: count:[%d] , values: [%#v]", attr, len(filteredVms), filteredVms)
+		spotCapped = spotCapped || req.spotCapped(filteredVms)
 
 		//todo add request validation for interdependent request fields, eg: onDemandPct is always 100 when spot
 		// instances are not available for provider
-		if provider == "oracle" {
-			log.Warnf("onDemand percentage in the request ignored for provider [%s]", provider)
-			req.OnDemandPct = 100
+		if provider == "oracle" && req.onDemandPct() < 100 {
+			warnings = append(warnings, newWarning(SpotUnavailable, "spot instances are not available on provider [%s], the nodes are recommended on-demand", provider))
+			pct := 100
+			req.OnDemandPct = &pct
 		}
 		nps, err := e.RecommendNodePools(attr, filteredVms, values, req)
+		if err != nil && ErrorCode(err) != "" {
+			log.Debugf("could not lay out node pools for attr: [%s], cause: [%s]", attr, err.Error())
+			layoutErr = err
+			continue
+		}
 		if err != nil {
 			return nil, fmt.Errorf("error while recommending node pools for attr: [%s], cause: [%s]", attr, err.Error())
 		}
 		log.Debugf("recommended node pools for [%s]: count:[%d] , values: [%#v]", attr, len(nps), nps)
 
+		if used := distinctTypes(nps); used < req.MinNodePools {
+			log.Debugf("could not diversify node pools for attr: [%s], distinct instance types: [%d]", attr, used)
+			layoutErr = NewError(NoViableInstances, "could not recommend [%d] node pools of distinct instance types, only [%d] instance types are viable for the request",
+				req.MinNodePools, distinctVmTypes(filteredVms))
+			continue
+		}
+
 		nodePools[attr] = nps
+
+		if req.SameSize || req.MaxNodePools > 0 {
+			mixedReq := req
+			mixedReq.SameSize = false
+			mixedReq.MaxNodePools = 0
+			mixed, err := e.RecommendNodePools(attr, append([]VirtualMachine{}, filteredVms...), values, mixedReq)
+			if err != nil {
+				log.WithError(err).Warnf("couldn't recommend mixed node pools for attr: [%s]", attr)
+				continue
+			}
+			mixedNodePools[attr] = mixed
+		}
+
+		if req.onDemandPct() < 100 {
+			// the baseline keeps the layout mode of the request: the node count, the same size nodes and the node pool
+			// limits select the layout of RecommendNodePools for the baseline as they do for the recommendation
+			onDemandReq := req
+			pct := 100
+			onDemandReq.OnDemandPct = &pct
+			onDemand, err := e.RecommendNodePools(attr, append([]VirtualMachine{}, filteredVms...), values, onDemandReq)
+			if err != nil {
+				log.WithError(err).Warnf("couldn't recommend on-demand node pools for attr: [%s]", attr)
+				continue
+			}
+			onDemandNodePools[attr] = onDemand
+		}
 	}
 
 	if len(nodePools) == 0 {
 		log.Debugf("could not recommend node pools for request: %v", req)
+		if layoutErr != nil {
+			return nil, layoutErr
+		}
+		if len(req.Includes) > 0 {
+			return nil, NewError(NoViableInstances, "the included instance types %v can't satisfy the requested resources", req.Includes)
+		}
+		if len(req.Excludes) > 0 {
+			return nil, NewError(NoViableInstances, "the instance types not excluded by %v can't satisfy the requested resources", req.Excludes)
+		}
 		return nil, errors.New("could not recommend cluster with the requested resources")
 	}
 
+	if req.CommitmentPct > 0 {
+		reserved := e.adjustReservedPrices(provider, region, pi.reserved)
+		// the node pool sets are compared at the blended price
+		for _, sets := range []map[string][]NodePool{nodePools, mixedNodePools, onDemandNodePools} {
+			for attr := range sets {
+				reserveNodes(sets[attr], reserved, req.CommitmentPct)
+			}
+		}
+	}
+
+	optimizeSustainedUse := req.OptimizeSustainedUse && provider == sustainedUseProvider
+	if optimizeSustainedUse {
+		// the node pool sets are compared at the discounted prices, only the reported prices are discounted otherwise
+		for _, sets := range []map[string][]NodePool{nodePools, mixedNodePools, onDemandNodePools} {
+			for attr := range sets {
+				sustainUse(sets[attr], req.monthlyHours())
+			}
+		}
+	}
+
 	cheapestNodePoolSet := e.findCheapestNodePoolSet(nodePools)
+	if req.PreferNewGen {
+		cheapestNodePoolSet = preferNewGen(nodePools, cheapestNodePoolSet, e.newGenEps)
+	}
+	cheapestNodePoolSet = bestObjectiveNodePoolSet(nodePools, cheapestNodePoolSet, req.costWeight())
+	if req.CustomTypes {
+		custom := e.customNodePools(region, pi.customPrices, req)
+		if optimizeSustainedUse {
+			sustainUse(custom, req.monthlyHours())
+		}
+		if custom != nil && poolSetPrice(custom) < poolSetPrice(cheapestNodePoolSet) {
+			log.Debugf("custom machine type [%s] is cheaper than the predefined instance types", custom[0].VmType.Type)
+			cheapestNodePoolSet = custom
+		}
+	}
+	if spotOnly {
+		cheapestNodePoolSet = spotPools(cheapestNodePoolSet)
+		// the nodes are recommended on-demand if the spot prices don't pay off with the safety margin
+		spotOnly = cheapestNodePoolSet[0].VmClass == spot
+	}
+	if req.MaxSpotPrice > 0 && !bidSpots(cheapestNodePoolSet, req.MaxSpotPrice) && spotCapped {
+		warnings = append(warnings, newWarning(SpotOverMaxPrice, "no instance type is available as spot instance for at most [%v] an hour, the nodes are recommended on-demand", req.MaxSpotPrice))
+	}
+	for i := range cheapestNodePoolSet {
+		cheapestNodePoolSet[i].PoolPrice = cheapestNodePoolSet[i].poolPrice()
+	}
+
+	resp, err := clusterReq.clusterResp(provider, region, cheapestNodePoolSet)
+	if err != nil {
+		return nil, err
+	}
+	resp.setWarnings(warnings)
+	resp.Rejected = rejected
+	if req.objectiveRequested() {
+		resp.Objective = &ObjectiveScore{CostWeight: req.costWeight(), Cost: poolSetPrice(resp.NodePools), Performance: layoutPerformance(resp.NodePools)}
+	}
+	resp.SpotOnly = spotOnly
 
-	accuracy := req.findResponseSum(provider, region, cheapestNodePoolSet)
+	if len(mixedNodePools) > 0 {
+		resp.Accuracy.RecCpuOverProvisioned, resp.Accuracy.RecMemOverProvisioned, resp.Accuracy.RecPriceOverhead =
+			overhead(cheapestNodePoolSet, e.findCheapestNodePoolSet(mixedNodePools))
+	}
+	if len(onDemandNodePools) > 0 {
+		onDemand := e.findCheapestNodePoolSet(onDemandNodePools)
+		if provider == sustainedUseProvider {
+			// priced as the recommended node pools are
+			sustainUse(onDemand, req.monthlyHours())
+		}
+		resp.Accuracy.RecMonthlySavings = (poolSetPrice(onDemand) - poolSetPrice(cheapestNodePoolSet)) * req.monthlyHours()
+	}
 
-	return &ClusterRecommendationResp{
-		Provider:  provider,
-		Zones:     req.Zones,
-		NodePools: cheapestNodePoolSet,
-		Accuracy:  accuracy,
-	}, nil
+	return resp, nil
+}
+
+// overhead calculates the cpus, memory and price of the node pools on top of the ones of the reference node pools
+func overhead(nodePools []NodePool, reference []NodePool) (float64, float64, float64) {
+	var cpus, mem, price float64
+	for _, np := range nodePools {
+		cpus += np.getSum(Cpu)
+		mem += np.getSum(Memory)
+		price += np.poolPrice()
+	}
+	for _, np := range reference {
+		cpus -= np.getSum(Cpu)
+		mem -= np.getSum(Memory)
+		price -= np.poolPrice()
+	}
+	return cpus, mem, price
+}
+
+// ignoreUnreported drops the parts of the request the product info source doesn't report the data of, and returns the
+// warnings about them; the errors of fetching the data are returned instead
+func (req *ClusterRecommendationReq) ignoreUnreported(provider string, region string, pi *productInfo) ([]Warning, error) {
+	var warnings []Warning
+	if req.SumStorage > 0 && pi.storage == nil {
+		if pi.storageErr != nil {
+			log.Errorf("couldn't get local storage. region: %s, provider: %s", region, provider)
+			return nil, pi.storageErr
+		}
+		warnings = append(warnings, newWarning(StorageUnreported, "local storage is not reported on provider [%s], the requested storage is not taken into account", provider))
+		req.SumStorage = 0
+	}
+	if req.SumPods > 0 && pi.podLimits == nil {
+		if pi.podLimitsErr != nil {
+			log.Errorf("couldn't get pod limits. region: %s, provider: %s", region, provider)
+			return nil, pi.podLimitsErr
+		}
+		warnings = append(warnings, newWarning(PodLimitsUnreported, "pod limits are not reported on provider [%s], the requested pods are not taken into account", provider))
+		req.SumPods = 0
+	}
+	if req.StorageType != "" && pi.storageTypes == nil {
+		if pi.storageTypesErr != nil {
+			log.Errorf("couldn't get storage types. region: %s, provider: %s", region, provider)
+			return nil, pi.storageTypesErr
+		}
+		warnings = append(warnings, newWarning(StorageTypesUnreported, "storage types are not reported on provider [%s], the requested storage type is not taken into account", provider))
+		req.StorageType = ""
+	}
+	if req.stableSpot() && pi.spotVariance == nil {
+		if pi.variancesErr != nil {
+			log.Errorf("couldn't get spot price variances. region: %s, provider: %s", region, provider)
+			return nil, pi.variancesErr
+		}
+		warnings = append(warnings, newWarning(SpotVarianceUnreported, "spot price variances are not reported on provider [%s], the spot pools are not ranked by stability", provider))
+		req.StableSpot = false
+	}
+	if req.interruptionsFiltered() && pi.interruptions == nil {
+		if pi.interruptErr != nil {
+			log.Errorf("couldn't get spot interruption rates. region: %s, provider: %s", region, provider)
+			return nil, pi.interruptErr
+		}
+		warnings = append(warnings, newWarning(SpotInterruptionsUnreported, "spot interruption rates are not reported on provider [%s], the spot instance types are not filtered by their interruption rate", provider))
+		req.MaxInterruptionRate = 0
+	}
+	if req.CommitmentPct > 0 && pi.reserved == nil {
+		if pi.reservedErr != nil {
+			log.Errorf("couldn't get reserved prices. region: %s, provider: %s", region, provider)
+			return nil, pi.reservedErr
+		}
+		warnings = append(warnings, newWarning(ReservedPricesUnreported, "reserved prices are not reported on provider [%s], the regular nodes are priced at the on-demand price", provider))
+		req.CommitmentPct = 0
+	}
+	if req.CustomTypes && pi.customPrices == nil {
+		if pi.customPricesErr != nil {
+			log.Errorf("couldn't get custom machine prices. region: %s, provider: %s", region, provider)
+			return nil, pi.customPricesErr
+		}
+		warnings = append(warnings, newWarning(CustomTypesUnavailable, "custom machine types are not available on provider [%s] in region [%s], only predefined instance types are recommended", provider, region))
+		req.CustomTypes = false
+	}
+	if req.PreferNewGen && !generationReported(provider) {
+		warnings = append(warnings, newWarning(GenerationUnknown, "the generation of the instance types is not known on provider [%s], the cheapest layout is recommended", provider))
+		req.PreferNewGen = false
+	}
+	if req.costWeight() < 1 && !generationReported(provider) {
+		warnings = append(warnings, newWarning(PerformanceUnknown, "the performance of the instance types is not known on provider [%s], the candidates are ranked by cost", provider))
+		req.Objective, req.CostWeight = objectiveCost, nil
+	}
+	return warnings, nil
+}
+
+// memTarget returns the memory the cluster is recommended for, the requested memory with the headroom
+func (req *ClusterRecommendationReq) memTarget() float64 {
+	return req.SumMem * (1 + req.MemHeadroomPct/100)
+}
+
+// remaining returns the request for the resources not covered by the existing node pools,
+// and the attributes new node pools need to be recommended for; the memory headroom is requested on top of the
+// memory, the tolerated shortfall of cpus and memory is not requested
+func (req *ClusterRecommendationReq) remaining() (ClusterRecommendationReq, []string) {
+	rem := *req
+	rem.Existing = nil
+	rem.MemHeadroomPct = 0
+	rem.SumCpu = req.SumCpu * (1 - req.Tolerance/100)
+	rem.SumMem = req.memTarget() * (1 - req.Tolerance/100)
+	for _, np := range append(append([]NodePool{}, req.Existing...), req.held...) {
+		rem.SumCpu -= np.getSum(Cpu)
+		rem.SumMem -= np.getSum(Memory)
+		rem.SumGpu -= np.getSum(Gpu)
+		rem.SumStorage -= np.getSum(Storage)
+		rem.SumPods -= int(np.getSum(Pods))
+	}
+	rem.SumCpu = math.Max(rem.SumCpu, 0)
+	rem.SumMem = math.Max(rem.SumMem, 0)
+	rem.SumGpu = math.Max(rem.SumGpu, 0)
+	rem.SumStorage = math.Max(rem.SumStorage, 0)
+	if rem.SumPods < 0 {
+		rem.SumPods = 0
+	}
+
+	var attributes []string
+	if rem.SumCpu > 0 {
+		attributes = append(attributes, Cpu)
+	}
+	if rem.SumMem > 0 {
+		attributes = append(attributes, Memory)
+	}
+	if len(attributes) == 0 && (rem.SumGpu > 0 || rem.SumStorage > 0 || rem.SumPods > 0) {
+		// only gpus, storage or pods are missing, the node pools are sized by cpu
+		attributes = append(attributes, Cpu)
+	}
+	return rem, attributes
+}
+
+// clusterResp assembles the response from the new node pools and the existing ones in the request,
+// the accuracy and the price limit apply to the whole cluster
+func (req *ClusterRecommendationReq) clusterResp(provider string, region string, nodePools []NodePool) (*ClusterRecommendationResp, error) {
+	var existing []NodePool
+	for _, np := range req.Existing {
+		np.PoolPrice = np.poolPrice()
+		existing = append(existing, np)
+	}
+	if provider == sustainedUseProvider {
+		sustainUse(existing, req.monthlyHours())
+		sustainUse(nodePools, req.monthlyHours())
+	}
+
+	accuracy := req.findResponseSum(provider, region, append(append(append([]NodePool{}, existing...), req.held...), nodePools...))
+
+	if req.MaxPrice > 0 && accuracy.RecTotalPrice > req.MaxPrice {
+		return nil, NewError(OverBudget, "could not recommend cluster under the max price [%f], the cheapest achievable price is [%f]",
+			req.MaxPrice, accuracy.RecTotalPrice)
+	}
+
+	balanceZones(nodePools, req.Zones)
+	if req.NamePrefix != "" {
+		nameNodePools(req.NamePrefix, nodePools, append(append([]NodePool{}, existing...), req.held...))
+	}
+	resp := &ClusterRecommendationResp{
+		Provider:          provider,
+		Region:            region,
+		Zones:             req.Zones,
+		NodePools:         nodePools,
+		ExistingNodePools: existing,
+		ReservedNodePools: req.held,
+		Accuracy:          accuracy,
+	}
+	if len(req.Reserved) > 0 {
+		resp.ReservedUsed = reservedUsed(req.Reserved, req.held)
+	}
+	if req.WithSummary {
+		resp.Summary = summary(region, resp)
+	}
+	if req.Weighted {
+		resp.WeightedCapacity = weightedCapacity(nodePools)
+	}
+	return resp, nil
+}
+
+// needsCandidateCheck returns true if the request has constraints that may not be satisfiable by any instance type
+func needsCandidateCheck(req ClusterRecommendationReq) bool {
+	return req.SumGpu > 0 || req.SumStorage > 0 || req.SumPods > 0 || req.StorageType != "" || req.NoBurstable || len(req.Includes) > 0 || len(req.Excludes) > 0 || req.Architecture != "" || req.NetworkPerf != nil || req.MinGen > 0 ||
+		req.boundsNodeSize()
+}
+
+// checkCandidates checks whether the instance types on the provider in the region can serve the request at all
+func checkCandidates(provider string, region string, pi *productInfo, req ClusterRecommendationReq) error {
+	allProducts := pi.products
+
+	if req.SumGpu > 0 {
+		var gpuVms int
+		for _, p := range allProducts {
+			if p.Gpus > 0 {
+				gpuVms++
+			}
+		}
+		if gpuVms == 0 {
+			return NewError(ResourceUnavailable, "there are no instance types with gpus on provider [%s] in region [%s]", provider, region)
+		}
+	}
+
+	if req.SumStorage > 0 {
+		var storageVms int
+		for _, p := range allProducts {
+			if pi.storage[p.Type] > 0 {
+				storageVms++
+			}
+		}
+		if storageVms == 0 {
+			return NewError(ResourceUnavailable, "there are no instance types with local storage on provider [%s] in region [%s]", provider, region)
+		}
+	}
+
+	if req.SumPods > 0 {
+		var podVms int
+		for _, p := range allProducts {
+			if pi.podLimits[p.Type] > 0 {
+				podVms++
+			}
+		}
+		if podVms == 0 {
+			return NewError(ResourceUnavailable, "there are no instance types with pod limits on provider [%s] in region [%s]", provider, region)
+		}
+	}
+
+	if req.StorageType != "" {
+		var storageTypeVms int
+		for _, p := range allProducts {
+			if contains(pi.storageTypes[p.Type], req.StorageType) {
+				storageTypeVms++
+			}
+		}
+		if storageTypeVms == 0 {
+			return NewError(ResourceUnavailable, "there are no instance types supporting the storage type [%s] on provider [%s] in region [%s]",
+				req.StorageType, provider, region)
+		}
+	}
+
+	if len(req.Includes) > 0 || len(req.Excludes) > 0 {
+		var includedVms, candidateVms int
+		for _, p := range allProducts {
+			if len(req.Includes) > 0 && !contains(req.Includes, p.Type) {
+				continue
+			}
+			includedVms++
+			// excludes win over includes
+			if !contains(req.Excludes, p.Type) {
+				candidateVms++
+			}
+		}
+		if len(req.Includes) > 0 && includedVms == 0 {
+			return NewError(NoViableInstances, "none of the included instance types %v are available, [%d] instance types were available before filtering",
+				req.Includes, len(allProducts))
+		}
+		if candidateVms == 0 {
+			return NewError(NoViableInstances, "all the [%d] available instance types are excluded by %v", includedVms, req.Excludes)
+		}
+	}
+
+	if req.NoBurstable {
+		var steadyVms int
+		for _, p := range allProducts {
+			if !p.Burst && !burstable(provider, p.Type) {
+				steadyVms++
+			}
+		}
+		if steadyVms == 0 {
+			return NewError(NoViableInstances, "only burstable instance types are available on provider [%s] in region [%s], burstable instance types are excluded by the request",
+				provider, region)
+		}
+	}
+
+	if req.Architecture != "" {
+		var archVms int
+		for _, p := range allProducts {
+			if architecture(provider, p.Type) == req.Architecture {
+				archVms++
+			}
+		}
+		if archVms == 0 {
+			return NewError(NoViableInstances, "there are no instance types with [%s] architecture on provider [%s] in region [%s]",
+				req.Architecture, provider, region)
+		}
+	}
+
+	if req.MinGen > 0 {
+		var genVms int
+		for _, p := range allProducts {
+			if generation(provider, p.Type) >= req.MinGen {
+				genVms++
+			}
+		}
+		if genVms == 0 {
+			return NewError(NoViableInstances, "there are no instance types of generation [%d] or newer on provider [%s] in region [%s]",
+				req.MinGen, provider, region)
+		}
+	}
+
+	if req.boundsNodeSize() {
+		var boundedVms int
+		var maxCpu, maxMem float64
+		for _, p := range allProducts {
+			if req.withinNodeSize(p.Cpus, p.Mem) {
+				boundedVms++
+				maxCpu = math.Max(maxCpu, p.Cpus)
+				maxMem = math.Max(maxMem, p.Mem)
+			}
+		}
+		if boundedVms == 0 {
+			return NewError(NoViableInstances, "there are no instance types within the node size bounds of %s on provider [%s] in region [%s]",
+				req.nodeSizeBounds(), provider, region)
+		}
+		if req.MaxNodes > 0 && (maxCpu*float64(req.MaxNodes) < req.SumCpu || maxMem*float64(req.MaxNodes) < req.SumMem) {
+			return NewError(NoViableInstances, "the node size bounds of %s conflict with maxNodes [%d]: the largest nodes within the bounds have [%v] cpus and [%v] GB memory, "+
+				"[%d] of them can't provide [%v] cpus and [%v] GB memory", req.nodeSizeBounds(), req.MaxNodes, maxCpu, maxMem, req.MaxNodes, req.SumCpu, req.SumMem)
+		}
+	}
+
+	if req.NetworkPerf != nil && reportsNetworkPerf(provider) {
+		highest := -1
+		for _, p := range allProducts {
+			if rank := networkPerfRank(p.NtwPerfCat); rank > highest {
+				highest = rank
+			}
+		}
+		if highest < networkPerfRank(*req.NetworkPerf) {
+			highestCat := "none"
+			if highest >= 0 {
+				highestCat = networkPerfCategories[highest]
+			}
+			return NewError(NoViableInstances, "there are no instance types with [%s] or better network performance on provider [%s] in region [%s], the highest available is [%s]",
+				*req.NetworkPerf, provider, region, highestCat)
+		}
+	}
+
+	return nil
 }
 
 func (req *ClusterRecommendationReq) findResponseSum(provider string, region string, nodePoolSet []NodePool) ClusterRecommendationAccuracy {
 	var sumCpus float64
 	var sumMem float64
+	var sumGpus float64
+	var sumStorage float64
+	var sumPods float64
 	var sumNodes int
 	var sumRegularPrice float64
 	var sumRegularNodes int
+	var sumReservedNodes int
 	var sumSpotPrice float64
 	var sumSpotNodes int
 	var sumTotalPrice float64
 	for _, nodePool := range nodePoolSet {
 		sumCpus += nodePool.getSum(Cpu)
 		sumMem += nodePool.getSum(Memory)
+		sumGpus += nodePool.getSum(Gpu)
+		sumStorage += nodePool.getSum(Storage)
+		sumPods += nodePool.getSum(Pods)
 		sumNodes += nodePool.SumNodes
 		if nodePool.VmClass == regular {
 			sumRegularPrice += nodePool.poolPrice()
 			sumRegularNodes += nodePool.SumNodes
+			sumReservedNodes += nodePool.ReservedNodes
 		} else {
 			sumSpotPrice += nodePool.poolPrice()
 			sumSpotNodes += nodePool.SumNodes
@@ -279,26 +1353,54 @@ func (req *ClusterRecommendationReq) findResponseSum(provider string, region str
 		sumTotalPrice += nodePool.poolPrice()
 	}
 
-	return ClusterRecommendationAccuracy{
-		RecCpu:          sumCpus,
-		RecMem:          sumMem,
-		RecNodes:        sumNodes,
-		RecZone:         req.Zones,
-		RecRegularPrice: sumRegularPrice,
-		RecRegularNodes: sumRegularNodes,
-		RecSpotPrice:    sumSpotPrice,
-		RecSpotNodes:    sumSpotNodes,
-		RecTotalPrice:   sumTotalPrice,
+	var onDemandPct float64
+	if sumNodes > 0 {
+		onDemandPct = float64(sumRegularNodes) / float64(sumNodes) * 100
 	}
+
+	accuracy := ClusterRecommendationAccuracy{
+		RecCpu:           sumCpus,
+		RecMem:           sumMem,
+		RecGpu:           sumGpus,
+		RecStorage:       sumStorage,
+		RecPods:          int(sumPods),
+		RecNodes:         sumNodes,
+		RecZone:          req.Zones,
+		RecRegularPrice:  sumRegularPrice,
+		RecRegularNodes:  sumRegularNodes,
+		RecReservedNodes: sumReservedNodes,
+		RecOnDemandPct:   onDemandPct,
+		RecCpuCoverage:   coverage(sumCpus, req.SumCpu),
+		RecMemCoverage:   coverage(sumMem, req.SumMem),
+		RecSpotPrice:     sumSpotPrice,
+		RecSpotNodes:     sumSpotNodes,
+		RecTotalPrice:    sumTotalPrice,
+		RecMonthlyPrice:  sumTotalPrice * req.monthlyHours(),
+	}
+	if req.MemHeadroomPct > 0 {
+		// the intentional over-provisioning is reported apart from the one due to the sizes of the nodes
+		accuracy.RecMemHeadroom = req.memTarget() - req.SumMem
+		accuracy.RecMemExcess = math.Max(sumMem-req.memTarget(), 0)
+	}
+	return accuracy
 }
 
-// findCheapestNodePoolSet looks up the "cheapest" node pool set from the provided map
+// findCheapestNodePoolSet looks up the "cheapest" node pool set from the provided map, the node pool sets of the same
+// price are ranked by the names of their instance types
 func (e *Engine) findCheapestNodePoolSet(nodePoolSets map[string][]NodePool) []NodePool {
 	log.Info("finding  cheapest pool set...")
 	var cheapestNpSet []NodePool
 	var bestPrice float64
 
-	for attr, nodePools := range nodePoolSets {
+	// the node pool sets are checked in a fixed order so that the same one is picked on every call
+	attrs := make([]string, 0, len(nodePoolSets))
+	for attr := range nodePoolSets {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+
+	for _, attr := range attrs {
+		nodePools := nodePoolSets[attr]
 		log.Debugf("checking node pool for attr: [%s]", attr)
 		var sumPrice float64
 		var sumCpus float64
@@ -313,7 +1415,7 @@ func (e *Engine) findCheapestNodePoolSet(nodePoolSets map[string][]NodePool) []N
 		log.Debugf("sum mem [%s]: %v", attr, sumMem)
 		log.Debugf("sum price [%s]: %v", attr, sumPrice)
 
-		if bestPrice == 0 || bestPrice > sumPrice {
+		if bestPrice == 0 || bestPrice > sumPrice || (bestPrice == sumPrice && layoutName(nodePools) < layoutName(cheapestNpSet)) {
 			log.Debugf("cheaper nodepoolset is found. price: [%f]", sumPrice)
 			bestPrice = sumPrice
 			cheapestNpSet = nodePools
@@ -360,13 +1462,65 @@ func findN(avg int) int {
 
 // RecommendVms selects a slice of VirtualMachines for the given attribute and requirements in the request
 func (e *Engine) RecommendVms(provider string, region string, attr string, values []float64, filters []vmFilter, req ClusterRecommendationReq) ([]VirtualMachine, error) {
+	pi, err := e.fetchProductInfo(context.Background(), provider, region, nil, req)
+	if err != nil {
+		return nil, err
+	}
+	return e.recommendVms(provider, region, pi, attr, values, filters, req)
+}
+
+// recommendVms selects the VirtualMachines for the given attribute and requirements from the fetched product info
+func (e *Engine) recommendVms(provider string, region string, pi *productInfo, attr string, values []float64, filters []vmFilter, req ClusterRecommendationReq) ([]VirtualMachine, error) {
 	log.Infof("recommending virtual machines for attribute: [%s]", attr)
 
-	vmsInRange, err := e.findVmsWithAttrValues(provider, region, req.Zones, attr, values)
+	if pi.zonesErr != nil {
+		log.Errorf("couldn't describe region: %s, provider: %s", region, provider)
+		return nil, pi.zonesErr
+	}
+	if pi.productsErr != nil {
+		log.Errorf("couldn't get product details. region: %s, provider: %s", region, provider)
+		return nil, pi.productsErr
+	}
+
+	vmsInRange, err := findVmsWithAttrValues(provider, pi.zones, pi.products, attr, values)
 	if err != nil {
 		return nil, err
 	}
 
+	if pi.storage != nil {
+		// the local storage is not part of the product details
+		for i := range vmsInRange {
+			vmsInRange[i].Storage = pi.storage[vmsInRange[i].Type]
+		}
+	}
+	if pi.podLimits != nil {
+		for i := range vmsInRange {
+			vmsInRange[i].MaxPods = pi.podLimits[vmsInRange[i].Type]
+		}
+	}
+	if pi.storageTypes != nil {
+		for i := range vmsInRange {
+			vmsInRange[i].StorageTypes = pi.storageTypes[vmsInRange[i].Type]
+		}
+	}
+	if pi.spotVariance != nil {
+		// the stability is scored on the list prices the variances are reported for
+		for i := range vmsInRange {
+			if variance, ok := pi.spotVariance[vmsInRange[i].Type]; ok {
+				vmsInRange[i].stability = stabilityScore(vmsInRange[i].AvgPrice, variance)
+			}
+		}
+	}
+	if pi.interruptions != nil {
+		for i := range vmsInRange {
+			if rate, ok := pi.interruptions[vmsInRange[i].Type]; ok {
+				vmsInRange[i].interruptionRate, vmsInRange[i].InterruptionTier = rate, interruptionTier(rate)
+			}
+		}
+	}
+
+	e.adjustPrices(provider, region, vmsInRange)
+
 	var filteredVms []VirtualMachine
 	for _, vm := range vmsInRange {
 		if e.filtersApply(vm, filters, req) {
@@ -381,27 +1535,12 @@ func (e *Engine) RecommendVms(provider string, region string, attr string, value
 	return filteredVms, nil
 }
 
-func (e *Engine) findVmsWithAttrValues(provider string, region string, zones []string, attr string, values []float64) ([]VirtualMachine, error) {
+func findVmsWithAttrValues(provider string, zones []string, allProducts []*models.ProductDetails, attr string, values []float64) ([]VirtualMachine, error) {
 	log.Infof("Getting instance types and on demand prices with %v %s", values, attr)
 	var (
 		vms []VirtualMachine
 	)
 
-	if zones == nil || len(zones) == 0 {
-		if z, err := e.piSource.GetRegion(provider, region); err == nil {
-			zones = z
-		} else {
-			log.Errorf("couldn't describe region: %s, provider: %s", region, provider)
-			return nil, err
-		}
-	}
-
-	allProducts, err := e.piSource.GetProductDetails(provider, region)
-	if err != nil {
-		log.Errorf("couldn't get product details. region: %s, provider: %s", region, provider)
-		return nil, err
-	}
-
 	for _, v := range values {
 		var filteredProducts []models.ProductDetails
 		for _, p := range allProducts {
@@ -421,19 +1560,7 @@ func (e *Engine) findVmsWithAttrValues(provider string, region string, zones []s
 		}
 
 		for _, p := range filteredProducts {
-			vm := VirtualMachine{
-				Type:           p.Type,
-				OnDemandPrice:  p.OnDemandPrice,
-				AvgPrice:       avg(p.SpotPrice, zones),
-				Cpus:           p.Cpus,
-				Mem:            p.Mem,
-				Gpus:           p.Gpus,
-				Burst:          p.Burst,
-				NetworkPerf:    p.NtwPerf,
-				NetworkPerfCat: p.NtwPerfCat,
-				CurrentGen:     p.CurrentGen,
-			}
-			vms = append(vms, vm)
+			vms = append(vms, newVirtualMachine(provider, zones, p))
 		}
 	}
 
@@ -441,6 +1568,32 @@ func (e *Engine) findVmsWithAttrValues(provider string, region string, zones []s
 	return vms, nil
 }
 
+// newVirtualMachine creates the vm of the product, the spot price is averaged over the zones
+func newVirtualMachine(provider string, zones []string, p models.ProductDetails) VirtualMachine {
+	return VirtualMachine{
+		Type:           p.Type,
+		OnDemandPrice:  p.OnDemandPrice,
+		AvgPrice:       avg(p.SpotPrice, zones),
+		Cpus:           p.Cpus,
+		Mem:            p.Mem,
+		Gpus:           p.Gpus,
+		Burst:          p.Burst || burstable(provider, p.Type),
+		NetworkPerf:    p.NtwPerf,
+		NetworkPerfCat: p.NtwPerfCat,
+		CurrentGen:     p.CurrentGen,
+		Architecture:   architecture(provider, p.Type),
+		Generation:     generation(provider, p.Type),
+	}
+}
+
+// coverage returns the percentage of the requested value covered by the recommended value, at most 100
+func coverage(recommended float64, requested float64) float64 {
+	if requested <= 0 {
+		return 100
+	}
+	return math.Min(recommended/requested*100, 100)
+}
+
 func avg(prices []*models.ZonePrice, recZones []string) float64 {
 	if len(prices) == 0 {
 		return 0.0
@@ -474,9 +1627,14 @@ func (e *Engine) RecommendAttrValues(provider string, region string, attr string
 
 	allValues, err := e.piSource.GetAttributeValues(provider, region, attr)
 	if err != nil {
-		return nil, err
+		return nil, upstreamError(err)
 	}
 
+	return selectAttrValues(allValues, attr, req)
+}
+
+// selectAttrValues selects the attribute values in the range allowed by the request
+func selectAttrValues(allValues []float64, attr string, req ClusterRecommendationReq) ([]float64, error) {
 	values, err := AttributeValues(allValues).SelectAttributeValues(req.minValuePerVm(attr), req.maxValuePerVm(attr))
 	if err != nil {
 		return nil, err
@@ -487,28 +1645,15 @@ func (e *Engine) RecommendAttrValues(provider string, region string, attr string
 
 // filtersForAttr returns the slice for
 func (e *Engine) filtersForAttr(attr string, provider string) ([]vmFilter, error) {
-	var
-	// generic filters - not depending on providers and attributes
-	filters []vmFilter = []vmFilter{e.includesFilter, e.excludesFilter}
-
-	// provider specific filters
-	switch provider {
-	case "ec2":
-		filters = append(filters, e.currentGenFilter, e.burstFilter, e.ntwPerformanceFilter)
-	case "gce":
-		filters = append(filters, e.ntwPerformanceFilter)
+	explained, err := e.explainedFilters(attr, provider)
+	if err != nil {
+		return nil, err
 	}
 
-	// attribute specific filters
-	switch attr {
-	case Cpu:
-		filters = append(filters, e.minMemRatioFilter)
-	case Memory:
-		filters = append(filters, e.minCpuRatioFilter)
-	default:
-		return nil, fmt.Errorf("unsupported attribute: [%s]", attr)
+	filters := make([]vmFilter, len(explained))
+	for i, f := range explained {
+		filters[i] = f.apply
 	}
-
 	return filters, nil
 }
 
@@ -528,27 +1673,45 @@ func (e *Engine) sortByAttrValue(attr string, vms []VirtualMachine) {
 // RecommendNodePools finds the slice of NodePools that may participate in the recommendation process
 func (e *Engine) RecommendNodePools(attr string, vms []VirtualMachine, values []float64, req ClusterRecommendationReq) ([]NodePool, error) {
 
+	if req.NodeCount > 0 {
+		return e.fixedCountNodePools(vms, req)
+	}
+	if req.SameSize {
+		return e.sameSizeNodePools(attr, vms, req)
+	}
+
 	var nps []NodePool
 
 	// find cheapest onDemand instance from the list - based on price per attribute
+	onDemandPricePerAttr := req.objectivePrice(vms, func(vm VirtualMachine) float64 {
+		return memRatioPrice(vm, req.MemRatio, vm.OnDemandPrice/vm.getAttrValue(attr))
+	})
 	selectedOnDemand := vms[0]
 	for _, vm := range vms {
-		if vm.OnDemandPrice/vm.getAttrValue(attr) < selectedOnDemand.OnDemandPrice/selectedOnDemand.getAttrValue(attr) {
+		if preferred(vm, selectedOnDemand, onDemandPricePerAttr) {
 			selectedOnDemand = vm
 		}
 	}
 
 	log.Debugf("requested sum for attribute [%s]: [%f]", attr, req.sum(attr))
 
-	var sumOnDemandValue = req.sum(attr) * float64(req.OnDemandPct) / 100
+	var sumOnDemandValue = req.sum(attr) * float64(req.onDemandPct()) / 100
 	var sumSpotValue = req.sum(attr) - sumOnDemandValue
 
+	// the requested gpus, storage and pods are split the same way as the attribute
+	var sumOnDemandGpus = req.SumGpu * float64(req.onDemandPct()) / 100
+	var sumSpotGpus = req.SumGpu - sumOnDemandGpus
+	var sumOnDemandStorage = req.SumStorage * float64(req.onDemandPct()) / 100
+	var sumSpotStorage = req.SumStorage - sumOnDemandStorage
+	var sumOnDemandPods = float64(req.SumPods) * float64(req.onDemandPct()) / 100
+	var sumSpotPods = float64(req.SumPods) - sumOnDemandPods
+
 	log.Debugf("on demand sum value for attr [%s]: [%f]", attr, sumOnDemandValue)
 	log.Debugf("spot sum value for attr [%s]: [%f]", attr, sumSpotValue)
 
 	// create and append on-demand pool
 	onDemandPool := NodePool{
-		SumNodes: int(math.Ceil(sumOnDemandValue / selectedOnDemand.getAttrValue(attr))),
+		SumNodes: nodeCount(sumOnDemandValue, sumOnDemandGpus, sumOnDemandStorage, sumOnDemandPods, attr, selectedOnDemand),
 		VmClass:  regular,
 		VmType:   selectedOnDemand,
 	}
@@ -556,19 +1719,37 @@ func (e *Engine) RecommendNodePools(attr string, vms []VirtualMachine, values []
 	nps = append(nps, onDemandPool)
 
 	// if spot price pools requested
-	if req.OnDemandPct < 100 {
+	if req.onDemandPct() < 100 {
 		// retain only the nodes that are available as spot instances
 		vms = e.filterSpots(vms)
 		if len(vms) == 0 {
 			return nil, errors.New("no vms suitable for spot pools")
 		}
+		if vms = req.spotWorthy(vms); len(vms) == 0 {
+			log.Debugf("no instance type is worth a spot pool for the request, recommending on-demand nodes only")
+			nps[0].SumNodes = nodeCount(req.sum(attr), req.SumGpu, req.SumStorage, float64(req.SumPods), attr, selectedOnDemand)
+			return nps, nil
+		}
 	}
 
 	// vms are sorted by attribute value
 	e.sortByAttrValue(attr, vms)
+	spotPricePerAttr := func(vm VirtualMachine) float64 {
+		return memRatioPrice(vm, req.MemRatio, vm.AvgPrice/vm.getAttrValue(attr))
+	}
+	if req.MemRatio > 0 {
+		// the ones close to the preferred ratio come before slightly cheaper ones
+		sort.SliceStable(vms, func(i, j int) bool {
+			return spotPricePerAttr(vms[i]) < spotPricePerAttr(vms[j])
+		})
+	}
+	req.sortByObjective(vms, spotPricePerAttr)
+	if req.stableSpot() {
+		sortByStability(vms, spotPricePerAttr)
+	}
 
-	// the "magic" number of machines for diversifying the types
-	N := int(math.Min(float64(findN(avgNodeCount(values, req.sum(attr)))), float64(len(vms))))
+	// the "magic" number of machines for diversifying the types, at least the number of node pools requested
+	N := int(math.Min(math.Max(float64(findN(avgNodeCount(values, req.sum(attr)))), float64(req.MinNodePools)), float64(len(vms))))
 
 	// the second "magic" number for diversifying the layout
 	M := int(math.Min(math.Ceil(float64(N)*1.5), float64(len(vms))))
@@ -581,9 +1762,10 @@ func (e *Engine) RecommendNodePools(attr string, vms []VirtualMachine, values []
 	// create spot nodepools - one for the first M vm-s
 	for _, vm := range recommendedVms {
 		nps = append(nps, NodePool{
-			SumNodes: 0,
-			VmClass:  spot,
-			VmType:   vm,
+			SumNodes:       0,
+			VmClass:        spot,
+			VmType:         vm,
+			StabilityScore: vm.stability,
 		})
 	}
 	log.Debugf("totally created [%d] regular and spot price node pools", len(nps))
@@ -591,12 +1773,18 @@ func (e *Engine) RecommendNodePools(attr string, vms []VirtualMachine, values []
 	// fill up instances in spot pools
 	i := 0
 	var sumValueInPools float64
-	for sumValueInPools < sumSpotValue {
+	var sumGpusInPools float64
+	var sumStorageInPools float64
+	var sumPodsInPools float64
+	for sumValueInPools < sumSpotValue || sumGpusInPools < sumSpotGpus || sumStorageInPools < sumSpotStorage || sumPodsInPools < sumSpotPods {
 		nodePoolIdx := i%N + 1
 		if nodePoolIdx == 1 {
 			// always add a new instance to the cheapest option and move on
 			nps[nodePoolIdx].SumNodes += 1
 			sumValueInPools += nps[nodePoolIdx].VmType.getAttrValue(attr)
+			sumGpusInPools += nps[nodePoolIdx].VmType.Gpus
+			sumStorageInPools += nps[nodePoolIdx].VmType.Storage
+			sumPodsInPools += nps[nodePoolIdx].VmType.getAttrValue(Pods)
 			log.Debugf("adding vm to the [%d]th node pool sum value in pools: [%f]", nodePoolIdx, sumValueInPools)
 			i++
 		} else if nps[nodePoolIdx].getNextSum(attr) > nps[1].getSum(attr) {
@@ -607,13 +1795,367 @@ func (e *Engine) RecommendNodePools(attr string, vms []VirtualMachine, values []
 			// otherwise add a new one, but do not move on to the next one
 			nps[nodePoolIdx].SumNodes += 1
 			sumValueInPools += nps[nodePoolIdx].VmType.getAttrValue(attr)
+			sumGpusInPools += nps[nodePoolIdx].VmType.Gpus
+			sumStorageInPools += nps[nodePoolIdx].VmType.Storage
+			sumPodsInPools += nps[nodePoolIdx].VmType.getAttrValue(Pods)
 			log.Debugf("adding vm to the [%d]th node pool sum value in pools: [%f]", nodePoolIdx, sumValueInPools)
 		}
 	}
 
+	if req.onDemandPct() < 100 {
+		diversify(nps, req.MinNodePools)
+	}
+
+	if req.MaxNodePools > 0 {
+		if err := collapse(nps, attr, req.MaxNodePools); err != nil {
+			return nil, err
+		}
+	}
+
+	ensureOnDemandNodes(nps, attr, req)
+
+	return nps, nil
+}
+
+// collapse moves the nodes of the smallest spot pools (all but the first in the slice) to the largest one until the
+// node pools have at most the given number of distinct instance types; the spot nodes are moved to the instance type of
+// the on-demand pool as a last resort
+func collapse(nps []NodePool, attr string, maxNodePools int) error {
+	for {
+		used := distinctTypes(nps)
+		if used <= maxNodePools {
+			return nil
+		}
+
+		// the largest spot pool is kept, the smallest one of another instance type is moved to it
+		target, source := -1, -1
+		for i := 1; i < len(nps); i++ {
+			if nps[i].SumNodes > 0 && (target < 0 || nps[i].getSum(attr) > nps[target].getSum(attr)) {
+				target = i
+			}
+		}
+		for i := 1; i < len(nps); i++ {
+			if nps[i].SumNodes > 0 && i != target && nps[i].VmType.Type != nps[target].VmType.Type &&
+				(source < 0 || nps[i].getSum(attr) < nps[source].getSum(attr)) {
+				source = i
+			}
+		}
+
+		if source < 0 {
+			// only the on-demand and a single spot instance type are left
+			if target < 0 || nps[0].VmType.AvgPrice == 0 {
+				return NewError(NoViableInstances, "could not collapse the node pools into [%d] instance types", maxNodePools)
+			}
+			log.Debugf("moving the nodes of the [%s] spot pool to the on-demand instance type", nps[target].VmType.Type)
+			np := nps[target]
+			nps[target] = NodePool{
+				SumNodes:       nodeCount(np.getSum(attr), np.getSum(Gpu), np.getSum(Storage), np.getSum(Pods), attr, nps[0].VmType),
+				VmClass:        spot,
+				VmType:         nps[0].VmType,
+				StabilityScore: nps[0].VmType.stability,
+			}
+			continue
+		}
+
+		log.Debugf("moving the nodes of the [%s] spot pool to the [%s] spot pool", nps[source].VmType.Type, nps[target].VmType.Type)
+		nps[target].SumNodes += nodeCount(nps[source].getSum(attr), nps[source].getSum(Gpu), nps[source].getSum(Storage), nps[source].getSum(Pods), attr, nps[target].VmType)
+		nps[source].SumNodes = 0
+	}
+}
+
+// diversify adds a node to the empty spot pools (all but the first in the slice) until the node pools have at least
+// the given number of distinct instance types
+func diversify(nps []NodePool, minNodePools int) {
+	for i := 1; i < len(nps); i++ {
+		if used := distinctTypes(nps); used >= minNodePools {
+			return
+		}
+		if nps[i].SumNodes > 0 {
+			continue
+		}
+		var typeUsed bool
+		for _, np := range nps {
+			if np.SumNodes > 0 && np.VmType.Type == nps[i].VmType.Type {
+				typeUsed = true
+			}
+		}
+		if !typeUsed {
+			nps[i].SumNodes = 1
+		}
+	}
+}
+
+// spotPools returns the spot node pools, dropping the empty regular pool of spot-only layouts
+func spotPools(nps []NodePool) []NodePool {
+	var spots []NodePool
+	for _, np := range nps {
+		if np.VmClass == spot || np.SumNodes > 0 {
+			spots = append(spots, np)
+		}
+	}
+	return spots
+}
+
+// distinctTypes returns the number of distinct instance types in the node pools with nodes
+func distinctTypes(nps []NodePool) int {
+	used := make(map[string]bool)
+	for _, np := range nps {
+		if np.SumNodes > 0 {
+			used[np.VmType.Type] = true
+		}
+	}
+	return len(used)
+}
+
+// distinctVmTypes returns the number of distinct instance types of the vms
+func distinctVmTypes(vms []VirtualMachine) int {
+	types := make(map[string]bool)
+	for _, vm := range vms {
+		types[vm.Type] = true
+	}
+	return len(types)
+}
+
+// preferred returns true if the vm is preferred over the selected one, the one of the lower price
+func preferred(vm VirtualMachine, selected VirtualMachine, price func(VirtualMachine) float64) bool {
+	if p, sp := price(vm), price(selected); p != sp {
+		return p < sp
+	}
+	// the instance types of the same price are ranked by their size and names so that the same one is picked every time
+	return typeLess(vm, selected)
+}
+
+// memRatioDistance measures how far the memory per cpu of the vm is from the given ratio, the same for ratios being
+// the same times smaller or larger
+func memRatioDistance(vm VirtualMachine, memRatio float64) float64 {
+	return math.Abs(math.Log(vm.Mem / vm.Cpus / memRatio))
+}
+
+// memRatioPrice biases the price the vm is ranked by with its distance from the preferred memory ratio, the price is
+// kept if there's no preferred ratio: a vm of twice or half the ratio is ranked as if it was memRatioWeight*ln(2)
+// (about 35%) pricier, so a vm close to the ratio is preferred over a slightly cheaper one but not over a much cheaper one
+func memRatioPrice(vm VirtualMachine, memRatio float64, price float64) float64 {
+	if memRatio <= 0 {
+		return price
+	}
+	return price * (1 + memRatioWeight*memRatioDistance(vm, memRatio))
+}
+
+// sameSizeNodePools recommends an on-demand and a spot node pool of a single instance type, the one with the cheapest
+// price per attribute for the requested percentage of on-demand nodes
+func (e *Engine) sameSizeNodePools(attr string, vms []VirtualMachine, req ClusterRecommendationReq) ([]NodePool, error) {
+	if req.onDemandPct() < 100 {
+		// the spot pool has the same instance type
+		vms = e.filterSpots(vms)
+		if len(vms) == 0 {
+			return nil, errors.New("no vms suitable for spot pools")
+		}
+	}
+
+	var onDemandRatio = float64(req.onDemandPct()) / 100
+	pricePerAttr := func(vm VirtualMachine) float64 {
+		// the instance types are scored at their buffered spot price
+		spotPrice := req.bufferedSpotPrice(vm)
+		if len(req.spotWorthy([]VirtualMachine{vm})) == 0 {
+			spotPrice = vm.OnDemandPrice
+		}
+		return memRatioPrice(vm, req.MemRatio, (onDemandRatio*vm.OnDemandPrice+(1-onDemandRatio)*spotPrice)/vm.getAttrValue(attr))
+	}
+	pricePerAttr = req.objectivePrice(vms, pricePerAttr)
+
+	selected := vms[0]
+	for _, vm := range vms[1:] {
+		if preferred(vm, selected, pricePerAttr) {
+			selected = vm
+		}
+	}
+	log.Debugf("selected instance type for same size node pools: [%s]", selected.Type)
+
+	if req.onDemandPct() < 100 && len(req.spotWorthy([]VirtualMachine{selected})) == 0 {
+		log.Debugf("the buffered spot price of [%s] exceeds the on-demand price, recommending on-demand nodes only", selected.Type)
+		onDemandRatio = 1
+	}
+	var sumOnDemandValue = req.sum(attr) * onDemandRatio
+	var sumOnDemandGpus = req.SumGpu * onDemandRatio
+	var sumOnDemandStorage = req.SumStorage * onDemandRatio
+	var sumOnDemandPods = float64(req.SumPods) * onDemandRatio
+
+	nps := []NodePool{{
+		SumNodes: nodeCount(sumOnDemandValue, sumOnDemandGpus, sumOnDemandStorage, sumOnDemandPods, attr, selected),
+		VmClass:  regular,
+		VmType:   selected,
+	}}
+
+	if onDemandRatio < 1 {
+		nps = append(nps, NodePool{
+			SumNodes:       nodeCount(req.sum(attr)-sumOnDemandValue, req.SumGpu-sumOnDemandGpus, req.SumStorage-sumOnDemandStorage, float64(req.SumPods)-sumOnDemandPods, attr, selected),
+			VmClass:        spot,
+			VmType:         selected,
+			StabilityScore: selected.stability,
+		})
+	}
+
+	ensureOnDemandNodes(nps, attr, req)
+
+	return nps, nil
+}
+
+// fixedCountNodePools recommends the requested number of nodes of the cheapest instance type providing the requested
+// resources on that many nodes, split into an on-demand and a spot pool of the same instance type
+func (e *Engine) fixedCountNodePools(vms []VirtualMachine, req ClusterRecommendationReq) ([]NodePool, error) {
+	if req.onDemandPct() < 100 {
+		vms = e.filterSpots(vms)
+		if len(vms) == 0 {
+			return nil, errors.New("no vms suitable for spot pools")
+		}
+	}
+
+	var onDemandRatio = float64(req.onDemandPct()) / 100
+	price := req.objectivePrice(vms, func(vm VirtualMachine) float64 {
+		return onDemandRatio*vm.OnDemandPrice + (1-onDemandRatio)*req.bufferedSpotPrice(vm)
+	})
+	nodes := float64(req.NodeCount)
+	var selected *VirtualMachine
+	for i, vm := range vms {
+		if vm.Cpus*nodes < req.SumCpu || vm.Mem*nodes < req.SumMem || vm.Gpus*nodes < req.SumGpu || vm.Storage*nodes < req.SumStorage ||
+			float64(vm.MaxPods)*nodes < float64(req.SumPods) {
+			continue
+		}
+		if selected == nil || price(vm) < price(*selected) || (price(vm) == price(*selected) && typeLess(vm, *selected)) {
+			selected = &vms[i]
+		}
+	}
+	if selected == nil {
+		return nil, NewError(NoViableInstances, "no instance type can provide [%v] cpus and [%v] GB memory on [%d] nodes",
+			req.SumCpu, req.SumMem, req.NodeCount)
+	}
+	log.Debugf("selected instance type for [%d] nodes: [%s]", req.NodeCount, selected.Type)
+
+	onDemandNodes := minOnDemandNodes(req.NodeCount, req.onDemandPct())
+	if len(req.spotWorthy([]VirtualMachine{*selected})) == 0 {
+		log.Debugf("the buffered spot price of [%s] exceeds the on-demand price, recommending on-demand nodes only", selected.Type)
+		onDemandNodes = req.NodeCount
+	}
+	nps := []NodePool{{
+		SumNodes: onDemandNodes,
+		VmClass:  regular,
+		VmType:   *selected,
+	}}
+	if onDemandNodes < req.NodeCount {
+		nps = append(nps, NodePool{
+			SumNodes:       req.NodeCount - onDemandNodes,
+			VmClass:        spot,
+			VmType:         *selected,
+			StabilityScore: selected.stability,
+		})
+	}
 	return nps, nil
 }
 
+// ensureOnDemandNodes converts spot nodes to on-demand ones (the pool of the first in the slice) until at least the
+// requested percentage (rounded up) of all the nodes are on-demand; the number of nodes is kept unless the on-demand
+// instance type is smaller than the converted ones: on-demand nodes are then added to restore the capacity, as long as
+// MaxNodes allows
+func ensureOnDemandNodes(nps []NodePool, attr string, req ClusterRecommendationReq) {
+	onDemandPct := req.onDemandPct()
+	if onDemandPct == 0 || len(nps) == 0 {
+		return
+	}
+	var sumNodes int
+	for _, np := range nps {
+		sumNodes += np.SumNodes
+	}
+	onDemand := nps[0].VmType
+	before := layoutCapacity(nps, attr)
+	for nps[0].SumNodes < minOnDemandNodes(sumNodes, onDemandPct) {
+		converted := -1
+		for i := 1; i < len(nps); i++ {
+			if nps[i].SumNodes == 0 {
+				continue
+			}
+			if converted < 0 || convertsBefore(nps[i].VmType, nps[converted].VmType, onDemand, attr) {
+				converted = i
+			}
+		}
+		if converted < 0 {
+			break
+		}
+		nps[converted].SumNodes--
+		nps[0].SumNodes++
+	}
+
+	// the requested capacity the layout provided before the conversion is restored
+	wanted := [4]float64{req.sum(attr), req.SumGpu, req.SumStorage, float64(req.SumPods)}
+	perNode := [4]float64{onDemand.getAttrValue(attr), onDemand.Gpus, onDemand.Storage, onDemand.getAttrValue(Pods)}
+	for req.MaxNodes == 0 || sumNodes < req.MaxNodes {
+		after, restores := layoutCapacity(nps, attr), false
+		for k := range after {
+			if after[k] < math.Min(before[k], wanted[k]) && perNode[k] > 0 {
+				restores = true
+			}
+		}
+		if !restores {
+			break
+		}
+		nps[0].SumNodes++
+		sumNodes++
+	}
+	log.Debugf("on-demand nodes: [%d], total nodes: [%d]", nps[0].SumNodes, sumNodes)
+}
+
+// convertsBefore reports whether a spot node of the instance type is converted to an on-demand node before the one of
+// the other: the ones fitting on the on-demand instance type first, the smaller ones among the ones that don't fit,
+// then the ones with the higher spot price, as their conversion adds the least to the price
+func convertsBefore(vm, other, onDemand VirtualMachine, attr string) bool {
+	fits, otherFits := vm.getAttrValue(attr) <= onDemand.getAttrValue(attr), other.getAttrValue(attr) <= onDemand.getAttrValue(attr)
+	if fits != otherFits {
+		return fits
+	}
+	if !fits && vm.getAttrValue(attr) != other.getAttrValue(attr) {
+		return vm.getAttrValue(attr) < other.getAttrValue(attr)
+	}
+	return vm.AvgPrice > other.AvgPrice
+}
+
+// layoutCapacity sums the attribute, the gpus, the storage and the pods of the node pools
+func layoutCapacity(nps []NodePool, attr string) [4]float64 {
+	var capacity [4]float64
+	for _, np := range nps {
+		nodes := float64(np.SumNodes)
+		capacity[0] += np.getSum(attr)
+		capacity[1] += nodes * np.VmType.Gpus
+		capacity[2] += nodes * np.VmType.Storage
+		capacity[3] += nodes * np.VmType.getAttrValue(Pods)
+	}
+	return capacity
+}
+
+// minOnDemandNodes calculates the minimum number of on-demand nodes out of the total for the given percentage
+func minOnDemandNodes(sumNodes int, onDemandPct int) int {
+	return int(math.Ceil(float64(sumNodes) * float64(onDemandPct) / 100))
+}
+
+// nodeCount calculates the number of vms needed to cover the attribute value, the gpus, the storage and the pods
+func nodeCount(sumValue float64, sumGpus float64, sumStorage float64, sumPods float64, attr string, vm VirtualMachine) int {
+	count := int(math.Ceil(sumValue / vm.getAttrValue(attr)))
+	if sumGpus > 0 && vm.Gpus > 0 {
+		if gpuCount := int(math.Ceil(sumGpus / vm.Gpus)); gpuCount > count {
+			count = gpuCount
+		}
+	}
+	if sumStorage > 0 && vm.Storage > 0 {
+		if storageCount := int(math.Ceil(sumStorage / vm.Storage)); storageCount > count {
+			count = storageCount
+		}
+	}
+	if sumPods > 0 && vm.MaxPods > 0 {
+		if podCount := int(math.Ceil(sumPods / float64(vm.MaxPods))); podCount > count {
+			count = podCount
+		}
+	}
+	return count
+}
+
 // maxValuePerVm calculates the maximum value per node for the given attribute
 func (req *ClusterRecommendationReq) maxValuePerVm(attr string) float64 {
 	switch attr {
@@ -669,12 +2211,17 @@ func (n *NodePool) addNode(attr string) float64 {
 	return n.getSum(attr) + n.VmType.getAttrValue(attr)
 }
 
-// poolPrice calculates the price of the pool
+// poolPrice calculates the price of the pool, the reserved regular nodes are priced at the reserved price and the
+// others at the sustained use price if it's set
 func (n *NodePool) poolPrice() float64 {
 	var sum = float64(0)
 	switch n.VmClass {
 	case regular:
-		sum = float64(n.SumNodes) * n.VmType.OnDemandPrice
+		price := n.VmType.OnDemandPrice
+		if n.VmType.SustainedUsePrice > 0 {
+			price = n.VmType.SustainedUsePrice
+		}
+		sum = float64(n.SumNodes-n.ReservedNodes)*price + float64(n.ReservedNodes)*n.VmType.ReservedPrice
 	case spot:
 		sum = float64(n.SumNodes) * n.VmType.AvgPrice
 	}
@@ -522,7 +522,7 @@ func TestEngine_ntwPerformanceFilter(t *testing.T) {
 			},
 		},
 		{
-			name:   "vm doesn't pass the network performance filter",
+			name:   "vm passes the network performance filter - better category than requested",
 			engine: Engine{},
 			req: ClusterRecommendationReq{
 				NetworkPerf: &NTW_LOW,
@@ -531,6 +531,33 @@ func TestEngine_ntwPerformanceFilter(t *testing.T) {
 				NetworkPerfCat: NTW_HIGH,
 				Type:           "instance type",
 			},
+			check: func(passed bool) {
+				assert.True(t, passed, "vm should pass the check")
+			},
+		},
+		{
+			name:   "vm doesn't pass the network performance filter",
+			engine: Engine{},
+			req: ClusterRecommendationReq{
+				NetworkPerf: &NTW_HIGH,
+			},
+			vm: VirtualMachine{
+				NetworkPerfCat: NTW_LOW,
+				Type:           "instance type",
+			},
+			check: func(passed bool) {
+				assert.False(t, passed, "vm should not pass the check")
+			},
+		},
+		{
+			name:   "vm doesn't pass the network performance filter - unknown category",
+			engine: Engine{},
+			req: ClusterRecommendationReq{
+				NetworkPerf: &NTW_LOW,
+			},
+			vm: VirtualMachine{
+				Type: "instance type",
+			},
 			check: func(passed bool) {
 				assert.False(t, passed, "vm should not pass the check")
 			},
@@ -560,6 +587,10 @@ func boolPointer(b bool) *bool {
 	return &b
 }
 
+func stringPointer(s string) *string {
+	return &s
+}
+
 func TestEngine_CurrGenFilter(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -657,3 +688,184 @@ func TestEngine_CurrGenFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestEngine_gpuFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		engine Engine
+		req    ClusterRecommendationReq
+		vm     VirtualMachine
+		check  func(filterApplies bool)
+	}{
+		{
+			name:   "gpu filter applies - no gpus requested",
+			engine: Engine{},
+			req:    ClusterRecommendationReq{},
+			vm:     VirtualMachine{Gpus: 0},
+			check: func(filterApplies bool) {
+				assert.Equal(t, true, filterApplies, "vm should pass the gpu filter")
+			},
+		},
+		{
+			name:   "gpu filter applies - gpus requested, vm with gpus",
+			engine: Engine{},
+			req:    ClusterRecommendationReq{SumGpu: 2},
+			vm:     VirtualMachine{Gpus: 1},
+			check: func(filterApplies bool) {
+				assert.Equal(t, true, filterApplies, "vm should pass the gpu filter")
+			},
+		},
+		{
+			name:   "gpu filter doesn't apply - gpus requested, vm without gpus",
+			engine: Engine{},
+			req:    ClusterRecommendationReq{SumGpu: 2},
+			vm:     VirtualMachine{Gpus: 0},
+			check: func(filterApplies bool) {
+				assert.Equal(t, false, filterApplies, "vm should not pass the gpu filter")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(test.engine.gpuFilter(test.vm, test.req))
+		})
+	}
+}
+
+func TestEngine_architectureFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		engine Engine
+		req    ClusterRecommendationReq
+		vm     VirtualMachine
+		check  func(filterApplies bool)
+	}{
+		{
+			name:   "architecture filter applies - no architecture requested",
+			engine: Engine{},
+			req:    ClusterRecommendationReq{},
+			vm:     VirtualMachine{Architecture: Arm64},
+			check: func(filterApplies bool) {
+				assert.Equal(t, true, filterApplies, "vm should pass the architecture filter")
+			},
+		},
+		{
+			name:   "architecture filter applies - architecture matches",
+			engine: Engine{},
+			req:    ClusterRecommendationReq{Architecture: Arm64},
+			vm:     VirtualMachine{Architecture: Arm64},
+			check: func(filterApplies bool) {
+				assert.Equal(t, true, filterApplies, "vm should pass the architecture filter")
+			},
+		},
+		{
+			name:   "architecture filter doesn't apply - architecture doesn't match",
+			engine: Engine{},
+			req:    ClusterRecommendationReq{Architecture: Arm64},
+			vm:     VirtualMachine{Architecture: Amd64},
+			check: func(filterApplies bool) {
+				assert.Equal(t, false, filterApplies, "vm should not pass the architecture filter")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(test.engine.architectureFilter(test.vm, test.req))
+		})
+	}
+}
+
+func TestEngine_minGenFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		engine Engine
+		req    ClusterRecommendationReq
+		vm     VirtualMachine
+		check  func(filterApplies bool)
+	}{
+		{
+			name:   "min generation filter applies - no minimum generation requested",
+			engine: Engine{},
+			req:    ClusterRecommendationReq{},
+			vm:     VirtualMachine{Type: "m4.large", Generation: 4},
+			check: func(filterApplies bool) {
+				assert.Equal(t, true, filterApplies, "vm should pass the min generation filter")
+			},
+		},
+		{
+			name:   "min generation filter applies - generation is the minimum",
+			engine: Engine{},
+			req:    ClusterRecommendationReq{MinGen: 5},
+			vm:     VirtualMachine{Type: "m5.large", Generation: 5},
+			check: func(filterApplies bool) {
+				assert.Equal(t, true, filterApplies, "vm should pass the min generation filter")
+			},
+		},
+		{
+			name:   "min generation filter doesn't apply - older generation",
+			engine: Engine{},
+			req:    ClusterRecommendationReq{MinGen: 5},
+			vm:     VirtualMachine{Type: "m4.large", Generation: 4},
+			check: func(filterApplies bool) {
+				assert.Equal(t, false, filterApplies, "vm should not pass the min generation filter")
+			},
+		},
+		{
+			name:   "min generation filter doesn't apply - unknown generation",
+			engine: Engine{},
+			req:    ClusterRecommendationReq{MinGen: 5},
+			vm:     VirtualMachine{Type: "u-6tb1.metal"},
+			check: func(filterApplies bool) {
+				assert.Equal(t, false, filterApplies, "vm should not pass the min generation filter")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(test.engine.minGenFilter(test.vm, test.req))
+		})
+	}
+}
+
+func TestEngine_storageTypeFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		engine Engine
+		req    ClusterRecommendationReq
+		vm     VirtualMachine
+		check  func(filterApplies bool)
+	}{
+		{
+			name:   "storage type filter applies - storage type not requested",
+			engine: Engine{},
+			req:    ClusterRecommendationReq{},
+			vm:     VirtualMachine{},
+			check: func(filterApplies bool) {
+				assert.Equal(t, true, filterApplies, "vm should pass the storage type filter")
+			},
+		},
+		{
+			name:   "storage type filter applies - requested storage type supported",
+			engine: Engine{},
+			req:    ClusterRecommendationReq{StorageType: "nvme"},
+			vm:     VirtualMachine{StorageTypes: []string{"ebs-optimized", "nvme"}},
+			check: func(filterApplies bool) {
+				assert.Equal(t, true, filterApplies, "vm should pass the storage type filter")
+			},
+		},
+		{
+			name:   "storage type filter doesn't apply - requested storage type not supported",
+			engine: Engine{},
+			req:    ClusterRecommendationReq{StorageType: "nvme"},
+			vm:     VirtualMachine{StorageTypes: []string{"ebs-optimized"}},
+			check: func(filterApplies bool) {
+				assert.Equal(t, false, filterApplies, "vm should not pass the storage type filter")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(test.engine.storageTypeFilter(test.vm, test.req))
+		})
+	}
+}
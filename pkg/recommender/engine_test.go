@@ -15,8 +15,12 @@
 package recommender
 
 import (
+	"encoding/json"
 	"errors"
+	"math"
+	"math/rand"
 	"testing"
+	"time"
 
 	"fmt"
 	"github.com/banzaicloud/productinfo/pkg/productinfo-client/models"
@@ -32,13 +36,33 @@ const (
 	DescribeRegionError = "could not describe region"
 	ProductDetailsError = "could not get product details"
 	AvgPriceNil         = "average price is nil"
+	ProvidersError      = "could not get providers"
+	RegionsError        = "could not get regions"
+	NoZones             = "no zones in the region"
+	GpuVms              = "vms with gpus in the region"
+	NetworkVms          = "vms with network performance categories in the region"
+	Ec2Vms              = "vms of multiple ec2 generations in the region"
 )
 
+// onDemand returns the percentage of regular nodes to set in the requests
+func onDemand(pct int) *int {
+	return &pct
+}
+
 type dummyProductInfoSource struct {
 	// test case id to drive the behaviour
 	TcId string
 }
 
+func (piCli *dummyProductInfoSource) GetProviders() ([]string, error) {
+	switch piCli.TcId {
+	case ProvidersError:
+		return nil, errors.New(ProvidersError)
+	default:
+		return []string{"ec2", "gce", "dummy"}, nil
+	}
+}
+
 func (piCli *dummyProductInfoSource) GetAttributeValues(provider string, region string, attr string) ([]float64, error) {
 	switch piCli.TcId {
 	case OutOfLimits:
@@ -55,10 +79,24 @@ func (piCli *dummyProductInfoSource) GetAttributeValues(provider string, region
 	return []float64{15, 16, 17}, nil
 }
 
+func (piCli *dummyProductInfoSource) GetRegions(provider string) ([]*models.RegionResp, error) {
+	switch piCli.TcId {
+	case RegionsError:
+		return nil, errors.New(RegionsError)
+	default:
+		return []*models.RegionResp{
+			{ID: "dummyRegion1", Name: "Dummy Region 1"},
+			{ID: "dummyRegion2", Name: "Dummy Region 2"},
+		}, nil
+	}
+}
+
 func (piCli *dummyProductInfoSource) GetRegion(provider string, region string) ([]string, error) {
 	switch piCli.TcId {
 	case DescribeRegionError:
 		return nil, errors.New(DescribeRegionError)
+	case NoZones:
+		return nil, nil
 	default:
 		return []string{"dummyZone1", "dummyZone2", "dummyZone3"}, nil
 	}
@@ -79,6 +117,92 @@ func (piCli *dummyProductInfoSource) GetProductDetails(provider string, region s
 				SpotPrice:     []*models.ZonePrice{{Price: 0.171, Zone: "invalidZone"}},
 			},
 		}, nil
+	case GpuVms:
+		return []*models.ProductDetails{
+			{
+				Type:          "type-10",
+				CurrentGen:    true,
+				OnDemandPrice: 0.68,
+				Cpus:          16,
+				Mem:           32,
+				SpotPrice:     []*models.ZonePrice{{Price: 0.171, Zone: "dummyZone2"}},
+			},
+			{
+				Type:          "gpu-type-1",
+				CurrentGen:    true,
+				OnDemandPrice: 0.9,
+				Cpus:          16,
+				Mem:           64,
+				Gpus:          1,
+				SpotPrice:     []*models.ZonePrice{{Price: 0.27, Zone: "dummyZone1"}},
+			},
+			{
+				Type:          "gpu-type-2",
+				CurrentGen:    true,
+				OnDemandPrice: 3.06,
+				Cpus:          16,
+				Mem:           128,
+				Gpus:          4,
+				SpotPrice:     []*models.ZonePrice{{Price: 0.918, Zone: "dummyZone2"}},
+			},
+		}, nil
+	case Ec2Vms:
+		return []*models.ProductDetails{
+			{
+				Type:          "m4.4xlarge",
+				CurrentGen:    true,
+				OnDemandPrice: 0.7,
+				Cpus:          16,
+				Mem:           64,
+				SpotPrice:     []*models.ZonePrice{{Price: 0.15, Zone: "dummyZone1"}},
+			},
+			{
+				Type:          "m5.4xlarge",
+				CurrentGen:    true,
+				OnDemandPrice: 0.768,
+				Cpus:          16,
+				Mem:           64,
+				SpotPrice:     []*models.ZonePrice{{Price: 0.2, Zone: "dummyZone1"}},
+			},
+			{
+				Type:          "m6i.4xlarge",
+				CurrentGen:    true,
+				OnDemandPrice: 0.768,
+				Cpus:          16,
+				Mem:           64,
+				SpotPrice:     []*models.ZonePrice{{Price: 0.25, Zone: "dummyZone1"}},
+			},
+		}, nil
+	case NetworkVms:
+		return []*models.ProductDetails{
+			{
+				Type:          "ntw-low",
+				CurrentGen:    true,
+				OnDemandPrice: 0.5,
+				Cpus:          16,
+				Mem:           32,
+				NtwPerfCat:    "low",
+				SpotPrice:     []*models.ZonePrice{{Price: 0.15, Zone: "dummyZone1"}},
+			},
+			{
+				Type:          "ntw-medium",
+				CurrentGen:    true,
+				OnDemandPrice: 0.68,
+				Cpus:          16,
+				Mem:           32,
+				NtwPerfCat:    "medium",
+				SpotPrice:     []*models.ZonePrice{{Price: 0.2, Zone: "dummyZone1"}},
+			},
+			{
+				Type:          "ntw-high",
+				CurrentGen:    true,
+				OnDemandPrice: 0.9,
+				Cpus:          16,
+				Mem:           32,
+				NtwPerfCat:    "high",
+				SpotPrice:     []*models.ZonePrice{{Price: 0.27, Zone: "dummyZone1"}},
+			},
+		}, nil
 	default:
 		return []*models.ProductDetails{
 			{
@@ -401,7 +525,7 @@ func TestEngine_RecommendCluster(t *testing.T) {
 				SumCpu:   16,
 			},
 			check: func(resp *ClusterRecommendationResp, err error) {
-				assert.Equal(t, 3, len(resp.NodePools))
+				assert.Equal(t, 2, len(resp.NodePools))
 				assert.Nil(t, err, "the error should be nil")
 				assert.Equal(t, float64(64), resp.Accuracy.RecMem)
 				assert.Equal(t, float64(16), resp.Accuracy.RecCpu)
@@ -416,13 +540,13 @@ func TestEngine_RecommendCluster(t *testing.T) {
 				MaxNodes:    10,
 				SumMem:      100,
 				SumCpu:      100,
-				OnDemandPct: 0,
+				OnDemandPct: onDemand(0),
 			},
 			check: func(resp *ClusterRecommendationResp, err error) {
 				assert.Equal(t, 7, resp.Accuracy.RecNodes)
 				assert.Equal(t, float64(112), resp.Accuracy.RecCpu)
 				assert.Equal(t, float64(352), resp.Accuracy.RecMem)
-				assert.Equal(t, 3, len(resp.NodePools))
+				assert.Equal(t, 2, len(resp.NodePools))
 				assert.Nil(t, err, "the error should be nil")
 				assert.Equal(t, 7, resp.Accuracy.RecSpotNodes)
 				assert.Equal(t, 0, resp.Accuracy.RecRegularNodes)
@@ -444,7 +568,7 @@ func TestEngine_RecommendCluster(t *testing.T) {
 				assert.Equal(t, 7, resp.Accuracy.RecNodes)
 				assert.Equal(t, float64(112), resp.Accuracy.RecCpu)
 				assert.Equal(t, float64(448), resp.Accuracy.RecMem)
-				assert.Equal(t, 2, len(resp.NodePools))
+				assert.Equal(t, 1, len(resp.NodePools))
 				assert.Nil(t, err, "the error should be nil")
 			},
 		},
@@ -456,7 +580,7 @@ func TestEngine_RecommendCluster(t *testing.T) {
 				MaxNodes:    10,
 				SumMem:      100,
 				SumCpu:      100,
-				OnDemandPct: 100,
+				OnDemandPct: onDemand(100),
 			},
 			check: func(resp *ClusterRecommendationResp, err error) {
 				assert.Equal(t, 7, resp.Accuracy.RecNodes)
@@ -482,7 +606,7 @@ func TestEngine_RecommendCluster(t *testing.T) {
 				assert.Equal(t, 7, resp.Accuracy.RecNodes)
 				assert.Equal(t, float64(112), resp.Accuracy.RecCpu)
 				assert.Equal(t, float64(352), resp.Accuracy.RecMem)
-				assert.Equal(t, 3, len(resp.NodePools))
+				assert.Equal(t, 2, len(resp.NodePools))
 				assert.Nil(t, err, "the error should be nil")
 			},
 		},
@@ -499,7 +623,7 @@ func TestEngine_RecommendCluster(t *testing.T) {
 				assert.Equal(t, 7, resp.Accuracy.RecNodes)
 				assert.Equal(t, float64(40), resp.Accuracy.RecCpu)
 				assert.Equal(t, float64(112), resp.Accuracy.RecMem)
-				assert.Equal(t, 3, len(resp.NodePools))
+				assert.Equal(t, 2, len(resp.NodePools))
 				assert.Nil(t, err, "the error should be nil")
 			},
 		},
@@ -516,7 +640,7 @@ func TestEngine_RecommendCluster(t *testing.T) {
 				assert.Equal(t, 7, resp.Accuracy.RecNodes)
 				assert.Equal(t, float64(112), resp.Accuracy.RecCpu)
 				assert.Equal(t, float64(352), resp.Accuracy.RecMem)
-				assert.Equal(t, 3, len(resp.NodePools))
+				assert.Equal(t, 2, len(resp.NodePools))
 				assert.Nil(t, err, "the error should be nil")
 			},
 		},
@@ -533,8 +657,281 @@ func TestEngine_RecommendCluster(t *testing.T) {
 				assert.Equal(t, 62500, resp.Accuracy.RecNodes)
 				assert.Equal(t, float64(1e+06), resp.Accuracy.RecCpu)
 				assert.Equal(t, float64(3e+06), resp.Accuracy.RecMem)
-				assert.Equal(t, 3, len(resp.NodePools))
+				assert.Equal(t, 2, len(resp.NodePools))
+				assert.Nil(t, err, "the error should be nil")
+			},
+		},
+		{
+			name: "cluster recommendation success - gpus are requested, only vms with gpus recommended",
+			pi:   &dummyProductInfoSource{GpuVms},
+			request: ClusterRecommendationReq{
+				MinNodes: 1,
+				MaxNodes: 1,
+				SumMem:   32,
+				SumCpu:   16,
+				SumGpu:   4,
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				for _, np := range resp.NodePools {
+					assert.True(t, np.VmType.Gpus > 0, "node pools should have gpus")
+				}
+				assert.True(t, resp.Accuracy.RecGpu >= 4, "the requested gpus should be covered")
+			},
+		},
+		{
+			name: "cluster recommendation success - no gpus requested, gpus not covered",
+			pi:   &dummyProductInfoSource{},
+			request: ClusterRecommendationReq{
+				MinNodes: 1,
+				MaxNodes: 1,
+				SumMem:   32,
+				SumCpu:   16,
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, float64(0), resp.Accuracy.RecGpu)
+			},
+		},
+		{
+			name: "gpus are requested in a region without gpu vms, we should report an error",
+			pi:   &dummyProductInfoSource{},
+			request: ClusterRecommendationReq{
+				MinNodes: 1,
+				MaxNodes: 1,
+				SumMem:   32,
+				SumCpu:   16,
+				SumGpu:   2,
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.Equal(t, ResourceUnavailable, ErrorCode(err))
+				assert.EqualError(t, err, "there are no instance types with gpus on provider [dummy] in region [dummyRegion]")
+			},
+		},
+		{
+			name: "cluster recommendation success - only included vms recommended",
+			pi:   &dummyProductInfoSource{},
+			request: ClusterRecommendationReq{
+				MinNodes: 1,
+				MaxNodes: 1,
+				SumMem:   32,
+				SumCpu:   16,
+				Includes: []string{"type-10"},
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
 				assert.Nil(t, err, "the error should be nil")
+				for _, np := range resp.NodePools {
+					assert.Equal(t, "type-10", np.VmType.Type)
+					assert.Equal(t, Amd64, np.VmType.Architecture)
+				}
+			},
+		},
+		{
+			name: "none of the included vms are available, we should report an error",
+			pi:   &dummyProductInfoSource{},
+			request: ClusterRecommendationReq{
+				MinNodes: 1,
+				MaxNodes: 1,
+				SumMem:   32,
+				SumCpu:   16,
+				Includes: []string{"unknown-type"},
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.Equal(t, NoViableInstances, ErrorCode(err))
+				assert.EqualError(t, err, "none of the included instance types [unknown-type] are available, [10] instance types were available before filtering")
+			},
+		},
+		{
+			name: "the included vms can't satisfy the requested resources, we should report an error",
+			pi:   &dummyProductInfoSource{},
+			request: ClusterRecommendationReq{
+				MinNodes: 1,
+				MaxNodes: 1,
+				SumMem:   32,
+				SumCpu:   16,
+				Includes: []string{"type-3"},
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.Equal(t, NoViableInstances, ErrorCode(err))
+				assert.EqualError(t, err, "the included instance types [type-3] can't satisfy the requested resources")
+			},
+		},
+		{
+			name: "cluster recommendation success - excluded vms not recommended, excludes win over includes",
+			pi:   &dummyProductInfoSource{},
+			request: ClusterRecommendationReq{
+				MinNodes: 1,
+				MaxNodes: 1,
+				SumMem:   32,
+				SumCpu:   16,
+				Includes: []string{"type-10", "type-11"},
+				Excludes: []string{"type-11"},
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				for _, np := range resp.NodePools {
+					assert.Equal(t, "type-10", np.VmType.Type)
+				}
+			},
+		},
+		{
+			name: "all the included vms are excluded, we should report an error",
+			pi:   &dummyProductInfoSource{},
+			request: ClusterRecommendationReq{
+				MinNodes: 1,
+				MaxNodes: 1,
+				SumMem:   32,
+				SumCpu:   16,
+				Includes: []string{"type-10"},
+				Excludes: []string{"type-10"},
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.Equal(t, NoViableInstances, ErrorCode(err))
+				assert.EqualError(t, err, "all the [1] available instance types are excluded by [type-10]")
+			},
+		},
+		{
+			name: "the vms not excluded can't satisfy the requested resources, we should report an error",
+			pi:   &dummyProductInfoSource{},
+			request: ClusterRecommendationReq{
+				MinNodes: 1,
+				MaxNodes: 1,
+				SumMem:   32,
+				SumCpu:   16,
+				Excludes: []string{"type-10", "type-11"},
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.Equal(t, NoViableInstances, ErrorCode(err))
+				assert.EqualError(t, err, "the instance types not excluded by [type-10 type-11] can't satisfy the requested resources")
+			},
+		},
+		{
+			name: "cluster recommendation success - node pool prices add up to the total price",
+			pi:   &dummyProductInfoSource{},
+			request: ClusterRecommendationReq{
+				MinNodes:    5,
+				MaxNodes:    10,
+				SumMem:      100,
+				SumCpu:      100,
+				OnDemandPct: onDemand(50),
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				var sumRegular, sumSpot float64
+				for _, np := range resp.NodePools {
+					switch np.VmClass {
+					case regular:
+						assert.Equal(t, float64(np.SumNodes)*np.VmType.OnDemandPrice, np.PoolPrice)
+						sumRegular += np.PoolPrice
+					case spot:
+						assert.Equal(t, float64(np.SumNodes)*np.VmType.AvgPrice, np.PoolPrice)
+						sumSpot += np.PoolPrice
+					}
+				}
+				assert.InDelta(t, resp.Accuracy.RecRegularPrice, sumRegular, 1e-9)
+				assert.InDelta(t, resp.Accuracy.RecSpotPrice, sumSpot, 1e-9)
+				assert.InDelta(t, resp.Accuracy.RecTotalPrice, sumRegular+sumSpot, 1e-9)
+				assert.True(t, resp.Accuracy.RecOnDemandPct >= 50, "at least 50% of the nodes should be on-demand")
+			},
+		},
+		{
+			name: "cluster recommendation success - the price is under the max price",
+			pi:   &dummyProductInfoSource{},
+			request: ClusterRecommendationReq{
+				MinNodes: 1,
+				MaxNodes: 1,
+				SumMem:   32,
+				SumCpu:   16,
+				MaxPrice: 1,
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.True(t, resp.Accuracy.RecTotalPrice <= 1, "the price should be under the max price")
+				assert.Equal(t, resp.Accuracy.RecTotalPrice*730, resp.Accuracy.RecMonthlyPrice)
+			},
+		},
+		{
+			name: "the price exceeds the max price, we should report an error",
+			pi:   &dummyProductInfoSource{},
+			request: ClusterRecommendationReq{
+				MinNodes: 1,
+				MaxNodes: 1,
+				SumMem:   32,
+				SumCpu:   16,
+				MaxPrice: 0.1,
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.Equal(t, OverBudget, ErrorCode(err))
+				assert.EqualError(t, err, "could not recommend cluster under the max price [0.100000], the cheapest achievable price is [0.157000]")
+			},
+		},
+		{
+			name: "there are no vms with the requested architecture, we should report an error",
+			pi:   &dummyProductInfoSource{},
+			request: ClusterRecommendationReq{
+				MinNodes:     1,
+				MaxNodes:     1,
+				SumMem:       32,
+				SumCpu:       16,
+				Architecture: Arm64,
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.Equal(t, NoViableInstances, ErrorCode(err))
+				assert.EqualError(t, err, "there are no instance types with [arm64] architecture on provider [dummy] in region [dummyRegion]")
+			},
+		},
+		{
+			name: "the existing node pools satisfy the request, no new node pools recommended",
+			// the product info isn't needed
+			pi: &dummyProductInfoSource{ProductDetailsError},
+			request: ClusterRecommendationReq{
+				MinNodes: 1,
+				MaxNodes: 5,
+				SumMem:   32,
+				SumCpu:   16,
+				Existing: []NodePool{
+					{VmType: VirtualMachine{Type: "type-1", OnDemandPrice: 0.5, Cpus: 8, Mem: 32}, SumNodes: 2, VmClass: regular},
+				},
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []NodePool{}, resp.NodePools, "no new node pools should be recommended")
+				assert.Equal(t, 1, len(resp.ExistingNodePools))
+				assert.Equal(t, float64(1), resp.ExistingNodePools[0].PoolPrice)
+				assert.Equal(t, float64(100), resp.Accuracy.RecCpuCoverage)
+				assert.Equal(t, float64(100), resp.Accuracy.RecMemCoverage)
+				assert.Equal(t, float64(1), resp.Accuracy.RecTotalPrice)
+			},
+		},
+		{
+			name: "new node pools recommended for the resources exceeding the existing node pools",
+			pi:   &dummyProductInfoSource{},
+			request: ClusterRecommendationReq{
+				MinNodes: 1,
+				MaxNodes: 5,
+				SumMem:   64,
+				SumCpu:   16,
+				Existing: []NodePool{
+					{VmType: VirtualMachine{Type: "type-1", OnDemandPrice: 0.5, Cpus: 16, Mem: 32}, SumNodes: 1, VmClass: regular},
+				},
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.NotEmpty(t, resp.NodePools, "new node pools should be recommended")
+				var newMem float64
+				for _, np := range resp.NodePools {
+					newMem += np.getSum(Memory)
+				}
+				assert.True(t, newMem >= 32, "the new node pools should cover the missing memory")
+				assert.Equal(t, float64(100), resp.Accuracy.RecMemCoverage)
+				assert.Equal(t, 32+newMem, resp.Accuracy.RecMem, "the accuracy should include the existing node pools")
 			},
 		},
 		{
@@ -562,7 +959,8 @@ func TestEngine_RecommendCluster(t *testing.T) {
 			},
 			check: func(resp *ClusterRecommendationResp, err error) {
 				assert.Nil(t, resp, "the response should be nil")
-				assert.EqualError(t, err, "could not get virtual machines for attr: [cpu], cause: [could not get product details]")
+				assert.EqualError(t, err, "could not get product details")
+				assert.Equal(t, ProductInfoUnavailable, ErrorCode(err), "the failure of the source should be reported as unavailable")
 			},
 		},
 		{
@@ -589,3 +987,1475 @@ func TestEngine_RecommendCluster(t *testing.T) {
 		})
 	}
 }
+
+func TestEngine_RecommendClusterSameSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		request ClusterRecommendationReq
+		check   func(sameSize *ClusterRecommendationResp, mixed *ClusterRecommendationResp)
+	}{
+		{
+			name:    "same size recommendation compared to the mixed one",
+			request: ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(50)},
+			check: func(sameSize *ClusterRecommendationResp, mixed *ClusterRecommendationResp) {
+				types := make(map[string]bool)
+				for _, np := range sameSize.NodePools {
+					types[np.VmType.Type] = true
+				}
+				assert.Equal(t, map[string]bool{"type-10": true}, types, "a single instance type should be recommended")
+				assert.Equal(t, 2, len(sameSize.NodePools))
+
+				assert.Equal(t, sameSize.Accuracy.RecCpu-mixed.Accuracy.RecCpu, sameSize.Accuracy.RecCpuOverProvisioned)
+				assert.Equal(t, float64(-64), sameSize.Accuracy.RecMemOverProvisioned)
+				assert.InDelta(t, sameSize.Accuracy.RecTotalPrice-mixed.Accuracy.RecTotalPrice, sameSize.Accuracy.RecPriceOverhead, 1e-9)
+				assert.True(t, sameSize.Accuracy.RecPriceOverhead > 0, "the same size cluster should be more expensive")
+
+				assert.Zero(t, mixed.Accuracy.RecCpuOverProvisioned, "the overhead is only reported for same size recommendations")
+				assert.Zero(t, mixed.Accuracy.RecPriceOverhead, "the overhead is only reported for same size recommendations")
+			},
+		},
+		{
+			name:    "same size on-demand recommendation",
+			request: ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(100)},
+			check: func(sameSize *ClusterRecommendationResp, mixed *ClusterRecommendationResp) {
+				assert.Equal(t, 1, len(sameSize.NodePools), "only the on-demand pool should be recommended")
+				assert.Equal(t, regular, sameSize.NodePools[0].VmClass)
+				assert.True(t, sameSize.Accuracy.RecCpu >= 100, "the requested cpus should be covered")
+				assert.True(t, sameSize.Accuracy.RecMem >= 100, "the requested memory should be covered")
+				assert.InDelta(t, sameSize.Accuracy.RecTotalPrice-mixed.Accuracy.RecTotalPrice, sameSize.Accuracy.RecPriceOverhead, 1e-9)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(&dummyProductInfoSource{}, WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			mixed, err := engine.RecommendCluster("dummy", "dummyRegion1", test.request)
+			assert.Nil(t, err, "the error should be nil")
+
+			req := test.request
+			req.SameSize = true
+			sameSize, err := engine.RecommendCluster("dummy", "dummyRegion1", req)
+			assert.Nil(t, err, "the error should be nil")
+
+			test.check(sameSize, mixed)
+		})
+	}
+}
+
+func TestEngine_ensureOnDemandNodes(t *testing.T) {
+	small := VirtualMachine{Type: "small", Cpus: 2, AvgPrice: 0.02, OnDemandPrice: 0.1}
+	medium := VirtualMachine{Type: "medium", Cpus: 4, AvgPrice: 0.04, OnDemandPrice: 0.2}
+	pricier := VirtualMachine{Type: "pricier", Cpus: 4, AvgPrice: 0.06, OnDemandPrice: 0.25}
+
+	tests := []struct {
+		name  string
+		nps   []NodePool
+		req   ClusterRecommendationReq
+		check func(nps []NodePool)
+	}{
+		{
+			name: "30% of a 3 node spot layout - naive rounding would result in 0 on-demand nodes",
+			nps: []NodePool{
+				{SumNodes: 0, VmClass: regular, VmType: medium},
+				{SumNodes: 3, VmClass: spot, VmType: medium},
+			},
+			req: ClusterRecommendationReq{SumCpu: 12, MaxNodes: 3, OnDemandPct: onDemand(30)},
+			check: func(nps []NodePool) {
+				assert.Equal(t, 1, nps[0].SumNodes, "at least 30% of the nodes should be on-demand")
+				assert.Equal(t, 2, nps[1].SumNodes, "the spot node should be converted")
+			},
+		},
+		{
+			name: "the on-demand percentage is already satisfied",
+			nps: []NodePool{
+				{SumNodes: 1, VmClass: regular, VmType: medium},
+				{SumNodes: 2, VmClass: spot, VmType: medium},
+			},
+			req: ClusterRecommendationReq{SumCpu: 12, MaxNodes: 3, OnDemandPct: onDemand(30)},
+			check: func(nps []NodePool) {
+				assert.Equal(t, 1, nps[0].SumNodes, "the on-demand pool should be unchanged")
+				assert.Equal(t, 2, nps[1].SumNodes, "the spot pool should be unchanged")
+			},
+		},
+		{
+			name: "on-demand nodes are rounded up, the pricier spot node is converted",
+			nps: []NodePool{
+				{SumNodes: 1, VmClass: regular, VmType: medium},
+				{SumNodes: 1, VmClass: spot, VmType: medium},
+				{SumNodes: 1, VmClass: spot, VmType: pricier},
+			},
+			req: ClusterRecommendationReq{SumCpu: 12, MaxNodes: 3, OnDemandPct: onDemand(50)},
+			check: func(nps []NodePool) {
+				assert.Equal(t, 2, nps[0].SumNodes, "at least 50% of the nodes should be on-demand")
+				assert.Equal(t, 1, nps[1].SumNodes, "the cheaper spot node should be kept")
+				assert.Equal(t, 0, nps[2].SumNodes, "the pricier spot node should be converted")
+			},
+		},
+		{
+			name: "on-demand percentage is 0, no spot nodes converted",
+			nps: []NodePool{
+				{SumNodes: 0, VmClass: regular, VmType: medium},
+				{SumNodes: 3, VmClass: spot, VmType: medium},
+			},
+			req: ClusterRecommendationReq{SumCpu: 12, MaxNodes: 3, OnDemandPct: onDemand(0)},
+			check: func(nps []NodePool) {
+				assert.Equal(t, 0, nps[0].SumNodes, "the on-demand pool should be unchanged")
+				assert.Equal(t, 3, nps[1].SumNodes, "the spot pool should be unchanged")
+			},
+		},
+		{
+			name: "the capacity lost to a smaller on-demand type restored",
+			nps: []NodePool{
+				{SumNodes: 0, VmClass: regular, VmType: small},
+				{SumNodes: 3, VmClass: spot, VmType: medium},
+			},
+			req: ClusterRecommendationReq{SumCpu: 12, MaxNodes: 5, OnDemandPct: onDemand(30)},
+			check: func(nps []NodePool) {
+				assert.Equal(t, 2, nps[0].SumNodes, "an on-demand node should be added for the lost capacity")
+				assert.Equal(t, 2, nps[1].SumNodes, "the spot node should be converted")
+			},
+		},
+		{
+			name: "the capacity not restored beyond the maximum number of nodes",
+			nps: []NodePool{
+				{SumNodes: 0, VmClass: regular, VmType: small},
+				{SumNodes: 3, VmClass: spot, VmType: medium},
+			},
+			req: ClusterRecommendationReq{SumCpu: 12, MaxNodes: 3, OnDemandPct: onDemand(30)},
+			check: func(nps []NodePool) {
+				assert.Equal(t, 1, nps[0].SumNodes, "no on-demand node should be added over the maximum")
+				assert.Equal(t, 2, nps[1].SumNodes, "the spot node should be converted")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ensureOnDemandNodes(test.nps, Cpu, test.req)
+			test.check(test.nps)
+		})
+	}
+}
+
+func TestEngine_RecommendClusterNetworkPerf(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		request  ClusterRecommendationReq
+		check    func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:     "cluster recommendation success - minimum network performance met",
+			provider: "gce",
+			request:  ClusterRecommendationReq{MinNodes: 1, MaxNodes: 5, SumMem: 64, SumCpu: 32, NetworkPerf: stringPointer("medium")},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				for _, np := range resp.NodePools {
+					assert.Contains(t, []string{"medium", "high"}, np.VmType.NetworkPerfCat, "node pools should meet the network performance")
+				}
+			},
+		},
+		{
+			name:     "cluster recommendation success - network performance not reported for the provider",
+			provider: "dummy",
+			request:  ClusterRecommendationReq{MinNodes: 1, MaxNodes: 5, SumMem: 64, SumCpu: 32, NetworkPerf: stringPointer("extra")},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.NotEmpty(t, resp.NodePools)
+			},
+		},
+		{
+			name:     "cluster recommendation error - network performance not available in the region",
+			provider: "gce",
+			request:  ClusterRecommendationReq{MinNodes: 1, MaxNodes: 5, SumMem: 64, SumCpu: 32, NetworkPerf: stringPointer("extra")},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.EqualError(t, err, "there are no instance types with [extra] or better network performance on provider [gce] in region [dummyRegion], the highest available is [high]")
+				assert.Equal(t, NoViableInstances, ErrorCode(err))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(&dummyProductInfoSource{NetworkVms})
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.RecommendCluster(test.provider, "dummyRegion", test.request))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterMemRatio(t *testing.T) {
+	tests := []struct {
+		name    string
+		request ClusterRecommendationReq
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:    "no ratio preference - the cheapest instance type recommended",
+			request: ClusterRecommendationReq{SumCpu: 64, SumMem: 64, MinNodes: 1, MaxNodes: 10, OnDemandPct: onDemand(100)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, regular, resp.NodePools[0].VmClass)
+				assert.Equal(t, "type-10", resp.NodePools[0].VmType.Type)
+			},
+		},
+		{
+			name:    "memory optimized instance type preferred over the cheaper one",
+			request: ClusterRecommendationReq{SumCpu: 64, SumMem: 64, MinNodes: 1, MaxNodes: 10, OnDemandPct: onDemand(100), MemRatio: 4},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, regular, resp.NodePools[0].VmClass)
+				assert.Equal(t, "type-11", resp.NodePools[0].VmType.Type)
+				assert.True(t, resp.Accuracy.RecCpu >= 64, "the requested cpus should be covered")
+				assert.True(t, resp.Accuracy.RecMem >= 64, "the requested memory should be covered")
+			},
+		},
+		{
+			name:    "closest ratio preferred in the spot pools",
+			request: ClusterRecommendationReq{SumCpu: 64, SumMem: 64, MinNodes: 1, MaxNodes: 10, MemRatio: 2},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				// the first spot pool is filled up first
+				assert.Equal(t, spot, resp.NodePools[0].VmClass)
+				assert.Equal(t, "type-10", resp.NodePools[0].VmType.Type)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(&dummyProductInfoSource{}, WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.RecommendCluster("dummy", "dummyRegion1", test.request))
+		})
+	}
+}
+
+func TestMemRatioPrice(t *testing.T) {
+	price := func(vm VirtualMachine) float64 { return vm.OnDemandPrice / vm.Cpus }
+	tests := []struct {
+		name      string
+		memRatio  float64
+		vm        VirtualMachine
+		selected  VirtualMachine
+		preferred bool
+	}{
+		{
+			name:      "no ratio preference - the cheaper one preferred",
+			vm:        VirtualMachine{Type: "c5.xlarge", Cpus: 4, Mem: 8, OnDemandPrice: 0.192},
+			selected:  VirtualMachine{Type: "r5.xlarge", Cpus: 4, Mem: 32, OnDemandPrice: 0.282},
+			preferred: true,
+		},
+		{
+			name:      "the one of the preferred ratio preferred over a slightly cheaper one",
+			memRatio:  4,
+			vm:        VirtualMachine{Type: "m5.xlarge", Cpus: 4, Mem: 16, OnDemandPrice: 0.214},
+			selected:  VirtualMachine{Type: "c5.xlarge", Cpus: 4, Mem: 8, OnDemandPrice: 0.192},
+			preferred: true,
+		},
+		{
+			name:      "a much cheaper one preferred over the one of the preferred ratio",
+			memRatio:  8,
+			vm:        VirtualMachine{Type: "c5.xlarge", Cpus: 4, Mem: 8, OnDemandPrice: 0.1},
+			selected:  VirtualMachine{Type: "r5.xlarge", Cpus: 4, Mem: 32, OnDemandPrice: 0.282},
+			preferred: true,
+		},
+		{
+			name:      "the same price - the closer one preferred",
+			memRatio:  8,
+			vm:        VirtualMachine{Type: "m5.xlarge", Cpus: 4, Mem: 16, OnDemandPrice: 0.214},
+			selected:  VirtualMachine{Type: "c5.xlarge", Cpus: 4, Mem: 8, OnDemandPrice: 0.214},
+			preferred: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			score := func(vm VirtualMachine) float64 { return memRatioPrice(vm, test.memRatio, price(vm)) }
+			assert.Equal(t, test.preferred, preferred(test.vm, test.selected, score))
+			assert.Equal(t, !test.preferred, preferred(test.selected, test.vm, score))
+		})
+	}
+}
+
+// storageProductInfoSource reports the local storage of the instance types
+type storageProductInfoSource struct {
+	dummyProductInfoSource
+	storage map[string]float64
+}
+
+func (piCli *storageProductInfoSource) GetLocalStorage(provider string, region string) (map[string]float64, error) {
+	return piCli.storage, nil
+}
+
+func TestEngine_RecommendClusterStorage(t *testing.T) {
+	tests := []struct {
+		name    string
+		pi      ProductInfoSource
+		request ClusterRecommendationReq
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name: "storage heavy request - only instance types with local storage recommended",
+			pi: &storageProductInfoSource{
+				dummyProductInfoSource: dummyProductInfoSource{NetworkVms},
+				storage:                map[string]float64{"ntw-low": 0, "ntw-medium": 150, "ntw-high": 600},
+			},
+			request: ClusterRecommendationReq{SumCpu: 32, SumMem: 64, MinNodes: 1, MaxNodes: 10, OnDemandPct: onDemand(50), SumStorage: 1200},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Empty(t, resp.Warnings)
+				for _, np := range resp.NodePools {
+					assert.True(t, np.VmType.Storage > 0, "node pools should have local storage")
+				}
+				assert.True(t, resp.Accuracy.RecStorage >= 1200, "the requested storage should be covered")
+				assert.True(t, resp.Accuracy.RecCpu >= 32, "the requested cpus should be covered")
+			},
+		},
+		{
+			name: "storage requested in a region without local storage",
+			pi: &storageProductInfoSource{
+				dummyProductInfoSource: dummyProductInfoSource{NetworkVms},
+				storage:                map[string]float64{},
+			},
+			request: ClusterRecommendationReq{SumCpu: 32, SumMem: 64, MinNodes: 1, MaxNodes: 10, SumStorage: 1200},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.EqualError(t, err, "there are no instance types with local storage on provider [dummy] in region [dummyRegion]")
+				assert.Equal(t, ResourceUnavailable, ErrorCode(err))
+			},
+		},
+		{
+			name:    "storage requested on a provider not reporting local storage",
+			pi:      &dummyProductInfoSource{NetworkVms},
+			request: ClusterRecommendationReq{SumCpu: 32, SumMem: 64, MinNodes: 1, MaxNodes: 10, SumStorage: 1200},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"local storage is not reported on provider [dummy], the requested storage is not taken into account"}, resp.Warnings)
+				assert.Zero(t, resp.Accuracy.RecStorage)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.pi)
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.RecommendCluster("dummy", "dummyRegion", test.request))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterMinGen(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		request  ClusterRecommendationReq
+		check    func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:     "older generations recommended if no minimum is requested",
+			provider: "ec2",
+			request:  ClusterRecommendationReq{SumCpu: 32, SumMem: 64, MinNodes: 1, MaxNodes: 10, OnDemandPct: onDemand(100)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "m4.4xlarge", resp.NodePools[0].VmType.Type)
+			},
+		},
+		{
+			name:     "only the minimum generation or newer recommended",
+			provider: "ec2",
+			request:  ClusterRecommendationReq{SumCpu: 32, SumMem: 64, MinNodes: 1, MaxNodes: 10, OnDemandPct: onDemand(50), MinGen: 5},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				for _, np := range resp.NodePools {
+					assert.True(t, np.VmType.Generation >= 5, "node pools should be of generation 5 or newer")
+				}
+			},
+		},
+		{
+			name:     "error - no instance types of the minimum generation",
+			provider: "ec2",
+			request:  ClusterRecommendationReq{SumCpu: 32, SumMem: 64, MinNodes: 1, MaxNodes: 10, MinGen: 7},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.EqualError(t, err, "there are no instance types of generation [7] or newer on provider [ec2] in region [dummyRegion]")
+				assert.Equal(t, NoViableInstances, ErrorCode(err))
+			},
+		},
+		{
+			name:     "error - generation not known on the provider",
+			provider: "gce",
+			request:  ClusterRecommendationReq{SumCpu: 32, SumMem: 64, MinNodes: 1, MaxNodes: 10, MinGen: 5},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.EqualError(t, err, "the generation of the instance types is not known on provider [gce], the minimum generation can't be applied")
+				assert.Equal(t, ResourceUnavailable, ErrorCode(err))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(&dummyProductInfoSource{Ec2Vms})
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.RecommendCluster(test.provider, "dummyRegion", test.request))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterMinNodePools(t *testing.T) {
+	tests := []struct {
+		name    string
+		request ClusterRecommendationReq
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:    "node pools diversified to the requested number of instance types",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 16, MinNodes: 1, MaxNodes: 10, MinNodePools: 2},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				used := distinctTypes(resp.NodePools)
+				assert.Equal(t, 2, used, "the node pools should have 2 distinct instance types")
+			},
+		},
+		{
+			name:    "error - not enough instance types are viable",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 16, MinNodes: 1, MaxNodes: 10, MinNodePools: 3},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.EqualError(t, err, "could not recommend [3] node pools of distinct instance types, only [2] instance types are viable for the request")
+			},
+		},
+		{
+			name:    "error - only one instance type is viable",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 16, MinNodes: 1, MaxNodes: 10, MinNodePools: 2, Includes: []string{"type-10"}},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.EqualError(t, err, "could not recommend [2] node pools of distinct instance types, only [1] instance types are viable for the request")
+				assert.Equal(t, NoViableInstances, ErrorCode(err))
+			},
+		},
+		{
+			name:    "error - on-demand node pools are not diversified",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 16, MinNodes: 1, MaxNodes: 10, MinNodePools: 2, OnDemandPct: onDemand(100)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.Equal(t, NoViableInstances, ErrorCode(err))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(&dummyProductInfoSource{}, WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.RecommendCluster("dummy", "dummyRegion1", test.request))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterMinNodePoolsViable(t *testing.T) {
+	// the instance types without spot price are viable but get no spot pool
+	fs := mustFileSource(t)
+	for _, p := range fs.snapshot.Providers["ec2"].Regions["eu-west-1"].Products {
+		if p.Type != "m5.xlarge" {
+			p.SpotPrice = nil
+		}
+	}
+	engine, err := NewEngine(fs, WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	resp, err := engine.RecommendCluster("ec2", "eu-west-1", ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, MinNodePools: 5, OnDemandPct: onDemand(50)})
+	assert.Nil(t, resp, "the response should be nil")
+	assert.EqualError(t, err, "could not recommend [5] node pools of distinct instance types, only [4] instance types are viable for the request")
+}
+
+func TestEngine_RecommendClusterMaxNodePools(t *testing.T) {
+	tests := []struct {
+		name    string
+		request ClusterRecommendationReq
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:    "unlimited node pools by default",
+			request: ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 1, MaxNodes: 10},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				used := distinctTypes(resp.NodePools)
+				assert.Equal(t, 2, used)
+				assert.Zero(t, resp.Accuracy.RecMemOverProvisioned, "the overhead is only reported for limited recommendations")
+			},
+		},
+		{
+			name:    "spot pools collapsed into a single instance type",
+			request: ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 1, MaxNodes: 10, MaxNodePools: 1},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				used := distinctTypes(resp.NodePools)
+				assert.Equal(t, 1, used)
+				assert.True(t, resp.Accuracy.RecCpu >= 100, "the requested cpus should be covered")
+				assert.Equal(t, float64(96), resp.Accuracy.RecMemOverProvisioned)
+			},
+		},
+		{
+			name:    "spot nodes collapsed into the on-demand instance type",
+			request: ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 1, MaxNodes: 10, OnDemandPct: onDemand(30), MaxNodePools: 1},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				for _, np := range resp.NodePools {
+					if np.SumNodes > 0 {
+						assert.Equal(t, resp.NodePools[0].VmType.Type, np.VmType.Type, "the node pools should have the on-demand instance type")
+					}
+				}
+				assert.True(t, resp.Accuracy.RecCpu >= 100, "the requested cpus should be covered")
+				assert.InDelta(t, 0.042, resp.Accuracy.RecPriceOverhead, 1e-9)
+			},
+		},
+		{
+			name:    "limit above the number of instance types leaves the layout unchanged",
+			request: ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 1, MaxNodes: 10, MaxNodePools: 3},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				used := distinctTypes(resp.NodePools)
+				assert.Equal(t, 2, used)
+				assert.Zero(t, resp.Accuracy.RecPriceOverhead)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(&dummyProductInfoSource{}, WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.RecommendCluster("dummy", "dummyRegion1", test.request))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterTolerance(t *testing.T) {
+	tests := []struct {
+		name    string
+		request ClusterRecommendationReq
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:    "requested resources covered without tolerance",
+			request: ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(50)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, float64(128), resp.Accuracy.RecCpu)
+				assert.Equal(t, float64(100), resp.Accuracy.RecCpuCoverage)
+				assert.InDelta(t, 3.376, resp.Accuracy.RecTotalPrice, 1e-9)
+			},
+		},
+		{
+			name:    "cheaper layout within the tolerance",
+			request: ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(50), Tolerance: 5},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, float64(96), resp.Accuracy.RecCpu)
+				assert.Equal(t, float64(96), resp.Accuracy.RecCpuCoverage)
+				assert.Equal(t, float64(100), resp.Accuracy.RecMemCoverage)
+				assert.InDelta(t, 2.525, resp.Accuracy.RecTotalPrice, 1e-9)
+			},
+		},
+		{
+			name: "tolerance applied before the existing node pools",
+			request: ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(50), Tolerance: 10,
+				Existing: []NodePool{{SumNodes: 6, VmClass: regular, VmType: VirtualMachine{Type: "type-10", Cpus: 16, Mem: 32, OnDemandPrice: 0.68}}}},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Empty(t, resp.NodePools, "the existing node pools should satisfy the request within the tolerance")
+				assert.Equal(t, float64(96), resp.Accuracy.RecCpuCoverage)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(&dummyProductInfoSource{}, WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.RecommendCluster("dummy", "dummyRegion1", test.request))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterMemHeadroom(t *testing.T) {
+	engine, err := NewEngine(mustFileSource(t), WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	tests := []struct {
+		name    string
+		request ClusterRecommendationReq
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:    "no headroom requested",
+			request: ClusterRecommendationReq{SumCpu: 8, SumMem: 32, MinNodes: 1, MaxNodes: 8, OnDemandPct: onDemand(100)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, float64(32), resp.Accuracy.RecMem)
+				assert.Equal(t, 0.0, resp.Accuracy.RecMemHeadroom)
+				assert.Equal(t, 0.0, resp.Accuracy.RecMemExcess)
+			},
+		},
+		{
+			name:    "memory recommended with the headroom, cpus as requested",
+			request: ClusterRecommendationReq{SumCpu: 8, SumMem: 32, MinNodes: 1, MaxNodes: 8, OnDemandPct: onDemand(100), MemHeadroomPct: 50},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.True(t, resp.Accuracy.RecMem >= 48, "the memory should cover the headroom")
+				assert.True(t, resp.Accuracy.RecCpu >= 8, "the cpus should cover the request")
+				assert.Equal(t, float64(16), resp.Accuracy.RecMemHeadroom)
+				assert.Equal(t, resp.Accuracy.RecMem-48, resp.Accuracy.RecMemExcess)
+				assert.Equal(t, float64(100), resp.Accuracy.RecMemCoverage)
+				assert.Equal(t, float64(32), resp.Request.SumMem, "the request should be echoed as requested")
+			},
+		},
+		{
+			name: "headroom covered by the existing node pools",
+			request: ClusterRecommendationReq{SumCpu: 8, SumMem: 32, MinNodes: 1, MaxNodes: 8, OnDemandPct: onDemand(100), MemHeadroomPct: 50,
+				Existing: []NodePool{{SumNodes: 2, VmClass: regular, VmType: VirtualMachine{Type: "r5.xlarge", Cpus: 4, Mem: 32, OnDemandPrice: 0.282}}}},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Empty(t, resp.NodePools, "the existing node pools should satisfy the request with the headroom")
+				assert.Equal(t, float64(16), resp.Accuracy.RecMemHeadroom)
+				assert.Equal(t, float64(16), resp.Accuracy.RecMemExcess)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(engine.RecommendCluster("ec2", "eu-west-1", test.request))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterMonthlySavings(t *testing.T) {
+	engine, err := NewEngine(&dummyProductInfoSource{}, WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	req := ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(100)}
+	// the all on-demand layout of the same request
+	allOnDemand, err := engine.RecommendCluster("dummy", "dummyRegion1", req)
+	assert.Nil(t, err, "the error should be nil")
+	assert.Equal(t, 0.0, allOnDemand.Accuracy.RecMonthlySavings, "no savings without spot nodes")
+
+	tests := []struct {
+		name   string
+		pct    int
+		hours  float64
+		months float64
+	}{
+		{name: "spot and on-demand nodes", pct: 50, months: hoursPerMonth},
+		{name: "spot nodes only", pct: 0, months: hoursPerMonth},
+		{name: "savings projected on the monthly runtime", pct: 50, hours: 365, months: 365},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := req
+			r.OnDemandPct, r.MonthlyHours = onDemand(test.pct), test.hours
+			resp, err := engine.RecommendCluster("dummy", "dummyRegion1", r)
+			assert.Nil(t, err, "the error should be nil")
+			assert.True(t, resp.Accuracy.RecSpotNodes > 0, "spot nodes should be recommended")
+			assert.InDelta(t, (allOnDemand.Accuracy.RecTotalPrice-resp.Accuracy.RecTotalPrice)*test.months, resp.Accuracy.RecMonthlySavings, 1e-9)
+			assert.True(t, resp.Accuracy.RecMonthlySavings > 0, "the spot nodes should be cheaper")
+		})
+	}
+}
+
+func TestEngine_RecommendClusterMonthlySavingsLayout(t *testing.T) {
+	engine, err := NewEngine(mustFileSource(t), WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	tests := []struct {
+		name    string
+		request ClusterRecommendationReq
+	}{
+		{
+			name:    "same size nodes",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, SameSize: true},
+		},
+		{
+			name:    "fixed node count",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, NodeCount: 3},
+		},
+		{
+			name:    "limited node pools",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, MaxNodePools: 2},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := test.request
+			r.OnDemandPct = onDemand(50)
+			resp, err := engine.RecommendCluster("ec2", "eu-west-1", r)
+			assert.Nil(t, err, "the error should be nil")
+			assert.True(t, resp.Accuracy.RecSpotNodes > 0, "spot nodes should be recommended")
+
+			// the baseline is laid out the way the recommendation is, with on-demand nodes only
+			r.OnDemandPct = onDemand(100)
+			allOnDemand, err := engine.RecommendCluster("ec2", "eu-west-1", r)
+			assert.Nil(t, err, "the error should be nil")
+			assert.InDelta(t, (allOnDemand.Accuracy.RecTotalPrice-resp.Accuracy.RecTotalPrice)*hoursPerMonth, resp.Accuracy.RecMonthlySavings, 1e-9)
+		})
+	}
+}
+
+func TestEngine_RecommendClusterNodeSize(t *testing.T) {
+	fs, err := NewFileProductInfoSource("testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := NewEngine(fs)
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	tests := []struct {
+		name    string
+		request ClusterRecommendationReq
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:    "nodes within the cpu cap",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(50), MaxNodeCpu: 4},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				for _, np := range resp.NodePools {
+					assert.True(t, np.VmType.Cpus <= 4, "the nodes should have at most 4 cpus")
+				}
+				assert.True(t, resp.Accuracy.RecCpu >= 16, "the requested cpus should be covered")
+			},
+		},
+		{
+			name:    "nodes within the memory cap",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(50), MaxNodeMem: 16},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				for _, np := range resp.NodePools {
+					assert.True(t, np.VmType.Mem <= 16, "the nodes should have at most 16 GB memory")
+				}
+			},
+		},
+		{
+			name:    "nodes above the cpu floor",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(50), MinNodeCpu: 8},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				for _, np := range resp.NodePools {
+					assert.Equal(t, "m5.2xlarge", np.VmType.Type, "only the instance types with 8 cpus should be recommended")
+				}
+			},
+		},
+		{
+			name:    "error - no instance types in the node size band",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 6, OnDemandPct: onDemand(50), MinNodeCpu: 8, MaxNodeMem: 16},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.EqualError(t, err, "there are no instance types within the node size bounds of at least [8] cpus, at most [16] GB memory on provider [ec2] in region [eu-west-1]")
+				assert.Equal(t, NoViableInstances, ErrorCode(err))
+			},
+		},
+		{
+			name:    "error - caps conflicting with the maximum number of nodes",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 6, OnDemandPct: onDemand(50), MaxNodeCpu: 2},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.EqualError(t, err, "the node size bounds of at most [2] cpus conflict with maxNodes [6]: the largest nodes within the bounds have [2] cpus and [8] GB memory, "+
+					"[6] of them can't provide [16] cpus and [64] GB memory")
+				assert.Equal(t, NoViableInstances, ErrorCode(err))
+			},
+		},
+		{
+			name:    "error - no instance types within the caps",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 6, OnDemandPct: onDemand(50), MaxNodeCpu: 4, MaxNodeMem: 4},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.EqualError(t, err, "there are no instance types within the node size bounds of at most [4] cpus, at most [4] GB memory on provider [ec2] in region [eu-west-1]")
+				assert.Equal(t, NoViableInstances, ErrorCode(err))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(engine.RecommendCluster("ec2", "eu-west-1", test.request))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterSpotOnly(t *testing.T) {
+	fs, err := NewFileProductInfoSource("testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+
+	tests := []struct {
+		name     string
+		minPools int
+		request  ClusterRecommendationReq
+		check    func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:     "spot-only layout diversified to the minimum number of instance types",
+			minPools: 3,
+			request:  ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.True(t, resp.SpotOnly, "the layout should be flagged as spot-only")
+				for _, np := range resp.NodePools {
+					assert.Equal(t, spot, np.VmClass, "no on-demand pools should be recommended")
+				}
+				used := distinctTypes(resp.NodePools)
+				assert.True(t, used >= 3, "the nodes should be spread over at least 3 instance types")
+				assert.Equal(t, 0, resp.Accuracy.RecRegularNodes)
+			},
+		},
+		{
+			name:     "spot-only layout not diversified by default",
+			minPools: 0,
+			request:  ClusterRecommendationReq{SumCpu: 4, SumMem: 16, MinNodes: 1, MaxNodes: 1},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.True(t, resp.SpotOnly, "the layout should be flagged as spot-only")
+				used := distinctTypes(resp.NodePools)
+				assert.Equal(t, 1, used, "the single node layout should be recommended")
+			},
+		},
+		{
+			name:     "the requested minimum number of node pools above the configured one",
+			minPools: 2,
+			request:  ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, MinNodePools: 4},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				used := distinctTypes(resp.NodePools)
+				assert.True(t, used >= 4, "the nodes should be spread over at least 4 instance types")
+			},
+		},
+		{
+			name:     "not a spot-only layout if on-demand nodes are requested",
+			minPools: 3,
+			request:  ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(50)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.False(t, resp.SpotOnly)
+				assert.Equal(t, regular, resp.NodePools[0].VmClass)
+			},
+		},
+		{
+			name:     "error - not enough viable instance types",
+			minPools: 6,
+			request:  ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.Equal(t, NoViableInstances, ErrorCode(err))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(fs, WithMinSpotPools(test.minPools), WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.RecommendCluster("ec2", "eu-west-1", test.request))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterDefaultOnDemandPct(t *testing.T) {
+	fs, err := NewFileProductInfoSource("testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+
+	tests := []struct {
+		name    string
+		pct     int
+		request ClusterRecommendationReq
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:    "the default applied if the percentage is omitted",
+			pct:     100,
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.False(t, resp.SpotOnly)
+				assert.Equal(t, 0, resp.Accuracy.RecSpotNodes, "all the nodes should be on-demand")
+			},
+		},
+		{
+			name:    "an explicit 0 kept",
+			pct:     100,
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(0)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.True(t, resp.SpotOnly, "the layout should be spot-only")
+				assert.Equal(t, 0, resp.Accuracy.RecRegularNodes)
+			},
+		},
+		{
+			name:    "spot-only by default",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.True(t, resp.SpotOnly, "the layout should be spot-only")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(fs, WithDefaultOnDemandPct(test.pct), WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.RecommendCluster("ec2", "eu-west-1", test.request))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterRequestEcho(t *testing.T) {
+	engine, err := NewEngine(mustFileSource(t), WithDefaultOnDemandPct(30), WithSpotMargin(5), WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	tests := []struct {
+		name    string
+		request ClusterRecommendationReq
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:    "the request echoed with the defaults applied",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, Alternatives: 1},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "ec2", resp.Provider)
+				assert.Equal(t, "eu-west-1", resp.Region)
+				assert.Equal(t, 16.0, resp.Request.SumCpu)
+				assert.Equal(t, 30, *resp.Request.OnDemandPct)
+				assert.Equal(t, 5.0, *resp.Request.SpotMargin)
+				for _, alt := range resp.Alternatives {
+					assert.Nil(t, alt.Request, "the request should only be echoed once")
+				}
+			},
+		},
+		{
+			name:    "the explicit values of the request kept",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(100)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, 100, *resp.Request.OnDemandPct)
+			},
+		},
+		{
+			name: "the request echoed if the existing node pools satisfy it",
+			request: ClusterRecommendationReq{SumCpu: 2, SumMem: 8, MinNodes: 1, MaxNodes: 2,
+				Existing: []NodePool{{SumNodes: 1, VmClass: regular, VmType: VirtualMachine{Type: "m5.large", Cpus: 2, Mem: 8, OnDemandPrice: 0.1}}}},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, 1, len(resp.Request.Existing))
+				assert.Equal(t, "eu-west-1", resp.Region)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(engine.RecommendCluster("ec2", "eu-west-1", test.request))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterStorageType(t *testing.T) {
+	tests := []struct {
+		name    string
+		pi      ProductInfoSource
+		region  string
+		request ClusterRecommendationReq
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:    "only the instance types supporting the storage type recommended",
+			pi:      mustFileSource(t),
+			region:  "eu-west-1",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(50), StorageType: "nvme"},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Empty(t, resp.Warnings)
+				for _, np := range resp.NodePools {
+					assert.Contains(t, []string{"c5.xlarge", "m5.2xlarge"}, np.VmType.Type)
+					assert.Contains(t, np.VmType.StorageTypes, "nvme", "the storage types should be surfaced")
+				}
+			},
+		},
+		{
+			name:    "error - storage type not supported in the region",
+			pi:      mustFileSource(t),
+			region:  "eu-west-1",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(50), StorageType: "io2"},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.EqualError(t, err, "there are no instance types supporting the storage type [io2] on provider [ec2] in region [eu-west-1]")
+				assert.Equal(t, ResourceUnavailable, ErrorCode(err))
+			},
+		},
+		{
+			name:    "storage types not reported",
+			pi:      &dummyProductInfoSource{},
+			region:  "dummyRegion1",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(50), StorageType: "nvme"},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"storage types are not reported on provider [ec2], the requested storage type is not taken into account"}, resp.Warnings)
+				assert.NotEmpty(t, resp.NodePools)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.pi, WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.RecommendCluster("ec2", test.region, test.request))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterNoBurstable(t *testing.T) {
+	fs, err := NewFileProductInfoSource("testdata/burstable.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := NewEngine(fs, WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	steady := ClusterRecommendationReq{SumCpu: 8, SumMem: 32, MinNodes: 2, MaxNodes: 4, OnDemandPct: onDemand(50), NoBurstable: true}
+	tests := []struct {
+		name     string
+		provider string
+		region   string
+		request  ClusterRecommendationReq
+		check    func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:     "burstable t3 types recommended by default",
+			provider: "ec2",
+			region:   "eu-west-1",
+			request:  ClusterRecommendationReq{SumCpu: 8, SumMem: 32, MinNodes: 2, MaxNodes: 4, OnDemandPct: onDemand(50)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "t3.large", resp.NodePools[0].VmType.Type, "the cheaper burstable type should be recommended")
+				assert.True(t, resp.NodePools[0].VmType.Burst, "the type should be classified as burstable")
+			},
+		},
+		{
+			name:     "burstable t3 types excluded",
+			provider: "ec2",
+			region:   "eu-west-1",
+			request:  steady,
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				for _, np := range resp.NodePools {
+					assert.Contains(t, []string{"m5.large", "m5.xlarge"}, np.VmType.Type)
+				}
+			},
+		},
+		{
+			name:     "shared-core e2 types excluded",
+			provider: "gce",
+			region:   "europe-west1",
+			request:  steady,
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				for _, np := range resp.NodePools {
+					assert.Contains(t, []string{"e2-standard-2", "e2-standard-4"}, np.VmType.Type)
+				}
+			},
+		},
+		{
+			name:     "error - only burstable types available",
+			provider: "ec2",
+			region:   "eu-north-1",
+			request:  steady,
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.EqualError(t, err, "only burstable instance types are available on provider [ec2] in region [eu-north-1], burstable instance types are excluded by the request")
+				assert.Equal(t, NoViableInstances, ErrorCode(err))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(engine.RecommendCluster(test.provider, test.region, test.request))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterSpotMargin(t *testing.T) {
+	fs, err := NewFileProductInfoSource("testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+
+	// the spot prices of the snapshot are about a third of the on-demand prices
+	margin := func(pct float64) *float64 { return &pct }
+	tests := []struct {
+		name    string
+		margin  float64
+		request ClusterRecommendationReq
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:    "spot pools recommended if the buffered spot prices are below the on-demand prices",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(25), SpotMargin: margin(100)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.True(t, resp.Accuracy.RecSpotNodes > 0, "spot nodes should be recommended")
+			},
+		},
+		{
+			name:    "on-demand nodes recommended if the buffered spot prices exceed the on-demand prices",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(25), SpotMargin: margin(250)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, 0, resp.Accuracy.RecSpotNodes, "no spot nodes should be recommended")
+				assert.True(t, resp.Accuracy.RecCpu >= 16, "the requested cpus should be covered by on-demand nodes")
+				assert.InDelta(t, resp.Accuracy.RecRegularPrice, resp.Accuracy.RecTotalPrice, 1e-9, "the actual on-demand prices should be reported")
+			},
+		},
+		{
+			name:    "the margin of the engine flips spot-only layouts to on-demand",
+			margin:  250,
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.False(t, resp.SpotOnly, "the layout should not be flagged as spot-only")
+				assert.Equal(t, 1, len(resp.NodePools))
+				assert.Equal(t, regular, resp.NodePools[0].VmClass)
+			},
+		},
+		{
+			name:    "the margin of the request overrides the margin of the engine",
+			margin:  250,
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, SpotMargin: margin(0)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.True(t, resp.SpotOnly, "the layout should be spot-only")
+			},
+		},
+		{
+			name:    "same size node pools flipped to on-demand",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(50), SameSize: true, SpotMargin: margin(250)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, 1, len(resp.NodePools))
+				assert.Equal(t, regular, resp.NodePools[0].VmClass)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(fs, WithSpotMargin(test.margin), WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.RecommendCluster("ec2", "eu-west-1", test.request))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterMaxSpotPrice(t *testing.T) {
+	engine, err := NewEngine(mustFileSource(t), WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	req := ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(25)}
+	uncapped, err := engine.RecommendCluster("ec2", "eu-west-1", req)
+	assert.Nil(t, err, "the error should be nil")
+	// the cap leaves out the most expensive spot instance type of the uncapped recommendation
+	var priciest float64
+	for _, np := range uncapped.NodePools {
+		if np.VmClass == spot && np.SumNodes > 0 {
+			assert.Equal(t, 0.0, np.SpotBid, "no bid should be set without a cap")
+			priciest = math.Max(priciest, np.VmType.AvgPrice)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		maxPrice    float64
+		onDemandPct *int
+		check       func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:     "the spot instance types priced above the cap dropped",
+			maxPrice: priciest * 0.99,
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.True(t, resp.Accuracy.RecSpotNodes > 0, "spot nodes should be recommended")
+				assert.Empty(t, resp.Warnings)
+				for _, np := range resp.NodePools {
+					if np.VmClass == spot && np.SumNodes > 0 {
+						assert.True(t, np.VmType.AvgPrice <= priciest*0.99, "the spot price of [%s] exceeds the cap", np.VmType.Type)
+						assert.InDelta(t, math.Min(priciest*0.99, np.VmType.OnDemandPrice), np.SpotBid, 1e-9)
+					}
+				}
+			},
+		},
+		{
+			name:     "the bid capped at the on-demand price",
+			maxPrice: 1000,
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				for _, np := range resp.NodePools {
+					if np.VmClass == spot && np.SumNodes > 0 {
+						assert.Equal(t, np.VmType.OnDemandPrice, np.SpotBid)
+					}
+				}
+			},
+		},
+		{
+			name:     "on-demand nodes recommended if no spot price is below the cap",
+			maxPrice: 0.0001,
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, 0, resp.Accuracy.RecSpotNodes, "no spot nodes should be recommended")
+				assert.True(t, resp.Accuracy.RecCpu >= 16, "the requested cpus should be covered by on-demand nodes")
+				assert.Equal(t, 1, len(resp.Warnings), "the fallback should be warned about")
+			},
+		},
+		{
+			name:        "no warning if the spot nodes are not dropped by the cap",
+			maxPrice:    1000,
+			onDemandPct: onDemand(90),
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				// 90% of the nodes rounded up leaves no spot node
+				assert.Equal(t, 0, resp.Accuracy.RecSpotNodes, "no spot nodes should be recommended")
+				assert.Empty(t, resp.Warnings)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := req
+			r.MaxSpotPrice = test.maxPrice
+			if test.onDemandPct != nil {
+				r.OnDemandPct = test.onDemandPct
+			}
+			test.check(engine.RecommendCluster("ec2", "eu-west-1", r))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterNodeCount(t *testing.T) {
+	fs, err := NewFileProductInfoSource("testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := NewEngine(fs, WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	tests := []struct {
+		name    string
+		request ClusterRecommendationReq
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:    "the cheapest instance type tiling the resources over the nodes",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 1, MaxNodes: 8, NodeCount: 4, OnDemandPct: onDemand(50)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, 2, len(resp.NodePools))
+				for _, np := range resp.NodePools {
+					assert.Equal(t, "m5.xlarge", np.VmType.Type)
+				}
+				assert.Equal(t, 2, resp.Accuracy.RecRegularNodes)
+				assert.Equal(t, 2, resp.Accuracy.RecSpotNodes)
+			},
+		},
+		{
+			name:    "larger instance types for fewer nodes",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 1, MaxNodes: 8, NodeCount: 2, OnDemandPct: onDemand(100)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, 1, len(resp.NodePools))
+				assert.Equal(t, "m5.2xlarge", resp.NodePools[0].VmType.Type)
+				assert.Equal(t, 2, resp.NodePools[0].SumNodes)
+			},
+		},
+		{
+			name:    "spot-only nodes of a single instance type",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 1, MaxNodes: 8, NodeCount: 4},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.True(t, resp.SpotOnly, "the layout should be spot-only")
+				assert.Equal(t, 1, len(resp.NodePools))
+				assert.Equal(t, 4, resp.NodePools[0].SumNodes)
+			},
+		},
+		{
+			name:    "error - no instance type large enough for the nodes",
+			request: ClusterRecommendationReq{SumCpu: 32, SumMem: 64, MinNodes: 1, MaxNodes: 8, NodeCount: 2, OnDemandPct: onDemand(100)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.EqualError(t, err, "no instance type can provide [32] cpus and [64] GB memory on [2] nodes")
+				assert.Equal(t, NoViableInstances, ErrorCode(err))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(engine.RecommendCluster("ec2", "eu-west-1", test.request))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterPods(t *testing.T) {
+	tests := []struct {
+		name     string
+		snapshot string
+		request  ClusterRecommendationReq
+		check    func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:     "node count raised by the pod limits before the cpu limits",
+			snapshot: "testdata/snapshot.yaml",
+			request:  ClusterRecommendationReq{SumCpu: 8, SumMem: 32, MinNodes: 1, MaxNodes: 20, OnDemandPct: onDemand(100), SumPods: 300},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				// 4 nodes of the small instance type would provide the cpus, but they can only host 29 pods each
+				assert.Equal(t, "m5.large", resp.NodePools[0].VmType.Type)
+				assert.Equal(t, 29, resp.NodePools[0].VmType.MaxPods)
+				assert.Equal(t, 11, resp.Accuracy.RecNodes, "the nodes should be able to host the pods")
+				assert.Equal(t, 319, resp.Accuracy.RecPods)
+			},
+		},
+		{
+			name:     "pods split between on-demand and spot nodes",
+			snapshot: "testdata/snapshot.yaml",
+			request:  ClusterRecommendationReq{SumCpu: 8, SumMem: 32, MinNodes: 1, MaxNodes: 20, OnDemandPct: onDemand(50), SumPods: 300},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.True(t, resp.Accuracy.RecPods >= 300, "the nodes should be able to host the pods")
+				assert.True(t, resp.Accuracy.RecRegularNodes > 0 && resp.Accuracy.RecSpotNodes > 0, "on-demand and spot nodes should be recommended")
+			},
+		},
+		{
+			name:     "pods not taken into account if the pod limits are not reported",
+			snapshot: "testdata/burstable.yaml",
+			request:  ClusterRecommendationReq{SumCpu: 8, SumMem: 32, MinNodes: 1, MaxNodes: 20, OnDemandPct: onDemand(100), SumPods: 300},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"pod limits are not reported on provider [ec2], the requested pods are not taken into account"}, resp.Warnings)
+				assert.Equal(t, 0, resp.Accuracy.RecPods)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs, err := NewFileProductInfoSource(test.snapshot)
+			assert.Nil(t, err, "the snapshot couldn't be loaded")
+			engine, err := NewEngine(fs, WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.RecommendCluster("ec2", "eu-west-1", test.request))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterPricingAsOf(t *testing.T) {
+	fs := mustFileSource(t)
+	req := ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 1, MaxNodes: 8, OnDemandPct: onDemand(50)}
+
+	tests := []struct {
+		name     string
+		pi       ProductInfoSource
+		provider string
+		region   string
+		check    func(resp *ClusterRecommendationResp, before time.Time)
+	}{
+		{
+			name:     "the time the prices were fetched",
+			pi:       &dummyProductInfoSource{},
+			provider: "dummy",
+			region:   "dummyRegion1",
+			check: func(resp *ClusterRecommendationResp, before time.Time) {
+				assert.False(t, resp.PricingAsOf.IsZero(), "the freshness of the prices should be reported")
+				assert.False(t, resp.PricingAsOf.Before(before), "the prices should be fetched for the request")
+				assert.Empty(t, resp.PriceVersion, "the source doesn't version the prices")
+			},
+		},
+		{
+			name:     "the time the snapshot was taken",
+			pi:       fs,
+			provider: "ec2",
+			region:   "eu-west-1",
+			check: func(resp *ClusterRecommendationResp, before time.Time) {
+				assert.Equal(t, fs.SnapshotTime().UTC(), resp.PricingAsOf)
+				assert.Equal(t, fs.PriceVersion("ec2", "eu-west-1"), resp.PriceVersion)
+				assert.NotEmpty(t, resp.PriceVersion)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.pi, WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			before := time.Now()
+			resp, err := engine.RecommendCluster(test.provider, test.region, req)
+			assert.Nil(t, err, "the error should be nil")
+			test.check(resp, before)
+		})
+	}
+}
+
+// shuffledProductInfoSource reports the products of the source in a random order; like the product info service, it
+// neither versions the prices nor reports a snapshot time
+type shuffledProductInfoSource struct {
+	ProductInfoSource
+	rnd *rand.Rand
+}
+
+func (piCli *shuffledProductInfoSource) GetProductDetails(provider string, region string) ([]*models.ProductDetails, error) {
+	products, err := piCli.ProductInfoSource.GetProductDetails(provider, region)
+	if err != nil {
+		return nil, err
+	}
+	shuffled := append([]*models.ProductDetails(nil), products...)
+	for i := len(shuffled) - 1; i > 0; i-- {
+		j := piCli.rnd.Intn(i + 1)
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	}
+	return shuffled, nil
+}
+
+func TestEngine_RecommendClusterDeterministic(t *testing.T) {
+	fs := mustFileSource(t)
+	tests := []struct {
+		name string
+		req  ClusterRecommendationReq
+	}{
+		{
+			// m5.large and m5.xlarge are of the same price per cpu and memory
+			name: "regular nodes of the same price",
+			req:  ClusterRecommendationReq{SumCpu: 8, SumMem: 32, MinNodes: 1, MaxNodes: 8, OnDemandPct: onDemand(100)},
+		},
+		{
+			name: "regular and spot nodes",
+			req:  ClusterRecommendationReq{SumCpu: 32, SumMem: 128, MinNodes: 4, MaxNodes: 16, OnDemandPct: onDemand(50)},
+		},
+		{
+			name: "nodes of the same size",
+			req:  ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 1, MaxNodes: 8, SameSize: true, OnDemandPct: onDemand(100)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var first []byte
+			for i := 0; i < 50; i++ {
+				// a new engine on every run so that the products are fetched in a new order
+				engine, err := NewEngine(&shuffledProductInfoSource{ProductInfoSource: fs, rnd: rand.New(rand.NewSource(int64(i)))}, WithCacheTTL(0))
+				assert.Nil(t, err, "the engine couldn't be created")
+				resp, err := engine.RecommendCluster("ec2", "eu-west-1", test.req)
+				assert.Nil(t, err, "the error should be nil")
+				// the time the prices were fetched is not part of the guarantee
+				assert.False(t, resp.PricingAsOf.IsZero(), "the freshness of the prices should be reported")
+				resp.PricingAsOf = time.Time{}
+				body, err := json.Marshal(resp)
+				assert.Nil(t, err, "the response couldn't be marshalled")
+				if first == nil {
+					first = body
+					continue
+				}
+				if !assert.Equal(t, string(first), string(body), "the responses of the run %d differ", i) {
+					return
+				}
+			}
+		})
+	}
+}
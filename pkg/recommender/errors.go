@@ -0,0 +1,83 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"fmt"
+)
+
+const (
+	// ResourceUnavailable signals that a resource in the request is not offered on the provider in the region
+	ResourceUnavailable = "resource_unavailable"
+	// NoViableInstances signals that none of the instance types satisfy the constraints in the request
+	NoViableInstances = "no_viable_instances"
+	// OverBudget signals that the requested resources can't be covered under the price limit in the request
+	OverBudget = "over_budget"
+	// ProductInfoUnavailable signals that the product info service kept failing after the calls were retried, or failed
+	// with an error that is not retried
+	ProductInfoUnavailable = "product_info_unavailable"
+	// UnknownInstanceType signals that the instance type is not offered on the provider in the region
+	UnknownInstanceType = "unknown_instance_type"
+	// RecommendationTimeout signals that the recommendation couldn't be computed within the request timeout of the engine
+	RecommendationTimeout = "recommendation_timeout"
+	// ProviderUnsupported signals that the provider is not known by the product info source
+	ProviderUnsupported = "provider_unsupported"
+	// RegionNotFound signals that the region is not known on the provider by the product info source
+	RegionNotFound = "region_not_found"
+)
+
+// EngineError is returned by the engine when the recommendation request can't be served; the code identifies the kind of
+// the problem so that callers can react to it without parsing the message
+type EngineError struct {
+	code    string
+	message string
+}
+
+// Error returns the message of the error
+func (e *EngineError) Error() string {
+	return e.message
+}
+
+// Code returns the code identifying the kind of the error
+func (e *EngineError) Code() string {
+	return e.code
+}
+
+// NewError creates a new engine error with the given code and formatted message, product info sources can use it to
+// report the problems the engine should pass on to its callers
+func NewError(code string, format string, args ...interface{}) error {
+	return &EngineError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// ErrorCode returns the code of the engine error, or an empty string if the error is not an engine error; the code of
+// the node groups error is the code of its first group
+func ErrorCode(err error) string {
+	switch e := err.(type) {
+	case *EngineError:
+		return e.code
+	case *ClusterGroupsError:
+		return ErrorCode(e.first)
+	}
+	return ""
+}
+
+// upstreamError wraps the failure of a product info call as ProductInfoUnavailable keeping its message, the engine
+// errors reported by the source are passed on as they are
+func upstreamError(err error) error {
+	if err == nil || ErrorCode(err) != "" {
+		return err
+	}
+	return NewError(ProductInfoUnavailable, "%s", err.Error())
+}
@@ -0,0 +1,173 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"fmt"
+	"sort"
+)
+
+// RejectedCandidate describes an instance type dropped from the candidates of the recommendation
+type RejectedCandidate struct {
+	// Instance type
+	Type string `json:"type"`
+	// The attribute the candidates were selected for
+	Attribute string `json:"attribute"`
+	// The reason the instance type was dropped
+	Reason string `json:"reason"`
+}
+
+// explainedFilter is a vm filter with the description of why it rejects a vm
+type explainedFilter struct {
+	apply  vmFilter
+	reason func(vm VirtualMachine, req ClusterRecommendationReq) string
+}
+
+// explainedFilters returns the filters of the attribute on the provider, in the order they're applied
+func (e *Engine) explainedFilters(attr string, provider string) ([]explainedFilter, error) {
+	// generic filters - not depending on providers and attributes
+	filters := []explainedFilter{
+		{e.includesFilter, func(vm VirtualMachine, req ClusterRecommendationReq) string {
+			return fmt.Sprintf("not included in %v", req.Includes)
+		}},
+		{e.excludesFilter, func(vm VirtualMachine, req ClusterRecommendationReq) string {
+			return "excluded by the request"
+		}},
+		{e.gpuFilter, func(vm VirtualMachine, req ClusterRecommendationReq) string {
+			return "no gpus, gpus are requested"
+		}},
+		{e.storageFilter, func(vm VirtualMachine, req ClusterRecommendationReq) string {
+			return "no local storage, local storage is requested"
+		}},
+		{e.podLimitFilter, func(vm VirtualMachine, req ClusterRecommendationReq) string {
+			return "no pod limit reported, pods are requested"
+		}},
+		{e.storageTypeFilter, func(vm VirtualMachine, req ClusterRecommendationReq) string {
+			return fmt.Sprintf("storage type [%s] not supported, the supported ones are %v", req.StorageType, vm.StorageTypes)
+		}},
+		{e.noBurstableFilter, func(vm VirtualMachine, req ClusterRecommendationReq) string {
+			return "burstable instance type, burstable instances are excluded by the request"
+		}},
+		{e.architectureFilter, func(vm VirtualMachine, req ClusterRecommendationReq) string {
+			return fmt.Sprintf("wrong architecture: [%s], [%s] is requested", vm.Architecture, req.Architecture)
+		}},
+		{e.minGenFilter, func(vm VirtualMachine, req ClusterRecommendationReq) string {
+			return fmt.Sprintf("too old: generation [%d], at least [%d] is requested", vm.Generation, req.MinGen)
+		}},
+		{e.minNodeCpuFilter, func(vm VirtualMachine, req ClusterRecommendationReq) string {
+			return fmt.Sprintf("too small: [%v] cpus per node, the floor is [%v]", vm.Cpus, req.MinNodeCpu)
+		}},
+		{e.minNodeMemFilter, func(vm VirtualMachine, req ClusterRecommendationReq) string {
+			return fmt.Sprintf("too small: [%v] GB memory per node, the floor is [%v]", vm.Mem, req.MinNodeMem)
+		}},
+		{e.maxNodeCpuFilter, func(vm VirtualMachine, req ClusterRecommendationReq) string {
+			return fmt.Sprintf("too large: [%v] cpus per node, the cap is [%v]", vm.Cpus, req.MaxNodeCpu)
+		}},
+		{e.maxNodeMemFilter, func(vm VirtualMachine, req ClusterRecommendationReq) string {
+			return fmt.Sprintf("too large: [%v] GB memory per node, the cap is [%v]", vm.Mem, req.MaxNodeMem)
+		}},
+	}
+
+	// provider specific filters
+	switch provider {
+	case "ec2":
+		filters = append(filters,
+			explainedFilter{e.currentGenFilter, func(vm VirtualMachine, req ClusterRecommendationReq) string {
+				return "not of the current generation, older generations are not allowed"
+			}},
+			explainedFilter{e.burstFilter, func(vm VirtualMachine, req ClusterRecommendationReq) string {
+				return "burst instance type, burst instances are not allowed"
+			}})
+	}
+	if reportsNetworkPerf(provider) {
+		filters = append(filters, explainedFilter{e.ntwPerformanceFilter, func(vm VirtualMachine, req ClusterRecommendationReq) string {
+			return fmt.Sprintf("network performance too low: [%s], at least [%s] is requested", vm.NetworkPerfCat, *req.NetworkPerf)
+		}})
+	}
+
+	// attribute specific filters
+	switch attr {
+	case Cpu:
+		filters = append(filters, explainedFilter{e.minMemRatioFilter, func(vm VirtualMachine, req ClusterRecommendationReq) string {
+			return fmt.Sprintf("too little memory per cpu: [%v] GB, at least [%v] is requested", vm.Mem/vm.Cpus, req.SumMem/req.SumCpu)
+		}})
+	case Memory:
+		filters = append(filters, explainedFilter{e.minCpuRatioFilter, func(vm VirtualMachine, req ClusterRecommendationReq) string {
+			return fmt.Sprintf("too few cpus per GB of memory: [%v], at least [%v] is requested", vm.Cpus/vm.Mem, req.SumCpu/req.SumMem)
+		}})
+	default:
+		return nil, fmt.Errorf("unsupported attribute: [%s]", attr)
+	}
+
+	return filters, nil
+}
+
+// rejectedCandidates lists the instance types of the product info dropped from the candidates of the attribute: the
+// ones with attribute values out of the selected values, the ones rejected by one of the filters and the ones over the
+// maximum spot price
+func (e *Engine) rejectedCandidates(provider string, pi *productInfo, attr string, values []float64, filters []explainedFilter, req ClusterRecommendationReq) []RejectedCandidate {
+	all, err := findVmsWithAttrValues(provider, pi.zones, pi.products, attr, pi.attrValues[attr])
+	if err != nil || len(values) == 0 {
+		return nil
+	}
+	if pi.storage != nil {
+		for i := range all {
+			all[i].Storage = pi.storage[all[i].Type]
+		}
+	}
+	if pi.podLimits != nil {
+		for i := range all {
+			all[i].MaxPods = pi.podLimits[all[i].Type]
+		}
+	}
+	if pi.storageTypes != nil {
+		for i := range all {
+			all[i].StorageTypes = pi.storageTypes[all[i].Type]
+		}
+	}
+
+	// the selected values are sorted
+	min, max := values[0], values[len(values)-1]
+
+	var rejected []RejectedCandidate
+	for _, vm := range all {
+		var reason string
+		switch v := vm.getAttrValue(attr); {
+		case v < min:
+			reason = fmt.Sprintf("too small: [%v] %s per node, at least [%v] is needed", v, attr, min)
+		case v > max:
+			reason = fmt.Sprintf("too large: [%v] %s per node, at most [%v] is allowed", v, attr, max)
+		default:
+			for _, f := range filters {
+				if !f.apply(vm, req) {
+					reason = f.reason(vm, req)
+					break
+				}
+			}
+			// the maximum spot price drops the instance type from the spot candidates only, as spotWorthy does
+			if reason == "" && req.MaxSpotPrice > 0 && req.onDemandPct() < 100 && vm.listSpot() > req.MaxSpotPrice {
+				reason = fmt.Sprintf("over budget: [%v] spot price, the max spot price is [%v], not recommended as spot", vm.listSpot(), req.MaxSpotPrice)
+			}
+		}
+		if reason != "" {
+			rejected = append(rejected, RejectedCandidate{Type: vm.Type, Attribute: attr, Reason: reason})
+		}
+	}
+
+	sort.SliceStable(rejected, func(i, j int) bool {
+		return rejected[i].Type < rejected[j].Type
+	})
+	return rejected
+}
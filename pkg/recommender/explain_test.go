@@ -0,0 +1,237 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"testing"
+
+	"github.com/banzaicloud/productinfo/pkg/productinfo-client/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_rejectedCandidates(t *testing.T) {
+	products := []*models.ProductDetails{
+		{Type: "m5.xlarge", CurrentGen: true, Cpus: 4, Mem: 16, NtwPerfCat: "high"},
+		{Type: "m5.2xlarge", CurrentGen: true, Cpus: 8, Mem: 32, NtwPerfCat: "high", SpotPrice: []*models.ZonePrice{{Zone: "dummyZone1", Price: 0.15}}},
+		{Type: "m5.8xlarge", CurrentGen: true, Cpus: 32, Mem: 128, NtwPerfCat: "extra"},
+		{Type: "m4.2xlarge", CurrentGen: false, Cpus: 8, Mem: 32, NtwPerfCat: "high"},
+		{Type: "m6g.2xlarge", CurrentGen: true, Cpus: 8, Mem: 32, NtwPerfCat: "high"},
+		{Type: "t3.2xlarge", CurrentGen: true, Cpus: 8, Mem: 32, NtwPerfCat: "low", Burst: true},
+		{Type: "c5.2xlarge", CurrentGen: true, Cpus: 8, Mem: 16, NtwPerfCat: "high"},
+		{Type: "p3.2xlarge", CurrentGen: true, Cpus: 8, Mem: 61, Gpus: 1, NtwPerfCat: "high"},
+	}
+	req := ClusterRecommendationReq{SumCpu: 32, SumMem: 128, MinNodes: 2, MaxNodes: 4}
+
+	tests := []struct {
+		name  string
+		attr  string
+		req   func() ClusterRecommendationReq
+		check func(reasons map[string]string)
+	}{
+		{
+			name: "attribute values out of the selected values",
+			attr: Cpu,
+			req:  func() ClusterRecommendationReq { return req },
+			check: func(reasons map[string]string) {
+				assert.Equal(t, "too small: [4] cpu per node, at least [8] is needed", reasons["m5.xlarge"])
+				assert.Equal(t, "too large: [32] cpu per node, at most [8] is allowed", reasons["m5.8xlarge"])
+				assert.NotContains(t, reasons, "m5.2xlarge")
+			},
+		},
+		{
+			name: "older generations and too little memory per cpu rejected by default",
+			attr: Cpu,
+			req:  func() ClusterRecommendationReq { return req },
+			check: func(reasons map[string]string) {
+				assert.Equal(t, "not of the current generation, older generations are not allowed", reasons["m4.2xlarge"])
+				assert.Equal(t, "too little memory per cpu: [2] GB, at least [4] is requested", reasons["c5.2xlarge"])
+			},
+		},
+		{
+			name: "too few cpus per memory",
+			attr: Memory,
+			req: func() ClusterRecommendationReq {
+				r := req
+				r.SumCpu, r.SumMem = 64, 32
+				return r
+			},
+			check: func(reasons map[string]string) {
+				assert.Equal(t, "too few cpus per GB of memory: [0.5], at least [2] is requested", reasons["c5.2xlarge"])
+			},
+		},
+		{
+			name: "included and excluded instance types",
+			attr: Cpu,
+			req: func() ClusterRecommendationReq {
+				r := req
+				r.Includes = []string{"m5.2xlarge", "t3.2xlarge"}
+				r.Excludes = []string{"t3.2xlarge"}
+				return r
+			},
+			check: func(reasons map[string]string) {
+				assert.Equal(t, "not included in [m5.2xlarge t3.2xlarge]", reasons["m6g.2xlarge"])
+				assert.Equal(t, "excluded by the request", reasons["t3.2xlarge"])
+				assert.NotContains(t, reasons, "m5.2xlarge")
+			},
+		},
+		{
+			name: "gpus requested",
+			attr: Cpu,
+			req: func() ClusterRecommendationReq {
+				r := req
+				r.SumGpu = 1
+				return r
+			},
+			check: func(reasons map[string]string) {
+				assert.Equal(t, "no gpus, gpus are requested", reasons["m5.2xlarge"])
+				assert.NotContains(t, reasons, "p3.2xlarge")
+			},
+		},
+		{
+			name: "local storage requested",
+			attr: Cpu,
+			req: func() ClusterRecommendationReq {
+				r := req
+				r.SumStorage = 100
+				return r
+			},
+			check: func(reasons map[string]string) {
+				assert.Equal(t, "no local storage, local storage is requested", reasons["m5.2xlarge"])
+			},
+		},
+		{
+			name: "architecture and generation requested",
+			attr: Cpu,
+			req: func() ClusterRecommendationReq {
+				r := req
+				r.Architecture = Amd64
+				r.MinGen = 5
+				return r
+			},
+			check: func(reasons map[string]string) {
+				assert.Equal(t, "wrong architecture: [arm64], [amd64] is requested", reasons["m6g.2xlarge"])
+				assert.Equal(t, "too old: generation [4], at least [5] is requested", reasons["m4.2xlarge"])
+			},
+		},
+		{
+			name: "burst instances and network performance",
+			attr: Cpu,
+			req: func() ClusterRecommendationReq {
+				r := req
+				r.AllowBurst = boolPointer(false)
+				r.NetworkPerf = stringPointer("medium")
+				return r
+			},
+			check: func(reasons map[string]string) {
+				assert.Equal(t, "burst instance type, burst instances are not allowed", reasons["t3.2xlarge"])
+			},
+		},
+		{
+			name: "network performance too low",
+			attr: Cpu,
+			req: func() ClusterRecommendationReq {
+				r := req
+				r.NetworkPerf = stringPointer("medium")
+				return r
+			},
+			check: func(reasons map[string]string) {
+				assert.Equal(t, "network performance too low: [low], at least [medium] is requested", reasons["t3.2xlarge"])
+			},
+		},
+		{
+			name: "over the max spot price",
+			attr: Cpu,
+			req: func() ClusterRecommendationReq {
+				r := req
+				r.MaxSpotPrice = 0.1
+				return r
+			},
+			check: func(reasons map[string]string) {
+				assert.Equal(t, "over budget: [0.15] spot price, the max spot price is [0.1], not recommended as spot", reasons["m5.2xlarge"])
+			},
+		},
+		{
+			name: "max spot price ignored without spot nodes",
+			attr: Cpu,
+			req: func() ClusterRecommendationReq {
+				r := req
+				r.MaxSpotPrice = 0.1
+				r.OnDemandPct = onDemand(100)
+				return r
+			},
+			check: func(reasons map[string]string) {
+				assert.NotContains(t, reasons, "m5.2xlarge")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := Engine{}
+			pi := &productInfo{
+				attrValues: map[string][]float64{Cpu: {4, 8, 32}, Memory: {16, 32, 61, 128}},
+				zones:      []string{"dummyZone1"},
+				products:   products,
+			}
+			r := test.req()
+			values, err := selectAttrValues(pi.attrValues[test.attr], test.attr, r)
+			assert.Nil(t, err)
+			filters, err := e.explainedFilters(test.attr, "ec2")
+			assert.Nil(t, err)
+
+			reasons := make(map[string]string)
+			for _, rc := range e.rejectedCandidates("ec2", pi, test.attr, values, filters, r) {
+				assert.Equal(t, test.attr, rc.Attribute)
+				reasons[rc.Type] = rc.Reason
+			}
+			test.check(reasons)
+		})
+	}
+}
+
+func TestEngine_RecommendClusterExplain(t *testing.T) {
+	req := ClusterRecommendationReq{SumCpu: 16, SumMem: 32, MinNodes: 1, MaxNodes: 1, Excludes: []string{"type-11"}}
+
+	tests := []struct {
+		name    string
+		explain bool
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name: "rejected candidates omitted by default",
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Nil(t, resp.Rejected, "the rejected candidates should be omitted")
+			},
+		},
+		{
+			name:    "rejected candidates listed on request",
+			explain: true,
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Contains(t, resp.Rejected, RejectedCandidate{Type: "type-11", Attribute: Cpu, Reason: "excluded by the request"})
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(&dummyProductInfoSource{})
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			r := req
+			r.Explain = test.explain
+			test.check(engine.RecommendCluster("dummy", "dummyRegion", r))
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// FailoverResp holds the recommendations in the candidate regions in their order of priority
+// swagger:model FailoverResponse
+type FailoverResp struct {
+	// The region of the highest priority the requested resources can be satisfied in, empty if none of the regions are
+	// feasible
+	Region string `json:"region"`
+	// The candidate regions in their order of priority, infeasible regions included
+	Regions []RegionFailover `json:"regions"`
+}
+
+// RegionFailover holds the recommendation in a candidate region
+type RegionFailover struct {
+	RegionRank
+	// The recommendation in the region, omitted if the region is infeasible
+	Recommendation *ClusterRecommendationResp `json:"recommendation,omitempty"`
+}
+
+// RecommendFailover recommends a cluster in each of the candidate regions and returns the recommendations in the order
+// of the regions, so that they can be tried in turn; the regions are returned with the reasons even if none of them are
+// feasible
+func (e *Engine) RecommendFailover(ctx context.Context, provider string, regions []string, req ClusterRecommendationReq) (*FailoverResp, error) {
+	log.Infof("recommending failover regions. Provider: [%s], regions: %v", provider, regions)
+
+	regions = dedupe(regions)
+	ranking, recs := e.recommendRegions(ctx, provider, regions, req)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	resp := &FailoverResp{Regions: make([]RegionFailover, len(regions))}
+	for i := range regions {
+		resp.Regions[i] = RegionFailover{RegionRank: ranking[i], Recommendation: recs[i]}
+		if resp.Region == "" && ranking[i].Feasible {
+			resp.Region = regions[i]
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,81 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_RecommendFailover(t *testing.T) {
+	pi := &regionalProductInfoSource{multipliers: map[string]float64{"dummyRegion1": 2, "dummyRegion2": 1}}
+	req := ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(50)}
+
+	tests := []struct {
+		name    string
+		regions []string
+		check   func(resp *FailoverResp, err error)
+	}{
+		{
+			name:    "regions kept in the order of priority regardless of the price",
+			regions: []string{"dummyRegion1", "dummyRegion2"},
+			check: func(resp *FailoverResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "dummyRegion1", resp.Region)
+				assert.Equal(t, []string{"dummyRegion1", "dummyRegion2"}, []string{resp.Regions[0].Region, resp.Regions[1].Region})
+				for _, r := range resp.Regions {
+					assert.True(t, r.Feasible, "the region should be feasible")
+					assert.Equal(t, r.Recommendation.Accuracy.RecTotalPrice, r.TotalPrice)
+				}
+				assert.True(t, resp.Regions[0].TotalPrice > resp.Regions[1].TotalPrice, "the primary region should be kept first")
+			},
+		},
+		{
+			name:    "infeasible regions kept in the order",
+			regions: []string{"emptyRegion", "dummyRegion2", "dummyRegion2", "dummyRegion1"},
+			check: func(resp *FailoverResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "dummyRegion2", resp.Region, "the first feasible region should be recommended")
+				assert.Equal(t, 3, len(resp.Regions), "duplicate regions should be listed once")
+				assert.Equal(t, RegionFailover{RegionRank: RegionRank{Region: "emptyRegion", Reason: "could not recommend cluster with the requested resources"}}, resp.Regions[0])
+				assert.Equal(t, "dummyRegion1", resp.Regions[2].Region)
+			},
+		},
+		{
+			name:    "no feasible region",
+			regions: []string{"emptyRegion", "anotherEmptyRegion"},
+			check: func(resp *FailoverResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Empty(t, resp.Region, "no region should be chosen")
+				assert.Equal(t, 2, len(resp.Regions), "the ranking should be kept")
+				for i, region := range []string{"emptyRegion", "anotherEmptyRegion"} {
+					assert.Equal(t, region, resp.Regions[i].Region)
+					assert.False(t, resp.Regions[i].Feasible)
+					assert.NotEmpty(t, resp.Regions[i].Reason, "the reason should be reported")
+					assert.Nil(t, resp.Regions[i].Recommendation)
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(pi)
+			assert.Nil(t, err, "the engine couldn't be created")
+			test.check(engine.RecommendFailover(context.Background(), "dummy", test.regions, req))
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+// the default price tolerance (percentage) of the layouts of the preferred instance families
+const defaultFamilyBonus = 10.0
+
+// ofFamilies returns true if the instance type is of one of the families, a family matches the families of its
+// generations too, eg: m matches m5 and m6i
+func ofFamilies(provider string, vmType string, families []string) bool {
+	family := strings.ToLower(InstanceFamily(provider, vmType))
+	for _, f := range families {
+		f = strings.ToLower(f)
+		if f != "" && (family == f || (strings.HasPrefix(family, f) && unicode.IsDigit(rune(family[len(f)])))) {
+			return true
+		}
+	}
+	return false
+}
+
+// preferredLayout returns true if all the node pools are of the preferred families
+func (req *ClusterRecommendationReq) preferredLayout(provider string, nodePools []NodePool) bool {
+	for _, np := range nodePools {
+		if np.SumNodes > 0 && !ofFamilies(provider, np.VmType.Type, req.PreferredFamilies) {
+			return false
+		}
+	}
+	return true
+}
+
+// preferredTypes returns the instance types of the preferred families among the products, the included ones only if
+// the request includes instance types
+func (req *ClusterRecommendationReq) preferredTypes(provider string, pi *productInfo) []string {
+	var types []string
+	for _, p := range pi.products {
+		if ofFamilies(provider, p.Type, req.PreferredFamilies) && (len(req.Includes) == 0 || contains(req.Includes, p.Type)) {
+			types = append(types, p.Type)
+		}
+	}
+	return types
+}
+
+// preferFamilies lays out the node pools of the request from the instance types of the preferred families, and returns
+// their recommendation if it doesn't cost more than the family bonus on top of the recommendation of all the families;
+// the recommendation of all the families is returned with a warning otherwise
+func (e *Engine) preferFamilies(ctx context.Context, provider string, region string, clusterReq ClusterRecommendationReq,
+	req ClusterRecommendationReq, attributes []string, pi *productInfo, resp *ClusterRecommendationResp) *ClusterRecommendationResp {
+	if req.preferredLayout(provider, resp.NodePools) {
+		return resp
+	}
+
+	types := req.preferredTypes(provider, pi)
+	if len(types) == 0 {
+		resp.setWarnings(append(resp.WarningDetails, newWarning(PreferredFamiliesUnavailable,
+			"no instance type of the preferred families %v is available in region [%s], other families are recommended", req.PreferredFamilies, region)))
+		return resp
+	}
+
+	// the preferred families are laid out as if only their instance types were included
+	clusterReq.Includes, req.Includes = types, types
+	preferred, err := e.layoutCluster(ctx, provider, region, clusterReq, req, attributes, pi)
+	if err != nil {
+		resp.setWarnings(append(resp.WarningDetails, newWarning(PreferredFamiliesUnavailable,
+			"the instance types of the preferred families %v can't satisfy the requested resources, other families are recommended", req.PreferredFamilies)))
+		return resp
+	}
+
+	price, preferredPrice := poolSetPrice(resp.NodePools), poolSetPrice(preferred.NodePools)
+	if preferredPrice > price*(1+e.familyBonus/100) {
+		resp.setWarnings(append(resp.WarningDetails, newWarning(PreferredFamiliesCostlier,
+			"the instance types of the preferred families %v cost [%.1f]%% more than the recommended ones, over the preference bonus of [%v]%%",
+			req.PreferredFamilies, (preferredPrice/price-1)*100, e.familyBonus)))
+		return resp
+	}
+	return preferred
+}
@@ -0,0 +1,116 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ofFamilies(t *testing.T) {
+	tests := []struct {
+		provider string
+		vmType   string
+		families []string
+		of       bool
+	}{
+		{provider: "ec2", vmType: "m5.large", families: []string{"m5"}, of: true},
+		{provider: "ec2", vmType: "m6i.large", families: []string{"m"}, of: true},
+		{provider: "ec2", vmType: "mac1.metal", families: []string{"m"}, of: false},
+		{provider: "ec2", vmType: "c5.xlarge", families: []string{"m", "r"}, of: false},
+		{provider: "gce", vmType: "n1-standard-4", families: []string{"n1-standard"}, of: true},
+		{provider: "azure", vmType: "Standard_D2s_v3", families: []string{"ds_v3"}, of: true},
+	}
+	for _, test := range tests {
+		t.Run(test.vmType, func(t *testing.T) {
+			assert.Equal(t, test.of, ofFamilies(test.provider, test.vmType, test.families))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterPreferredFamilies(t *testing.T) {
+	// the c5 nodes are the cheapest for the cpus, the m5 nodes cost 11.5% more
+	req := ClusterRecommendationReq{SumCpu: 16, SumMem: 32, MinNodes: 1, MaxNodes: 8, OnDemandPct: onDemand(100)}
+
+	tests := []struct {
+		name     string
+		bonus    float64
+		families []string
+		maxNodes int
+		check    func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:     "preferred family recommended within the bonus",
+			bonus:    15,
+			families: []string{"m"},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Empty(t, resp.WarningDetails)
+				for _, np := range resp.NodePools {
+					assert.Equal(t, "m5", InstanceFamily("ec2", np.VmType.Type))
+				}
+				assert.Equal(t, []string{"m"}, resp.Request.PreferredFamilies)
+				assert.Empty(t, resp.Request.Includes, "the request should be echoed as requested")
+			},
+		},
+		{
+			name:     "cheapest family recommended if the preferred one costs more than the bonus",
+			bonus:    defaultFamilyBonus,
+			families: []string{"m"},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "c5.xlarge", resp.NodePools[0].VmType.Type)
+				assert.Equal(t, []Warning{{Code: PreferredFamiliesCostlier,
+					Message: "the instance types of the preferred families [m] cost [11.5]% more than the recommended ones, over the preference bonus of [10]%"}}, resp.WarningDetails)
+			},
+		},
+		{
+			name:     "other families recommended if the preferred one can't satisfy the request",
+			bonus:    defaultFamilyBonus,
+			families: []string{"r5"},
+			maxNodes: 2,
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "m5.2xlarge", resp.NodePools[0].VmType.Type)
+				assert.Equal(t, []Warning{{Code: PreferredFamiliesUnavailable,
+					Message: "the instance types of the preferred families [r5] can't satisfy the requested resources, other families are recommended"}}, resp.WarningDetails)
+			},
+		},
+		{
+			name:     "other families recommended if the preferred one is not available",
+			bonus:    defaultFamilyBonus,
+			families: []string{"x1"},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "c5.xlarge", resp.NodePools[0].VmType.Type)
+				assert.Equal(t, PreferredFamiliesUnavailable, resp.WarningDetails[0].Code)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(mustFileSource(t), WithCacheTTL(0), WithFamilyBonus(test.bonus))
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			r := req
+			r.PreferredFamilies = test.families
+			if test.maxNodes > 0 {
+				r.MaxNodes = test.maxNodes
+			}
+			test.check(engine.RecommendCluster("ec2", "eu-west-1", r))
+		})
+	}
+}
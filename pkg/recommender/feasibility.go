@@ -0,0 +1,152 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/banzaicloud/productinfo/pkg/productinfo-client/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// Feasibility tells whether a recommendation request can be served, checked without laying out the node pools
+// swagger:model FeasibilityResponse
+type Feasibility struct {
+	// True if the request is well-formed and there are viable instance types for it
+	Valid bool `json:"valid"`
+	// The reasons the request can't be served
+	Reasons []string `json:"reasons,omitempty"`
+	// Warnings about the parts of the request that wouldn't be taken into account
+	Warnings []string `json:"warnings,omitempty"`
+	// The warnings with the codes identifying their kind, in the order of the messages
+	WarningDetails []Warning `json:"warningDetails,omitempty"`
+}
+
+// CheckFeasibility checks whether the request can be served on the provider in the region: whether any instance type
+// satisfies the constraints of the request and whether the largest of them can provide the requested resources on the
+// maximum number of nodes; the node pools are not laid out, so a feasible request may still fail on other limits,
+// eg: the max price
+func (e *Engine) CheckFeasibility(ctx context.Context, provider string, region string, req ClusterRecommendationReq) (*Feasibility, error) {
+	log.Infof("checking feasibility of recommendation. Provider: [%s], region: [%s]", provider, region)
+
+	req = e.withDefaults(provider, req)
+	req, attributes := req.remaining()
+	if len(attributes) == 0 {
+		return &Feasibility{Valid: true}, nil
+	}
+	if req.MinGen > 0 && !generationReported(provider) {
+		return &Feasibility{Reasons: []string{fmt.Sprintf("the generation of the instance types is not known on provider [%s], the minimum generation can't be applied", provider)}}, nil
+	}
+
+	// the attribute values are not needed, only the products are checked
+	pi, err := e.fetchProductInfo(ctx, provider, region, nil, req)
+	if err != nil {
+		return nil, err
+	}
+	if pi.zonesErr != nil {
+		return nil, pi.zonesErr
+	}
+	if len(req.ExcludeZones) > 0 {
+		if pi.zones, err = excludeZones(region, pi, req.ExcludeZones); err != nil {
+			if ErrorCode(err) == "" {
+				return nil, err
+			}
+			return &Feasibility{Reasons: []string{err.Error()}}, nil
+		}
+	}
+	if pi.productsErr != nil {
+		return nil, pi.productsErr
+	}
+	warnings, err := req.ignoreUnreported(provider, region, pi)
+	if err != nil {
+		return nil, err
+	}
+	if req.NodeCount > 0 {
+		req.MinNodes, req.MaxNodes = req.NodeCount, req.NodeCount
+	}
+
+	f := &Feasibility{Warnings: warningMessages(warnings), WarningDetails: warnings}
+	if needsCandidateCheck(req) {
+		if err := checkCandidates(provider, region, pi, req); err != nil {
+			if ErrorCode(err) == "" {
+				return nil, err
+			}
+			f.Reasons = append(f.Reasons, err.Error())
+			return f, nil
+		}
+	}
+
+	// the instance types passing the filters of any of the attributes are viable
+	var (
+		viable              int
+		maxCpus, maxMem     float64
+		cpuType, memoryType string
+	)
+	for _, attr := range []string{Cpu, Memory} {
+		filters, _ := e.filtersForAttr(attr, provider)
+		vms, err := e.recommendVms(provider, region, pi, attr, productAttrValues(pi.products, attr), filters, req)
+		if err != nil {
+			return nil, err
+		}
+		viable += len(vms)
+		for _, vm := range vms {
+			if vm.Cpus > maxCpus {
+				maxCpus, cpuType = vm.Cpus, vm.Type
+			}
+			if vm.Mem > maxMem {
+				maxMem, memoryType = vm.Mem, vm.Type
+			}
+		}
+	}
+
+	if viable == 0 {
+		f.Reasons = append(f.Reasons, fmt.Sprintf("there are no viable instance types for the request on provider [%s] in region [%s]", provider, region))
+		return f, nil
+	}
+	nodes := float64(req.MaxNodes)
+	if maxCpus*nodes < req.SumCpu {
+		f.Reasons = append(f.Reasons, fmt.Sprintf("the requested [%v] cpus can't be provided by [%d] nodes of the largest viable instance type [%s]",
+			req.SumCpu, req.MaxNodes, cpuType))
+	}
+	if maxMem*nodes < req.SumMem {
+		f.Reasons = append(f.Reasons, fmt.Sprintf("the requested [%v] GB memory can't be provided by [%d] nodes of the largest viable instance type [%s]",
+			req.SumMem, req.MaxNodes, memoryType))
+	}
+	f.Valid = len(f.Reasons) == 0
+	return f, nil
+}
+
+// productAttrValues returns the distinct values of the attribute of the products in ascending order
+func productAttrValues(products []*models.ProductDetails, attr string) []float64 {
+	seen := make(map[float64]bool)
+	var values []float64
+	for _, p := range products {
+		var v float64
+		switch attr {
+		case Cpu:
+			v = p.Cpus
+		case Memory:
+			v = p.Mem
+		}
+		if !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	sort.Float64s(values)
+	return values
+}
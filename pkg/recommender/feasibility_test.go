@@ -0,0 +1,84 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_CheckFeasibility(t *testing.T) {
+	engine, err := NewEngine(mustFileSource(t))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	tests := []struct {
+		name    string
+		request ClusterRecommendationReq
+		check   func(f *Feasibility, err error)
+	}{
+		{
+			name:    "feasible request",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 1, MaxNodes: 8, OnDemandPct: onDemand(50)},
+			check: func(f *Feasibility, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.True(t, f.Valid, "the request should be feasible")
+				assert.Empty(t, f.Reasons)
+			},
+		},
+		{
+			name:    "too few nodes for the resources",
+			request: ClusterRecommendationReq{SumCpu: 40, SumMem: 64, MinNodes: 1, MaxNodes: 4, OnDemandPct: onDemand(50)},
+			check: func(f *Feasibility, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.False(t, f.Valid, "the request shouldn't be feasible")
+				assert.Equal(t, []string{"the requested [40] cpus can't be provided by [4] nodes of the largest viable instance type [m5.2xlarge]"}, f.Reasons)
+			},
+		},
+		{
+			name:    "the fixed number of nodes counts",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 200, MinNodes: 1, MaxNodes: 8, NodeCount: 2, OnDemandPct: onDemand(50)},
+			check: func(f *Feasibility, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.False(t, f.Valid, "the request shouldn't be feasible")
+				assert.Equal(t, []string{"the requested [200] GB memory can't be provided by [2] nodes of the largest viable instance type [r5.xlarge]"}, f.Reasons)
+			},
+		},
+		{
+			name:    "no instance types with the requested resources",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, SumGpu: 2, MinNodes: 1, MaxNodes: 8, OnDemandPct: onDemand(50)},
+			check: func(f *Feasibility, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.False(t, f.Valid, "the request shouldn't be feasible")
+				assert.Equal(t, []string{"there are no instance types with gpus on provider [ec2] in region [eu-west-1]"}, f.Reasons)
+			},
+		},
+		{
+			name:    "all instance types filtered",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 1, MaxNodes: 8, OnDemandPct: onDemand(50), Includes: []string{"c5.xlarge"}, Excludes: []string{"c5.xlarge"}},
+			check: func(f *Feasibility, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.False(t, f.Valid, "the request shouldn't be feasible")
+				assert.Equal(t, 1, len(f.Reasons))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(engine.CheckFeasibility(context.Background(), "ec2", "eu-west-1", test.request))
+		})
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/banzaicloud/productinfo/pkg/productinfo-client/models"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// the default maximum number of parallel product info calls of a recommendation
+	defaultWorkers = 10
+	// the default time limit for fetching the product info of a recommendation
+	defaultFetchTimeout = 30 * time.Second
+)
+
+// productInfo holds the product info fetched for a recommendation, errors are kept per call so that they can be
+// reported in the same order as the calls would have been made sequentially
+type productInfo struct {
+	attrValues      map[string][]float64
+	attrValuesErr   map[string]error
+	zones           []string
+	zonesErr        error
+	products        []*models.ProductDetails
+	productsErr     error
+	storage         map[string]float64
+	storageErr      error
+	podLimits       map[string]int
+	podLimitsErr    error
+	customPrices    *CustomMachinePrices
+	customPricesErr error
+	storageTypes    map[string][]string
+	storageTypesErr error
+	spotVariance    map[string]float64
+	variancesErr    error
+	interruptions   map[string]float64
+	interruptErr    error
+	reserved        map[string]float64
+	reservedErr     error
+	// the time the product info was fetched
+	fetched time.Time
+}
+
+// fetchProductInfo fetches the attribute values, zones and products required to recommend a cluster concurrently,
+// running at most e.workers calls at a time and retrying the failed ones as set by e.retry; the local storage, the pod limits,
+// the custom machine prices, the storage types, the spot price variances, the spot interruption rates and the reserved prices are fetched too if the request needs them and the source reports them.
+// The attribute values, zones and products are served from the catalog cache while the version of the prices is unchanged,
+// or for the cache ttl if the source doesn't version the prices
+func (e *Engine) fetchProductInfo(ctx context.Context, provider string, region string, attributes []string, req ClusterRecommendationReq) (*productInfo, error) {
+	var (
+		zones = req.Zones
+		pi    = &productInfo{
+			attrValues:    make(map[string][]float64, len(attributes)),
+			attrValuesErr: make(map[string]error, len(attributes)),
+			zones:         zones,
+		}
+		// the catalog of the region is cached by the version of the prices, or for the cache ttl if they aren't versioned
+		version = e.PriceVersion(provider, region)
+		cached  catalog
+		fetched = catalog{version: version, attrValues: make(map[string][]float64)}
+		mu      sync.Mutex
+		tasks   []func()
+	)
+	// the unversioned catalog may be stale, the requests asking for fresh product info refresh it
+	if e.catalog.enabled(version) && (version != "" || !req.NoCache) {
+		cached = e.catalog.get(provider, region, version)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.fetchTimeout)
+	defer cancel()
+
+	for _, attr := range attributes {
+		if values, ok := cached.attrValues[attr]; ok {
+			pi.attrValues[attr] = values
+			continue
+		}
+		attr := attr
+		tasks = append(tasks, func() {
+			var values []float64
+			err := e.withRetry(ctx, "get attribute values", func() (err error) {
+				values, err = e.piSource.GetAttributeValues(provider, region, attr)
+				return
+			})
+			mu.Lock()
+			pi.attrValues[attr], pi.attrValuesErr[attr] = values, err
+			if err == nil {
+				fetched.attrValues[attr] = values
+			}
+			mu.Unlock()
+		})
+	}
+
+	if len(zones) == 0 && cached.zones != nil {
+		pi.zones = cached.zones
+	} else if len(zones) == 0 {
+		tasks = append(tasks, func() {
+			var z []string
+			err := e.withRetry(ctx, "describe region", func() (err error) {
+				z, err = e.piSource.GetRegion(provider, region)
+				return
+			})
+			mu.Lock()
+			pi.zones, pi.zonesErr = z, err
+			if err == nil {
+				fetched.zones = z
+			}
+			mu.Unlock()
+		})
+	}
+
+	if cached.products != nil {
+		pi.products = cached.products
+	} else {
+		tasks = append(tasks, func() {
+			var products []*models.ProductDetails
+			err := e.withRetry(ctx, "get product details", func() (err error) {
+				products, err = e.piSource.GetProductDetails(provider, region)
+				return
+			})
+			mu.Lock()
+			pi.products, pi.productsErr = products, err
+			if err == nil {
+				fetched.products = products
+			}
+			mu.Unlock()
+		})
+	}
+
+	if ls, ok := e.piSource.(LocalStorageSource); ok && req.SumStorage > 0 {
+		tasks = append(tasks, func() {
+			var st map[string]float64
+			err := e.withRetry(ctx, "get local storage", func() (err error) {
+				st, err = ls.GetLocalStorage(provider, region)
+				return
+			})
+			mu.Lock()
+			pi.storage, pi.storageErr = st, err
+			mu.Unlock()
+		})
+	}
+
+	if ps, ok := e.piSource.(PodLimitSource); ok && req.SumPods > 0 {
+		tasks = append(tasks, func() {
+			var pl map[string]int
+			err := e.withRetry(ctx, "get pod limits", func() (err error) {
+				pl, err = ps.GetPodLimits(provider, region)
+				return
+			})
+			mu.Lock()
+			pi.podLimits, pi.podLimitsErr = pl, err
+			mu.Unlock()
+		})
+	}
+
+	if cs, ok := e.piSource.(CustomMachinePriceSource); ok && req.CustomTypes && provider == customTypesProvider {
+		tasks = append(tasks, func() {
+			var cp *CustomMachinePrices
+			err := e.withRetry(ctx, "get custom machine prices", func() (err error) {
+				cp, err = cs.GetCustomMachinePrices(provider, region)
+				return
+			})
+			mu.Lock()
+			pi.customPrices, pi.customPricesErr = cp, err
+			mu.Unlock()
+		})
+	}
+
+	if ss, ok := e.piSource.(StorageTypeSource); ok && req.StorageType != "" {
+		tasks = append(tasks, func() {
+			var st map[string][]string
+			err := e.withRetry(ctx, "get storage types", func() (err error) {
+				st, err = ss.GetStorageTypes(provider, region)
+				return
+			})
+			mu.Lock()
+			pi.storageTypes, pi.storageTypesErr = st, err
+			mu.Unlock()
+		})
+	}
+
+	if vs, ok := e.piSource.(SpotPriceVarianceSource); ok && req.stableSpot() {
+		tasks = append(tasks, func() {
+			var v map[string]float64
+			err := e.withRetry(ctx, "get spot price variance", func() (err error) {
+				v, err = vs.GetSpotPriceVariance(provider, region)
+				return
+			})
+			mu.Lock()
+			pi.spotVariance, pi.variancesErr = v, err
+			mu.Unlock()
+		})
+	}
+
+	if is, ok := e.piSource.(SpotInterruptionSource); ok && req.interruptionsFiltered() {
+		tasks = append(tasks, func() {
+			var r map[string]float64
+			err := e.withRetry(ctx, "get spot interruption rates", func() (err error) {
+				r, err = is.GetSpotInterruptionRates(provider, region)
+				return
+			})
+			mu.Lock()
+			pi.interruptions, pi.interruptErr = r, err
+			mu.Unlock()
+		})
+	}
+
+	if rs, ok := e.piSource.(ReservedPriceSource); ok && req.CommitmentPct > 0 {
+		tasks = append(tasks, func() {
+			var r map[string]float64
+			err := e.withRetry(ctx, "get reserved prices", func() (err error) {
+				r, err = rs.GetReservedPrices(provider, region, req.commitmentTerm())
+				return
+			})
+			mu.Lock()
+			pi.reserved, pi.reservedErr = r, err
+			mu.Unlock()
+		})
+	}
+
+	if err := e.runTasks(ctx, tasks); err != nil {
+		log.WithError(err).Errorf("couldn't fetch product info. region: %s, provider: %s", region, provider)
+		return nil, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	pi.fetched = time.Now().UTC()
+	if cached.products != nil {
+		// the prices are as fresh as the cached products
+		pi.fetched = cached.fetched
+	}
+	if e.catalog.enabled(version) {
+		fetched.fetched = pi.fetched
+		e.catalog.update(provider, region, fetched)
+	}
+	return pi, nil
+}
+
+// runTasks runs the tasks on a pool of e.workers goroutines and waits for all of them to finish,
+// returns the error of the context if it's done before that; the remaining tasks are not started then, the running
+// ones can't be interrupted as the source calls take no context, they finish in the background
+func (e *Engine) runTasks(ctx context.Context, tasks []func()) error {
+	var (
+		wg   sync.WaitGroup
+		done = make(chan struct{})
+		sem  = make(chan struct{}, e.workers)
+	)
+
+	wg.Add(len(tasks))
+	go func() {
+		for _, task := range tasks {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				// don't start the remaining tasks
+				wg.Done()
+				continue
+			}
+			go func(task func()) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				task()
+			}(task)
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/productinfo/pkg/productinfo-client/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// slowProductInfoSource simulates the latency of the product info service
+type slowProductInfoSource struct {
+	dummyProductInfoSource
+	latency time.Duration
+}
+
+func (piCli *slowProductInfoSource) GetProviders() ([]string, error) {
+	time.Sleep(piCli.latency)
+	return piCli.dummyProductInfoSource.GetProviders()
+}
+
+func (piCli *slowProductInfoSource) GetAttributeValues(provider string, region string, attr string) ([]float64, error) {
+	time.Sleep(piCli.latency)
+	return piCli.dummyProductInfoSource.GetAttributeValues(provider, region, attr)
+}
+
+func (piCli *slowProductInfoSource) GetRegion(provider string, region string) ([]string, error) {
+	time.Sleep(piCli.latency)
+	return piCli.dummyProductInfoSource.GetRegion(provider, region)
+}
+
+func (piCli *slowProductInfoSource) GetProductDetails(provider string, region string) ([]*models.ProductDetails, error) {
+	time.Sleep(piCli.latency)
+	return piCli.dummyProductInfoSource.GetProductDetails(provider, region)
+}
+
+func TestNewEngine(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []EngineOption
+		check func(e *Engine, err error)
+	}{
+		{
+			name: "defaults",
+			check: func(e *Engine, err error) {
+				assert.Nil(t, err, "the engine should be created")
+				assert.Equal(t, defaultWorkers, e.workers)
+				assert.Equal(t, defaultFetchTimeout, e.fetchTimeout)
+				assert.Equal(t, defaultCacheTTL, e.cache.ttl)
+				assert.Equal(t, defaultRetryPolicy, e.retry)
+			},
+		},
+		{
+			name: "options applied",
+			opts: []EngineOption{WithWorkers(3), WithFetchTimeout(time.Second), WithCacheTTL(time.Minute), WithMaxAttempts(5)},
+			check: func(e *Engine, err error) {
+				assert.Nil(t, err, "the engine should be created")
+				assert.Equal(t, 3, e.workers)
+				assert.Equal(t, time.Second, e.fetchTimeout)
+				assert.Equal(t, time.Minute, e.cache.ttl)
+				assert.Equal(t, 5, e.retry.MaxAttempts)
+			},
+		},
+		{
+			name: "caching disabled",
+			opts: []EngineOption{WithCacheTTL(0)},
+			check: func(e *Engine, err error) {
+				assert.Nil(t, err, "the engine should be created")
+				assert.Nil(t, e.cache, "the cache should be disabled")
+			},
+		},
+		{
+			name: "error - invalid number of workers",
+			opts: []EngineOption{WithWorkers(0)},
+			check: func(e *Engine, err error) {
+				assert.Nil(t, e, "the engine should be nil")
+				assert.EqualError(t, err, "invalid number of workers: 0")
+			},
+		},
+		{
+			name: "error - invalid fetch timeout",
+			opts: []EngineOption{WithFetchTimeout(0)},
+			check: func(e *Engine, err error) {
+				assert.Nil(t, e, "the engine should be nil")
+				assert.EqualError(t, err, "invalid fetch timeout: 0s")
+			},
+		},
+		{
+			name: "error - invalid cache ttl",
+			opts: []EngineOption{WithCacheTTL(-time.Second)},
+			check: func(e *Engine, err error) {
+				assert.Nil(t, e, "the engine should be nil")
+				assert.EqualError(t, err, "invalid cache ttl: -1s")
+			},
+		},
+		{
+			name: "error - invalid request timeout",
+			opts: []EngineOption{WithRequestTimeout(0)},
+			check: func(e *Engine, err error) {
+				assert.Nil(t, e, "the engine should be nil")
+				assert.EqualError(t, err, "invalid request timeout: 0s")
+			},
+		},
+		{
+			name: "error - invalid number of attempts",
+			opts: []EngineOption{WithMaxAttempts(0)},
+			check: func(e *Engine, err error) {
+				assert.Nil(t, e, "the engine should be nil")
+				assert.EqualError(t, err, "invalid number of attempts: 0")
+			},
+		},
+		{
+			name: "error - invalid retry jitter",
+			opts: []EngineOption{WithRetryPolicy(RetryPolicy{MaxAttempts: 3, Jitter: 1.5})},
+			check: func(e *Engine, err error) {
+				assert.Nil(t, e, "the engine should be nil")
+				assert.EqualError(t, err, "invalid retry jitter: 1.5")
+			},
+		},
+		{
+			name: "error - invalid default on-demand percentage",
+			opts: []EngineOption{WithDefaultOnDemandPct(120)},
+			check: func(e *Engine, err error) {
+				assert.Nil(t, e, "the engine should be nil")
+				assert.EqualError(t, err, "invalid default on-demand percentage: 120")
+			},
+		},
+		{
+			name: "error - invalid new generation epsilon",
+			opts: []EngineOption{WithNewGenEpsilon(-1)},
+			check: func(e *Engine, err error) {
+				assert.Nil(t, e, "the engine should be nil")
+				assert.EqualError(t, err, "invalid new generation epsilon: -1")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(NewEngine(&dummyProductInfoSource{}, test.opts...))
+		})
+	}
+}
+
+func TestEngine_fetchProductInfo(t *testing.T) {
+	tests := []struct {
+		name  string
+		pi    ProductInfoSource
+		zones []string
+		opts  []EngineOption
+		check func(pi *productInfo, err error)
+	}{
+		{
+			name: "product info fetched for every attribute",
+			pi:   &dummyProductInfoSource{},
+			check: func(pi *productInfo, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []float64{15, 16, 17}, pi.attrValues[Cpu])
+				assert.Equal(t, []float64{15, 16, 17}, pi.attrValues[Memory])
+				assert.Equal(t, []string{"dummyZone1", "dummyZone2", "dummyZone3"}, pi.zones)
+				assert.Equal(t, 10, len(pi.products))
+			},
+		},
+		{
+			name:  "zones in the request are not fetched",
+			pi:    &dummyProductInfoSource{DescribeRegionError},
+			zones: []string{"dummyZone1"},
+			check: func(pi *productInfo, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Nil(t, pi.zonesErr, "the zones should not be fetched")
+				assert.Equal(t, []string{"dummyZone1"}, pi.zones)
+			},
+		},
+		{
+			name: "errors are kept per call",
+			pi:   &dummyProductInfoSource{ProductDetailsError},
+			check: func(pi *productInfo, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Nil(t, pi.attrValuesErr[Cpu])
+				assert.EqualError(t, pi.productsErr, ProductDetailsError)
+			},
+		},
+		{
+			name: "serial fetch with a single worker",
+			pi:   &slowProductInfoSource{latency: 10 * time.Millisecond},
+			opts: []EngineOption{WithWorkers(1)},
+			check: func(pi *productInfo, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, 10, len(pi.products))
+			},
+		},
+		{
+			name: "error - product info not fetched in time",
+			pi:   &slowProductInfoSource{latency: 100 * time.Millisecond},
+			opts: []EngineOption{WithFetchTimeout(10 * time.Millisecond)},
+			check: func(pi *productInfo, err error) {
+				assert.Nil(t, pi, "the product info should be nil")
+				assert.Equal(t, context.DeadlineExceeded, err)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.pi, test.opts...)
+			assert.Nil(t, err, "the engine couldn't be created")
+			test.check(engine.fetchProductInfo(context.Background(), "dummy", "dummyRegion1", []string{Cpu, Memory}, ClusterRecommendationReq{Zones: test.zones}))
+		})
+	}
+}
+
+func BenchmarkEngine_RecommendCluster(b *testing.B) {
+	req := ClusterRecommendationReq{
+		MinNodes:    5,
+		MaxNodes:    10,
+		SumMem:      100,
+		SumCpu:      100,
+		OnDemandPct: onDemand(50),
+	}
+	pi := &slowProductInfoSource{latency: time.Millisecond}
+
+	benchmarks := []struct {
+		name    string
+		workers int
+	}{
+		{name: "serial", workers: 1},
+		{name: "parallel", workers: defaultWorkers},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			engine, err := NewEngine(pi, WithWorkers(bm.workers), WithCacheTTL(0))
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := engine.RecommendCluster("dummy", "dummyRegion1", req); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestEngine_RecommendClusterCtx(t *testing.T) {
+	req := ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10}
+
+	tests := []struct {
+		name  string
+		ctx   func() (context.Context, context.CancelFunc)
+		opts  []EngineOption
+		check func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name: "recommendation ready before the deadline",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Second)
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.NotNil(t, resp, "the recommendation should be returned")
+			},
+		},
+		{
+			name: "error - request cancelled by the caller",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				go func() {
+					time.Sleep(10 * time.Millisecond)
+					cancel()
+				}()
+				return ctx, cancel
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the recommendation should be nil")
+				assert.Equal(t, context.Canceled, err)
+			},
+		},
+		{
+			name: "error - deadline of the caller exceeded",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 10*time.Millisecond)
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the recommendation should be nil")
+				assert.Equal(t, context.DeadlineExceeded, err)
+			},
+		},
+		{
+			name: "error - request timeout of the engine exceeded",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Second)
+			},
+			opts: []EngineOption{WithRequestTimeout(10 * time.Millisecond)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the recommendation should be nil")
+				assert.Equal(t, RecommendationTimeout, ErrorCode(err))
+				assert.EqualError(t, err, "the recommendation couldn't be computed in [10ms], the request is aborted")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(&slowProductInfoSource{latency: 100 * time.Millisecond}, append(test.opts, WithCacheTTL(0))...)
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			ctx, cancel := test.ctx()
+			defer cancel()
+			test.check(engine.RecommendClusterCtx(ctx, "dummy", "dummyRegion1", req))
+		})
+	}
+}
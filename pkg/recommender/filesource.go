@@ -0,0 +1,285 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/banzaicloud/productinfo/pkg/productinfo-client/models"
+	"github.com/ghodss/yaml"
+)
+
+// ProductInfoSnapshot is the product info of the providers saved to a JSON or YAML file,
+// the schema of the file is api/snapshot-schema.json
+type ProductInfoSnapshot struct {
+	// The snapshot of the providers by provider id
+	Providers map[string]ProviderSnapshot `json:"providers"`
+}
+
+// ProviderSnapshot is the product info of the regions of a provider
+type ProviderSnapshot struct {
+	// The snapshot of the regions by region id
+	Regions map[string]RegionSnapshot `json:"regions"`
+}
+
+// RegionSnapshot is the product info of a region
+type RegionSnapshot struct {
+	// The display name of the region
+	Name string `json:"name,omitempty"`
+	// The availability zones of the region
+	Zones []string `json:"zones"`
+	// The instance types available in the region, with their prices
+	Products []*models.ProductDetails `json:"products"`
+	// The storage classes supported by the instance types by instance type, optional
+	StorageTypes map[string][]string `json:"storageTypes,omitempty"`
+	// The maximum number of pods on the instance types by instance type, optional
+	PodLimits map[string]int `json:"podLimits,omitempty"`
+	// The prices of the resources of the custom machine types, optional
+	CustomMachinePrices *CustomMachinePrices `json:"customMachinePrices,omitempty"`
+	// The local storage (GB) of the instance types by instance type, optional
+	LocalStorage map[string]float64 `json:"localStorage,omitempty"`
+	// The variance of the recent spot prices of the instance types by instance type, optional
+	SpotPriceVariance map[string]float64 `json:"spotPriceVariance,omitempty"`
+	// The effective hourly reserved prices of the instance types by commitment term and instance type, optional
+	ReservedPrices map[string]map[string]float64 `json:"reservedPrices,omitempty"`
+	// The monthly interruption rates (percentage) of the spot instances of the instance types by instance type, optional
+	SpotInterruptionRates map[string]float64 `json:"spotInterruptionRates,omitempty"`
+}
+
+// FileProductInfoSource serves the product info from a snapshot file instead of the product info service,
+// so that recommendations can be made where the service is unreachable
+type FileProductInfoSource struct {
+	snapshot ProductInfoSnapshot
+	// the digest of the snapshot file
+	version string
+	// the modification time of the snapshot file
+	taken time.Time
+}
+
+var (
+	_ ProductInfoSource        = (*FileProductInfoSource)(nil)
+	_ PriceVersionSource       = (*FileProductInfoSource)(nil)
+	_ StorageTypeSource        = (*FileProductInfoSource)(nil)
+	_ PodLimitSource           = (*FileProductInfoSource)(nil)
+	_ SnapshotSource           = (*FileProductInfoSource)(nil)
+	_ CustomMachinePriceSource = (*FileProductInfoSource)(nil)
+	_ LocalStorageSource       = (*FileProductInfoSource)(nil)
+	_ SpotPriceVarianceSource  = (*FileProductInfoSource)(nil)
+	_ ReservedPriceSource      = (*FileProductInfoSource)(nil)
+	_ SpotInterruptionSource   = (*FileProductInfoSource)(nil)
+)
+
+// NewFileProductInfoSource loads the product info snapshot from the JSON or YAML file at the path
+func NewFileProductInfoSource(path string) (*FileProductInfoSource, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the product info snapshot: %s", err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the product info snapshot: %s", err)
+	}
+
+	var snapshot ProductInfoSnapshot
+	// YAML is a superset of JSON, both formats are accepted
+	if err := yaml.Unmarshal(b, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid product info snapshot [%s]: %s", path, err)
+	}
+	if len(snapshot.Providers) == 0 {
+		return nil, fmt.Errorf("invalid product info snapshot [%s]: no providers", path)
+	}
+	for provider, ps := range snapshot.Providers {
+		for region, rs := range ps.Regions {
+			for _, p := range rs.Products {
+				if p == nil || p.Type == "" {
+					return nil, fmt.Errorf("invalid product info snapshot [%s]: product without type in region [%s] of provider [%s]", path, region, provider)
+				}
+			}
+		}
+	}
+	digest := sha256.Sum256(b)
+	return &FileProductInfoSource{snapshot: snapshot, version: hex.EncodeToString(digest[:]), taken: fi.ModTime()}, nil
+}
+
+// SnapshotTime returns the modification time of the snapshot file
+func (fs *FileProductInfoSource) SnapshotTime() time.Time {
+	return fs.taken
+}
+
+// PriceVersion identifies the version of the prices by the digest of the snapshot file, the same in all the regions
+func (fs *FileProductInfoSource) PriceVersion(provider string, region string) string {
+	return fs.version
+}
+
+// region returns the snapshot of the region of the provider
+func (fs *FileProductInfoSource) region(provider string, region string) (*RegionSnapshot, error) {
+	ps, ok := fs.snapshot.Providers[provider]
+	if !ok {
+		return nil, NewError(ProviderUnsupported, "provider [%s] is not in the product info snapshot", provider)
+	}
+	rs, ok := ps.Regions[region]
+	if !ok {
+		return nil, NewError(RegionNotFound, "region [%s] of provider [%s] is not in the product info snapshot", region, provider)
+	}
+	return &rs, nil
+}
+
+// GetProviders retrieves the identifiers of the providers in the snapshot
+func (fs *FileProductInfoSource) GetProviders() ([]string, error) {
+	var providers []string
+	for p := range fs.snapshot.Providers {
+		providers = append(providers, p)
+	}
+	sort.Strings(providers)
+	return providers, nil
+}
+
+// GetAttributeValues retrieves the distinct values of the attribute of the instance types in the region
+func (fs *FileProductInfoSource) GetAttributeValues(provider string, region string, attr string) ([]float64, error) {
+	rs, err := fs.region(provider, region)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[float64]bool)
+	values := make([]float64, 0)
+	for _, p := range rs.Products {
+		var v float64
+		switch attr {
+		case Cpu:
+			v = p.Cpus
+		case Memory:
+			v = p.Mem
+		default:
+			return nil, fmt.Errorf("unsupported attribute: %s", attr)
+		}
+		if !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	sort.Float64s(values)
+	return values, nil
+}
+
+// GetRegions retrieves the regions of the provider in the snapshot
+func (fs *FileProductInfoSource) GetRegions(provider string) ([]*models.RegionResp, error) {
+	ps, ok := fs.snapshot.Providers[provider]
+	if !ok {
+		return nil, NewError(ProviderUnsupported, "provider [%s] is not in the product info snapshot", provider)
+	}
+	var regions []*models.RegionResp
+	for id, rs := range ps.Regions {
+		regions = append(regions, &models.RegionResp{ID: id, Name: rs.Name})
+	}
+	sort.Slice(regions, func(i, j int) bool {
+		return regions[i].ID < regions[j].ID
+	})
+	return regions, nil
+}
+
+// GetRegion retrieves the zones of the region
+func (fs *FileProductInfoSource) GetRegion(provider string, region string) ([]string, error) {
+	rs, err := fs.region(provider, region)
+	if err != nil {
+		return nil, err
+	}
+	return rs.Zones, nil
+}
+
+// GetProductDetails retrieves the instance types of the region
+func (fs *FileProductInfoSource) GetProductDetails(provider string, region string) ([]*models.ProductDetails, error) {
+	rs, err := fs.region(provider, region)
+	if err != nil {
+		return nil, err
+	}
+	return rs.Products, nil
+}
+
+// GetStorageTypes retrieves the storage classes of the instance types of the region, nil if the snapshot of the region
+// doesn't hold them
+func (fs *FileProductInfoSource) GetStorageTypes(provider string, region string) (map[string][]string, error) {
+	rs, err := fs.region(provider, region)
+	if err != nil {
+		return nil, err
+	}
+	return rs.StorageTypes, nil
+}
+
+// GetPodLimits retrieves the maximum number of pods on the instance types of the region, nil if the snapshot of the
+// region doesn't hold them
+func (fs *FileProductInfoSource) GetPodLimits(provider string, region string) (map[string]int, error) {
+	rs, err := fs.region(provider, region)
+	if err != nil {
+		return nil, err
+	}
+	return rs.PodLimits, nil
+}
+
+// GetCustomMachinePrices retrieves the prices of the custom machine types of the region, nil if the snapshot of the
+// region doesn't hold them
+func (fs *FileProductInfoSource) GetCustomMachinePrices(provider string, region string) (*CustomMachinePrices, error) {
+	rs, err := fs.region(provider, region)
+	if err != nil {
+		return nil, err
+	}
+	return rs.CustomMachinePrices, nil
+}
+
+// GetLocalStorage retrieves the local storage of the instance types of the region, nil if the snapshot of the region
+// doesn't hold it
+func (fs *FileProductInfoSource) GetLocalStorage(provider string, region string) (map[string]float64, error) {
+	rs, err := fs.region(provider, region)
+	if err != nil {
+		return nil, err
+	}
+	return rs.LocalStorage, nil
+}
+
+// GetSpotPriceVariance retrieves the variance of the recent spot prices of the instance types of the region, nil if the
+// snapshot of the region doesn't hold it
+func (fs *FileProductInfoSource) GetSpotPriceVariance(provider string, region string) (map[string]float64, error) {
+	rs, err := fs.region(provider, region)
+	if err != nil {
+		return nil, err
+	}
+	return rs.SpotPriceVariance, nil
+}
+
+// GetReservedPrices retrieves the effective hourly reserved prices of the instance types of the region for the
+// commitment term, nil if the snapshot of the region doesn't hold them
+func (fs *FileProductInfoSource) GetReservedPrices(provider string, region string, term string) (map[string]float64, error) {
+	rs, err := fs.region(provider, region)
+	if err != nil {
+		return nil, err
+	}
+	return rs.ReservedPrices[term], nil
+}
+
+// GetSpotInterruptionRates retrieves the monthly interruption rates of the spot instances of the instance types of the
+// region, nil if the snapshot of the region doesn't hold them
+func (fs *FileProductInfoSource) GetSpotInterruptionRates(provider string, region string) (map[string]float64, error) {
+	rs, err := fs.region(provider, region)
+	if err != nil {
+		return nil, err
+	}
+	return rs.SpotInterruptionRates, nil
+}
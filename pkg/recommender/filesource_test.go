@@ -0,0 +1,196 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFileProductInfoSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	tests := []struct {
+		name  string
+		path  string
+		check func(fs *FileProductInfoSource, err error)
+	}{
+		{
+			name: "yaml snapshot loaded",
+			path: "testdata/snapshot.yaml",
+			check: func(fs *FileProductInfoSource, err error) {
+				assert.Nil(t, err, "the snapshot should be loaded")
+				providers, _ := fs.GetProviders()
+				assert.Equal(t, []string{"ec2"}, providers)
+				zones, _ := fs.GetRegion("ec2", "eu-west-1")
+				assert.Equal(t, []string{"eu-west-1a", "eu-west-1b", "eu-west-1c"}, zones)
+				cpus, _ := fs.GetAttributeValues("ec2", "eu-west-1", Cpu)
+				assert.Equal(t, []float64{2, 4, 8}, cpus)
+				mems, _ := fs.GetAttributeValues("ec2", "eu-west-1", Memory)
+				assert.Equal(t, []float64{8, 16, 32}, mems)
+			},
+		},
+		{
+			name: "json snapshot loaded",
+			path: "testdata/snapshot.json",
+			check: func(fs *FileProductInfoSource, err error) {
+				assert.Nil(t, err, "the snapshot should be loaded")
+				regions, _ := fs.GetRegions("gce")
+				assert.Equal(t, 1, len(regions))
+				assert.Equal(t, "europe-west1", regions[0].ID)
+				assert.Equal(t, "St. Ghislain, Belgium", regions[0].Name)
+				products, _ := fs.GetProductDetails("gce", "europe-west1")
+				assert.Equal(t, 1, len(products))
+				assert.Equal(t, float64(7.5), products[0].Mem)
+			},
+		},
+		{
+			name: "supplement loaded",
+			path: "testdata/supplement.yaml",
+			check: func(fs *FileProductInfoSource, err error) {
+				assert.Nil(t, err, "the supplement should be loaded")
+				storage, err := fs.GetLocalStorage("ec2", "eu-west-1")
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, float64(300), storage["m5.2xlarge"])
+				variances, err := fs.GetSpotPriceVariance("ec2", "eu-west-1")
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, 0.01, variances["c5.xlarge"])
+				reserved, err := fs.GetReservedPrices("ec2", "eu-west-1", "3yr-all-upfront")
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, 0.164, reserved["m5.2xlarge"])
+				reserved, err = fs.GetReservedPrices("ec2", "eu-west-1", "3yr-no-upfront")
+				assert.Nil(t, err, "the error should be nil")
+				assert.Nil(t, reserved, "the term isn't in the supplement")
+				rates, err := fs.GetSpotInterruptionRates("ec2", "eu-west-1")
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, float64(25), rates["c5.xlarge"])
+			},
+		},
+		{
+			name: "error - missing file",
+			path: filepath.Join(dir, "missing.yaml"),
+			check: func(fs *FileProductInfoSource, err error) {
+				assert.Nil(t, fs, "the source should be nil")
+				assert.Contains(t, err.Error(), "could not read the product info snapshot")
+			},
+		},
+		{
+			name: "error - no providers",
+			path: write("empty.yaml", "providers: {}\n"),
+			check: func(fs *FileProductInfoSource, err error) {
+				assert.Nil(t, fs, "the source should be nil")
+				assert.EqualError(t, err, "invalid product info snapshot ["+filepath.Join(dir, "empty.yaml")+"]: no providers")
+			},
+		},
+		{
+			name: "error - product without type",
+			path: write("untyped.yaml", "providers:\n  ec2:\n    regions:\n      eu-west-1:\n        products:\n          - cpusPerVm: 2\n"),
+			check: func(fs *FileProductInfoSource, err error) {
+				assert.Nil(t, fs, "the source should be nil")
+				assert.Contains(t, err.Error(), "product without type in region [eu-west-1] of provider [ec2]")
+			},
+		},
+		{
+			name: "error - malformed snapshot",
+			path: write("malformed.yaml", "providers: [ec2"),
+			check: func(fs *FileProductInfoSource, err error) {
+				assert.Nil(t, fs, "the source should be nil")
+				assert.Contains(t, err.Error(), "invalid product info snapshot")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(NewFileProductInfoSource(test.path))
+		})
+	}
+}
+
+func TestFileProductInfoSource_RecommendCluster(t *testing.T) {
+	fs, err := NewFileProductInfoSource("testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := NewEngine(fs)
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	req := ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 6, OnDemandPct: onDemand(50)}
+
+	tests := []struct {
+		name   string
+		region string
+		check  func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:   "cluster recommended from the snapshot",
+			region: "eu-west-1",
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "ec2", resp.Provider)
+				assert.Equal(t, 3, resp.Accuracy.RecNodes)
+				assert.Equal(t, 2, resp.Accuracy.RecRegularNodes)
+				assert.Equal(t, 1, resp.Accuracy.RecSpotNodes)
+				assert.Equal(t, float64(16), resp.Accuracy.RecCpu)
+				assert.Equal(t, float64(64), resp.Accuracy.RecMem)
+				assert.InDelta(t, 0.5667, resp.Accuracy.RecTotalPrice, 1e-9)
+
+				assert.Equal(t, "m5.xlarge", resp.NodePools[0].VmType.Type)
+				assert.Equal(t, regular, resp.NodePools[0].VmClass)
+				assert.Equal(t, 2, resp.NodePools[0].SumNodes)
+				assert.Equal(t, "m5.2xlarge", resp.NodePools[1].VmType.Type)
+				assert.Equal(t, spot, resp.NodePools[1].VmClass)
+				assert.Equal(t, 1, resp.NodePools[1].SumNodes)
+			},
+		},
+		{
+			name:   "error - region not in the snapshot",
+			region: "us-east-1",
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the recommendation should be nil")
+				assert.EqualError(t, err, "region [us-east-1] of provider [ec2] is not in the product info snapshot")
+				assert.Equal(t, RegionNotFound, ErrorCode(err))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(engine.RecommendCluster("ec2", test.region, req))
+		})
+	}
+}
+
+func TestFileProductInfoSource_PriceVersion(t *testing.T) {
+	yamlSource, err := NewFileProductInfoSource("testdata/snapshot.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	jsonSource, err := NewFileProductInfoSource("testdata/snapshot.json")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := NewEngine(yamlSource)
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	assert.NotEmpty(t, yamlSource.PriceVersion("ec2", "eu-west-1"))
+	assert.NotEqual(t, yamlSource.PriceVersion("ec2", "eu-west-1"), jsonSource.PriceVersion("ec2", "eu-west-1"),
+		"the version should identify the snapshot file")
+	assert.Equal(t, yamlSource.PriceVersion("ec2", "eu-west-1"), engine.PriceVersion("ec2", "eu-west-1"))
+}
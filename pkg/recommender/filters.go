@@ -15,6 +15,10 @@
 package recommender
 
 import (
+	"fmt"
+	"math"
+	"strings"
+
 	log "github.com/sirupsen/logrus"
 )
 
@@ -37,6 +41,11 @@ func (e *Engine) burstFilter(vm VirtualMachine, req ClusterRecommendationReq) bo
 	return !vm.Burst
 }
 
+// noBurstableFilter removes the burstable instance types on any provider if they're excluded
+func (e *Engine) noBurstableFilter(vm VirtualMachine, req ClusterRecommendationReq) bool {
+	return !req.NoBurstable || !vm.Burst
+}
+
 func (e *Engine) minCpuRatioFilter(vm VirtualMachine, req ClusterRecommendationReq) bool {
 	minCpuToMemRatio := req.SumCpu / req.SumMem
 	if vm.Cpus/vm.Mem < minCpuToMemRatio {
@@ -45,14 +54,31 @@ func (e *Engine) minCpuRatioFilter(vm VirtualMachine, req ClusterRecommendationR
 	return true
 }
 
+// ntwPerformanceFilter checks whether the network performance category of the vm meets the requested minimum
 func (e *Engine) ntwPerformanceFilter(vm VirtualMachine, req ClusterRecommendationReq) bool {
 	if req.NetworkPerf == nil { //there is no filter set
 		return true
 	}
-	if vm.NetworkPerfCat == *req.NetworkPerf { //the network performance category matches the vm
-		return true
+	vmRank := networkPerfRank(vm.NetworkPerfCat)
+	return vmRank >= 0 && vmRank >= networkPerfRank(*req.NetworkPerf)
+}
+
+// reportsNetworkPerf returns true if the network performance category of the instance types is reported for the provider
+func reportsNetworkPerf(provider string) bool {
+	return provider == "ec2" || provider == "gce"
+}
+
+// networkPerfCategories holds the network performance categories in increasing order
+var networkPerfCategories = []string{"low", "medium", "high", "extra"}
+
+// networkPerfRank returns the position of the category in the order of network performance categories, -1 if unknown
+func networkPerfRank(category string) int {
+	for i, c := range networkPerfCategories {
+		if c == category {
+			return i
+		}
 	}
-	return false
+	return -1
 }
 
 // excludeFilter checks for the vm type in the request' exclude list, the filter  passes if the type is not excluded
@@ -81,6 +107,109 @@ func (e *Engine) includesFilter(vm VirtualMachine, req ClusterRecommendationReq)
 	return false
 }
 
+// gpuFilter removes instance types without gpus if gpus are requested
+func (e *Engine) gpuFilter(vm VirtualMachine, req ClusterRecommendationReq) bool {
+	if req.SumGpu == 0 {
+		// gpus are not requested, the filter passes
+		return true
+	}
+	return vm.Gpus > 0
+}
+
+// storageFilter removes instance types without local storage if storage is requested
+func (e *Engine) storageFilter(vm VirtualMachine, req ClusterRecommendationReq) bool {
+	if req.SumStorage == 0 {
+		// storage is not requested, the filter passes
+		return true
+	}
+	return vm.Storage > 0
+}
+
+// podLimitFilter removes instance types without a pod limit if pods are requested
+func (e *Engine) podLimitFilter(vm VirtualMachine, req ClusterRecommendationReq) bool {
+	if req.SumPods == 0 {
+		// pods are not requested, the filter passes
+		return true
+	}
+	return vm.MaxPods > 0
+}
+
+// storageTypeFilter removes instance types not supporting the requested storage type
+func (e *Engine) storageTypeFilter(vm VirtualMachine, req ClusterRecommendationReq) bool {
+	if req.StorageType == "" {
+		// any storage type is allowed
+		return true
+	}
+	return contains(vm.StorageTypes, req.StorageType)
+}
+
+// minGenFilter removes instance types older than the requested minimum generation, or of unknown generation
+func (e *Engine) minGenFilter(vm VirtualMachine, req ClusterRecommendationReq) bool {
+	if req.MinGen == 0 {
+		// any generation is allowed
+		return true
+	}
+	return vm.Generation >= req.MinGen
+}
+
+// architectureFilter removes instance types with a cpu architecture other than the requested one
+func (e *Engine) architectureFilter(vm VirtualMachine, req ClusterRecommendationReq) bool {
+	if req.Architecture == "" {
+		// any architecture is allowed
+		return true
+	}
+	return vm.Architecture == req.Architecture
+}
+
+// minNodeCpuFilter removes instance types with fewer cpus than the per node floor
+func (e *Engine) minNodeCpuFilter(vm VirtualMachine, req ClusterRecommendationReq) bool {
+	return vm.Cpus >= req.MinNodeCpu
+}
+
+// minNodeMemFilter removes instance types with less memory than the per node floor
+func (e *Engine) minNodeMemFilter(vm VirtualMachine, req ClusterRecommendationReq) bool {
+	return vm.Mem >= req.MinNodeMem
+}
+
+// maxNodeCpuFilter removes instance types with more cpus than the per node cap
+func (e *Engine) maxNodeCpuFilter(vm VirtualMachine, req ClusterRecommendationReq) bool {
+	return req.MaxNodeCpu == 0 || vm.Cpus <= req.MaxNodeCpu
+}
+
+// maxNodeMemFilter removes instance types with more memory than the per node cap
+func (e *Engine) maxNodeMemFilter(vm VirtualMachine, req ClusterRecommendationReq) bool {
+	return req.MaxNodeMem == 0 || vm.Mem <= req.MaxNodeMem
+}
+
+// boundsNodeSize reports whether the size of the nodes is bounded by the request
+func (req *ClusterRecommendationReq) boundsNodeSize() bool {
+	return req.MinNodeCpu > 0 || req.MaxNodeCpu > 0 || req.MinNodeMem > 0 || req.MaxNodeMem > 0
+}
+
+// withinNodeSize reports whether a node with the given cpus and memory is within the node size bounds of the request
+func (req *ClusterRecommendationReq) withinNodeSize(cpus float64, mem float64) bool {
+	return cpus >= req.MinNodeCpu && (req.MaxNodeCpu == 0 || cpus <= req.MaxNodeCpu) &&
+		mem >= req.MinNodeMem && (req.MaxNodeMem == 0 || mem <= req.MaxNodeMem)
+}
+
+// nodeSizeBounds describes the node size bounds of the request
+func (req *ClusterRecommendationReq) nodeSizeBounds() string {
+	var bounds []string
+	if req.MinNodeCpu > 0 {
+		bounds = append(bounds, fmt.Sprintf("at least [%v] cpus", req.MinNodeCpu))
+	}
+	if req.MaxNodeCpu > 0 {
+		bounds = append(bounds, fmt.Sprintf("at most [%v] cpus", req.MaxNodeCpu))
+	}
+	if req.MinNodeMem > 0 {
+		bounds = append(bounds, fmt.Sprintf("at least [%v] GB memory", req.MinNodeMem))
+	}
+	if req.MaxNodeMem > 0 {
+		bounds = append(bounds, fmt.Sprintf("at most [%v] GB memory", req.MaxNodeMem))
+	}
+	return strings.Join(bounds, ", ")
+}
+
 // filterSpots selects vm-s that potentially can be part of "spot" node pools
 func (e *Engine) filterSpots(vms []VirtualMachine) []VirtualMachine {
 	log.Debugf("selecting spot instances for recommending spot pools")
@@ -93,6 +222,64 @@ func (e *Engine) filterSpots(vms []VirtualMachine) []VirtualMachine {
 	return fvms
 }
 
+// spotWorthy retains the vms whose spot price with the safety margin of the request is still below the on-demand price
+// and whose current spot price doesn't exceed the maximum spot price of the request, the vms are not compared to their
+// on-demand price without a margin
+func (req *ClusterRecommendationReq) spotWorthy(vms []VirtualMachine) []VirtualMachine {
+	margin := req.SpotMargin != nil && *req.SpotMargin > 0
+	if !margin && req.MaxSpotPrice == 0 && req.MaxInterruptionRate == 0 {
+		return vms
+	}
+	fvms := make([]VirtualMachine, 0, len(vms))
+	for _, vm := range vms {
+		if margin && req.bufferedSpotPrice(vm) >= vm.OnDemandPrice {
+			continue
+		}
+		// the maximum spot price is the bid sent to the provider, it's compared to the list price
+		if req.MaxSpotPrice > 0 && vm.listSpot() > req.MaxSpotPrice {
+			continue
+		}
+		if req.MaxInterruptionRate > 0 && vm.interruptionRate > req.MaxInterruptionRate {
+			continue
+		}
+		fvms = append(fvms, vm)
+	}
+	return fvms
+}
+
+// spotCapped returns true if the maximum spot price drops any of the vms that would be spot candidates otherwise
+func (req *ClusterRecommendationReq) spotCapped(vms []VirtualMachine) bool {
+	if req.MaxSpotPrice == 0 || req.onDemandPct() == 100 {
+		return false
+	}
+	uncapped := *req
+	uncapped.MaxSpotPrice = 0
+	return len(req.spotWorthy(vms)) < len(uncapped.spotWorthy(vms))
+}
+
+// bidSpots sets the effective bid of the spot pools, the maximum spot price capped at the on-demand list price of the
+// instance type, and returns true if any spot nodes are recommended
+func bidSpots(nodePools []NodePool, maxPrice float64) bool {
+	var spots bool
+	for i := range nodePools {
+		np := &nodePools[i]
+		if np.VmClass != spot || np.SumNodes == 0 {
+			continue
+		}
+		np.SpotBid = math.Min(maxPrice, np.VmType.listOnDemand())
+		spots = true
+	}
+	return spots
+}
+
+// bufferedSpotPrice returns the average spot price of the vm raised by the safety margin of the request
+func (req *ClusterRecommendationReq) bufferedSpotPrice(vm VirtualMachine) float64 {
+	if req.SpotMargin == nil {
+		return vm.AvgPrice
+	}
+	return vm.AvgPrice * (1 + *req.SpotMargin/100)
+}
+
 // currentGenFilter removes instance types that are not the current generation (amazon only)
 func (e *Engine) currentGenFilter(vm VirtualMachine, req ClusterRecommendationReq) bool {
 	if req.AllowOlderGen == nil || !*req.AllowOlderGen {
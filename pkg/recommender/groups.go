@@ -0,0 +1,107 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ClusterGroupsReq holds the requirements of the node groups of a single cluster, keyed by the name of the group
+type ClusterGroupsReq struct {
+	// The requirements of the workload classes of the cluster, keyed by the name of the node group; the groups are
+	// validated one by one like the cluster recommendation requests
+	Groups map[string]ClusterRecommendationReq `json:"groups" binding:"required,min=1,max=10"`
+}
+
+// ClusterGroupsResp holds the recommendation for each node group of the cluster, and the combined accuracy of the groups
+// swagger:model ClusterGroupsResponse
+type ClusterGroupsResp struct {
+	// The cloud provider
+	Provider string `json:"provider"`
+	// The region of the cluster
+	Region string `json:"region"`
+	// The recommended node pools of each node group, keyed by the name of the group
+	Groups map[string]*ClusterRecommendationResp `json:"groups"`
+	// Number of recommended nodes across all groups
+	RecNodes int `json:"nodes"`
+	// Total hourly price of the regular nodes across all groups
+	RecRegularPrice float64 `json:"regularPrice"`
+	// Total hourly price of the spot nodes across all groups
+	RecSpotPrice float64 `json:"spotPrice"`
+	// Total hourly price of the cluster across all groups
+	RecTotalPrice float64 `json:"totalPrice"`
+}
+
+// ClusterGroupsError is returned if some of the node groups can't be recommended for, it holds the error of each of them
+// keyed by the name of the group; it's reported with the code and the message of the first of them by name
+type ClusterGroupsError struct {
+	// The errors of the node groups that couldn't be recommended for, keyed by the name of the group
+	Groups map[string]error
+	first  error
+}
+
+// Error returns the message of the error of the first failed group, naming the group
+func (e *ClusterGroupsError) Error() string {
+	return e.first.Error()
+}
+
+// RecommendClusterGroups recommends the node pools of each node group of a cluster on the same provider and region,
+// the groups are recommended for independently; the recommendation fails if any of the groups can't be recommended for,
+// the response holds the groups recommended for anyway and the error the errors of the failed ones
+func (e *Engine) RecommendClusterGroups(ctx context.Context, provider string, region string, req ClusterGroupsReq) (*ClusterGroupsResp, error) {
+	log.Infof("recommending cluster node groups. Provider: [%s], region: [%s], groups: [%d]", provider, region, len(req.Groups))
+
+	// the groups are recommended for in the order of their names, so that the same group is reported for the same request
+	names := make([]string, 0, len(req.Groups))
+	for name := range req.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resp := &ClusterGroupsResp{
+		Provider: provider,
+		Region:   region,
+		Groups:   make(map[string]*ClusterRecommendationResp, len(names)),
+	}
+	var failed *ClusterGroupsError
+	for _, name := range names {
+		rec, err := e.RecommendClusterCtx(ctx, provider, region, req.Groups[name])
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			if failed == nil {
+				failed = &ClusterGroupsError{Groups: make(map[string]error), first: err}
+				if ErrorCode(err) != "" {
+					failed.first = NewError(ErrorCode(err), "could not recommend node group [%s]: %s", name, err.Error())
+				}
+			}
+			failed.Groups[name] = err
+			continue
+		}
+		resp.Groups[name] = rec
+		resp.RecNodes += rec.Accuracy.RecNodes
+		resp.RecRegularPrice += rec.Accuracy.RecRegularPrice
+		resp.RecSpotPrice += rec.Accuracy.RecSpotPrice
+		resp.RecTotalPrice += rec.Accuracy.RecTotalPrice
+	}
+	if failed != nil {
+		return resp, failed
+	}
+	return resp, nil
+}
@@ -0,0 +1,102 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_RecommendClusterGroups(t *testing.T) {
+	engine, err := NewEngine(mustFileSource(t), WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	tests := []struct {
+		name   string
+		groups map[string]ClusterRecommendationReq
+		check  func(resp *ClusterGroupsResp, err error)
+	}{
+		{
+			name: "a recommendation per group, the prices combined",
+			groups: map[string]ClusterRecommendationReq{
+				"web":   {SumCpu: 8, SumMem: 16, MinNodes: 1, MaxNodes: 4, OnDemandPct: onDemand(100)},
+				"cache": {SumCpu: 4, SumMem: 64, MinNodes: 1, MaxNodes: 4, OnDemandPct: onDemand(50)},
+			},
+			check: func(resp *ClusterGroupsResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "ec2", resp.Provider)
+				assert.Equal(t, "eu-west-1", resp.Region)
+				assert.Equal(t, 2, len(resp.Groups))
+
+				web, cache := resp.Groups["web"], resp.Groups["cache"]
+				assert.Equal(t, 0, web.Accuracy.RecSpotNodes, "the groups should be recommended for with their own requirements")
+				assert.True(t, cache.Accuracy.RecMem >= 64)
+				assert.Equal(t, web.Accuracy.RecNodes+cache.Accuracy.RecNodes, resp.RecNodes)
+				assert.InDelta(t, web.Accuracy.RecTotalPrice+cache.Accuracy.RecTotalPrice, resp.RecTotalPrice, 1e-9)
+				assert.InDelta(t, resp.RecRegularPrice+resp.RecSpotPrice, resp.RecTotalPrice, 1e-9)
+			},
+		},
+		{
+			name: "the failing group named in the error",
+			groups: map[string]ClusterRecommendationReq{
+				"web": {SumCpu: 8, SumMem: 16, MinNodes: 1, MaxNodes: 4, OnDemandPct: onDemand(100)},
+				"ml":  {SumCpu: 8, SumMem: 16, SumGpu: 1, MinNodes: 1, MaxNodes: 4, OnDemandPct: onDemand(100)},
+			},
+			check: func(resp *ClusterGroupsResp, err error) {
+				assert.Equal(t, ResourceUnavailable, ErrorCode(err))
+				assert.Contains(t, err.Error(), "could not recommend node group [ml]")
+				if groupsErr, ok := err.(*ClusterGroupsError); assert.True(t, ok, "the error should hold the errors of the groups") {
+					assert.Equal(t, []string{"ml"}, groupNames(groupsErr.Groups))
+					assert.Equal(t, ResourceUnavailable, ErrorCode(groupsErr.Groups["ml"]))
+				}
+				assert.NotNil(t, resp.Groups["web"], "the other groups should be recommended for")
+				assert.Equal(t, resp.Groups["web"].Accuracy.RecNodes, resp.RecNodes)
+			},
+		},
+		{
+			name: "the errors of every failing group kept",
+			groups: map[string]ClusterRecommendationReq{
+				"web": {SumCpu: 8, SumMem: 16, MinNodes: 1, MaxNodes: 4, OnDemandPct: onDemand(100), MaxNodeCpu: 1},
+				"ml":  {SumCpu: 8, SumMem: 16, SumGpu: 1, MinNodes: 1, MaxNodes: 4, OnDemandPct: onDemand(100)},
+			},
+			check: func(resp *ClusterGroupsResp, err error) {
+				assert.Contains(t, err.Error(), "could not recommend node group [ml]", "the first group by name should be reported")
+				if groupsErr, ok := err.(*ClusterGroupsError); assert.True(t, ok, "the error should hold the errors of the groups") {
+					assert.Equal(t, []string{"ml", "web"}, groupNames(groupsErr.Groups))
+					assert.NotEqual(t, groupsErr.Groups["ml"].Error(), groupsErr.Groups["web"].Error())
+				}
+				assert.Empty(t, resp.Groups)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(engine.RecommendClusterGroups(context.Background(), "ec2", "eu-west-1", ClusterGroupsReq{Groups: test.groups}))
+		})
+	}
+}
+
+// groupNames returns the names of the groups in order
+func groupNames(errs map[string]error) []string {
+	var names []string
+	for name := range errs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
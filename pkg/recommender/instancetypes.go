@@ -0,0 +1,140 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Amd64 represents the x86-64 cpu architecture
+	Amd64 = "amd64"
+	// Arm64 represents the 64 bit arm cpu architecture
+	Arm64 = "arm64"
+)
+
+var (
+	// ec2 instance types are named as <class><generation><attributes>.<size>, eg: m5d.large
+	ec2TypeRegexp = regexp.MustCompile(`^([a-z]+)(\d+)([a-z\-]*)\.`)
+	// azure instance types are named as Standard_<family><size><attributes>_<version>, eg: Standard_D2ps_v5
+	azureTypeRegexp = regexp.MustCompile(`^(?:Standard|Basic)_([A-Z]+)(\d+)([a-z\-]*)`)
+	// the family of the azure instance types is named after the family, the attributes and the version
+	azureFamilyRegexp = regexp.MustCompile(`^(?:Standard|Basic)_([A-Z]+)\d+([a-z\-]*)(_v\d+)?$`)
+	// the size of the gce instance types is the number of vcpus, eg: n1-standard-4
+	gceSizeRegexp = regexp.MustCompile(`-\d+$`)
+	// gce instance type families running on arm cpus
+	gceArmFamilies = []string{"t2a", "c4a"}
+	// gce shared-core instance types, bursting over their fraction of a cpu
+	gceBurstableTypes = []string{"f1-micro", "g1-small", "e2-micro", "e2-small", "e2-medium"}
+	// alibaba burstable instance type families
+	alibabaBurstableFamilies = []string{"ecs.t5", "ecs.t6"}
+)
+
+// architecture derives the cpu architecture of the instance type from its name, as the product info doesn't report it
+func architecture(provider string, vmType string) string {
+	switch provider {
+	case "ec2":
+		// graviton instance types are marked with the "g" attribute, eg: m6g.large, c7gn.xlarge; a1 is the first generation
+		if m := ec2TypeRegexp.FindStringSubmatch(vmType); m != nil {
+			if m[1] == "a" || strings.Contains(m[3], "g") {
+				return Arm64
+			}
+		}
+	case "gce":
+		for _, family := range gceArmFamilies {
+			if strings.HasPrefix(vmType, family+"-") {
+				return Arm64
+			}
+		}
+	case "azure":
+		// ampere instance types are marked with the "p" attribute, eg: Standard_D2ps_v5
+		if m := azureTypeRegexp.FindStringSubmatch(vmType); m != nil && strings.Contains(m[3], "p") {
+			return Arm64
+		}
+	}
+	return Amd64
+}
+
+// burstable derives from its name whether the instance type is of a burstable (cpu credit based) family, as the
+// product info only reports it on some of the providers
+func burstable(provider string, vmType string) bool {
+	switch provider {
+	case "ec2":
+		// the t class, eg: t3.large, t4g.micro
+		if m := ec2TypeRegexp.FindStringSubmatch(vmType); m != nil {
+			return m[1] == "t"
+		}
+	case "gce":
+		return contains(gceBurstableTypes, vmType)
+	case "azure":
+		// the B series, eg: Standard_B2s, Standard_B2ats_v2
+		if m := azureTypeRegexp.FindStringSubmatch(vmType); m != nil {
+			return m[1] == "B"
+		}
+	case "alibaba":
+		for _, family := range alibabaBurstableFamilies {
+			if strings.HasPrefix(vmType, family+"-") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// generationReported returns true if the generation of the instance types can be derived on the provider
+func generationReported(provider string) bool {
+	return provider == "ec2"
+}
+
+// generation derives the generation of the instance type from its name, 0 if it can't be derived
+func generation(provider string, vmType string) int {
+	switch provider {
+	case "ec2":
+		// the generation follows the class, eg: 5 for m5d.large
+		if m := ec2TypeRegexp.FindStringSubmatch(vmType); m != nil {
+			if gen, err := strconv.Atoi(m[2]); err == nil {
+				return gen
+			}
+		}
+	}
+	return 0
+}
+
+// InstanceFamily returns the family of the instance type on the provider, the instance type itself if its family can't
+// be derived from its name
+func InstanceFamily(provider string, vmType string) string {
+	switch provider {
+	case "gce":
+		// eg: n1-standard for n1-standard-4, custom for custom-6-8192
+		if strings.HasPrefix(vmType, "custom-") {
+			return "custom"
+		}
+		return gceSizeRegexp.ReplaceAllString(vmType, "")
+	case "azure":
+		// eg: Ds_v3 for Standard_D2s_v3
+		if m := azureFamilyRegexp.FindStringSubmatch(vmType); m != nil {
+			return m[1] + m[2] + m[3]
+		}
+		return vmType
+	}
+	// the size is the last part of the dotted names, eg: m5 for m5.large, ecs.g6 for ecs.g6.large, VM.Standard2 for
+	// VM.Standard2.1
+	if i := strings.LastIndex(vmType, "."); i > 0 {
+		return vmType[:i]
+	}
+	return vmType
+}
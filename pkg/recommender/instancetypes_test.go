@@ -0,0 +1,117 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_architecture(t *testing.T) {
+	tests := []struct {
+		provider string
+		vmType   string
+		arch     string
+	}{
+		{provider: "ec2", vmType: "m5.large", arch: Amd64},
+		{provider: "ec2", vmType: "g4dn.xlarge", arch: Amd64},
+		{provider: "ec2", vmType: "m6g.large", arch: Arm64},
+		{provider: "ec2", vmType: "c7gn.xlarge", arch: Arm64},
+		{provider: "ec2", vmType: "a1.medium", arch: Arm64},
+		{provider: "gce", vmType: "n1-standard-4", arch: Amd64},
+		{provider: "gce", vmType: "t2a-standard-4", arch: Arm64},
+		{provider: "azure", vmType: "Standard_D2s_v3", arch: Amd64},
+		{provider: "azure", vmType: "Standard_D2ps_v5", arch: Arm64},
+		{provider: "oracle", vmType: "VM.Standard2.1", arch: Amd64},
+	}
+	for _, test := range tests {
+		t.Run(test.provider+"/"+test.vmType, func(t *testing.T) {
+			assert.Equal(t, test.arch, architecture(test.provider, test.vmType))
+		})
+	}
+}
+
+func Test_generation(t *testing.T) {
+	tests := []struct {
+		provider string
+		vmType   string
+		gen      int
+	}{
+		{provider: "ec2", vmType: "m5.large", gen: 5},
+		{provider: "ec2", vmType: "m5d.xlarge", gen: 5},
+		{provider: "ec2", vmType: "c4.8xlarge", gen: 4},
+		{provider: "ec2", vmType: "g4dn.xlarge", gen: 4},
+		{provider: "ec2", vmType: "c7gn.xlarge", gen: 7},
+		{provider: "ec2", vmType: "x2iedn.32xlarge", gen: 2},
+		{provider: "ec2", vmType: "u-6tb1.metal", gen: 0},
+		{provider: "gce", vmType: "n2-standard-4", gen: 0},
+		{provider: "azure", vmType: "Standard_D2s_v3", gen: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.provider+"/"+test.vmType, func(t *testing.T) {
+			assert.Equal(t, test.gen, generation(test.provider, test.vmType))
+		})
+	}
+}
+
+func Test_burstable(t *testing.T) {
+	tests := []struct {
+		provider  string
+		vmType    string
+		burstable bool
+	}{
+		{provider: "ec2", vmType: "t3.large", burstable: true},
+		{provider: "ec2", vmType: "t3a.micro", burstable: true},
+		{provider: "ec2", vmType: "t4g.medium", burstable: true},
+		{provider: "ec2", vmType: "m5.large", burstable: false},
+		{provider: "ec2", vmType: "trn1.2xlarge", burstable: false},
+		{provider: "gce", vmType: "e2-micro", burstable: true},
+		{provider: "gce", vmType: "e2-medium", burstable: true},
+		{provider: "gce", vmType: "f1-micro", burstable: true},
+		{provider: "gce", vmType: "e2-standard-2", burstable: false},
+		{provider: "gce", vmType: "t2a-standard-4", burstable: false},
+		{provider: "azure", vmType: "Standard_B2s", burstable: true},
+		{provider: "azure", vmType: "Standard_D2s_v3", burstable: false},
+		{provider: "alibaba", vmType: "ecs.t6-c1m2.large", burstable: true},
+		{provider: "alibaba", vmType: "ecs.g6.large", burstable: false},
+	}
+	for _, test := range tests {
+		t.Run(test.provider+"/"+test.vmType, func(t *testing.T) {
+			assert.Equal(t, test.burstable, burstable(test.provider, test.vmType))
+		})
+	}
+}
+
+func TestInstanceFamily(t *testing.T) {
+	tests := []struct {
+		provider string
+		vmType   string
+		family   string
+	}{
+		{provider: "ec2", vmType: "m5.2xlarge", family: "m5"},
+		{provider: "gce", vmType: "n1-standard-4", family: "n1-standard"},
+		{provider: "gce", vmType: "custom-6-8192", family: "custom"},
+		{provider: "gce", vmType: "f1-micro", family: "f1-micro"},
+		{provider: "azure", vmType: "Standard_D2s_v3", family: "Ds_v3"},
+		{provider: "oracle", vmType: "VM.Standard2.1", family: "VM.Standard2"},
+		{provider: "alibaba", vmType: "ecs.g6.large", family: "ecs.g6"},
+	}
+	for _, test := range tests {
+		t.Run(test.vmType, func(t *testing.T) {
+			assert.Equal(t, test.family, InstanceFamily(test.provider, test.vmType))
+		})
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+// the interruption frequency tiers of the spot instances by the upper bound of their rates, the rates over the last
+// bound are in the last tier
+var interruptionTiers = []struct {
+	rate float64
+	tier string
+}{
+	{rate: 5, tier: "<5%"},
+	{rate: 10, tier: "5-10%"},
+	{rate: 15, tier: "10-15%"},
+	{rate: 20, tier: "15-20%"},
+}
+
+// interruptionsFiltered returns true if the spot instance types are filtered by their interruption rate and there are
+// spot pools in the recommendation
+func (req *ClusterRecommendationReq) interruptionsFiltered() bool {
+	return req.MaxInterruptionRate > 0 && req.onDemandPct() < 100
+}
+
+// interruptionTier returns the interruption frequency tier of the rate (percentage of the spot instances interrupted a
+// month), eg: 5-10% for 10
+func interruptionTier(rate float64) string {
+	for _, t := range interruptionTiers {
+		if rate <= t.rate {
+			return t.tier
+		}
+	}
+	return ">20%"
+}
@@ -0,0 +1,115 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// interruptionProductInfoSource reports synthetic spot interruption rates
+type interruptionProductInfoSource struct {
+	dummyProductInfoSource
+	rates map[string]float64
+}
+
+func (piCli *interruptionProductInfoSource) GetSpotInterruptionRates(provider string, region string) (map[string]float64, error) {
+	return piCli.rates, nil
+}
+
+func Test_interruptionTier(t *testing.T) {
+	tests := []struct {
+		rate float64
+		tier string
+	}{
+		{rate: 0, tier: "<5%"},
+		{rate: 5, tier: "<5%"},
+		{rate: 7.5, tier: "5-10%"},
+		{rate: 15, tier: "10-15%"},
+		{rate: 19.9, tier: "15-20%"},
+		{rate: 30, tier: ">20%"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.tier, interruptionTier(test.rate), "rate: %v", test.rate)
+	}
+}
+
+func TestEngine_RecommendClusterInterruptionRate(t *testing.T) {
+	rates := &interruptionProductInfoSource{rates: map[string]float64{"type-10": 3, "type-11": 25}}
+
+	tests := []struct {
+		name    string
+		pi      ProductInfoSource
+		request ClusterRecommendationReq
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:    "spot instance types not filtered by default",
+			pi:      rates,
+			request: ClusterRecommendationReq{SumCpu: 64, SumMem: 64, MinNodes: 1, MaxNodes: 10},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "type-11", resp.NodePools[0].VmType.Type)
+				assert.Empty(t, resp.NodePools[0].VmType.InterruptionTier)
+			},
+		},
+		{
+			name:    "frequently interrupted spot instance type excluded",
+			pi:      rates,
+			request: ClusterRecommendationReq{SumCpu: 64, SumMem: 64, MinNodes: 1, MaxNodes: 10, MaxInterruptionRate: 10},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Empty(t, resp.Warnings)
+				var spotPools int
+				for _, np := range resp.NodePools {
+					if np.VmClass == spot && np.SumNodes > 0 {
+						spotPools++
+						assert.NotEqual(t, "type-11", np.VmType.Type, "the spot pool is interrupted too often")
+						assert.Equal(t, "<5%", np.VmType.InterruptionTier)
+					}
+				}
+				assert.True(t, spotPools > 0, "the rarely interrupted spot instance types should be recommended")
+			},
+		},
+		{
+			name:    "spot interruption rates not reported",
+			pi:      &dummyProductInfoSource{},
+			request: ClusterRecommendationReq{SumCpu: 64, SumMem: 64, MinNodes: 1, MaxNodes: 10, MaxInterruptionRate: 10},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"spot interruption rates are not reported on provider [dummy], the spot instance types are not filtered by their interruption rate"}, resp.Warnings)
+				assert.Equal(t, "type-11", resp.NodePools[0].VmType.Type)
+			},
+		},
+		{
+			name:    "no spot pools requested",
+			pi:      &dummyProductInfoSource{},
+			request: ClusterRecommendationReq{SumCpu: 64, SumMem: 64, MinNodes: 1, MaxNodes: 10, OnDemandPct: onDemand(100), MaxInterruptionRate: 10},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Empty(t, resp.Warnings, "the interruption rates are not needed without spot pools")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.pi, WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.RecommendCluster("dummy", "dummyRegion1", test.request))
+		})
+	}
+}
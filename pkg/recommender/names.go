@@ -0,0 +1,50 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"fmt"
+	"strings"
+)
+
+// nameNodePools names the node pools <prefix>-<type>-<class> in their order, the names are lowercased with the
+// characters other than letters and digits replaced by hyphens; the names taken by the other node pools of the cluster
+// or by an earlier node pool get a numeric suffix, so that the names are unique within the response
+func nameNodePools(prefix string, nodePools []NodePool, others []NodePool) {
+	taken := make(map[string]bool)
+	for _, np := range others {
+		taken[np.Name] = true
+	}
+	for i := range nodePools {
+		base := poolName(prefix, nodePools[i].VmType.Type, nodePools[i].VmClass)
+		name := base
+		for n := 2; taken[name]; n++ {
+			name = fmt.Sprintf("%s-%d", base, n)
+		}
+		taken[name] = true
+		nodePools[i].Name = name
+	}
+}
+
+// poolName returns the sanitised name of the node pool of the instance type and class, eg: prod-m5-xlarge-spot
+func poolName(prefix string, vmType string, vmClass string) string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(strings.Join([]string{prefix, vmType, vmClass}, "-")))
+	return strings.Trim(name, "-")
+}
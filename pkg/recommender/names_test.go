@@ -0,0 +1,82 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_poolName(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		vmType  string
+		vmClass string
+		name    string
+	}{
+		{prefix: "prod", vmType: "m5.xlarge", vmClass: spot, name: "prod-m5-xlarge-spot"},
+		{prefix: "Prod_EU", vmType: "n1-standard-2", vmClass: regular, name: "prod-eu-n1-standard-2-regular"},
+		{prefix: "-prod", vmType: "Standard_D2s_v3", vmClass: regular, name: "prod-standard-d2s-v3-regular"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.name, poolName(test.prefix, test.vmType, test.vmClass))
+	}
+}
+
+func Test_nameNodePools(t *testing.T) {
+	nodePools := []NodePool{
+		{VmType: VirtualMachine{Type: "m5.xlarge"}, VmClass: regular},
+		{VmType: VirtualMachine{Type: "m5.xlarge"}, VmClass: spot},
+		{VmType: VirtualMachine{Type: "c5.xlarge"}, VmClass: spot},
+		{VmType: VirtualMachine{Type: "m5.xlarge"}, VmClass: spot},
+	}
+	others := []NodePool{{VmType: VirtualMachine{Type: "c5.xlarge"}, VmClass: spot, Name: "prod-c5-xlarge-spot"}}
+
+	nameNodePools("prod", nodePools, others)
+
+	assert.Equal(t, "prod-m5-xlarge-regular", nodePools[0].Name)
+	assert.Equal(t, "prod-m5-xlarge-spot", nodePools[1].Name, "the same type should be named by its class")
+	assert.Equal(t, "prod-c5-xlarge-spot-2", nodePools[2].Name, "the name of an existing node pool should not be reused")
+	assert.Equal(t, "prod-m5-xlarge-spot-2", nodePools[3].Name, "the name of an earlier node pool should not be reused")
+}
+
+func TestEngine_RecommendClusterNamePrefix(t *testing.T) {
+	engine, err := NewEngine(mustFileSource(t), WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+	req := ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 6, OnDemandPct: onDemand(50), NamePrefix: "prod"}
+
+	resp, err := engine.RecommendCluster("ec2", "eu-west-1", req)
+	assert.Nil(t, err, "the error should be nil")
+	names := make(map[string]bool)
+	for _, np := range resp.NodePools {
+		assert.Equal(t, poolName("prod", np.VmType.Type, np.VmClass), np.Name)
+		assert.False(t, names[np.Name], "the name [%s] is not unique", np.Name)
+		names[np.Name] = true
+	}
+
+	again, err := engine.RecommendCluster("ec2", "eu-west-1", req)
+	assert.Nil(t, err, "the error should be nil")
+	for i, np := range again.NodePools {
+		assert.Equal(t, resp.NodePools[i].Name, np.Name, "the names should be stable")
+	}
+
+	req.NamePrefix = ""
+	unnamed, err := engine.RecommendCluster("ec2", "eu-west-1", req)
+	assert.Nil(t, err, "the error should be nil")
+	for _, np := range unnamed.NodePools {
+		assert.Empty(t, np.Name, "the node pools should not be named without a prefix")
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import "sort"
+
+// the default price tolerance (percentage) of the layouts the newest generation is preferred among
+const defaultNewGenEpsilon = 1.0
+
+// avgGeneration returns the average generation of the nodes of the node pools, the instance types of unknown
+// generation count as generation 0
+func avgGeneration(nodePools []NodePool) float64 {
+	var sum float64
+	var nodes int
+	for _, np := range nodePools {
+		sum += float64(np.SumNodes * np.VmType.Generation)
+		nodes += np.SumNodes
+	}
+	if nodes == 0 {
+		return 0
+	}
+	return sum / float64(nodes)
+}
+
+// preferNewGen returns the layout of the newest average generation among the layouts priced within epsilon
+// (percentage) of the cheapest one; layouts of the same generation are ranked by their price, the cheapest one is
+// kept if none of the others is newer
+func preferNewGen(nodePoolSets map[string][]NodePool, cheapest []NodePool, epsilon float64) []NodePool {
+	limit := poolSetPrice(cheapest) * (1 + epsilon/100)
+
+	// the layouts are checked in a fixed order so that the same one is picked on every call
+	attrs := make([]string, 0, len(nodePoolSets))
+	for attr := range nodePoolSets {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+
+	best, bestGen, bestPrice := cheapest, avgGeneration(cheapest), poolSetPrice(cheapest)
+	for _, attr := range attrs {
+		nps := nodePoolSets[attr]
+		price := poolSetPrice(nps)
+		if price > limit {
+			continue
+		}
+		if gen := avgGeneration(nps); gen > bestGen || (gen == bestGen && price < bestPrice) {
+			best, bestGen, bestPrice = nps, gen, price
+		}
+	}
+	return best
+}
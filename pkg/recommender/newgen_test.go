@@ -0,0 +1,96 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_preferNewGen(t *testing.T) {
+	m4 := []NodePool{{SumNodes: 4, VmClass: regular, VmType: VirtualMachine{Type: "m4.large", OnDemandPrice: 0.1, Generation: 4}}}
+	m5 := []NodePool{{SumNodes: 4, VmClass: regular, VmType: VirtualMachine{Type: "m5.large", OnDemandPrice: 0.1, Generation: 5}}}
+	m5Pricier := []NodePool{{SumNodes: 4, VmClass: regular, VmType: VirtualMachine{Type: "m5.large", OnDemandPrice: 0.1005, Generation: 5}}}
+	c5Pricier := []NodePool{{SumNodes: 4, VmClass: regular, VmType: VirtualMachine{Type: "c5.large", OnDemandPrice: 0.12, Generation: 5}}}
+
+	tests := []struct {
+		name     string
+		sets     map[string][]NodePool
+		cheapest []NodePool
+		epsilon  float64
+		check    func(nps []NodePool)
+	}{
+		{
+			name:     "the newer generation of the equally priced layouts",
+			sets:     map[string][]NodePool{Cpu: m4, Memory: m5},
+			cheapest: m4,
+			epsilon:  1,
+			check: func(nps []NodePool) {
+				assert.Equal(t, "m5.large", nps[0].VmType.Type)
+			},
+		},
+		{
+			name:     "the newer generation within the epsilon",
+			sets:     map[string][]NodePool{Cpu: m4, Memory: m5Pricier},
+			cheapest: m4,
+			epsilon:  1,
+			check: func(nps []NodePool) {
+				assert.Equal(t, "m5.large", nps[0].VmType.Type)
+			},
+		},
+		{
+			name:     "the cheapest layout if the newer generation is over the epsilon",
+			sets:     map[string][]NodePool{Cpu: m4, Memory: c5Pricier},
+			cheapest: m4,
+			epsilon:  1,
+			check: func(nps []NodePool) {
+				assert.Equal(t, "m4.large", nps[0].VmType.Type)
+			},
+		},
+		{
+			name:     "the cheapest layout with a zero epsilon",
+			sets:     map[string][]NodePool{Cpu: m4, Memory: m5Pricier},
+			cheapest: m4,
+			check: func(nps []NodePool) {
+				assert.Equal(t, "m4.large", nps[0].VmType.Type)
+			},
+		},
+		{
+			name:     "the cheapest of the layouts of the same generation",
+			sets:     map[string][]NodePool{Cpu: m5Pricier, Memory: m5},
+			cheapest: m5,
+			epsilon:  1,
+			check: func(nps []NodePool) {
+				assert.Equal(t, 0.1, nps[0].VmType.OnDemandPrice)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(preferNewGen(test.sets, test.cheapest, test.epsilon))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterPreferNewGen(t *testing.T) {
+	engine, err := NewEngine(&dummyProductInfoSource{}, WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	resp, err := engine.RecommendCluster("dummy", "dummyRegion1",
+		ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(50), PreferNewGen: true})
+	assert.Nil(t, err, "the error should be nil")
+	assert.Equal(t, []string{"the generation of the instance types is not known on provider [dummy], the cheapest layout is recommended"}, resp.Warnings)
+}
@@ -0,0 +1,150 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// the candidates are ranked by their price only
+	objectiveCost = "cost"
+	// the candidates are ranked by their performance only
+	objectivePerformance = "performance"
+	// the candidates are ranked by the blend of their price and performance weighted by the cost weight
+	objectiveBlend = "blend"
+
+	// the weight of the price in the blend objective if the request doesn't set it
+	defaultCostWeight = 0.5
+	// the per vcpu performance gained by every instance generation, relative to the first one
+	generationSpeedup = 0.1
+)
+
+// ObjectiveScore reports the cost and the performance of the recommended layout the objective ranked it by
+type ObjectiveScore struct {
+	// The weight of the cost in the objective between 0 (performance only) and 1 (cost only)
+	CostWeight float64 `json:"costWeight"`
+	// The hourly price of the layout (USD)
+	Cost float64 `json:"cost"`
+	// The performance of the layout: the vcpus weighted by their relative performance of the instance generation
+	Performance float64 `json:"performance"`
+}
+
+// objectiveRequested returns true if the request ranks the candidates by an objective other than the default pure cost
+func (req *ClusterRecommendationReq) objectiveRequested() bool {
+	return req.Objective != "" || req.CostWeight != nil
+}
+
+// costWeight returns the weight of the price in the ranking of the candidates, 1 if no objective is requested
+func (req *ClusterRecommendationReq) costWeight() float64 {
+	switch req.Objective {
+	case objectiveCost:
+		return 1
+	case objectivePerformance:
+		return 0
+	}
+	if req.CostWeight != nil {
+		return *req.CostWeight
+	}
+	if req.Objective == objectiveBlend {
+		return defaultCostWeight
+	}
+	return 1
+}
+
+// vcpuPerformance returns the relative performance of a vcpu of the instance type, the vcpus of unknown generation
+// are rated as first generation ones
+func vcpuPerformance(vm VirtualMachine) float64 {
+	if vm.Generation == 0 {
+		return 1
+	}
+	return 1 + float64(vm.Generation-1)*generationSpeedup
+}
+
+// layoutPerformance returns the vcpus of the node pools weighted by their relative performance
+func layoutPerformance(nodePools []NodePool) float64 {
+	var perf float64
+	for _, np := range nodePools {
+		perf += float64(np.SumNodes) * np.VmType.Cpus * vcpuPerformance(np.VmType)
+	}
+	return perf
+}
+
+// objectivePrice wraps the price function the candidates are ranked by to rank them by the objective of the request:
+// the price and the vcpu performance are normalized to the best one among the candidates and blended by the cost
+// weight, the negated score is returned so that the lower is the better
+func (req *ClusterRecommendationReq) objectivePrice(vms []VirtualMachine, price func(VirtualMachine) float64) func(VirtualMachine) float64 {
+	w := req.costWeight()
+	if w == 1 || len(vms) == 0 {
+		return price
+	}
+	minPrice, maxPerf := math.Inf(1), 0.0
+	for _, vm := range vms {
+		minPrice = math.Min(minPrice, price(vm))
+		maxPerf = math.Max(maxPerf, vcpuPerformance(vm))
+	}
+	return func(vm VirtualMachine) float64 {
+		score := (1 - w) * vcpuPerformance(vm) / maxPerf
+		if p := price(vm); p > 0 {
+			score += w * minPrice / p
+		}
+		return -score
+	}
+}
+
+// sortByObjective orders the vms by the objective of the request, the vms of the same score keep their order
+func (req *ClusterRecommendationReq) sortByObjective(vms []VirtualMachine, price func(VirtualMachine) float64) {
+	if req.costWeight() == 1 {
+		return
+	}
+	score := req.objectivePrice(vms, price)
+	sort.SliceStable(vms, func(i, j int) bool {
+		return score(vms[i]) < score(vms[j])
+	})
+}
+
+// bestObjectiveNodePoolSet returns the layout of the best blend of the total price and performance, both normalized to
+// the best one among the layouts; the cheapest layout is kept among the equally scored ones
+func bestObjectiveNodePoolSet(nodePoolSets map[string][]NodePool, cheapest []NodePool, costWeight float64) []NodePool {
+	if costWeight == 1 {
+		return cheapest
+	}
+	minPrice, maxPerf := poolSetPrice(cheapest), 0.0
+	for _, nps := range nodePoolSets {
+		maxPerf = math.Max(maxPerf, layoutPerformance(nps))
+	}
+	if minPrice == 0 || maxPerf == 0 {
+		return cheapest
+	}
+	score := func(nps []NodePool) float64 {
+		return costWeight*minPrice/poolSetPrice(nps) + (1-costWeight)*layoutPerformance(nps)/maxPerf
+	}
+
+	// the layouts are checked in a fixed order so that the same one is picked on every call
+	attrs := make([]string, 0, len(nodePoolSets))
+	for attr := range nodePoolSets {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+
+	best, bestScore := cheapest, score(cheapest)
+	for _, attr := range attrs {
+		if s := score(nodePoolSets[attr]); s > bestScore {
+			best, bestScore = nodePoolSets[attr], s
+		}
+	}
+	return best
+}
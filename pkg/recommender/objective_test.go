@@ -0,0 +1,94 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterRecommendationReq_costWeight(t *testing.T) {
+	weight := func(w float64) *float64 { return &w }
+	tests := []struct {
+		name   string
+		req    ClusterRecommendationReq
+		weight float64
+	}{
+		{name: "pure cost by default", req: ClusterRecommendationReq{}, weight: 1},
+		{name: "cost objective", req: ClusterRecommendationReq{Objective: objectiveCost}, weight: 1},
+		{name: "performance objective", req: ClusterRecommendationReq{Objective: objectivePerformance}, weight: 0},
+		{name: "blend objective without a weight", req: ClusterRecommendationReq{Objective: objectiveBlend}, weight: 0.5},
+		{name: "blend implied by the weight", req: ClusterRecommendationReq{CostWeight: weight(0.3)}, weight: 0.3},
+		{name: "the weight ignored by the cost objective", req: ClusterRecommendationReq{Objective: objectiveCost, CostWeight: weight(0.3)}, weight: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.weight, test.req.costWeight())
+		})
+	}
+}
+
+func TestEngine_RecommendClusterObjective(t *testing.T) {
+	fs, err := NewFileProductInfoSource("testdata/generations.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := NewEngine(fs, WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	// m4.xlarge is the cheapest, m6i.xlarge the fastest, m5.xlarge is slightly pricier and faster than m4.xlarge
+	weight := func(w float64) *float64 { return &w }
+	tests := []struct {
+		name       string
+		objective  string
+		costWeight *float64
+		vmType     string
+	}{
+		{name: "the cheapest by default", vmType: "m4.xlarge"},
+		{name: "the cheapest with the cost objective", objective: objectiveCost, vmType: "m4.xlarge"},
+		{name: "the balance of cost and performance", objective: objectiveBlend, costWeight: weight(0.4), vmType: "m5.xlarge"},
+		{name: "the cheapest with a high cost weight", costWeight: weight(0.8), vmType: "m4.xlarge"},
+		{name: "the fastest with a low cost weight", costWeight: weight(0.2), vmType: "m6i.xlarge"},
+		{name: "the fastest with the performance objective", objective: objectivePerformance, vmType: "m6i.xlarge"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := engine.RecommendCluster("ec2", "us-east-1", ClusterRecommendationReq{SumCpu: 8, SumMem: 32, MinNodes: 1, MaxNodes: 4,
+				OnDemandPct: onDemand(100), Objective: test.objective, CostWeight: test.costWeight})
+			assert.Nil(t, err, "the error should be nil")
+			for _, np := range resp.NodePools {
+				if np.SumNodes > 0 {
+					assert.Equal(t, test.vmType, np.VmType.Type)
+				}
+			}
+			if test.objective == "" && test.costWeight == nil {
+				assert.Nil(t, resp.Objective, "the scores should only be reported if an objective is requested")
+				return
+			}
+			assert.InDelta(t, resp.Accuracy.RecTotalPrice, resp.Objective.Cost, 1e-9)
+			assert.True(t, resp.Objective.Performance >= 8, "the performance should weigh the recommended vcpus")
+		})
+	}
+}
+
+func TestEngine_RecommendClusterObjectiveUnreported(t *testing.T) {
+	engine, err := NewEngine(&dummyProductInfoSource{}, WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	resp, err := engine.RecommendCluster("dummy", "dummyRegion1",
+		ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(50), Objective: objectivePerformance})
+	assert.Nil(t, err, "the error should be nil")
+	assert.Equal(t, []string{"the performance of the instance types is not known on provider [dummy], the candidates are ranked by cost"}, resp.Warnings)
+	assert.Equal(t, 1.0, resp.Objective.CostWeight)
+}
@@ -0,0 +1,184 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// the default number of provider regions searched for the cheapest cluster
+	defaultMaxTargets = 20
+	// the number of the cheapest provider regions listed in the ranking of the search
+	optimumRankingSize = 5
+)
+
+// Target identifies a region of a provider a cluster is recommended in
+type Target struct {
+	// The provider of the region
+	Provider string `json:"provider"`
+	// The region of the provider
+	Region string `json:"region"`
+}
+
+// TargetRank summarizes the recommendation in a provider region
+type TargetRank struct {
+	Target
+	// Total hourly price of the recommended cluster
+	TotalPrice float64 `json:"totalPrice"`
+}
+
+// OptimumResp holds the recommendation in the cheapest of the provider regions searched, and the ranking of the
+// cheapest ones
+// swagger:model OptimumResponse
+type OptimumResp struct {
+	Target
+	// The recommendation in the cheapest provider region
+	Recommendation *ClusterRecommendationResp `json:"recommendation"`
+	// The cheapest provider regions the requested resources can be satisfied in, ranked by the total price
+	Ranking []TargetRank `json:"ranking"`
+	// Number of the provider regions searched
+	Searched int `json:"searched"`
+	// Number of the provider regions the requested resources can be satisfied in
+	Feasible int `json:"feasible"`
+	// Number of the provider regions not searched as they are over the maximum number of targets
+	Skipped int `json:"skipped,omitempty"`
+	// Number of the provider regions searched but not recommended for before the deadline of the search
+	Unfinished int `json:"unfinished,omitempty"`
+}
+
+// RecommendOptimum searches the regions of the providers for the cheapest cluster, all the providers of the source if
+// none are given; at most maxTargets regions are searched, spread over the providers, and the search is bounded by the
+// request timeout of the engine
+func (e *Engine) RecommendOptimum(ctx context.Context, providers []string, maxTargets int, req ClusterRecommendationReq) (*OptimumResp, error) {
+	log.Infof("recommending optimum. Providers: %v, max targets: [%d]", providers, maxTargets)
+	if maxTargets <= 0 {
+		maxTargets = defaultMaxTargets
+	}
+
+	targets, err := e.optimumTargets(dedupe(providers))
+	if err != nil {
+		return nil, err
+	}
+	resp := &OptimumResp{}
+	if len(targets) > maxTargets {
+		resp.Skipped = len(targets) - maxTargets
+		targets = targets[:maxTargets]
+	}
+	resp.Searched = len(targets)
+
+	searchCtx, cancel := context.WithTimeout(ctx, e.reqTimeout)
+	defer cancel()
+
+	var (
+		recs = make([]*ClusterRecommendationResp, len(targets))
+		errs = make([]error, len(targets))
+		sem  = make(chan struct{}, maxParallelRegions)
+		wg   sync.WaitGroup
+	)
+	for i := range targets {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			recs[i], errs[i] = e.RecommendClusterCtx(searchCtx, targets[i].Provider, targets[i].Region, req)
+		}(i)
+	}
+	wg.Wait()
+
+	// the deadline of the search only fails the targets it cut short, the request is abandoned if the caller is gone
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var ranking []TargetRank
+	byTarget := make(map[Target]*ClusterRecommendationResp, len(targets))
+	for i, target := range targets {
+		if errs[i] != nil {
+			if errs[i] == context.DeadlineExceeded || ErrorCode(errs[i]) == RecommendationTimeout {
+				resp.Unfinished++
+			}
+			log.WithError(errs[i]).Debugf("no cluster recommended on provider: %s, region: %s", target.Provider, target.Region)
+			continue
+		}
+		byTarget[target] = recs[i]
+		ranking = append(ranking, TargetRank{Target: target, TotalPrice: recs[i].Accuracy.RecTotalPrice})
+	}
+	if len(ranking) == 0 {
+		return nil, NewError(NoViableInstances, "could not recommend cluster with the requested resources in any of the [%d] provider regions searched", len(targets))
+	}
+
+	sort.SliceStable(ranking, func(i, j int) bool {
+		if ranking[i].TotalPrice != ranking[j].TotalPrice {
+			return ranking[i].TotalPrice < ranking[j].TotalPrice
+		}
+		if ranking[i].Provider != ranking[j].Provider {
+			return ranking[i].Provider < ranking[j].Provider
+		}
+		return ranking[i].Region < ranking[j].Region
+	})
+	resp.Feasible = len(ranking)
+	if len(ranking) > optimumRankingSize {
+		ranking = ranking[:optimumRankingSize]
+	}
+	resp.Target, resp.Recommendation, resp.Ranking = ranking[0].Target, byTarget[ranking[0].Target], ranking
+	return resp, nil
+}
+
+// optimumTargets lists the regions of the providers, all the providers of the source if none are given; the regions
+// are listed round-robin over the providers so that every provider is searched if the targets are limited
+func (e *Engine) optimumTargets(providers []string) ([]Target, error) {
+	known, err := e.GetProviders()
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, p := range known {
+		ids = append(ids, p.ID)
+	}
+	if len(providers) == 0 {
+		providers = ids
+	}
+
+	regions := make([][]Region, len(providers))
+	var most int
+	for i, provider := range providers {
+		if !contains(ids, provider) {
+			return nil, NewError(ProviderUnsupported, "provider [%s] is not supported", provider)
+		}
+		if regions[i], err = e.GetRegions(provider); err != nil {
+			return nil, err
+		}
+		sort.Slice(regions[i], func(a, b int) bool { return regions[i][a].ID < regions[i][b].ID })
+		if len(regions[i]) > most {
+			most = len(regions[i])
+		}
+	}
+
+	var targets []Target
+	for r := 0; r < most; r++ {
+		for i, provider := range providers {
+			if r < len(regions[i]) {
+				targets = append(targets, Target{Provider: provider, Region: regions[i][r].ID})
+			}
+		}
+	}
+	return targets, nil
+}
@@ -0,0 +1,112 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"testing"
+
+	"github.com/banzaicloud/productinfo/pkg/productinfo-client/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// globalProductInfoSource prices the products of the dummy source per provider and region
+type globalProductInfoSource struct {
+	dummyProductInfoSource
+	// price multiplier per provider/region, regions without a multiplier have no products
+	multipliers map[string]float64
+}
+
+func (piCli *globalProductInfoSource) GetProductDetails(provider string, region string) ([]*models.ProductDetails, error) {
+	m, ok := piCli.multipliers[provider+"/"+region]
+	if !ok {
+		return nil, nil
+	}
+	regional := regionalProductInfoSource{multipliers: map[string]float64{region: m}}
+	return regional.GetProductDetails(provider, region)
+}
+
+func TestEngine_RecommendOptimum(t *testing.T) {
+	pi := &globalProductInfoSource{multipliers: map[string]float64{
+		"dummy/dummyRegion1": 2,
+		"dummy/dummyRegion2": 1.5,
+		"ec2/dummyRegion2":   1,
+	}}
+	req := ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(100)}
+
+	tests := []struct {
+		name       string
+		providers  []string
+		maxTargets int
+		check      func(resp *OptimumResp, err error)
+	}{
+		{
+			name: "cheapest region of all the providers",
+			check: func(resp *OptimumResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, Target{Provider: "ec2", Region: "dummyRegion2"}, resp.Target)
+				assert.Equal(t, resp.Recommendation.Accuracy.RecTotalPrice, resp.Ranking[0].TotalPrice)
+				assert.Equal(t, 6, resp.Searched)
+				assert.Equal(t, 3, resp.Feasible)
+				assert.Equal(t, []Target{{"ec2", "dummyRegion2"}, {"dummy", "dummyRegion2"}, {"dummy", "dummyRegion1"}},
+					[]Target{resp.Ranking[0].Target, resp.Ranking[1].Target, resp.Ranking[2].Target})
+			},
+		},
+		{
+			name:      "cheapest region of the allowed providers",
+			providers: []string{"dummy", "dummy"},
+			check: func(resp *OptimumResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, Target{Provider: "dummy", Region: "dummyRegion2"}, resp.Target)
+				assert.Equal(t, 2, resp.Searched)
+			},
+		},
+		{
+			name:       "targets limited round-robin over the providers",
+			maxTargets: 4,
+			check: func(resp *OptimumResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				// the first regions of the providers, then the second region of ec2
+				assert.Equal(t, 4, resp.Searched)
+				assert.Equal(t, 2, resp.Skipped)
+				assert.Equal(t, Target{Provider: "ec2", Region: "dummyRegion2"}, resp.Target)
+				assert.Equal(t, 2, resp.Feasible)
+			},
+		},
+		{
+			name:      "error - unsupported provider",
+			providers: []string{"dummy", "unknown"},
+			check: func(resp *OptimumResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.Equal(t, ProviderUnsupported, ErrorCode(err))
+			},
+		},
+		{
+			name:      "error - no feasible region",
+			providers: []string{"gce"},
+			check: func(resp *OptimumResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.Equal(t, NoViableInstances, ErrorCode(err))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(pi, WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+			test.check(engine.RecommendOptimum(context.Background(), test.providers, test.maxTargets, req))
+		})
+	}
+}
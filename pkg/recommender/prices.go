@@ -0,0 +1,76 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+// PriceAdjuster transforms the hourly list prices of the instance types before the candidates are scored, eg: to
+// apply negotiated discounts or chargeback overheads; the returned price is used for scoring and reported in the
+// recommendation, the maximum spot price of the request and the spot bids are compared to the list prices. The
+// custom machine types and the reserved prices (as regular prices) are adjusted too
+type PriceAdjuster interface {
+	// AdjustPrice returns the price of the instance type of the class (regular or spot) on the provider in the region
+	AdjustPrice(provider string, region string, vmType string, vmClass string, price float64) float64
+}
+
+// listPrices is the default price adjuster of the engine, the list prices are used as reported
+type listPrices struct{}
+
+// AdjustPrice returns the list price
+func (listPrices) AdjustPrice(provider string, region string, vmType string, vmClass string, price float64) float64 {
+	return price
+}
+
+// adjustPrices sets the on-demand and spot prices of the vms to the prices returned by the price adjuster of the engine,
+// the list prices are kept for the bids sent to the provider
+func (e *Engine) adjustPrices(provider string, region string, vms []VirtualMachine) {
+	if _, ok := e.adjuster.(listPrices); ok {
+		return
+	}
+	for i := range vms {
+		vms[i].listOnDemandPrice, vms[i].listSpotPrice = vms[i].OnDemandPrice, vms[i].AvgPrice
+		vms[i].OnDemandPrice = e.adjuster.AdjustPrice(provider, region, vms[i].Type, regular, vms[i].OnDemandPrice)
+		if vms[i].AvgPrice > 0 {
+			vms[i].AvgPrice = e.adjuster.AdjustPrice(provider, region, vms[i].Type, spot, vms[i].AvgPrice)
+		}
+	}
+}
+
+// adjustReservedPrices returns the reserved prices of the instance types adjusted by the price adjuster of the engine
+// as regular prices
+func (e *Engine) adjustReservedPrices(provider string, region string, prices map[string]float64) map[string]float64 {
+	if _, ok := e.adjuster.(listPrices); ok || prices == nil {
+		return prices
+	}
+	adjusted := make(map[string]float64, len(prices))
+	for vmType, price := range prices {
+		adjusted[vmType] = e.adjuster.AdjustPrice(provider, region, vmType, regular, price)
+	}
+	return adjusted
+}
+
+// listOnDemand returns the on-demand list price of the vm
+func (v *VirtualMachine) listOnDemand() float64 {
+	if v.listOnDemandPrice > 0 {
+		return v.listOnDemandPrice
+	}
+	return v.OnDemandPrice
+}
+
+// listSpot returns the average spot list price of the vm
+func (v *VirtualMachine) listSpot() float64 {
+	if v.listSpotPrice > 0 {
+		return v.listSpotPrice
+	}
+	return v.AvgPrice
+}
@@ -0,0 +1,175 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// discountAdjuster applies negotiated discounts (percentage) per instance family
+type discountAdjuster struct {
+	discounts map[string]float64
+}
+
+func (a discountAdjuster) AdjustPrice(provider string, region string, vmType string, vmClass string, price float64) float64 {
+	return price * (1 - a.discounts[InstanceFamily(provider, vmType)]/100)
+}
+
+func TestEngine_RecommendClusterPriceAdjuster(t *testing.T) {
+	req := ClusterRecommendationReq{SumCpu: 16, SumMem: 32, MinNodes: 2, MaxNodes: 6, OnDemandPct: onDemand(100)}
+
+	tests := []struct {
+		name     string
+		adjuster PriceAdjuster
+		check    func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:     "list prices by default",
+			adjuster: listPrices{},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"c5.xlarge"}, poolTypes(resp))
+				assert.InDelta(t, 4*0.192, resp.Accuracy.RecTotalPrice, 1e-9)
+			},
+		},
+		{
+			name:     "discount on every family",
+			adjuster: discountAdjuster{discounts: map[string]float64{"c5": 20, "m5": 20, "r5": 20}},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"c5.xlarge"}, poolTypes(resp))
+				assert.InDelta(t, 4*0.192*0.8, resp.Accuracy.RecTotalPrice, 1e-9, "the discounted price should be reported")
+				assert.InDelta(t, 0.192*0.8, resp.NodePools[0].VmType.OnDemandPrice, 1e-9)
+			},
+		},
+		{
+			name:     "discount on a family changes the layout",
+			adjuster: discountAdjuster{discounts: map[string]float64{"m5": 20}},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"m5.xlarge"}, poolTypes(resp))
+				assert.InDelta(t, 4*0.214*0.8, resp.Accuracy.RecTotalPrice, 1e-9)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(mustFileSource(t), WithCacheTTL(0), WithPriceAdjuster(test.adjuster))
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.RecommendCluster("ec2", "eu-west-1", req))
+		})
+	}
+
+	_, err := NewEngine(mustFileSource(t), WithPriceAdjuster(nil))
+	assert.NotNil(t, err, "the engine should not be created without a price adjuster")
+}
+
+// poolTypes returns the instance types of the node pools with nodes
+func poolTypes(resp *ClusterRecommendationResp) []string {
+	var types []string
+	for _, np := range resp.NodePools {
+		if np.SumNodes > 0 {
+			types = append(types, np.VmType.Type)
+		}
+	}
+	return types
+}
+
+// flatAdjuster applies the same factor to every price
+type flatAdjuster float64
+
+func (a flatAdjuster) AdjustPrice(provider string, region string, vmType string, vmClass string, price float64) float64 {
+	return price * float64(a)
+}
+
+func TestEngine_RecommendClusterPriceAdjusterCustomTypes(t *testing.T) {
+	fs, err := NewFileProductInfoSource("testdata/custom.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	small := ClusterRecommendationReq{SumCpu: 6, SumMem: 8, MinNodes: 1, MaxNodes: 3, OnDemandPct: onDemand(100), CustomTypes: true}
+	large := ClusterRecommendationReq{SumCpu: 8, SumMem: 30, MinNodes: 1, MaxNodes: 4, OnDemandPct: onDemand(100), CustomTypes: true}
+
+	tests := []struct {
+		name     string
+		adjuster PriceAdjuster
+		request  ClusterRecommendationReq
+		check    func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:     "custom machine type discounted like the predefined ones",
+			adjuster: flatAdjuster(0.8),
+			request:  small,
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "custom-6-8192", resp.NodePools[0].VmType.Type)
+				assert.InDelta(t, 0.180649*0.8, resp.Accuracy.RecTotalPrice, 1e-9)
+			},
+		},
+		{
+			name:     "discounted predefined instance types cheaper than the custom one at list price",
+			adjuster: discountAdjuster{discounts: map[string]float64{"n1-standard": 20, "n1-highcpu": 20, "n1-highmem": 20}},
+			request:  small,
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"n1-standard-2"}, poolTypes(resp))
+			},
+		},
+		{
+			name:     "discounted custom machine type cheaper than the predefined instance types",
+			adjuster: discountAdjuster{discounts: map[string]float64{"custom": 20}},
+			request:  large,
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"custom-8-30720"}, poolTypes(resp))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(fs, WithCacheTTL(0), WithPriceAdjuster(test.adjuster))
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.RecommendCluster("gce", "europe-west1", test.request))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterPriceAdjusterSpotBid(t *testing.T) {
+	engine, err := NewEngine(mustFileSource(t), WithCacheTTL(0), WithPriceAdjuster(flatAdjuster(0.8)))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	resp, err := engine.RecommendCluster("ec2", "eu-west-1", ClusterRecommendationReq{SumCpu: 16, SumMem: 32, MinNodes: 2, MaxNodes: 6, OnDemandPct: onDemand(0), MaxSpotPrice: 10})
+	assert.Nil(t, err, "the error should be nil")
+	var bids int
+	for _, np := range resp.NodePools {
+		if np.VmClass == spot && np.SumNodes > 0 {
+			bids++
+			assert.InDelta(t, np.VmType.OnDemandPrice/0.8, np.SpotBid, 1e-9, "the bid should be capped at the on-demand list price")
+		}
+	}
+	assert.True(t, bids > 0, "spot pools should be recommended")
+}
+
+func TestEngine_RecommendClusterPriceAdjusterMaxSpotPrice(t *testing.T) {
+	engine, err := NewEngine(mustFileSource(t), WithCacheTTL(0), WithPriceAdjuster(flatAdjuster(0.8)))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	// the discounted spot prices of m5.xlarge and c5.xlarge are below the cap, their list prices aren't
+	resp, err := engine.RecommendCluster("ec2", "eu-west-1", ClusterRecommendationReq{SumCpu: 16, SumMem: 32, MinNodes: 2, MaxNodes: 10, OnDemandPct: onDemand(0), MaxSpotPrice: 0.065})
+	assert.Nil(t, err, "the error should be nil")
+	assert.Equal(t, []string{"m5.large"}, poolTypes(resp), "the spot prices should be capped at their list price")
+}
@@ -15,18 +15,31 @@
 package recommender
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/banzaicloud/productinfo/pkg/productinfo-client/client"
 	"github.com/banzaicloud/productinfo/pkg/productinfo-client/client/attributes"
 	"github.com/banzaicloud/productinfo/pkg/productinfo-client/client/products"
+	"github.com/banzaicloud/productinfo/pkg/productinfo-client/client/providers"
 	"github.com/banzaicloud/productinfo/pkg/productinfo-client/client/regions"
 	"github.com/banzaicloud/productinfo/pkg/productinfo-client/models"
+	"github.com/go-openapi/runtime"
 )
 
-// ProductInfoSource declares operations for retrieving information required for the recommender engine
+// ProductInfoSource declares operations for retrieving information required for the recommender engine; the operations
+// take no context, a call the engine gives up on keeps running until the source returns, so the sources should bound
+// their calls, eg: the product info client times out the requests to the service
 type ProductInfoSource interface {
+	// GetProviders retrieves the identifiers of the supported cloud providers
+	GetProviders() ([]string, error)
+
 	// GetAttributeValues retrieves attribute values based on the given arguments
 	GetAttributeValues(provider string, region string, attr string) ([]float64, error)
 
+	// GetRegions retrieves the regions of the given provider
+	GetRegions(provider string) ([]*models.RegionResp, error)
+
 	// GetRegion describes the given region fof the given provider
 	GetRegion(provider string, region string) ([]string, error)
 
@@ -34,33 +47,121 @@ type ProductInfoSource interface {
 	GetProductDetails(provider string, region string) ([]*models.ProductDetails, error)
 }
 
+// LocalStorageSource is implemented by the product info sources reporting the local storage of the instance types
+type LocalStorageSource interface {
+	// GetLocalStorage retrieves the local storage (GB) per instance type on the provider in the region,
+	// nil if it's not reported on the provider
+	GetLocalStorage(provider string, region string) (map[string]float64, error)
+}
+
+// PodLimitSource is implemented by the product info sources reporting the maximum number of pods on the instance types
+type PodLimitSource interface {
+	// GetPodLimits retrieves the maximum number of pods per instance type on the provider in the region, eg: the limits
+	// derived from the network interfaces and their ip addresses on ec2; the instance types without a limit are omitted
+	GetPodLimits(provider string, region string) (map[string]int, error)
+}
+
+// StorageTypeSource is implemented by the product info sources reporting the storage classes supported by the instance types
+type StorageTypeSource interface {
+	// GetStorageTypes retrieves the supported storage classes per instance type on the provider in the region, eg: nvme,
+	// nil if they're not reported on the provider
+	GetStorageTypes(provider string, region string) (map[string][]string, error)
+}
+
+// SpotPriceVarianceSource is implemented by the product info sources reporting the recent variance of the spot prices
+type SpotPriceVarianceSource interface {
+	// GetSpotPriceVariance retrieves the variance of the recent spot prices per instance type on the provider in the region,
+	// nil if it's not reported on the provider
+	GetSpotPriceVariance(provider string, region string) (map[string]float64, error)
+}
+
+// SpotInterruptionSource is implemented by the product info sources reporting the frequency of the spot interruptions
+type SpotInterruptionSource interface {
+	// GetSpotInterruptionRates retrieves the upper bound of the interruption frequency tier (percentage of the spot
+	// instances interrupted a month) per instance type on the provider in the region, nil if it's not reported on the provider
+	GetSpotInterruptionRates(provider string, region string) (map[string]float64, error)
+}
+
+// ReservedPriceSource is implemented by the product info sources reporting the reserved (committed use) prices of the instance types
+type ReservedPriceSource interface {
+	// GetReservedPrices retrieves the effective hourly reserved price per instance type on the provider in the region for the
+	// commitment term, nil if it's not reported on the provider
+	GetReservedPrices(provider string, region string, term string) (map[string]float64, error)
+}
+
+// CustomMachinePriceSource is implemented by the product info sources reporting the prices of custom machine types
+type CustomMachinePriceSource interface {
+	// GetCustomMachinePrices retrieves the prices of the resources of the custom machine types on the provider in the region,
+	// nil if custom machine types are not available there
+	GetCustomMachinePrices(provider string, region string) (*CustomMachinePrices, error)
+}
+
+// PriceVersionSource is implemented by the product info sources identifying the version of the prices they report
+type PriceVersionSource interface {
+	// PriceVersion identifies the version of the prices on the provider in the region, it changes whenever the prices change
+	PriceVersion(provider string, region string) string
+}
+
+// SnapshotSource is implemented by the product info sources serving a snapshot of the product info instead of live data
+type SnapshotSource interface {
+	// SnapshotTime returns the time the snapshot was taken
+	SnapshotTime() time.Time
+}
+
 // ProductInfoClient application struct to retrieve data for the recommender; wraps the generated product info client
 // It implements the ProductInfoSource interface, delegates to the embedded generated client
 type ProductInfoClient struct {
 	*client.Productinfo
 }
 
+// the engine depends on the interface only, the product info client is its default implementation
+var _ ProductInfoSource = (*ProductInfoClient)(nil)
+
 // NewProductInfoClient creates a new product info client wrapper instance
 func NewProductInfoClient(pic *client.Productinfo) *ProductInfoClient {
 	return &ProductInfoClient{Productinfo: pic}
 }
 
+// GetProviders retrieves the identifiers of the providers known by the product info service
+func (piCli *ProductInfoClient) GetProviders() ([]string, error) {
+	gpp := providers.NewGetProvidersParams()
+	r, err := piCli.Providers.GetProviders(gpp)
+	if err != nil {
+		return nil, err
+	}
+	var ps []string
+	for _, p := range r.Payload.Providers {
+		ps = append(ps, p.Provider)
+	}
+	return ps, nil
+}
+
 // GetAttributeValues retrieves available attribute values on the provider in the region for the attribute
 func (piCli *ProductInfoClient) GetAttributeValues(provider string, region string, attr string) ([]float64, error) {
 	attrParams := attributes.NewGetAttrValuesParams().WithProvider(provider).WithRegion(region).WithAttribute(attr).WithService("compute")
 	allValues, err := piCli.Attributes.GetAttrValues(attrParams)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err, RegionNotFound, "region [%s] is not known on provider [%s]", region, provider)
 	}
 	return allValues.Payload.AttributeValues, nil
 }
 
+// GetRegions retrieves the regions the product info service has information about for the provider
+func (piCli *ProductInfoClient) GetRegions(provider string) ([]*models.RegionResp, error) {
+	grp := regions.NewGetRegionsParams().WithProvider(provider).WithService("compute")
+	r, err := piCli.Regions.GetRegions(grp)
+	if err != nil {
+		return nil, notFound(err, ProviderUnsupported, "provider [%s] is not supported", provider)
+	}
+	return r.Payload, nil
+}
+
 // GetRegion describes the region (eventually returns the zones in the region)
 func (piCli *ProductInfoClient) GetRegion(provider string, region string) ([]string, error) {
 	grp := regions.NewGetRegionParams().WithProvider(provider).WithService("compute").WithRegion(region)
 	r, err := piCli.Regions.GetRegion(grp)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err, RegionNotFound, "region [%s] is not known on provider [%s]", region, provider)
 	}
 	return r.Payload.Zones, nil
 }
@@ -70,7 +171,16 @@ func (piCli *ProductInfoClient) GetProductDetails(provider string, region string
 	gpdp := products.NewGetProductsParams().WithRegion(region).WithProvider(provider).WithService("compute")
 	allProducts, err := piCli.Products.GetProducts(gpdp)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err, RegionNotFound, "region [%s] is not known on provider [%s]", region, provider)
 	}
 	return allProducts.Payload.Products, nil
 }
+
+// notFound turns the not found responses of the product info service into an engine error with the code, other
+// errors are returned as they are
+func notFound(err error, code string, format string, args ...interface{}) error {
+	if e, ok := err.(*runtime.APIError); ok && e.Code == http.StatusNotFound {
+		return NewError(code, format, args...)
+	}
+	return err
+}
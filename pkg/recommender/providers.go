@@ -0,0 +1,439 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/banzaicloud/productinfo/pkg/productinfo-client/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// providerNames holds the display names of the well known providers
+var providerNames = map[string]string{
+	"ec2":     "Amazon Web Services",
+	"gce":     "Google Cloud",
+	"azure":   "Microsoft Azure",
+	"oracle":  "Oracle Cloud",
+	"alibaba": "Alibaba Cloud",
+}
+
+// Provider describes a cloud provider the engine can recommend clusters on
+type Provider struct {
+	// Identifier of the provider, to be used in the request path
+	ID string `json:"id"`
+	// Display name of the provider
+	Name string `json:"name"`
+}
+
+// GetProviders retrieves the providers the engine can serve recommendations for
+func (e *Engine) GetProviders() ([]Provider, error) {
+	ids, err := e.piSource.GetProviders()
+	if err != nil {
+		log.WithError(err).Error("could not retrieve providers")
+		return nil, upstreamError(err)
+	}
+
+	providers := make([]Provider, 0, len(ids))
+	for _, id := range ids {
+		name, ok := providerNames[id]
+		if !ok {
+			// fall back to the identifier for providers without a display name
+			name = id
+		}
+		providers = append(providers, Provider{ID: id, Name: name})
+	}
+	return providers, nil
+}
+
+// Region describes a region of a cloud provider
+type Region struct {
+	// Identifier of the region, to be used in the request path
+	ID string `json:"id"`
+	// Display name of the region
+	Name string `json:"name"`
+}
+
+// GetRegions retrieves the regions of the provider the engine can serve recommendations for
+func (e *Engine) GetRegions(provider string) ([]Region, error) {
+	rs, err := e.piSource.GetRegions(provider)
+	if err != nil {
+		log.WithError(err).Errorf("could not retrieve regions for provider: %s", provider)
+		return nil, upstreamError(err)
+	}
+
+	regions := make([]Region, 0, len(rs))
+	for _, r := range rs {
+		regions = append(regions, Region{ID: r.ID, Name: r.Name})
+	}
+	return regions, nil
+}
+
+// GetZones retrieves the availability zones in the region of the provider
+func (e *Engine) GetZones(provider string, region string) ([]string, error) {
+	zones, err := e.piSource.GetRegion(provider, region)
+	if err != nil {
+		log.WithError(err).Errorf("could not describe region: %s, provider: %s", region, provider)
+		return nil, upstreamError(err)
+	}
+
+	if zones == nil {
+		// no zones reported for the region
+		zones = make([]string, 0)
+	}
+	return zones, nil
+}
+
+// InstanceType describes an instance type in a region as reported by the product info source
+type InstanceType struct {
+	VirtualMachine
+	// Current spot price of the instance type per availability zone
+	SpotPrice map[string]float64 `json:"spotPrice"`
+	// On-demand price per cpu
+	PricePerCpu float64 `json:"pricePerCpu"`
+	// On-demand price per GB of memory
+	PricePerMem float64 `json:"pricePerMem"`
+	// Average spot price per cpu, set if the spot metrics are requested
+	SpotPricePerCpu float64 `json:"spotPricePerCpu,omitempty"`
+	// Average spot price per GB of memory, set if the spot metrics are requested
+	SpotPricePerMem float64 `json:"spotPricePerMem,omitempty"`
+}
+
+// the orders of the listed instance types by normalized price
+const (
+	sortPricePerCpu = "pricePerCpu"
+	sortPricePerMem = "pricePerMem"
+)
+
+// GetInstanceType retrieves the product info of the instance type in the region of the provider, the same product info
+// the recommendations are made from; well-known aliases of the instance type are accepted
+func (e *Engine) GetInstanceType(provider string, region string, vmType string) (*InstanceType, error) {
+	zones, products, err := e.regionProducts(provider, region)
+	if err != nil {
+		return nil, err
+	}
+	return lookupInstanceType(provider, region, zones, products, vmType)
+}
+
+// InstanceTypeComparison is the side-by-side of two instance types in a region
+type InstanceTypeComparison struct {
+	// The first instance type, with its spot metrics
+	A InstanceType `json:"a"`
+	// The second instance type, with its spot metrics
+	B InstanceType `json:"b"`
+	// The instance type of the lower on-demand price per cpu, empty if they are priced the same
+	CheaperPerCpu string `json:"cheaperPerCpu,omitempty"`
+	// The instance type of the lower average spot price per cpu, empty if they are priced the same
+	CheaperSpotPerCpu string `json:"cheaperSpotPerCpu,omitempty"`
+}
+
+// CompareInstanceTypes retrieves the product info of two instance types in the region of the provider side-by-side,
+// both of them must be available in the region
+func (e *Engine) CompareInstanceTypes(provider string, region string, a string, b string) (*InstanceTypeComparison, error) {
+	zones, products, err := e.regionProducts(provider, region)
+	if err != nil {
+		return nil, err
+	}
+	itA, err := lookupInstanceType(provider, region, zones, products, a)
+	if err != nil {
+		return nil, err
+	}
+	itB, err := lookupInstanceType(provider, region, zones, products, b)
+	if err != nil {
+		return nil, err
+	}
+	itA.normalizePrices(true)
+	itB.normalizePrices(true)
+	return &InstanceTypeComparison{
+		A:                 *itA,
+		B:                 *itB,
+		CheaperPerCpu:     cheaperType(itA.Type, itA.PricePerCpu, itB.Type, itB.PricePerCpu),
+		CheaperSpotPerCpu: cheaperType(itA.Type, itA.SpotPricePerCpu, itB.Type, itB.SpotPricePerCpu),
+	}, nil
+}
+
+// cheaperType returns the instance type of the lower price, empty if they are priced the same or a price is unknown
+func cheaperType(a string, priceA float64, b string, priceB float64) string {
+	switch {
+	case priceA == 0 || priceB == 0 || priceA == priceB:
+		return ""
+	case priceA < priceB:
+		return a
+	default:
+		return b
+	}
+}
+
+// regionProducts retrieves the zones and the products of the region of the provider
+func (e *Engine) regionProducts(provider string, region string) ([]string, []*models.ProductDetails, error) {
+	zones, err := e.GetZones(provider, region)
+	if err != nil {
+		return nil, nil, err
+	}
+	products, err := e.piSource.GetProductDetails(provider, region)
+	if err != nil {
+		log.WithError(err).Errorf("could not get product details. region: %s, provider: %s", region, provider)
+		return nil, nil, upstreamError(err)
+	}
+	return zones, products, nil
+}
+
+// lookupInstanceType looks up the instance type among the products of the region by its name on the provider
+func lookupInstanceType(provider string, region string, zones []string, products []*models.ProductDetails, vmType string) (*InstanceType, error) {
+	canonical := canonicalInstanceType(provider, vmType)
+	for _, p := range products {
+		if p.Type == canonical {
+			it := newInstanceType(provider, zones, *p)
+			return &it, nil
+		}
+	}
+	return nil, NewError(UnknownInstanceType, "instance type [%s] is not available on provider [%s] in region [%s]", vmType, provider, region)
+}
+
+// InstanceFilters selects the instance types listed in a region
+type InstanceFilters struct {
+	// Minimum number of cpus of the instance types
+	MinCpu float64 `form:"minCpu" json:"minCpu" binding:"min=0"`
+	// Minimum memory of the instance types (GB)
+	MinMem float64 `form:"minMem" json:"minMem" binding:"min=0"`
+	// Maximum on-demand price of the instance types, no limit if not set
+	MaxPrice float64 `form:"maxPrice" json:"maxPrice" binding:"min=0"`
+	// Cpu architecture of the instance types (amd64 or arm64), any if not set
+	Arch string `form:"arch" json:"arch" binding:"omitempty,architecture"`
+	// Order of the instance types: price (on-demand, default), cpu, memory, pricePerCpu or pricePerMem, ascending
+	Sort string `form:"sort" json:"sort" binding:"omitempty,eq=price|eq=cpu|eq=memory|eq=pricePerCpu|eq=pricePerMem"`
+	// Compute the normalized prices from the average spot price as well, the instance types are sorted by the spot
+	// metrics if ordered by normalized price
+	Spot bool `form:"spot" json:"spot"`
+}
+
+// the default number of instance types listed on a page
+const defaultPageSize = 50
+
+// Page selects a page of a listing
+type Page struct {
+	// Maximum number of items on the page, defaults to 50
+	Limit int `form:"limit" json:"limit" binding:"min=0,max=500"`
+	// Number of items skipped before the page
+	Offset int `form:"offset" json:"offset" binding:"min=0"`
+}
+
+// InstanceTypePage is a page of the instance types matching the filters
+type InstanceTypePage struct {
+	// The instance types on the page
+	InstanceTypes []InstanceType `json:"instanceTypes"`
+	// Total number of instance types matching the filters
+	Total int `json:"total"`
+	// Maximum number of instance types on the page
+	Limit int `json:"limit"`
+	// Number of instance types before the page
+	Offset int `json:"offset"`
+	// Offset of the next page, not set on the last page
+	NextOffset int `json:"nextOffset,omitempty"`
+}
+
+// ListInstances retrieves a page of the instance types in the region of the provider matching the filters, from the
+// same product info the recommendations are made from; only the instance types on the page are described in full
+func (e *Engine) ListInstances(provider string, region string, filters InstanceFilters, page Page) (*InstanceTypePage, error) {
+	zones, err := e.GetZones(provider, region)
+	if err != nil {
+		return nil, err
+	}
+	products, err := e.piSource.GetProductDetails(provider, region)
+	if err != nil {
+		log.WithError(err).Errorf("could not get product details. region: %s, provider: %s", region, provider)
+		return nil, upstreamError(err)
+	}
+
+	type match struct {
+		vm      VirtualMachine
+		product *models.ProductDetails
+	}
+	var matches []match
+	for _, p := range products {
+		if vm := newVirtualMachine(provider, zones, *p); filters.matches(vm) {
+			matches = append(matches, match{vm, p})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i].vm, matches[j].vm
+		switch filters.Sort {
+		case Cpu:
+			if a.Cpus != b.Cpus {
+				return a.Cpus < b.Cpus
+			}
+		case Memory:
+			if a.Mem != b.Mem {
+				return a.Mem < b.Mem
+			}
+		case sortPricePerCpu, sortPricePerMem:
+			pa, okA := filters.normalizedPrice(a)
+			pb, okB := filters.normalizedPrice(b)
+			if okA != okB {
+				return okA
+			}
+			if pa != pb {
+				return pa < pb
+			}
+		}
+		if a.OnDemandPrice != b.OnDemandPrice {
+			return a.OnDemandPrice < b.OnDemandPrice
+		}
+		return a.Type < b.Type
+	})
+
+	if page.Limit == 0 {
+		page.Limit = defaultPageSize
+	}
+	resp := &InstanceTypePage{InstanceTypes: make([]InstanceType, 0), Total: len(matches), Limit: page.Limit, Offset: page.Offset}
+	if page.Offset >= len(matches) {
+		return resp, nil
+	}
+	end := page.Offset + page.Limit
+	if end < len(matches) {
+		resp.NextOffset = end
+	} else {
+		end = len(matches)
+	}
+	for _, m := range matches[page.Offset:end] {
+		it := InstanceType{VirtualMachine: m.vm, SpotPrice: spotPrices(m.product)}
+		it.normalizePrices(filters.Spot)
+		resp.InstanceTypes = append(resp.InstanceTypes, it)
+	}
+	return resp, nil
+}
+
+// matches checks whether the vm passes the filters
+func (f InstanceFilters) matches(vm VirtualMachine) bool {
+	if vm.Cpus < f.MinCpu || vm.Mem < f.MinMem {
+		return false
+	}
+	if f.MaxPrice > 0 && vm.OnDemandPrice > f.MaxPrice {
+		return false
+	}
+	return f.Arch == "" || vm.Architecture == f.Arch
+}
+
+// normalizedPrice returns the price per cpu or memory of the vm the instance types are sorted by, computed from the
+// average spot price if the spot metrics are requested; false if the vm has no spot price, it's sorted after the priced ones
+func (f InstanceFilters) normalizedPrice(vm VirtualMachine) (float64, bool) {
+	price := vm.OnDemandPrice
+	if f.Spot {
+		if vm.AvgPrice == 0 {
+			return 0, false
+		}
+		price = vm.AvgPrice
+	}
+	if f.Sort == sortPricePerCpu {
+		return perUnit(price, vm.Cpus), true
+	}
+	return perUnit(price, vm.Mem), true
+}
+
+// normalizePrices computes the on-demand prices per cpu and memory of the instance type, and the spot variants if requested
+func (it *InstanceType) normalizePrices(spot bool) {
+	it.PricePerCpu = perUnit(it.OnDemandPrice, it.Cpus)
+	it.PricePerMem = perUnit(it.OnDemandPrice, it.Mem)
+	if spot {
+		it.SpotPricePerCpu = perUnit(it.AvgPrice, it.Cpus)
+		it.SpotPricePerMem = perUnit(it.AvgPrice, it.Mem)
+	}
+}
+
+// perUnit divides the price by the number of units, 0 if there are no units
+func perUnit(price float64, units float64) float64 {
+	if units == 0 {
+		return 0
+	}
+	return price / units
+}
+
+// newInstanceType creates the instance type of the product
+func newInstanceType(provider string, zones []string, p models.ProductDetails) InstanceType {
+	it := InstanceType{VirtualMachine: newVirtualMachine(provider, zones, p), SpotPrice: spotPrices(&p)}
+	it.normalizePrices(false)
+	return it
+}
+
+// spotPrices returns the spot prices of the product by zone
+func spotPrices(p *models.ProductDetails) map[string]float64 {
+	prices := make(map[string]float64, len(p.SpotPrice))
+	for _, zp := range p.SpotPrice {
+		prices[zp.Zone] = zp.Price
+	}
+	return prices
+}
+
+// PriceVersion identifies the version of the prices the recommendations on the provider in the region are made from,
+// empty if the product info source doesn't report it
+func (e *Engine) PriceVersion(provider string, region string) string {
+	if vs, ok := e.piSource.(PriceVersionSource); ok {
+		return vs.PriceVersion(provider, region)
+	}
+	return ""
+}
+
+// Ping checks whether the product info source is reachable by listing the providers, gives up when the context is done
+func (e *Engine) Ping(ctx context.Context) error {
+	errc := make(chan error, 1)
+	go func() {
+		_, err := e.piSource.GetProviders()
+		errc <- err
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Health describes the reachability of the product info source and the age of the product info the recommendations
+// are made from
+type Health struct {
+	// The product info source responded in time
+	Reachable bool `json:"reachable"`
+	// The reason the product info source is unreachable
+	Error string `json:"error,omitempty"`
+	// The round-trip time of listing the providers (seconds)
+	LatencySeconds float64 `json:"latencySeconds"`
+	// The age of the product info snapshot served by the source (seconds), not set if the source serves live product info
+	SnapshotAgeSeconds float64 `json:"snapshotAgeSeconds,omitempty"`
+	// The age of the oldest cached recommendation (seconds), not set if no recommendations are cached
+	CacheAgeSeconds float64 `json:"cacheAgeSeconds,omitempty"`
+}
+
+// Health pings the product info source and measures the round-trip time, gives up when the context is done
+func (e *Engine) Health(ctx context.Context) Health {
+	start := time.Now()
+	err := e.Ping(ctx)
+	h := Health{Reachable: err == nil, LatencySeconds: time.Since(start).Seconds()}
+	if err != nil {
+		h.Error = err.Error()
+	}
+	if ss, ok := e.piSource.(SnapshotSource); ok {
+		h.SnapshotAgeSeconds = time.Since(ss.SnapshotTime()).Seconds()
+	}
+	if e.cache != nil {
+		if oldest, ok := e.cache.oldest(); ok {
+			h.CacheAgeSeconds = time.Since(oldest).Seconds()
+		}
+	}
+	return h
+}
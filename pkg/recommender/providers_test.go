@@ -0,0 +1,506 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_GetProviders(t *testing.T) {
+	tests := []struct {
+		name  string
+		pi    ProductInfoSource
+		check func([]Provider, error)
+	}{
+		{
+			name: "providers retrieved with display names",
+			pi:   &dummyProductInfoSource{},
+			check: func(providers []Provider, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []Provider{
+					{ID: "ec2", Name: "Amazon Web Services"},
+					{ID: "gce", Name: "Google Cloud"},
+					{ID: "dummy", Name: "dummy"}},
+					providers)
+			},
+		},
+		{
+			name: "error - providers could not be retrieved",
+			pi:   &dummyProductInfoSource{ProvidersError},
+			check: func(providers []Provider, err error) {
+				assert.Nil(t, providers, "the providers should be nil")
+				assert.EqualError(t, err, ProvidersError)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.pi)
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.GetProviders())
+		})
+	}
+}
+
+func TestEngine_GetRegions(t *testing.T) {
+	tests := []struct {
+		name  string
+		pi    ProductInfoSource
+		check func([]Region, error)
+	}{
+		{
+			name: "regions retrieved",
+			pi:   &dummyProductInfoSource{},
+			check: func(regions []Region, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []Region{
+					{ID: "dummyRegion1", Name: "Dummy Region 1"},
+					{ID: "dummyRegion2", Name: "Dummy Region 2"}},
+					regions)
+			},
+		},
+		{
+			name: "error - regions could not be retrieved",
+			pi:   &dummyProductInfoSource{RegionsError},
+			check: func(regions []Region, err error) {
+				assert.Nil(t, regions, "the regions should be nil")
+				assert.EqualError(t, err, RegionsError)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.pi)
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.GetRegions("dummy"))
+		})
+	}
+}
+
+func TestEngine_GetZones(t *testing.T) {
+	tests := []struct {
+		name  string
+		pi    ProductInfoSource
+		check func([]string, error)
+	}{
+		{
+			name: "zones retrieved",
+			pi:   &dummyProductInfoSource{},
+			check: func(zones []string, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"dummyZone1", "dummyZone2", "dummyZone3"}, zones)
+			},
+		},
+		{
+			name: "no zones reported - empty slice returned",
+			pi:   &dummyProductInfoSource{NoZones},
+			check: func(zones []string, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.NotNil(t, zones, "the zones should not be nil")
+				assert.Equal(t, 0, len(zones))
+			},
+		},
+		{
+			name: "error - region could not be described",
+			pi:   &dummyProductInfoSource{DescribeRegionError},
+			check: func(zones []string, err error) {
+				assert.Nil(t, zones, "the zones should be nil")
+				assert.EqualError(t, err, DescribeRegionError)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.pi)
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.GetZones("dummy", "dummyRegion"))
+		})
+	}
+}
+
+func TestEngine_GetInstanceType(t *testing.T) {
+	tests := []struct {
+		name   string
+		pi     ProductInfoSource
+		vmType string
+		check  func(*InstanceType, error)
+	}{
+		{
+			name:   "instance type found",
+			pi:     &dummyProductInfoSource{},
+			vmType: "type-10",
+			check: func(it *InstanceType, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "type-10", it.Type)
+				assert.Equal(t, float64(16), it.Cpus)
+				assert.Equal(t, float64(32), it.Mem)
+				assert.Equal(t, 0.68, it.OnDemandPrice)
+				assert.Equal(t, map[string]float64{"dummyZone2": 0.171}, it.SpotPrice)
+			},
+		},
+		{
+			name:   "error - instance type not available in the region",
+			pi:     &dummyProductInfoSource{},
+			vmType: "type-unknown",
+			check: func(it *InstanceType, err error) {
+				assert.Nil(t, it, "the instance type should be nil")
+				assert.Equal(t, UnknownInstanceType, ErrorCode(err))
+				assert.EqualError(t, err, "instance type [type-unknown] is not available on provider [dummy] in region [dummyRegion]")
+			},
+		},
+		{
+			name:   "error - product details could not be retrieved",
+			pi:     &dummyProductInfoSource{ProductDetailsError},
+			vmType: "type-10",
+			check: func(it *InstanceType, err error) {
+				assert.Nil(t, it, "the instance type should be nil")
+				assert.EqualError(t, err, ProductDetailsError)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.pi)
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.GetInstanceType("dummy", "dummyRegion", test.vmType))
+		})
+	}
+}
+
+func TestEngine_CompareInstanceTypes(t *testing.T) {
+	engine, err := NewEngine(mustFileSource(t))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	tests := []struct {
+		name  string
+		a, b  string
+		check func(*InstanceTypeComparison, error)
+	}{
+		{
+			name: "instance types side by side",
+			a:    "c5.xlarge",
+			b:    "m5.xlarge",
+			check: func(cmp *InstanceTypeComparison, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "c5.xlarge", cmp.A.Type)
+				assert.Equal(t, "m5.xlarge", cmp.B.Type)
+				assert.InDelta(t, 0.048, cmp.A.PricePerCpu, 1e-9)
+				assert.InDelta(t, 0.0535, cmp.B.PricePerCpu, 1e-9)
+				assert.Equal(t, cmp.A.AvgPrice/4, cmp.A.SpotPricePerCpu)
+				assert.Equal(t, "c5.xlarge", cmp.CheaperPerCpu)
+			},
+		},
+		{
+			name: "instance types of the same price per cpu",
+			a:    "m5.large",
+			b:    "m5.xl",
+			check: func(cmp *InstanceTypeComparison, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "m5.xlarge", cmp.B.Type, "the aliases should be resolved")
+				assert.Equal(t, "", cmp.CheaperPerCpu)
+			},
+		},
+		{
+			name: "error - instance type not available in the region",
+			a:    "m5.large",
+			b:    "x1.32xlarge",
+			check: func(cmp *InstanceTypeComparison, err error) {
+				assert.Nil(t, cmp, "the comparison should be nil")
+				assert.Equal(t, UnknownInstanceType, ErrorCode(err))
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(engine.CompareInstanceTypes("ec2", "eu-west-1", test.a, test.b))
+		})
+	}
+}
+
+func TestEngine_ListInstances(t *testing.T) {
+	tests := []struct {
+		name    string
+		pi      ProductInfoSource
+		filters InstanceFilters
+		page    Page
+		check   func(*InstanceTypePage, error)
+	}{
+		{
+			name:    "instance types filtered by resources, sorted by price",
+			pi:      &dummyProductInfoSource{},
+			filters: InstanceFilters{MinCpu: 16, MinMem: 32},
+			check: func(page *InstanceTypePage, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"type-10", "type-11", "type-12"}, instanceTypeNames(page.InstanceTypes))
+				assert.Equal(t, 3, page.Total)
+				assert.Equal(t, defaultPageSize, page.Limit)
+				assert.Equal(t, 0, page.NextOffset, "there should be no next page")
+			},
+		},
+		{
+			name: "first page of the instance types",
+			pi:   &dummyProductInfoSource{},
+			page: Page{Limit: 4},
+			check: func(page *InstanceTypePage, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"type-3", "type-5", "type-4", "type-6"}, instanceTypeNames(page.InstanceTypes))
+				assert.Equal(t, 10, page.Total)
+				assert.Equal(t, 4, page.NextOffset)
+			},
+		},
+		{
+			name: "last page of the instance types",
+			pi:   &dummyProductInfoSource{},
+			page: Page{Limit: 4, Offset: 8},
+			check: func(page *InstanceTypePage, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"type-11", "type-12"}, instanceTypeNames(page.InstanceTypes))
+				assert.Equal(t, 10, page.Total)
+				assert.Equal(t, 0, page.NextOffset, "there should be no next page")
+			},
+		},
+		{
+			name: "offset beyond the instance types - empty page returned",
+			pi:   &dummyProductInfoSource{},
+			page: Page{Offset: 20},
+			check: func(page *InstanceTypePage, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, 0, len(page.InstanceTypes))
+				assert.Equal(t, 10, page.Total)
+			},
+		},
+		{
+			name:    "instance types filtered by price, ties sorted by type",
+			pi:      &dummyProductInfoSource{},
+			filters: InstanceFilters{MaxPrice: 0.1},
+			check: func(page *InstanceTypePage, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"type-3", "type-5", "type-4", "type-6"}, instanceTypeNames(page.InstanceTypes))
+			},
+		},
+		{
+			name:    "instance types sorted by memory",
+			pi:      &dummyProductInfoSource{},
+			filters: InstanceFilters{MinMem: 16, MaxPrice: 1, Sort: Memory},
+			check: func(page *InstanceTypePage, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"type-8", "type-9", "type-10", "type-11"}, instanceTypeNames(page.InstanceTypes))
+			},
+		},
+		{
+			name:    "no instance types with the architecture - empty slice returned",
+			pi:      &dummyProductInfoSource{},
+			filters: InstanceFilters{Arch: Arm64},
+			check: func(page *InstanceTypePage, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.NotNil(t, page.InstanceTypes, "the instance types should not be nil")
+				assert.Equal(t, 0, len(page.InstanceTypes))
+				assert.Equal(t, 0, page.Total)
+			},
+		},
+		{
+			name: "error - product details could not be retrieved",
+			pi:   &dummyProductInfoSource{ProductDetailsError},
+			check: func(page *InstanceTypePage, err error) {
+				assert.Nil(t, page, "the page should be nil")
+				assert.EqualError(t, err, ProductDetailsError)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.pi)
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.ListInstances("dummy", "dummyRegion", test.filters, test.page))
+		})
+	}
+}
+
+func TestEngine_ListInstancesNormalizedPrices(t *testing.T) {
+	engine, err := NewEngine(mustFileSource(t))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	tests := []struct {
+		name    string
+		filters InstanceFilters
+		check   func(*InstanceTypePage, error)
+	}{
+		{
+			name:    "instance types sorted by on-demand price per cpu, ties sorted by price",
+			filters: InstanceFilters{Sort: sortPricePerCpu},
+			check: func(page *InstanceTypePage, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"c5.xlarge", "m5.large", "m5.xlarge", "m5.2xlarge", "r5.xlarge"}, instanceTypeNames(page.InstanceTypes))
+				assert.InDelta(t, 0.048, page.InstanceTypes[0].PricePerCpu, 1e-9)
+				assert.InDelta(t, 0.024, page.InstanceTypes[0].PricePerMem, 1e-9)
+				assert.Equal(t, 0.0, page.InstanceTypes[0].SpotPricePerCpu, "the spot metrics should not be set")
+			},
+		},
+		{
+			name:    "instance types sorted by on-demand price per memory",
+			filters: InstanceFilters{Sort: sortPricePerMem},
+			check: func(page *InstanceTypePage, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"r5.xlarge", "m5.large", "m5.xlarge", "m5.2xlarge", "c5.xlarge"}, instanceTypeNames(page.InstanceTypes))
+			},
+		},
+		{
+			name:    "instance types sorted by spot price per cpu",
+			filters: InstanceFilters{Sort: sortPricePerCpu, Spot: true},
+			check: func(page *InstanceTypePage, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"c5.xlarge", "m5.2xlarge", "m5.xlarge", "m5.large", "r5.xlarge"}, instanceTypeNames(page.InstanceTypes))
+				assert.InDelta(t, 0.0677/4, page.InstanceTypes[0].SpotPricePerCpu, 1e-9)
+				assert.InDelta(t, 0.0677/8, page.InstanceTypes[0].SpotPricePerMem, 1e-9)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(engine.ListInstances("ec2", "eu-west-1", test.filters, Page{}))
+		})
+	}
+
+	t.Run("instance types without spot price sorted last", func(t *testing.T) {
+		// the cheapest instance type per cpu has no spot price
+		fs := mustFileSource(t)
+		for _, p := range fs.snapshot.Providers["ec2"].Regions["eu-west-1"].Products {
+			if p.Type == "c5.xlarge" {
+				p.SpotPrice = nil
+			}
+		}
+		engine, err := NewEngine(fs)
+		assert.Nil(t, err, "the engine couldn't be created")
+
+		for _, sort := range []string{sortPricePerCpu, sortPricePerMem} {
+			page, err := engine.ListInstances("ec2", "eu-west-1", InstanceFilters{Sort: sort, Spot: true}, Page{})
+			assert.Nil(t, err, "the error should be nil")
+			names := instanceTypeNames(page.InstanceTypes)
+			assert.Equal(t, "c5.xlarge", names[len(names)-1], "sort: %s", sort)
+			assert.Equal(t, 0.0, page.InstanceTypes[len(names)-1].SpotPricePerCpu, "sort: %s", sort)
+		}
+	})
+}
+
+// instanceTypeNames returns the names of the instance types in order
+func instanceTypeNames(its []InstanceType) []string {
+	var names []string
+	for _, it := range its {
+		names = append(names, it.Type)
+	}
+	return names
+}
+
+func TestEngine_Ping(t *testing.T) {
+	tests := []struct {
+		name  string
+		pi    ProductInfoSource
+		check func(error)
+	}{
+		{
+			name: "product info reachable",
+			pi:   &dummyProductInfoSource{},
+			check: func(err error) {
+				assert.Nil(t, err, "the error should be nil")
+			},
+		},
+		{
+			name: "error - product info unreachable",
+			pi:   &dummyProductInfoSource{ProvidersError},
+			check: func(err error) {
+				assert.EqualError(t, err, ProvidersError)
+			},
+		},
+		{
+			name: "error - product info not responding in time",
+			pi:   &slowProductInfoSource{latency: 100 * time.Millisecond},
+			check: func(err error) {
+				assert.Equal(t, context.DeadlineExceeded, err)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.pi)
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			test.check(engine.Ping(ctx))
+		})
+	}
+}
+
+func TestEngine_Health(t *testing.T) {
+	tests := []struct {
+		name  string
+		pi    ProductInfoSource
+		check func(Health)
+	}{
+		{
+			name: "live product info reachable",
+			pi:   &dummyProductInfoSource{},
+			check: func(h Health) {
+				assert.True(t, h.Reachable, "the product info should be reachable")
+				assert.Empty(t, h.Error)
+				assert.Equal(t, 0.0, h.SnapshotAgeSeconds, "the snapshot age should not be set")
+			},
+		},
+		{
+			name: "age of the product info snapshot",
+			pi:   mustFileSource(t),
+			check: func(h Health) {
+				assert.True(t, h.Reachable, "the product info should be reachable")
+				assert.True(t, h.SnapshotAgeSeconds > 0, "the snapshot age should be set")
+			},
+		},
+		{
+			name: "error - product info unreachable",
+			pi:   &dummyProductInfoSource{ProvidersError},
+			check: func(h Health) {
+				assert.False(t, h.Reachable, "the product info should be unreachable")
+				assert.Equal(t, ProvidersError, h.Error)
+			},
+		},
+		{
+			name: "error - product info not responding in time",
+			pi:   &slowProductInfoSource{latency: 100 * time.Millisecond},
+			check: func(h Health) {
+				assert.False(t, h.Reachable, "the product info should be unreachable")
+				assert.Equal(t, context.DeadlineExceeded.Error(), h.Error)
+				assert.True(t, h.LatencySeconds < 0.1, "the ping should be time-boxed")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.pi)
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			test.check(engine.Health(ctx))
+		})
+	}
+}
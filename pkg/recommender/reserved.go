@@ -0,0 +1,115 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"math"
+	"sort"
+
+	"github.com/banzaicloud/productinfo/pkg/productinfo-client/models"
+)
+
+// the commitment term of the reserved prices if it's not set in the request
+const defaultCommitmentTerm = "1yr-no-upfront"
+
+// commitmentTerm returns the commitment term of the reserved prices requested
+func (req *ClusterRecommendationReq) commitmentTerm() string {
+	if req.CommitmentTerm == "" {
+		return defaultCommitmentTerm
+	}
+	return req.CommitmentTerm
+}
+
+// reserveNodes prices the requested percentage of the nodes of every regular node pool at the reserved price of its
+// instance type, the node pools of instance types without a reserved price are priced at the on-demand price
+func reserveNodes(nodePools []NodePool, prices map[string]float64, pct int) {
+	for i := range nodePools {
+		np := &nodePools[i]
+		price, ok := prices[np.VmType.Type]
+		if np.VmClass != regular || !ok || price <= 0 {
+			continue
+		}
+		np.VmType.ReservedPrice = price
+		// rounded to the nearest node
+		np.ReservedNodes = (np.SumNodes*pct + 50) / 100
+	}
+}
+
+// heldReservations lays out zero-priced node pools of the reserved instances held by the request, only as many of them
+// as needed for the resources not covered by the existing node pools, the instance types with the most cpus first; the
+// held instances are used regardless of the instance type filters of the request. The reservations of the instance
+// types not available in the region are ignored with a warning
+func (req *ClusterRecommendationReq) heldReservations(provider string, region string, pi *productInfo) ([]NodePool, []Warning) {
+	products := make(map[string]*models.ProductDetails, len(pi.products))
+	for _, p := range pi.products {
+		products[p.Type] = p
+	}
+
+	var (
+		vms      []VirtualMachine
+		warnings []Warning
+	)
+	for vmType, count := range req.Reserved {
+		p, ok := products[vmType]
+		if !ok {
+			warnings = append(warnings, newWarning(ReservationIgnored, "the reserved instance type [%s] is not available on provider [%s] in region [%s], the reservation is ignored", vmType, provider, region))
+			continue
+		}
+		if count > 0 {
+			vms = append(vms, newVirtualMachine(provider, pi.zones, *p))
+		}
+	}
+	sort.Slice(warnings, func(i, j int) bool {
+		return warnings[i].Message < warnings[j].Message
+	})
+	sort.Slice(vms, func(i, j int) bool {
+		if vms[i].Cpus != vms[j].Cpus {
+			return vms[i].Cpus > vms[j].Cpus
+		}
+		return vms[i].Type < vms[j].Type
+	})
+
+	rem, _ := req.remaining()
+	var held []NodePool
+	for _, vm := range vms {
+		nodes := int(math.Ceil(math.Max(rem.SumCpu/vm.Cpus, rem.SumMem/vm.Mem)))
+		if nodes > req.Reserved[vm.Type] {
+			nodes = req.Reserved[vm.Type]
+		}
+		if nodes <= 0 {
+			continue
+		}
+		// the held instances are a sunk cost
+		vm.ReservedPrice = 0
+		np := NodePool{VmType: vm, SumNodes: nodes, VmClass: regular, ReservedNodes: nodes}
+		held = append(held, np)
+		rem.SumCpu -= np.getSum(Cpu)
+		rem.SumMem -= np.getSum(Memory)
+	}
+	balanceZones(held, req.Zones)
+	return held, warnings
+}
+
+// reservedUsed returns the number of the held reserved instances consumed per instance type
+func reservedUsed(reserved map[string]int, held []NodePool) map[string]int {
+	used := make(map[string]int, len(reserved))
+	for vmType := range reserved {
+		used[vmType] = 0
+	}
+	for _, np := range held {
+		used[np.VmType.Type] += np.SumNodes
+	}
+	return used
+}
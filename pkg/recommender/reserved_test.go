@@ -0,0 +1,236 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the reserved price of the instance types relative to their on-demand price
+const reservedDiscount = 0.6
+
+// reservedProductInfoSource reports reserved prices for every instance type of the dummy source
+type reservedProductInfoSource struct {
+	dummyProductInfoSource
+	err  error
+	term string
+}
+
+func (piCli *reservedProductInfoSource) GetReservedPrices(provider string, region string, term string) (map[string]float64, error) {
+	piCli.term = term
+	if piCli.err != nil {
+		return nil, piCli.err
+	}
+	products, _ := piCli.GetProductDetails(provider, region)
+	prices := make(map[string]float64, len(products))
+	for _, p := range products {
+		prices[p.Type] = p.OnDemandPrice * reservedDiscount
+	}
+	return prices, nil
+}
+
+func Test_reserveNodes(t *testing.T) {
+	tests := []struct {
+		name      string
+		nodePools []NodePool
+		pct       int
+		check     func(nps []NodePool)
+	}{
+		{
+			name: "regular nodes reserved",
+			nodePools: []NodePool{
+				{SumNodes: 4, VmClass: regular, VmType: VirtualMachine{Type: "m5.large", OnDemandPrice: 0.1}},
+				{SumNodes: 3, VmClass: spot, VmType: VirtualMachine{Type: "m5.large", OnDemandPrice: 0.1, AvgPrice: 0.03}},
+			},
+			pct: 50,
+			check: func(nps []NodePool) {
+				assert.Equal(t, 2, nps[0].ReservedNodes)
+				assert.Equal(t, 0.06, nps[0].VmType.ReservedPrice)
+				assert.InDelta(t, 0.32, nps[0].poolPrice(), 1e-9)
+				assert.Equal(t, 0, nps[1].ReservedNodes, "spot nodes should not be reserved")
+			},
+		},
+		{
+			name:      "reserved nodes rounded",
+			nodePools: []NodePool{{SumNodes: 3, VmClass: regular, VmType: VirtualMachine{Type: "m5.large", OnDemandPrice: 0.1}}},
+			pct:       50,
+			check: func(nps []NodePool) {
+				assert.Equal(t, 2, nps[0].ReservedNodes)
+			},
+		},
+		{
+			name:      "no reserved price for the instance type",
+			nodePools: []NodePool{{SumNodes: 4, VmClass: regular, VmType: VirtualMachine{Type: "c5.large", OnDemandPrice: 0.1}}},
+			pct:       100,
+			check: func(nps []NodePool) {
+				assert.Equal(t, 0, nps[0].ReservedNodes)
+				assert.InDelta(t, 0.4, nps[0].poolPrice(), 1e-9)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reserveNodes(test.nodePools, map[string]float64{"m5.large": 0.06}, test.pct)
+			test.check(test.nodePools)
+		})
+	}
+}
+
+func TestEngine_RecommendClusterCommitment(t *testing.T) {
+	req := ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(100)}
+	engine, err := NewEngine(&dummyProductInfoSource{}, WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+	onDemand, err := engine.RecommendCluster("dummy", "dummyRegion1", req)
+	assert.Nil(t, err, "the on-demand recommendation should be computed")
+
+	tests := []struct {
+		name  string
+		pi    *reservedProductInfoSource
+		req   func() ClusterRecommendationReq
+		check func(pi *reservedProductInfoSource, resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name: "committed nodes priced at the reserved price",
+			pi:   &reservedProductInfoSource{},
+			req: func() ClusterRecommendationReq {
+				r := req
+				r.CommitmentPct = 100
+				return r
+			},
+			check: func(pi *reservedProductInfoSource, resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, defaultCommitmentTerm, pi.term)
+				assert.Equal(t, resp.Accuracy.RecRegularNodes, resp.Accuracy.RecReservedNodes)
+				assert.InDelta(t, onDemand.Accuracy.RecTotalPrice*reservedDiscount, resp.Accuracy.RecTotalPrice, 1e-9)
+				assert.Empty(t, resp.Warnings)
+			},
+		},
+		{
+			name: "commitment term passed to the source",
+			pi:   &reservedProductInfoSource{},
+			req: func() ClusterRecommendationReq {
+				r := req
+				r.CommitmentPct = 50
+				r.CommitmentTerm = "3yr-all-upfront"
+				return r
+			},
+			check: func(pi *reservedProductInfoSource, resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "3yr-all-upfront", pi.term)
+				assert.True(t, resp.Accuracy.RecReservedNodes > 0, "some nodes should be reserved")
+				assert.True(t, resp.Accuracy.RecReservedNodes < resp.Accuracy.RecRegularNodes, "some nodes should be on-demand")
+				assert.True(t, resp.Accuracy.RecTotalPrice < onDemand.Accuracy.RecTotalPrice, "the blended price should be lower")
+			},
+		},
+		{
+			name: "reserved prices not fetched without commitment",
+			pi:   &reservedProductInfoSource{},
+			req:  func() ClusterRecommendationReq { return req },
+			check: func(pi *reservedProductInfoSource, resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Empty(t, pi.term, "the reserved prices should not be fetched")
+				assert.Equal(t, 0, resp.Accuracy.RecReservedNodes)
+			},
+		},
+		{
+			name: "error - reserved prices couldn't be fetched",
+			pi:   &reservedProductInfoSource{err: errors.New("could not get reserved prices")},
+			req: func() ClusterRecommendationReq {
+				r := req
+				r.CommitmentPct = 100
+				return r
+			},
+			check: func(pi *reservedProductInfoSource, resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the recommendation should be nil")
+				assert.EqualError(t, err, "could not get reserved prices")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.pi, WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+			resp, err := engine.RecommendCluster("dummy", "dummyRegion1", test.req())
+			test.check(test.pi, resp, err)
+		})
+	}
+
+	t.Run("reserved prices not reported", func(t *testing.T) {
+		r := req
+		r.CommitmentPct = 100
+		resp, err := engine.RecommendCluster("dummy", "dummyRegion1", r)
+		assert.Nil(t, err, "the error should be nil")
+		assert.Equal(t, []string{"reserved prices are not reported on provider [dummy], the regular nodes are priced at the on-demand price"}, resp.Warnings)
+		assert.Equal(t, onDemand.Accuracy.RecTotalPrice, resp.Accuracy.RecTotalPrice)
+		assert.Equal(t, 0, resp.Accuracy.RecReservedNodes)
+	})
+}
+
+func TestEngine_RecommendClusterHeldReservations(t *testing.T) {
+	engine, err := NewEngine(mustFileSource(t), WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	req := ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 1, MaxNodes: 8, OnDemandPct: onDemand(100)}
+	tests := []struct {
+		name     string
+		reserved map[string]int
+		check    func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:     "the request fully covered by the reservations",
+			reserved: map[string]int{"m5.2xlarge": 4},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Empty(t, resp.NodePools, "no new nodes should be recommended")
+				assert.Equal(t, 1, len(resp.ReservedNodePools))
+				assert.Equal(t, map[string]int{"m5.2xlarge": 2}, resp.ReservedUsed, "only the needed reserved nodes should be consumed")
+				assert.Equal(t, 0.0, resp.Accuracy.RecTotalPrice, "the reserved nodes should be free")
+				assert.True(t, resp.Accuracy.RecCpu >= 16, "the requested cpus should be covered")
+				assert.True(t, resp.Accuracy.RecMem >= 64, "the requested memory should be covered")
+				assert.Equal(t, map[string]int{"m5.2xlarge": 4}, resp.Request.Reserved)
+			},
+		},
+		{
+			name:     "new nodes recommended for the resources not covered",
+			reserved: map[string]int{"m5.xlarge": 1, "c5.xlarge": 0},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, map[string]int{"m5.xlarge": 1, "c5.xlarge": 0}, resp.ReservedUsed)
+				assert.NotEmpty(t, resp.NodePools, "new nodes should be recommended")
+				assert.True(t, resp.Accuracy.RecCpu >= 16, "the requested cpus should be covered")
+				assert.Equal(t, resp.Accuracy.RecTotalPrice, poolSetPrice(resp.NodePools), "only the new nodes should be priced")
+			},
+		},
+		{
+			name:     "the reservations of unknown instance types ignored",
+			reserved: map[string]int{"x1.32xlarge": 2},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Empty(t, resp.ReservedNodePools)
+				assert.Equal(t, []string{"the reserved instance type [x1.32xlarge] is not available on provider [ec2] in region [eu-west-1], the reservation is ignored"}, resp.Warnings)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := req
+			r.Reserved = test.reserved
+			test.check(engine.RecommendCluster("ec2", "eu-west-1", r))
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-openapi/runtime"
+	log "github.com/sirupsen/logrus"
+)
+
+// the default retry policy of the product info calls
+var defaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   100 * time.Millisecond,
+	Jitter:      0.2,
+}
+
+// RetryPolicy describes how failed product info calls are retried
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a call is made, 1 disables retries
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, it's doubled for every further retry
+	BaseDelay time.Duration
+	// Jitter is the fraction (0..1) the delays are randomly shortened or lengthened by
+	Jitter float64
+}
+
+// WithRetryPolicy sets how failed product info calls are retried
+func WithRetryPolicy(policy RetryPolicy) EngineOption {
+	return func(e *Engine) {
+		e.retry = policy
+	}
+}
+
+// WithMaxAttempts sets the maximum number of times a product info call is made
+func WithMaxAttempts(attempts int) EngineOption {
+	return func(e *Engine) {
+		e.retry.MaxAttempts = attempts
+	}
+}
+
+// delay returns the time to wait before the given retry (counted from 1)
+func (p RetryPolicy) delay(retry int) time.Duration {
+	d := float64(p.BaseDelay) * float64(int64(1)<<uint(retry-1))
+	return time.Duration(d * (1 + p.Jitter*(2*rand.Float64()-1)))
+}
+
+// withRetry makes the product info call, retrying it with exponential backoff as long as it fails with a transient
+// error; the call fails with ProductInfoUnavailable if it's still failing after the last attempt or if it failed with
+// an error other than an engine error that is not retried
+func (e *Engine) withRetry(ctx context.Context, op string, call func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = call(); err == nil || !retryable(err) {
+			return upstreamError(err)
+		}
+		if attempt >= e.retry.MaxAttempts {
+			break
+		}
+
+		delay := e.retry.delay(attempt)
+		log.WithField("requestId", CorrelationID(ctx)).WithError(err).Warnf("%s failed, retrying in %s (attempt %d of %d)",
+			op, delay, attempt+1, e.retry.MaxAttempts)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return NewError(ProductInfoUnavailable, "%s failed after [%d] attempts: %s", op, e.retry.MaxAttempts, err.Error())
+}
+
+// retryable checks whether the error of a product info call is transient: server errors and timeouts are retried,
+// client errors are not
+func retryable(err error) bool {
+	switch e := err.(type) {
+	case *runtime.APIError:
+		return e.Code >= http.StatusInternalServerError
+	case net.Error:
+		return e.Timeout()
+	}
+	return false
+}
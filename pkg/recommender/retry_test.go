@@ -0,0 +1,125 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/banzaicloud/productinfo/pkg/productinfo-client/models"
+	"github.com/go-openapi/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyProductInfoSource fails the first product details calls with the given error
+type flakyProductInfoSource struct {
+	dummyProductInfoSource
+	failures int32
+	err      error
+	calls    int32
+}
+
+func (piCli *flakyProductInfoSource) GetProductDetails(provider string, region string) ([]*models.ProductDetails, error) {
+	if atomic.AddInt32(&piCli.calls, 1) <= piCli.failures {
+		return nil, piCli.err
+	}
+	return piCli.dummyProductInfoSource.GetProductDetails(provider, region)
+}
+
+// timeoutError is a network error timing out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func Test_retryable(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{name: "server error", err: runtime.NewAPIError("getProducts", nil, http.StatusBadGateway), retryable: true},
+		{name: "timeout", err: timeoutError{}, retryable: true},
+		{name: "client error", err: runtime.NewAPIError("getProducts", nil, http.StatusNotFound), retryable: false},
+		{name: "other error", err: errors.New("invalid response"), retryable: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.retryable, retryable(test.err))
+		})
+	}
+}
+
+func TestRetryPolicy_delay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 4, BaseDelay: 100 * time.Millisecond, Jitter: 0.2}
+	for retry, base := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+		d := p.delay(retry + 1)
+		assert.True(t, d >= base*8/10 && d <= base*12/10, "delay %s of retry %d is out of range", d, retry+1)
+	}
+}
+
+func TestEngine_RecommendClusterRetry(t *testing.T) {
+	req := ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10}
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	tests := []struct {
+		name  string
+		pi    *flakyProductInfoSource
+		check func(resp *ClusterRecommendationResp, err error, calls int32)
+	}{
+		{
+			name: "server errors retried until the call succeeds",
+			pi:   &flakyProductInfoSource{failures: 2, err: runtime.NewAPIError("getProducts", nil, http.StatusServiceUnavailable)},
+			check: func(resp *ClusterRecommendationResp, err error, calls int32) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.NotNil(t, resp, "the recommendation should be returned")
+				assert.Equal(t, int32(3), calls)
+			},
+		},
+		{
+			name: "error - client errors not retried",
+			pi:   &flakyProductInfoSource{failures: 1, err: runtime.NewAPIError("getProducts", nil, http.StatusBadRequest)},
+			check: func(resp *ClusterRecommendationResp, err error, calls int32) {
+				assert.Nil(t, resp, "the recommendation should be nil")
+				assert.Equal(t, ProductInfoUnavailable, ErrorCode(err))
+				assert.Equal(t, int32(1), calls)
+			},
+		},
+		{
+			name: "error - retries exhausted",
+			pi:   &flakyProductInfoSource{failures: 3, err: timeoutError{}},
+			check: func(resp *ClusterRecommendationResp, err error, calls int32) {
+				assert.Nil(t, resp, "the recommendation should be nil")
+				assert.Equal(t, ProductInfoUnavailable, ErrorCode(err))
+				assert.EqualError(t, err, "get product details failed after [3] attempts: i/o timeout")
+				assert.Equal(t, int32(3), calls)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.pi, WithRetryPolicy(policy), WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			resp, err := engine.RecommendClusterCtx(context.Background(), "dummy", "dummyRegion1", req)
+			test.check(resp, err, atomic.LoadInt32(&test.pi.calls))
+		})
+	}
+}
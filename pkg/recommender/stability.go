@@ -0,0 +1,48 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"math"
+	"sort"
+)
+
+// stableSpot returns true if stable spot pools are requested and there are spot pools in the recommendation
+func (req *ClusterRecommendationReq) stableSpot() bool {
+	return req.StableSpot && req.onDemandPct() < 100
+}
+
+// stabilityScore scores the stability of the spot price from its recent variance relative to the price,
+// 1 for a constant price, approaching 0 as the deviation grows
+func stabilityScore(avgPrice float64, variance float64) float64 {
+	if avgPrice <= 0 || variance < 0 {
+		return 0
+	}
+	return 1 / (1 + math.Sqrt(variance)/avgPrice)
+}
+
+// sortByStability sorts the vms by their spot price per attribute weighted by the stability of their spot price,
+// so that a stabler instance type comes before a slightly cheaper one; vms of unknown stability come last
+func sortByStability(vms []VirtualMachine, price func(VirtualMachine) float64) {
+	weightedPrice := func(vm VirtualMachine) float64 {
+		if vm.stability == 0 {
+			return math.Inf(1)
+		}
+		return price(vm) / vm.stability
+	}
+	sort.SliceStable(vms, func(i, j int) bool {
+		return weightedPrice(vms[i]) < weightedPrice(vms[j])
+	})
+}
@@ -0,0 +1,133 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// varianceProductInfoSource reports synthetic spot price variances
+type varianceProductInfoSource struct {
+	dummyProductInfoSource
+	variances map[string]float64
+}
+
+func (piCli *varianceProductInfoSource) GetSpotPriceVariance(provider string, region string) (map[string]float64, error) {
+	return piCli.variances, nil
+}
+
+func Test_stabilityScore(t *testing.T) {
+	tests := []struct {
+		name     string
+		avgPrice float64
+		variance float64
+		check    func(score float64)
+	}{
+		{
+			name:     "constant price",
+			avgPrice: 0.1,
+			variance: 0,
+			check: func(score float64) {
+				assert.Equal(t, float64(1), score)
+			},
+		},
+		{
+			name:     "deviation as large as the price",
+			avgPrice: 0.1,
+			variance: 0.01,
+			check: func(score float64) {
+				assert.InDelta(t, 0.5, score, 1e-9)
+			},
+		},
+		{
+			name:     "no spot price",
+			avgPrice: 0,
+			variance: 0.01,
+			check: func(score float64) {
+				assert.Zero(t, score)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(stabilityScore(test.avgPrice, test.variance))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterStableSpot(t *testing.T) {
+	// the cheapest spot instance type per cpu (type-11) has a volatile price
+	variances := &varianceProductInfoSource{variances: map[string]float64{"type-10": 0.0001, "type-11": 0.01}}
+
+	tests := []struct {
+		name    string
+		pi      ProductInfoSource
+		request ClusterRecommendationReq
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:    "cheapest spot instance type preferred by default",
+			pi:      variances,
+			request: ClusterRecommendationReq{SumCpu: 64, SumMem: 64, MinNodes: 1, MaxNodes: 10},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, "type-11", resp.NodePools[0].VmType.Type)
+				assert.Zero(t, resp.NodePools[0].StabilityScore, "the stability should only be scored if requested")
+			},
+		},
+		{
+			name:    "stabler spot instance type preferred over the cheaper one",
+			pi:      variances,
+			request: ClusterRecommendationReq{SumCpu: 64, SumMem: 64, MinNodes: 1, MaxNodes: 10, StableSpot: true},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Empty(t, resp.Warnings)
+				// the first spot pool is filled up first
+				assert.Equal(t, "type-10", resp.NodePools[0].VmType.Type)
+				assert.True(t, resp.NodePools[0].StabilityScore > resp.NodePools[1].StabilityScore, "the stabler pool should come first")
+				assert.True(t, resp.NodePools[0].SumNodes >= resp.NodePools[1].SumNodes, "the stabler pool should have the most nodes")
+			},
+		},
+		{
+			name:    "spot price variances not reported",
+			pi:      &dummyProductInfoSource{},
+			request: ClusterRecommendationReq{SumCpu: 64, SumMem: 64, MinNodes: 1, MaxNodes: 10, StableSpot: true},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"spot price variances are not reported on provider [dummy], the spot pools are not ranked by stability"}, resp.Warnings)
+				assert.Equal(t, "type-11", resp.NodePools[0].VmType.Type)
+			},
+		},
+		{
+			name:    "no spot pools requested",
+			pi:      &dummyProductInfoSource{},
+			request: ClusterRecommendationReq{SumCpu: 64, SumMem: 64, MinNodes: 1, MaxNodes: 10, OnDemandPct: onDemand(100), StableSpot: true},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Empty(t, resp.Warnings, "the variances are not needed without spot pools")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.pi, WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.RecommendCluster("dummy", "dummyRegion1", test.request))
+		})
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"fmt"
+	"strings"
+)
+
+// summary describes the recommendation in a sentence, eg: 3× m5.large (on-demand) + 5× m5.xlarge (spot) in us-east-1,
+// ~$1.24/hr, covers 28 vCPU / 112 GB; it's derived from the node pools and the accuracy of the response only
+func summary(region string, resp *ClusterRecommendationResp) string {
+	var pools []string
+	for _, np := range resp.ExistingNodePools {
+		if np.SumNodes > 0 {
+			pools = append(pools, fmt.Sprintf("%d× %s (existing %s)", np.SumNodes, np.VmType.Type, vmClassName(np.VmClass)))
+		}
+	}
+	for _, np := range resp.NodePools {
+		if np.SumNodes > 0 {
+			pools = append(pools, fmt.Sprintf("%d× %s (%s)", np.SumNodes, np.VmType.Type, vmClassName(np.VmClass)))
+		}
+	}
+	if len(pools) == 0 {
+		pools = append(pools, "no nodes")
+	}
+
+	covers := fmt.Sprintf("%g vCPU / %g GB", resp.Accuracy.RecCpu, resp.Accuracy.RecMem)
+	if resp.Accuracy.RecGpu > 0 {
+		covers += fmt.Sprintf(" / %g GPU", resp.Accuracy.RecGpu)
+	}
+
+	return fmt.Sprintf("%s in %s, ~$%.2f/hr, covers %s.", strings.Join(pools, " + "), region, resp.Accuracy.RecTotalPrice, covers)
+}
+
+// vmClassName returns the name of the vm class used in the summary
+func vmClassName(vmClass string) string {
+	if vmClass == regular {
+		return "on-demand"
+	}
+	return vmClass
+}
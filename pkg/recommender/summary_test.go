@@ -0,0 +1,101 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_summary(t *testing.T) {
+	tests := []struct {
+		name  string
+		resp  ClusterRecommendationResp
+		check func(s string)
+	}{
+		{
+			name: "on-demand and spot node pools",
+			resp: ClusterRecommendationResp{
+				NodePools: []NodePool{
+					{SumNodes: 3, VmClass: regular, VmType: VirtualMachine{Type: "m5.large"}},
+					{SumNodes: 5, VmClass: spot, VmType: VirtualMachine{Type: "m5.xlarge"}},
+					{SumNodes: 0, VmClass: spot, VmType: VirtualMachine{Type: "c5.xlarge"}},
+				},
+				Accuracy: ClusterRecommendationAccuracy{RecCpu: 26, RecMem: 104, RecTotalPrice: 1.2361},
+			},
+			check: func(s string) {
+				assert.Equal(t, "3× m5.large (on-demand) + 5× m5.xlarge (spot) in us-east-1, ~$1.24/hr, covers 26 vCPU / 104 GB.", s)
+			},
+		},
+		{
+			name: "existing node pools and gpus",
+			resp: ClusterRecommendationResp{
+				ExistingNodePools: []NodePool{{SumNodes: 2, VmClass: regular, VmType: VirtualMachine{Type: "p3.2xlarge"}}},
+				NodePools:         []NodePool{{SumNodes: 1, VmClass: spot, VmType: VirtualMachine{Type: "p3.2xlarge"}}},
+				Accuracy:          ClusterRecommendationAccuracy{RecCpu: 24, RecMem: 183, RecGpu: 3, RecTotalPrice: 7.1},
+			},
+			check: func(s string) {
+				assert.Equal(t, "2× p3.2xlarge (existing on-demand) + 1× p3.2xlarge (spot) in us-east-1, ~$7.10/hr, covers 24 vCPU / 183 GB / 3 GPU.", s)
+			},
+		},
+		{
+			name: "no nodes",
+			resp: ClusterRecommendationResp{},
+			check: func(s string) {
+				assert.Equal(t, "no nodes in us-east-1, ~$0.00/hr, covers 0 vCPU / 0 GB.", s)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(summary("us-east-1", &test.resp))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterSummary(t *testing.T) {
+	tests := []struct {
+		name    string
+		request ClusterRecommendationReq
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:    "summary not requested",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 16, MinNodes: 1, MaxNodes: 10, OnDemandPct: onDemand(100)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Empty(t, resp.Summary)
+			},
+		},
+		{
+			name:    "summary matches the recommendation",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 16, MinNodes: 1, MaxNodes: 10, OnDemandPct: onDemand(100), WithSummary: true},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, summary("dummyRegion1", resp), resp.Summary)
+				assert.Contains(t, resp.Summary, "(on-demand) in dummyRegion1")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(&dummyProductInfoSource{})
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.RecommendCluster("dummy", "dummyRegion1", test.request))
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+// SupplementedProductInfoSource serves the product info of a source, eg: the product info service, supplemented with the
+// data of the instance types the source doesn't report, read from a snapshot file holding only that data
+type SupplementedProductInfoSource struct {
+	ProductInfoSource
+	supplement *FileProductInfoSource
+}
+
+var (
+	_ LocalStorageSource       = (*SupplementedProductInfoSource)(nil)
+	_ PodLimitSource           = (*SupplementedProductInfoSource)(nil)
+	_ StorageTypeSource        = (*SupplementedProductInfoSource)(nil)
+	_ CustomMachinePriceSource = (*SupplementedProductInfoSource)(nil)
+	_ SpotPriceVarianceSource  = (*SupplementedProductInfoSource)(nil)
+	_ ReservedPriceSource      = (*SupplementedProductInfoSource)(nil)
+	_ SpotInterruptionSource   = (*SupplementedProductInfoSource)(nil)
+)
+
+// NewSupplementedProductInfoSource creates a source serving the product info of the source, and the local storage, the
+// pod limits, the storage types, the custom machine prices, the spot price variances, the reserved prices and the spot
+// interruption rates of the instance types from the supplement
+func NewSupplementedProductInfoSource(source ProductInfoSource, supplement *FileProductInfoSource) *SupplementedProductInfoSource {
+	return &SupplementedProductInfoSource{ProductInfoSource: source, supplement: supplement}
+}
+
+// unreported turns the errors of the providers and regions missing from the supplement into nil, the data is not
+// reported there
+func unreported(err error) error {
+	if code := ErrorCode(err); code == ProviderUnsupported || code == RegionNotFound {
+		return nil
+	}
+	return err
+}
+
+// GetLocalStorage retrieves the local storage of the instance types from the supplement
+func (ss *SupplementedProductInfoSource) GetLocalStorage(provider string, region string) (map[string]float64, error) {
+	storage, err := ss.supplement.GetLocalStorage(provider, region)
+	return storage, unreported(err)
+}
+
+// GetPodLimits retrieves the maximum number of pods on the instance types from the supplement
+func (ss *SupplementedProductInfoSource) GetPodLimits(provider string, region string) (map[string]int, error) {
+	limits, err := ss.supplement.GetPodLimits(provider, region)
+	return limits, unreported(err)
+}
+
+// GetStorageTypes retrieves the storage classes of the instance types from the supplement
+func (ss *SupplementedProductInfoSource) GetStorageTypes(provider string, region string) (map[string][]string, error) {
+	types, err := ss.supplement.GetStorageTypes(provider, region)
+	return types, unreported(err)
+}
+
+// GetCustomMachinePrices retrieves the prices of the custom machine types from the supplement
+func (ss *SupplementedProductInfoSource) GetCustomMachinePrices(provider string, region string) (*CustomMachinePrices, error) {
+	prices, err := ss.supplement.GetCustomMachinePrices(provider, region)
+	return prices, unreported(err)
+}
+
+// GetSpotPriceVariance retrieves the variance of the recent spot prices of the instance types from the supplement
+func (ss *SupplementedProductInfoSource) GetSpotPriceVariance(provider string, region string) (map[string]float64, error) {
+	variances, err := ss.supplement.GetSpotPriceVariance(provider, region)
+	return variances, unreported(err)
+}
+
+// GetReservedPrices retrieves the effective hourly reserved prices of the instance types for the commitment term from
+// the supplement
+func (ss *SupplementedProductInfoSource) GetReservedPrices(provider string, region string, term string) (map[string]float64, error) {
+	prices, err := ss.supplement.GetReservedPrices(provider, region, term)
+	return prices, unreported(err)
+}
+
+// GetSpotInterruptionRates retrieves the monthly interruption rates of the spot instances of the instance types from
+// the supplement
+func (ss *SupplementedProductInfoSource) GetSpotInterruptionRates(provider string, region string) (map[string]float64, error) {
+	rates, err := ss.supplement.GetSpotInterruptionRates(provider, region)
+	return rates, unreported(err)
+}
@@ -0,0 +1,230 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupplementedProductInfoSource_RecommendClusterStorage(t *testing.T) {
+	tests := []struct {
+		name   string
+		region string
+		check  func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:   "local storage of the supplement taken into account",
+			region: "eu-west-1",
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Empty(t, resp.Warnings)
+				for _, np := range resp.NodePools {
+					assert.True(t, np.VmType.Storage > 0, "node pools should have local storage")
+				}
+				assert.True(t, resp.Accuracy.RecStorage >= 900, "the requested storage should be covered")
+			},
+		},
+		{
+			name:   "region not supplemented",
+			region: "eu-west-2",
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"local storage is not reported on provider [ec2], the requested storage is not taken into account"}, resp.Warnings)
+			},
+		},
+	}
+
+	// the second region is only known by the supplemented source
+	fs := mustFileSource(t)
+	rs := fs.snapshot.Providers["ec2"].Regions["eu-west-1"]
+	fs.snapshot.Providers["ec2"].Regions["eu-west-2"] = rs
+	supplement, err := NewFileProductInfoSource("testdata/supplement.yaml")
+	assert.Nil(t, err, "the supplement couldn't be loaded")
+	engine, err := NewEngine(NewSupplementedProductInfoSource(fs, supplement), WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(engine.RecommendCluster("ec2", test.region, ClusterRecommendationReq{
+				SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(50), SumStorage: 900,
+			}))
+		})
+	}
+}
+
+func TestSupplementedProductInfoSource_RecommendClusterStableSpot(t *testing.T) {
+	supplement, err := NewFileProductInfoSource("testdata/supplement.yaml")
+	assert.Nil(t, err, "the supplement couldn't be loaded")
+	// the cheapest spot instance type per cpu (c5.xlarge) has a volatile price in the supplement
+	req := ClusterRecommendationReq{SumCpu: 32, SumMem: 32, MinNodes: 2, MaxNodes: 16, StableSpot: true}
+
+	tests := []struct {
+		name   string
+		source ProductInfoSource
+		check  func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:   "spot pools ranked by the variances of the supplement",
+			source: NewSupplementedProductInfoSource(mustFileSource(t), supplement),
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Empty(t, resp.Warnings)
+				assert.True(t, resp.NodePools[0].StabilityScore > 0.9, "the stabler pool should come first")
+				for _, np := range resp.NodePools {
+					if np.VmType.Type == "c5.xlarge" {
+						assert.Zero(t, np.SumNodes, "the volatile instance type shouldn't have nodes")
+					}
+				}
+			},
+		},
+		{
+			name:   "variances not reported without the supplement",
+			source: mustFileSource(t),
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"spot price variances are not reported on provider [ec2], the spot pools are not ranked by stability"}, resp.Warnings)
+				assert.Equal(t, "c5.xlarge", resp.NodePools[0].VmType.Type)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.source, WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+			test.check(engine.RecommendCluster("ec2", "eu-west-1", req))
+		})
+	}
+}
+
+func TestSupplementedProductInfoSource_RecommendClusterReserved(t *testing.T) {
+	supplement, err := NewFileProductInfoSource("testdata/supplement.yaml")
+	assert.Nil(t, err, "the supplement couldn't be loaded")
+
+	tests := []struct {
+		name   string
+		source ProductInfoSource
+		term   string
+		check  func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:   "regular nodes priced at the reserved prices of the supplement",
+			source: NewSupplementedProductInfoSource(mustFileSource(t), supplement),
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Empty(t, resp.Warnings)
+				assert.True(t, resp.Accuracy.RecReservedNodes > 0, "nodes should be reserved")
+				reserved, _ := supplement.GetReservedPrices("ec2", "eu-west-1", "1yr-no-upfront")
+				for _, np := range resp.NodePools {
+					if np.VmClass == regular && np.SumNodes > 0 {
+						assert.Equal(t, reserved[np.VmType.Type], np.VmType.ReservedPrice, "the reserved price of %s", np.VmType.Type)
+					}
+				}
+			},
+		},
+		{
+			name:   "reserved prices of the requested term",
+			source: NewSupplementedProductInfoSource(mustFileSource(t), supplement),
+			term:   "3yr-all-upfront",
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Empty(t, resp.Warnings)
+				assert.True(t, resp.Accuracy.RecReservedNodes > 0, "nodes should be reserved")
+				reserved, _ := supplement.GetReservedPrices("ec2", "eu-west-1", "3yr-all-upfront")
+				for _, np := range resp.NodePools {
+					if np.VmClass == regular && np.SumNodes > 0 {
+						assert.Equal(t, reserved[np.VmType.Type], np.VmType.ReservedPrice, "the reserved price of %s", np.VmType.Type)
+					}
+				}
+			},
+		},
+		{
+			name:   "term not in the supplement",
+			source: NewSupplementedProductInfoSource(mustFileSource(t), supplement),
+			term:   "3yr-no-upfront",
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"reserved prices are not reported on provider [ec2], the regular nodes are priced at the on-demand price"}, resp.Warnings)
+				assert.Zero(t, resp.Accuracy.RecReservedNodes)
+			},
+		},
+		{
+			name:   "reserved prices not reported without the supplement",
+			source: mustFileSource(t),
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"reserved prices are not reported on provider [ec2], the regular nodes are priced at the on-demand price"}, resp.Warnings)
+				assert.Zero(t, resp.Accuracy.RecReservedNodes)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.source, WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+			req := ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(100), CommitmentPct: 50, CommitmentTerm: test.term}
+			test.check(engine.RecommendCluster("ec2", "eu-west-1", req))
+		})
+	}
+}
+
+func TestSupplementedProductInfoSource_RecommendClusterInterruptionRate(t *testing.T) {
+	supplement, err := NewFileProductInfoSource("testdata/supplement.yaml")
+	assert.Nil(t, err, "the supplement couldn't be loaded")
+	// the cheapest spot instance type per cpu (c5.xlarge) is interrupted frequently in the supplement
+	req := ClusterRecommendationReq{SumCpu: 32, SumMem: 32, MinNodes: 2, MaxNodes: 16, MaxInterruptionRate: 10}
+
+	tests := []struct {
+		name   string
+		source ProductInfoSource
+		check  func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:   "spot instance types filtered by the interruption rates of the supplement",
+			source: NewSupplementedProductInfoSource(mustFileSource(t), supplement),
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Empty(t, resp.Warnings)
+				var spotPools int
+				for _, np := range resp.NodePools {
+					if np.VmClass == spot && np.SumNodes > 0 {
+						spotPools++
+						assert.NotEqual(t, "c5.xlarge", np.VmType.Type, "the spot pool is interrupted too often")
+						assert.NotEqual(t, "m5.2xlarge", np.VmType.Type, "the spot pool is interrupted too often")
+						assert.Contains(t, []string{"<5%", "5-10%"}, np.VmType.InterruptionTier)
+					}
+				}
+				assert.True(t, spotPools > 0, "the rarely interrupted spot instance types should be recommended")
+			},
+		},
+		{
+			name:   "interruption rates not reported without the supplement",
+			source: mustFileSource(t),
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"spot interruption rates are not reported on provider [ec2], the spot instance types are not filtered by their interruption rate"}, resp.Warnings)
+				assert.Equal(t, "c5.xlarge", resp.NodePools[0].VmType.Type)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.source, WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+			test.check(engine.RecommendCluster("ec2", "eu-west-1", req))
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import "strings"
+
+// the provider discounting the regular instances by their monthly runtime
+const sustainedUseProvider = "gce"
+
+var (
+	// the machine families eligible for sustained use discounts, custom machine types are n1 ones
+	sustainedUseFamilies = []string{"n1", "n2", "n2d", "c2", "m1", "m2", "f1", "g1", "custom"}
+	// the rates of the consecutive quarters of the month, relative to the on-demand price
+	sustainedUseRates = []float64{1, 0.8, 0.6, 0.4}
+)
+
+// monthlyHours returns the expected monthly runtime of the nodes, the whole month if not set
+func (req *ClusterRecommendationReq) monthlyHours() float64 {
+	if req.MonthlyHours == 0 {
+		return hoursPerMonth
+	}
+	return req.MonthlyHours
+}
+
+// sustainedUseEligible returns true if the regular instances of the type are discounted by their monthly runtime
+func sustainedUseEligible(vmType string) bool {
+	family := strings.SplitN(vmType, "-", 2)[0]
+	return contains(sustainedUseFamilies, family)
+}
+
+// sustainedUseFactor returns the ratio of the average hourly price of an instance running hours a month to its
+// on-demand price: every quarter of the month the instance runs is billed at a lower rate than the previous one,
+// an instance running the whole month is discounted by 30%
+func sustainedUseFactor(hours float64) float64 {
+	if hours <= 0 {
+		return 1
+	}
+	usage, quarter := hours/hoursPerMonth, 0.25
+	var billed float64
+	for i, rate := range sustainedUseRates {
+		if used := usage - float64(i)*quarter; used > 0 {
+			if used > quarter {
+				used = quarter
+			}
+			billed += used * rate
+		}
+	}
+	return billed / usage
+}
+
+// sustainUse prices the regular nodes of the pools of the eligible instance types with the sustained use discount of
+// the monthly runtime, the reserved nodes keep their reserved price
+func sustainUse(nodePools []NodePool, hours float64) {
+	factor := sustainedUseFactor(hours)
+	for i := range nodePools {
+		np := &nodePools[i]
+		if np.VmClass != regular || factor >= 1 || !sustainedUseEligible(np.VmType.Type) {
+			continue
+		}
+		np.VmType.SustainedUsePrice = np.VmType.OnDemandPrice * factor
+		np.PoolPrice = np.poolPrice()
+	}
+}
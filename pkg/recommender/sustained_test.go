@@ -0,0 +1,134 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_sustainedUseFactor(t *testing.T) {
+	tests := []struct {
+		name   string
+		hours  float64
+		factor float64
+	}{
+		{name: "the whole month", hours: 730, factor: 0.7},
+		{name: "three quarters of the month", hours: 547.5, factor: 0.8},
+		{name: "half of the month", hours: 365, factor: 0.9},
+		{name: "a quarter of the month", hours: 182.5, factor: 1},
+		{name: "no runtime", hours: 0, factor: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.InDelta(t, test.factor, sustainedUseFactor(test.hours), 1e-9)
+		})
+	}
+}
+
+func TestEngine_RecommendClusterSustainedUse(t *testing.T) {
+	fs, err := NewFileProductInfoSource("testdata/custom.yaml")
+	assert.Nil(t, err, "the snapshot couldn't be loaded")
+	engine, err := NewEngine(fs, WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	req := ClusterRecommendationReq{SumCpu: 8, SumMem: 30, MinNodes: 1, MaxNodes: 4, OnDemandPct: onDemand(100)}
+	// the nodes running a quarter of the month are not discounted
+	undiscountedReq := req
+	undiscountedReq.MonthlyHours = 150
+	undiscounted, err := engine.RecommendCluster("gce", "europe-west1", undiscountedReq)
+	assert.Nil(t, err, "the error should be nil")
+
+	tests := []struct {
+		name    string
+		request func() ClusterRecommendationReq
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:    "regular nodes running the whole month discounted by default",
+			request: func() ClusterRecommendationReq { return req },
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.InDelta(t, undiscounted.Accuracy.RecTotalPrice*0.7, resp.Accuracy.RecTotalPrice, 1e-9)
+				assert.InDelta(t, resp.Accuracy.RecTotalPrice*hoursPerMonth, resp.Accuracy.RecMonthlyPrice, 1e-9)
+				for _, np := range resp.NodePools {
+					if np.VmClass == regular {
+						assert.InDelta(t, np.VmType.OnDemandPrice*0.7, np.VmType.SustainedUsePrice, 1e-9)
+					}
+				}
+			},
+		},
+		{
+			name: "the discount of the expected runtime",
+			request: func() ClusterRecommendationReq {
+				r := req
+				r.MonthlyHours = 365
+				return r
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.InDelta(t, undiscounted.Accuracy.RecTotalPrice*0.9, resp.Accuracy.RecTotalPrice, 1e-9)
+				assert.InDelta(t, resp.Accuracy.RecTotalPrice*365, resp.Accuracy.RecMonthlyPrice, 1e-9)
+			},
+		},
+		{
+			name: "the discount doesn't change the selection",
+			request: func() ClusterRecommendationReq {
+				r := req
+				r.OptimizeSustainedUse = true
+				return r
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, len(undiscounted.NodePools), len(resp.NodePools))
+				for i, np := range resp.NodePools {
+					assert.Equal(t, undiscounted.NodePools[i].VmType.Type, np.VmType.Type)
+					assert.Equal(t, undiscounted.NodePools[i].SumNodes, np.SumNodes)
+				}
+			},
+		},
+		{
+			name: "preemptible nodes not discounted",
+			request: func() ClusterRecommendationReq {
+				r := req
+				r.OnDemandPct = onDemand(0)
+				return r
+			},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				for _, np := range resp.NodePools {
+					assert.Equal(t, 0.0, np.VmType.SustainedUsePrice)
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(engine.RecommendCluster("gce", "europe-west1", test.request()))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterSustainedUseOtherProviders(t *testing.T) {
+	engine, err := NewEngine(mustFileSource(t), WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	resp, err := engine.RecommendCluster("ec2", "eu-west-1", ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 1, MaxNodes: 8, OnDemandPct: onDemand(100)})
+	assert.Nil(t, err, "the error should be nil")
+	for _, np := range resp.NodePools {
+		assert.Equal(t, 0.0, np.VmType.SustainedUsePrice, "sustained use discounts are only modeled on gce")
+	}
+}
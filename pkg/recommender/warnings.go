@@ -0,0 +1,86 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// StorageUnreported signals that the requested local storage is not taken into account
+	StorageUnreported = "storage_unreported"
+	// PodLimitsUnreported signals that the requested pods are not taken into account
+	PodLimitsUnreported = "pod_limits_unreported"
+	// StorageTypesUnreported signals that the requested storage type is not taken into account
+	StorageTypesUnreported = "storage_types_unreported"
+	// SpotVarianceUnreported signals that the spot pools are not ranked by the stability of their prices
+	SpotVarianceUnreported = "spot_variance_unreported"
+	// SpotInterruptionsUnreported signals that the spot candidates are not filtered by their interruption frequency
+	SpotInterruptionsUnreported = "spot_interruptions_unreported"
+	// ReservedPricesUnreported signals that the regular nodes are priced at the on-demand price despite the commitment
+	ReservedPricesUnreported = "reserved_prices_unreported"
+	// CustomTypesUnavailable signals that only predefined instance types are recommended
+	CustomTypesUnavailable = "custom_types_unavailable"
+	// GenerationUnknown signals that the cheapest layout is recommended instead of the newest generation
+	GenerationUnknown = "generation_unknown"
+	// PerformanceUnknown signals that the candidates are ranked by cost instead of the requested objective
+	PerformanceUnknown = "performance_unknown"
+	// SpotUnavailable signals that the nodes are recommended on-demand as spot instances are not offered on the provider
+	SpotUnavailable = "spot_unavailable"
+	// SpotOverMaxPrice signals that the nodes are recommended on-demand as no spot price is below the maximum spot price
+	SpotOverMaxPrice = "spot_over_max_price"
+	// ReservationIgnored signals that a held reservation is not used as its instance type is not offered in the region
+	ReservationIgnored = "reservation_ignored"
+	// PreferredFamiliesUnavailable signals that other families are recommended as the preferred ones can't satisfy the request
+	PreferredFamiliesUnavailable = "preferred_families_unavailable"
+	// PreferredFamiliesCostlier signals that other families are recommended as the preferred ones cost more than the bonus
+	PreferredFamiliesCostlier = "preferred_families_costlier"
+)
+
+// Warning tells about a part of the request the recommendation was degraded on instead of being rejected, the code
+// identifies the kind of the degradation so that callers can react to it without parsing the message
+type Warning struct {
+	// The code identifying the kind of the warning
+	Code string `json:"code"`
+	// The human readable message of the warning
+	Message string `json:"message"`
+}
+
+// newWarning creates and logs a new warning with the given code and formatted message
+func newWarning(code string, format string, args ...interface{}) Warning {
+	w := Warning{Code: code, Message: fmt.Sprintf(format, args...)}
+	log.Warn(w.Message)
+	return w
+}
+
+// warningMessages returns the messages of the warnings, nil if there are no warnings
+func warningMessages(warnings []Warning) []string {
+	if len(warnings) == 0 {
+		return nil
+	}
+	messages := make([]string, len(warnings))
+	for i, w := range warnings {
+		messages[i] = w.Message
+	}
+	return messages
+}
+
+// setWarnings sets the warnings of the recommendation both as messages and structured
+func (resp *ClusterRecommendationResp) setWarnings(warnings []Warning) {
+	resp.Warnings = warningMessages(warnings)
+	resp.WarningDetails = warnings
+}
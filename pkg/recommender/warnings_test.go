@@ -0,0 +1,104 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_RecommendClusterWarnings(t *testing.T) {
+	tests := []struct {
+		name     string
+		pi       ProductInfoSource
+		provider string
+		region   string
+		request  ClusterRecommendationReq
+		codes    []string
+	}{
+		{
+			name:     "no warnings if the request is served as requested",
+			pi:       &dummyProductInfoSource{},
+			provider: "dummy",
+			region:   "dummyRegion1",
+			request:  ClusterRecommendationReq{SumCpu: 32, SumMem: 64, MinNodes: 1, MaxNodes: 10, OnDemandPct: onDemand(50)},
+		},
+		{
+			name:     "local storage not reported",
+			pi:       &dummyProductInfoSource{NetworkVms},
+			provider: "dummy",
+			region:   "dummyRegion1",
+			request:  ClusterRecommendationReq{SumCpu: 32, SumMem: 64, MinNodes: 1, MaxNodes: 10, SumStorage: 1200},
+			codes:    []string{StorageUnreported},
+		},
+		{
+			name:     "reserved prices not reported",
+			pi:       &dummyProductInfoSource{},
+			provider: "dummy",
+			region:   "dummyRegion1",
+			request:  ClusterRecommendationReq{SumCpu: 32, SumMem: 64, MinNodes: 1, MaxNodes: 10, OnDemandPct: onDemand(100), CommitmentPct: 50},
+			codes:    []string{ReservedPricesUnreported},
+		},
+		{
+			name:     "the generation and the performance of the instance types not known",
+			pi:       &dummyProductInfoSource{},
+			provider: "dummy",
+			region:   "dummyRegion1",
+			request:  ClusterRecommendationReq{SumCpu: 32, SumMem: 64, MinNodes: 1, MaxNodes: 10, PreferNewGen: true, Objective: objectivePerformance},
+			codes:    []string{GenerationUnknown, PerformanceUnknown},
+		},
+		{
+			name:     "no spot price below the maximum spot price",
+			pi:       mustFileSource(t),
+			provider: "ec2",
+			region:   "eu-west-1",
+			request:  ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(25), MaxSpotPrice: 0.0001},
+			codes:    []string{SpotOverMaxPrice},
+		},
+		{
+			name:     "the reservation of an unknown instance type",
+			pi:       mustFileSource(t),
+			provider: "ec2",
+			region:   "eu-west-1",
+			request:  ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(100), Reserved: map[string]int{"x1.32xlarge": 1}},
+			codes:    []string{ReservationIgnored},
+		},
+		{
+			name:     "spot instances not available",
+			pi:       &dummyProductInfoSource{},
+			provider: "oracle",
+			region:   "dummyRegion1",
+			request:  ClusterRecommendationReq{SumCpu: 32, SumMem: 64, MinNodes: 1, MaxNodes: 10, OnDemandPct: onDemand(50)},
+			codes:    []string{SpotUnavailable},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(test.pi, WithCacheTTL(0))
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			resp, err := engine.RecommendCluster(test.provider, test.region, test.request)
+			assert.Nil(t, err, "the error should be nil")
+			var codes []string
+			for i, w := range resp.WarningDetails {
+				codes = append(codes, w.Code)
+				assert.Equal(t, resp.Warnings[i], w.Message, "the messages should be in the order of the codes")
+			}
+			assert.Equal(t, test.codes, codes)
+			assert.Equal(t, len(resp.WarningDetails), len(resp.Warnings))
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+// WeightedCapacity is the recommended cluster as a spot fleet style target capacity specification,
+// the capacity of the instance types is expressed in weight units instead of node counts
+type WeightedCapacity struct {
+	// Total capacity of the recommended node pools in weight units
+	TargetCapacity float64 `json:"targetCapacity"`
+	// Capacity of the recommended on-demand node pools in weight units
+	OnDemandTargetCapacity float64 `json:"onDemandTargetCapacity"`
+	// The instance types of the recommended node pools with their weights
+	Specifications []WeightedSpecification `json:"specifications"`
+}
+
+// WeightedSpecification is an instance type with the capacity an instance of it counts for
+type WeightedSpecification struct {
+	// The instance type
+	Type string `json:"type"`
+	// Whether the instances are regular or spot/preemptible ones
+	VmClass string `json:"vmClass"`
+	// The capacity of an instance, relative to the instance type with the fewest cpus
+	Weight float64 `json:"weight"`
+}
+
+// weightedCapacity computes the weighted capacity of the node pools from the relative cpus of the instance types,
+// the instance type with the fewest cpus has a weight of 1; empty node pools are left out
+func weightedCapacity(nodePools []NodePool) *WeightedCapacity {
+	var minCpus float64
+	for _, np := range nodePools {
+		if np.SumNodes > 0 && np.VmType.Cpus > 0 && (minCpus == 0 || np.VmType.Cpus < minCpus) {
+			minCpus = np.VmType.Cpus
+		}
+	}
+
+	wc := &WeightedCapacity{Specifications: make([]WeightedSpecification, 0)}
+	if minCpus == 0 {
+		return wc
+	}
+	for _, np := range nodePools {
+		if np.SumNodes == 0 {
+			continue
+		}
+		weight := np.VmType.Cpus / minCpus
+		wc.Specifications = append(wc.Specifications, WeightedSpecification{
+			Type:    np.VmType.Type,
+			VmClass: np.VmClass,
+			Weight:  weight,
+		})
+		wc.TargetCapacity += weight * float64(np.SumNodes)
+		if np.VmClass == regular {
+			wc.OnDemandTargetCapacity += weight * float64(np.SumNodes)
+		}
+	}
+	return wc
+}
@@ -0,0 +1,117 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_weightedCapacity(t *testing.T) {
+	tests := []struct {
+		name      string
+		nodePools []NodePool
+		check     func(wc *WeightedCapacity)
+	}{
+		{
+			name: "weights relative to the fewest cpus",
+			nodePools: []NodePool{
+				{SumNodes: 3, VmClass: regular, VmType: VirtualMachine{Type: "m5.large", Cpus: 2}},
+				{SumNodes: 5, VmClass: spot, VmType: VirtualMachine{Type: "m5.2xlarge", Cpus: 8}},
+				{SumNodes: 2, VmClass: spot, VmType: VirtualMachine{Type: "c5.xlarge", Cpus: 4}},
+			},
+			check: func(wc *WeightedCapacity) {
+				assert.Equal(t, []WeightedSpecification{
+					{Type: "m5.large", VmClass: regular, Weight: 1},
+					{Type: "m5.2xlarge", VmClass: spot, Weight: 4},
+					{Type: "c5.xlarge", VmClass: spot, Weight: 2},
+				}, wc.Specifications)
+				assert.Equal(t, float64(27), wc.TargetCapacity)
+				assert.Equal(t, float64(3), wc.OnDemandTargetCapacity)
+			},
+		},
+		{
+			name: "empty node pools left out",
+			nodePools: []NodePool{
+				{SumNodes: 0, VmClass: spot, VmType: VirtualMachine{Type: "m5.large", Cpus: 2}},
+				{SumNodes: 2, VmClass: spot, VmType: VirtualMachine{Type: "m5.xlarge", Cpus: 4}},
+			},
+			check: func(wc *WeightedCapacity) {
+				assert.Equal(t, []WeightedSpecification{{Type: "m5.xlarge", VmClass: spot, Weight: 1}}, wc.Specifications)
+				assert.Equal(t, float64(2), wc.TargetCapacity)
+				assert.Equal(t, float64(0), wc.OnDemandTargetCapacity)
+			},
+		},
+		{
+			name: "no nodes",
+			check: func(wc *WeightedCapacity) {
+				assert.Empty(t, wc.Specifications)
+				assert.Equal(t, float64(0), wc.TargetCapacity)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(weightedCapacity(test.nodePools))
+		})
+	}
+}
+
+func TestEngine_RecommendClusterWeighted(t *testing.T) {
+	tests := []struct {
+		name    string
+		request ClusterRecommendationReq
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:    "weighted capacity not requested",
+			request: ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(50)},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Nil(t, resp.WeightedCapacity)
+			},
+		},
+		{
+			name:    "weighted capacity matches the node pools",
+			request: ClusterRecommendationReq{SumCpu: 100, SumMem: 100, MinNodes: 5, MaxNodes: 10, OnDemandPct: onDemand(50), Weighted: true},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.NotNil(t, resp.WeightedCapacity, "the weighted capacity should be set")
+
+				var minCpus, nonEmpty float64
+				for _, np := range resp.NodePools {
+					if np.SumNodes > 0 {
+						nonEmpty++
+						if minCpus == 0 || np.VmType.Cpus < minCpus {
+							minCpus = np.VmType.Cpus
+						}
+					}
+				}
+				assert.Equal(t, int(nonEmpty), len(resp.WeightedCapacity.Specifications))
+				// the weight units are the cpus of the smallest instance type
+				assert.Equal(t, resp.Accuracy.RecCpu, resp.WeightedCapacity.TargetCapacity*minCpus)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := NewEngine(&dummyProductInfoSource{})
+			assert.Nil(t, err, "the engine couldn't be created")
+
+			test.check(engine.RecommendCluster("dummy", "dummyRegion1", test.request))
+		})
+	}
+}
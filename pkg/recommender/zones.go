@@ -0,0 +1,64 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+// ZoneNodes holds the number of nodes of a node pool placed in an availability zone
+type ZoneNodes struct {
+	// The availability zone
+	Zone string `json:"zone"`
+	// Number of the nodes of the node pool in the zone
+	Nodes int `json:"nodes"`
+}
+
+// balanceZones spreads the nodes of each node pool evenly across the zones, the pools get at most one node more in a
+// zone than in the others; the remainders are placed round-robin across the pools, starting in the zone after the last
+// remainder of the previous pool, so that the whole cluster is balanced too
+func balanceZones(nodePools []NodePool, zones []string) {
+	if len(zones) < 2 {
+		return
+	}
+
+	var next int
+	for i := range nodePools {
+		base, rem := nodePools[i].SumNodes/len(zones), nodePools[i].SumNodes%len(zones)
+		zoneNodes := make([]ZoneNodes, len(zones))
+		for j, zone := range zones {
+			zoneNodes[j] = ZoneNodes{Zone: zone, Nodes: base}
+		}
+		for ; rem > 0; rem-- {
+			zoneNodes[next].Nodes++
+			next = (next + 1) % len(zones)
+		}
+		nodePools[i].ZoneNodes = zoneNodes
+	}
+}
+
+// excludeZones removes the excluded zones of the request from the zones of the product info, the requested zones or
+// the zones of the region if none are requested; the remaining zones are the ones the cluster expands to
+func excludeZones(region string, pi *productInfo, excluded []string) ([]string, error) {
+	if pi.zonesErr != nil {
+		return nil, pi.zonesErr
+	}
+	var zones []string
+	for _, zone := range pi.zones {
+		if !contains(excluded, zone) {
+			zones = append(zones, zone)
+		}
+	}
+	if len(zones) == 0 {
+		return nil, NewError(NoViableInstances, "no zones left in region [%s] after excluding the zones %v", region, excluded)
+	}
+	return zones, nil
+}
@@ -0,0 +1,148 @@
+// Copyright © 2018 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommender
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_balanceZones(t *testing.T) {
+	zones := []string{"eu-west-1a", "eu-west-1b", "eu-west-1c"}
+
+	tests := []struct {
+		name      string
+		nodePools []NodePool
+		zones     []string
+		check     func(nodePools []NodePool)
+	}{
+		{
+			name:      "remainders placed round-robin across the pools",
+			nodePools: []NodePool{{SumNodes: 4}, {SumNodes: 5}, {SumNodes: 2}},
+			zones:     zones,
+			check: func(nodePools []NodePool) {
+				assert.Equal(t, []ZoneNodes{{Zone: "eu-west-1a", Nodes: 2}, {Zone: "eu-west-1b", Nodes: 1}, {Zone: "eu-west-1c", Nodes: 1}}, nodePools[0].ZoneNodes)
+				assert.Equal(t, []ZoneNodes{{Zone: "eu-west-1a", Nodes: 1}, {Zone: "eu-west-1b", Nodes: 2}, {Zone: "eu-west-1c", Nodes: 2}}, nodePools[1].ZoneNodes)
+				assert.Equal(t, []ZoneNodes{{Zone: "eu-west-1a", Nodes: 1}, {Zone: "eu-west-1b", Nodes: 1}, {Zone: "eu-west-1c", Nodes: 0}}, nodePools[2].ZoneNodes)
+			},
+		},
+		{
+			name:      "evenly divided pools",
+			nodePools: []NodePool{{SumNodes: 6}},
+			zones:     zones,
+			check: func(nodePools []NodePool) {
+				assert.Equal(t, []ZoneNodes{{Zone: "eu-west-1a", Nodes: 2}, {Zone: "eu-west-1b", Nodes: 2}, {Zone: "eu-west-1c", Nodes: 2}}, nodePools[0].ZoneNodes)
+			},
+		},
+		{
+			name:      "single zone",
+			nodePools: []NodePool{{SumNodes: 5}},
+			zones:     zones[:1],
+			check: func(nodePools []NodePool) {
+				assert.Nil(t, nodePools[0].ZoneNodes, "a single zone shouldn't be broken down")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			balanceZones(test.nodePools, test.zones)
+			test.check(test.nodePools)
+		})
+	}
+}
+
+func TestEngine_RecommendClusterZoneNodes(t *testing.T) {
+	engine, err := NewEngine(mustFileSource(t), WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	zones := []string{"eu-west-1a", "eu-west-1b", "eu-west-1c"}
+	resp, err := engine.RecommendCluster("ec2", "eu-west-1",
+		ClusterRecommendationReq{SumCpu: 20, SumMem: 80, MinNodes: 1, MaxNodes: 10, OnDemandPct: onDemand(50), Zones: zones})
+	assert.Nil(t, err, "the error should be nil")
+
+	perZone := make(map[string]int)
+	for _, np := range resp.NodePools {
+		var sum int
+		for i, zn := range np.ZoneNodes {
+			assert.Equal(t, zones[i], zn.Zone)
+			sum += zn.Nodes
+			perZone[zn.Zone] += zn.Nodes
+		}
+		assert.Equal(t, np.SumNodes, sum, "all the nodes of the pool should be placed")
+	}
+	min, max := resp.Accuracy.RecNodes, 0
+	for _, nodes := range perZone {
+		if nodes < min {
+			min = nodes
+		}
+		if nodes > max {
+			max = nodes
+		}
+	}
+	assert.True(t, max-min <= 1, "the cluster should be balanced across the zones")
+}
+
+func TestEngine_RecommendClusterExcludeZones(t *testing.T) {
+	engine, err := NewEngine(mustFileSource(t), WithCacheTTL(0))
+	assert.Nil(t, err, "the engine couldn't be created")
+
+	tests := []struct {
+		name    string
+		request ClusterRecommendationReq
+		check   func(resp *ClusterRecommendationResp, err error)
+	}{
+		{
+			name:    "excluded zones removed from the zones of the region",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(50), ExcludeZones: []string{"eu-west-1b"}},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"eu-west-1a", "eu-west-1c"}, resp.Zones)
+				for _, np := range resp.NodePools {
+					for _, zn := range np.ZoneNodes {
+						assert.NotEqual(t, "eu-west-1b", zn.Zone, "no nodes should be placed in the excluded zone")
+					}
+				}
+			},
+		},
+		{
+			name: "excluded zones removed from the requested zones",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(50),
+				Zones: []string{"eu-west-1a", "eu-west-1b"}, ExcludeZones: []string{"eu-west-1b", "eu-west-1c"}},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, err, "the error should be nil")
+				assert.Equal(t, []string{"eu-west-1a"}, resp.Zones)
+				for _, np := range resp.NodePools {
+					assert.Empty(t, np.ZoneNodes, "the nodes of a single zone shouldn't be spread")
+				}
+			},
+		},
+		{
+			name: "error - all the requested zones excluded",
+			request: ClusterRecommendationReq{SumCpu: 16, SumMem: 64, MinNodes: 2, MaxNodes: 8, OnDemandPct: onDemand(50),
+				Zones: []string{"eu-west-1a", "eu-west-1b"}, ExcludeZones: []string{"eu-west-1a", "eu-west-1b"}},
+			check: func(resp *ClusterRecommendationResp, err error) {
+				assert.Nil(t, resp, "the response should be nil")
+				assert.Equal(t, NoViableInstances, ErrorCode(err))
+				assert.EqualError(t, err, "no zones left in region [eu-west-1] after excluding the zones [eu-west-1a eu-west-1b]")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check(engine.RecommendCluster("ec2", "eu-west-1", test.request))
+		})
+	}
+}